@@ -65,6 +65,9 @@ func FindProtocol(registry *protocol.Registry) *Protocol {
 func (p *Protocol) Handle(ctx context.Context, act action.Action, sm protocol.StateManager) (*action.Receipt, error) {
 	switch act := act.(type) {
 	case *action.Transfer:
+		if sm == nil {
+			return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to handle transfer")
+		}
 		return p.handleTransfer(ctx, act, sm)
 	}
 	return nil, nil
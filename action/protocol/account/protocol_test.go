@@ -12,9 +12,11 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
 	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-core/action"
 	"github.com/iotexproject/iotex-core/action/protocol"
 	accountutil "github.com/iotexproject/iotex-core/action/protocol/account/util"
 	"github.com/iotexproject/iotex-core/action/protocol/rewarding"
@@ -126,3 +128,24 @@ func TestProtocol_Initialize(t *testing.T) {
 	require.NoError(err)
 	require.Equal(big.NewInt(100), acc0.Balance)
 }
+
+func TestProtocol_HandleNilStateManager(t *testing.T) {
+	require := require.New(t)
+
+	p := NewProtocol(rewarding.DepositGas)
+	ctx := context.Background()
+
+	// non-matching action type returns before touching the state
+	exec, err := action.NewExecution(identityset.Address(28).String(), 1, big.NewInt(0), 100000, big.NewInt(0), nil)
+	require.NoError(err)
+	receipt, err := p.Handle(ctx, exec, nil)
+	require.NoError(err)
+	require.Nil(receipt)
+
+	// matching action type without state manager
+	tsf, err := action.NewTransfer(1, big.NewInt(1), identityset.Address(28).String(), nil, 100000, big.NewInt(0))
+	require.NoError(err)
+	receipt, err = p.Handle(ctx, tsf, nil)
+	require.Equal(protocol.ErrNilStateManager, errors.Cause(err))
+	require.Nil(receipt)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"github.com/iotexproject/iotex-core/state"
+)
+
+type (
+	cachedState struct {
+		height uint64
+		data   []byte
+	}
+
+	// cachedStateReader memoizes the states read from the underlying state reader, keyed by namespace and key.
+	// Reads at a given height, and states which cannot be serialized and deserialized, are not cached
+	cachedStateReader struct {
+		StateReader
+		cache map[string]cachedState
+	}
+
+	// cachedStateManager is a cachedStateReader over a state manager, which drops the cached state of a key written
+	// or deleted through it, and drops all the cached states on revert
+	cachedStateManager struct {
+		*cachedStateReader
+		sm StateManager
+	}
+)
+
+// NewCachedStateReader returns a state reader which caches the states read through it. It is meant to be used within
+// the execution of one action and discarded afterward
+func NewCachedStateReader(sr StateReader) StateReader {
+	return newCachedStateReader(sr)
+}
+
+// NewCachedStateManager returns a state manager which caches the states read through it, writes are not cached but
+// invalidate the cached state of the key. It is meant to be used within the execution of one action and discarded
+// afterward
+func NewCachedStateManager(sm StateManager) StateManager {
+	return &cachedStateManager{
+		cachedStateReader: newCachedStateReader(sm),
+		sm:                sm,
+	}
+}
+
+func newCachedStateReader(sr StateReader) *cachedStateReader {
+	return &cachedStateReader{
+		StateReader: sr,
+		cache:       map[string]cachedState{},
+	}
+}
+
+func cacheKey(cfg *StateConfig) string {
+	return cfg.Namespace + "\x00" + string(cfg.Key)
+}
+
+func (sr *cachedStateReader) State(s interface{}, opts ...StateOption) (uint64, error) {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return 0, err
+	}
+	ser, okSer := s.(state.Serializer)
+	deser, okDeser := s.(state.Deserializer)
+	if cfg.AtHeight || !okSer || !okDeser {
+		return sr.StateReader.State(s, opts...)
+	}
+	key := cacheKey(cfg)
+	if cs, ok := sr.cache[key]; ok {
+		return cs.height, deser.Deserialize(cs.data)
+	}
+	height, err := sr.StateReader.State(s, opts...)
+	if err != nil {
+		return height, err
+	}
+	data, err := ser.Serialize()
+	if err != nil {
+		// the state is read successfully, it is just not cached
+		return height, nil
+	}
+	sr.cache[key] = cachedState{height: height, data: data}
+	return height, nil
+}
+
+func (sr *cachedStateReader) Exists(opts ...StateOption) (bool, error) {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := sr.cache[cacheKey(cfg)]; ok && !cfg.AtHeight {
+		return true, nil
+	}
+	return sr.StateReader.Exists(opts...)
+}
+
+func (sm *cachedStateManager) Snapshot() int {
+	return sm.sm.Snapshot()
+}
+
+func (sm *cachedStateManager) Revert(snapshot int) error {
+	sm.cache = map[string]cachedState{}
+	return sm.sm.Revert(snapshot)
+}
+
+func (sm *cachedStateManager) PutState(s interface{}, opts ...StateOption) (uint64, error) {
+	if err := sm.invalidate(opts...); err != nil {
+		return 0, err
+	}
+	return sm.sm.PutState(s, opts...)
+}
+
+func (sm *cachedStateManager) DelState(opts ...StateOption) (uint64, error) {
+	if err := sm.invalidate(opts...); err != nil {
+		return 0, err
+	}
+	return sm.sm.DelState(opts...)
+}
+
+func (sm *cachedStateManager) invalidate(opts ...StateOption) error {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return err
+	}
+	delete(sm.cache, cacheKey(cfg))
+	return nil
+}
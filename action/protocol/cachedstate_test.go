@@ -0,0 +1,137 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/state"
+)
+
+type testState struct {
+	value string
+}
+
+func (s *testState) Serialize() ([]byte, error) { return []byte(s.value), nil }
+
+func (s *testState) Deserialize(data []byte) error {
+	s.value = string(data)
+	return nil
+}
+
+// countingStateManager is an in-memory state manager counting the reads of the states
+type countingStateManager struct {
+	StateManager
+	kv    map[string][]byte
+	reads int
+}
+
+func (sm *countingStateManager) State(s interface{}, opts ...StateOption) (uint64, error) {
+	sm.reads++
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return 0, err
+	}
+	data, ok := sm.kv[cfg.Namespace+string(cfg.Key)]
+	if !ok {
+		return 0, state.ErrStateNotExist
+	}
+	return 0, state.Deserialize(s, data)
+}
+
+func (sm *countingStateManager) PutState(s interface{}, opts ...StateOption) (uint64, error) {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return 0, err
+	}
+	data, err := state.Serialize(s)
+	if err != nil {
+		return 0, err
+	}
+	sm.kv[cfg.Namespace+string(cfg.Key)] = data
+	return 0, nil
+}
+
+func (sm *countingStateManager) DelState(opts ...StateOption) (uint64, error) {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		return 0, err
+	}
+	delete(sm.kv, cfg.Namespace+string(cfg.Key))
+	return 0, nil
+}
+
+func (sm *countingStateManager) Revert(int) error { return nil }
+
+func TestCachedStateManager(t *testing.T) {
+	require := require.New(t)
+
+	backend := &countingStateManager{kv: map[string][]byte{}}
+	_, err := backend.PutState(&testState{"cat"}, NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	sm := NewCachedStateManager(backend)
+
+	// repeated reads of the same key hit the backend once
+	for i := 0; i < 3; i++ {
+		var s testState
+		_, err := sm.State(&s, NamespaceOption("ns"), KeyOption([]byte("k1")))
+		require.NoError(err)
+		require.Equal("cat", s.value)
+	}
+	require.Equal(1, backend.reads)
+	exists, err := sm.Exists(NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	require.True(exists)
+
+	// a missing state is not cached
+	for i := 0; i < 2; i++ {
+		_, err = sm.State(&testState{}, NamespaceOption("ns"), KeyOption([]byte("k2")))
+		require.Equal(state.ErrStateNotExist, errors.Cause(err))
+	}
+	require.Equal(3, backend.reads)
+
+	// a write invalidates the cached state of the key
+	_, err = sm.PutState(&testState{"dog"}, NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	var s testState
+	_, err = sm.State(&s, NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	require.Equal("dog", s.value)
+	require.Equal(4, backend.reads)
+	_, err = sm.State(&s, NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	require.Equal(4, backend.reads)
+
+	// so does a deletion
+	_, err = sm.DelState(NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	_, err = sm.State(&s, NamespaceOption("ns"), KeyOption([]byte("k1")))
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+	require.Equal(5, backend.reads)
+
+	// the same key in another namespace is cached apart, and a revert drops all the cached states
+	_, err = backend.PutState(&testState{"fox"}, NamespaceOption("ns2"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	_, err = sm.State(&s, NamespaceOption("ns2"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	require.Equal("fox", s.value)
+	require.Equal(6, backend.reads)
+	require.NoError(sm.Revert(0))
+	_, err = sm.State(&s, NamespaceOption("ns2"), KeyOption([]byte("k1")))
+	require.NoError(err)
+	require.Equal(7, backend.reads)
+
+	// reads at a height are not cached
+	for i := 0; i < 2; i++ {
+		_, err = sm.State(&s, NamespaceOption("ns2"), KeyOption([]byte("k1")), BlockHeightOption(1))
+		require.NoError(err)
+	}
+	require.Equal(9, backend.reads)
+}
@@ -65,6 +65,9 @@ func (p *Protocol) Handle(ctx context.Context, act action.Action, sm protocol.St
 	if !ok {
 		return nil, nil
 	}
+	if sm == nil {
+		return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to execute contract")
+	}
 	_, receipt, err := evm.ExecuteContract(ctx, sm, exec, p.getBlockHash)
 
 	if err != nil {
@@ -773,6 +773,27 @@ func TestProtocol_Handle(t *testing.T) {
 
 }
 
+func TestProtocol_HandleNilStateManager(t *testing.T) {
+	require := require.New(t)
+
+	p := NewProtocol(func(uint64) (hash.Hash256, error) { return hash.ZeroHash256, nil })
+	ctx := context.Background()
+
+	// non-matching action type returns before touching the state
+	tsf, err := action.NewTransfer(1, big.NewInt(1), identityset.Address(28).String(), nil, 100000, big.NewInt(0))
+	require.NoError(err)
+	receipt, err := p.Handle(ctx, tsf, nil)
+	require.NoError(err)
+	require.Nil(receipt)
+
+	// matching action type without state manager
+	exec, err := action.NewExecution(identityset.Address(28).String(), 1, big.NewInt(0), 100000, big.NewInt(0), nil)
+	require.NoError(err)
+	receipt, err = p.Handle(ctx, exec, nil)
+	require.Equal(protocol.ErrNilStateManager, errors.Cause(err))
+	require.Nil(receipt)
+}
+
 func TestProtocol_Validate(t *testing.T) {
 	require := require.New(t)
 
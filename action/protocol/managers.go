@@ -54,6 +54,15 @@ func FilterOption(cond db.Condition, minKey, maxKey []byte) StateOption {
 	}
 }
 
+// LastKeyOption sets the key after which the iteration of states resumes
+func LastKeyOption(key []byte) StateOption {
+	return func(cfg *StateConfig) error {
+		cfg.LastKey = make([]byte, len(key))
+		copy(cfg.LastKey, key)
+		return nil
+	}
+}
+
 // CreateStateConfig creates a config for accessing stateDB
 func CreateStateConfig(opts ...StateOption) (*StateConfig, error) {
 	cfg := StateConfig{AtHeight: false}
@@ -74,6 +83,7 @@ type (
 		Key       []byte
 		MinKey    []byte
 		MaxKey    []byte
+		LastKey   []byte
 		Cond      db.Condition
 	}
 
@@ -85,9 +95,12 @@ type (
 		Height() (uint64, error)
 		State(interface{}, ...StateOption) (uint64, error)
 		States(...StateOption) (uint64, state.Iterator, error)
+		Exists(...StateOption) (bool, error)
 	}
 
-	// StateManager defines the stateDB interface atop IoTeX blockchain
+	// StateManager defines the stateDB interface atop IoTeX blockchain. A state written by PutState or removed by
+	// DelState is reflected by the reads right after it, and Revert restores the states as of the snapshot, which
+	// discards the snapshots taken after it. protocoltest.TestStateManager verifies an implementation against these
 	StateManager interface {
 		StateReader
 		// Accounts
@@ -97,4 +110,15 @@ type (
 		PutState(interface{}, ...StateOption) (uint64, error)
 		DelState(...StateOption) (uint64, error)
 	}
+
+	// SnapshotListener is notified when the state manager it subscribes to takes or reverts to a snapshot
+	SnapshotListener interface {
+		Snapshot(int)
+		Revert(int) error
+	}
+
+	// SnapshotNotifier defines a state manager which notifies its listeners on snapshot and revert
+	SnapshotNotifier interface {
+		Subscribe(SnapshotListener)
+	}
 )
@@ -8,6 +8,7 @@ package poll
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -20,11 +21,16 @@ import (
 	"github.com/iotexproject/iotex-core/state"
 )
 
+// _candidateHeightsKey is the key of the sorted heights of the candidate lists
+var _candidateHeightsKey = []byte("heights")
+
 var (
 	// CandidateNamespace is a namespace to store raw candidate
 	CandidateNamespace = "candidates"
 	// KickoutNamespace is a namespace to store kickoutlist
 	KickoutNamespace = "kickout"
+	// CandidateHeightNamespace is a namespace to store the sorted heights of the candidate lists
+	CandidateHeightNamespace = "candidateHeights"
 	// ErrIndexerNotExist is an error that shows not exist in candidate indexer DB
 	ErrIndexerNotExist = errors.New("not exist in DB")
 )
@@ -65,7 +71,10 @@ func (cd *CandidateIndexer) PutCandidateList(height uint64, candidates *state.Ca
 		return err
 	}
 	log.L().Debug("put candidatelist into candidate indexer", zap.Uint64("height", height))
-	return cd.kvStore.Put(CandidateNamespace, byteutil.Uint64ToBytes(height), candidatesByte)
+	if err := cd.kvStore.Put(CandidateNamespace, byteutil.Uint64ToBytes(height), candidatesByte); err != nil {
+		return err
+	}
+	return cd.putCandidateHeight(height)
 }
 
 // PutKickoutList puts kickout list into indexer
@@ -99,6 +108,26 @@ func (cd *CandidateIndexer) CandidateList(height uint64) (state.CandidateList, e
 	return *candidates, nil
 }
 
+// ListByHeight gets candidate list from indexer given height. If there is no list at the height and fallback is
+// true, the latest list put at a lower height is returned instead. ErrIndexerNotExist is returned if there is none
+func (cd *CandidateIndexer) ListByHeight(height uint64, fallback bool) (state.CandidateList, error) {
+	candidates, err := cd.CandidateList(height)
+	if errors.Cause(err) != ErrIndexerNotExist || !fallback {
+		return candidates, err
+	}
+	cd.mutex.RLock()
+	heights, err := cd.candidateHeights()
+	cd.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(heights), func(i int) bool { return heights[i] > height })
+	if i == 0 {
+		return nil, ErrIndexerNotExist
+	}
+	return cd.CandidateList(heights[i-1])
+}
+
 // KickoutList gets kickout list from indexer given epoch start height
 func (cd *CandidateIndexer) KickoutList(height uint64) (*vote.Blacklist, error) {
 	cd.mutex.RLock()
@@ -117,3 +146,43 @@ func (cd *CandidateIndexer) KickoutList(height uint64) (*vote.Blacklist, error)
 	}
 	return bl, nil
 }
+
+// candidateHeights returns the heights of the candidate lists in ascending order. The lists put before the heights
+// were recorded are not included
+func (cd *CandidateIndexer) candidateHeights() ([]uint64, error) {
+	data, err := cd.kvStore.Get(CandidateHeightNamespace, _candidateHeightsKey)
+	switch errors.Cause(err) {
+	case nil:
+	case db.ErrNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	if len(data)%8 != 0 {
+		return nil, errors.Errorf("invalid candidate heights of length %d", len(data))
+	}
+	heights := make([]uint64, 0, len(data)/8)
+	for i := 0; i < len(data); i += 8 {
+		heights = append(heights, byteutil.BytesToUint64BigEndian(data[i:i+8]))
+	}
+	return heights, nil
+}
+
+func (cd *CandidateIndexer) putCandidateHeight(height uint64) error {
+	heights, err := cd.candidateHeights()
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(heights), func(i int) bool { return heights[i] >= height })
+	if i < len(heights) && heights[i] == height {
+		return nil
+	}
+	heights = append(heights, 0)
+	copy(heights[i+1:], heights[i:])
+	heights[i] = height
+	data := make([]byte, 0, len(heights)*8)
+	for _, h := range heights {
+		data = append(data, byteutil.Uint64ToBytesBigEndian(h)...)
+	}
+	return cd.kvStore.Put(CandidateHeightNamespace, _candidateHeightsKey, data)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package poll
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/db"
+	"github.com/iotexproject/iotex-core/state"
+	"github.com/iotexproject/iotex-core/test/identityset"
+)
+
+func TestCandidateIndexer_ListByHeight(t *testing.T) {
+	require := require.New(t)
+	indexer, err := NewCandidateIndexer(db.NewMemKVStore())
+	require.NoError(err)
+	require.NoError(indexer.Start(context.Background()))
+	defer func() {
+		require.NoError(indexer.Stop(context.Background()))
+	}()
+
+	_, err = indexer.ListByHeight(150, true)
+	require.Equal(ErrIndexerNotExist, errors.Cause(err))
+
+	list100 := state.CandidateList{
+		{Address: identityset.Address(1).String(), Votes: big.NewInt(10), RewardAddress: "rewardAddress1"},
+	}
+	list200 := state.CandidateList{
+		{Address: identityset.Address(2).String(), Votes: big.NewInt(20), RewardAddress: "rewardAddress2"},
+	}
+	// put out of order
+	require.NoError(indexer.PutCandidateList(200, &list200))
+	require.NoError(indexer.PutCandidateList(100, &list100))
+	require.NoError(indexer.PutCandidateList(100, &list100))
+
+	tests := []struct {
+		height   uint64
+		fallback bool
+		expected state.CandidateList
+	}{
+		{100, false, list100},
+		{100, true, list100},
+		{150, false, nil},
+		{150, true, list100},
+		{200, true, list200},
+		{250, true, list200},
+		{99, true, nil},
+	}
+	for _, test := range tests {
+		list, err := indexer.ListByHeight(test.height, test.fallback)
+		if test.expected == nil {
+			require.Equal(ErrIndexerNotExist, errors.Cause(err), "height %d", test.height)
+			continue
+		}
+		require.NoError(err, "height %d", test.height)
+		require.Equal(test.expected, list, "height %d", test.height)
+	}
+}
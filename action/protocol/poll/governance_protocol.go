@@ -9,6 +9,7 @@ package poll
 import (
 	"context"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/iotexproject/go-pkgs/hash"
@@ -49,6 +50,46 @@ type governanceChainCommitteeProtocol struct {
 	kickoutIntensity          uint32
 	maxKickoutPeriod          uint64
 	indexer                   *CandidateIndexer
+	intensityEscalation       IntensityEscalation
+	delegateSet               delegateSetCache
+}
+
+// delegateSetCache keeps the set of the delegates of the last queried epoch, along with the state height it was read
+// at, since the delegates of the current and next epoch may change as blocks are committed
+type delegateSetCache struct {
+	mu          sync.RWMutex
+	epochNum    uint64
+	stateHeight uint64
+	set         map[string]struct{}
+}
+
+func (c *delegateSetCache) get(epochNum, stateHeight uint64) (map[string]struct{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.set == nil || c.epochNum != epochNum || c.stateHeight != stateHeight {
+		return nil, false
+	}
+	return c.set, true
+}
+
+func (c *delegateSetCache) put(epochNum, stateHeight uint64, set map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epochNum = epochNum
+	c.stateHeight = stateHeight
+	c.set = set
+}
+
+// GovernanceOption is optional setting for the governance chain committee protocol
+type GovernanceOption func(*governanceChainCommitteeProtocol) error
+
+// IntensityEscalationOption applies the kick-out intensity rate per delegate according to the number of offenses,
+// instead of applying the intensity rate of the blacklist uniformly
+func IntensityEscalationOption(escalation IntensityEscalation) GovernanceOption {
+	return func(p *governanceChainCommitteeProtocol) error {
+		p.intensityEscalation = escalation
+		return nil
+	}
 }
 
 // NewGovernanceChainCommitteeProtocol creates a Poll Protocol which fetch result from governance chain
@@ -70,6 +111,7 @@ func NewGovernanceChainCommitteeProtocol(
 	kickoutEpochPeriod uint64,
 	kickoutIntensity uint32,
 	maxKickoutPeriod uint64,
+	opts ...GovernanceOption,
 ) (Protocol, error) {
 	if electionCommittee == nil {
 		return nil, ErrNoElectionCommittee
@@ -83,7 +125,7 @@ func NewGovernanceChainCommitteeProtocol(
 	if err != nil {
 		log.L().Panic("Error when constructing the address of poll protocol", zap.Error(err))
 	}
-	return &governanceChainCommitteeProtocol{
+	p := &governanceChainCommitteeProtocol{
 		indexer:                   candidatesIndexer,
 		candidatesByHeight:        candidatesByHeight,
 		getCandidates:             getCandidates,
@@ -102,7 +144,13 @@ func NewGovernanceChainCommitteeProtocol(
 		kickoutEpochPeriod:        kickoutEpochPeriod,
 		kickoutIntensity:          kickoutIntensity,
 		maxKickoutPeriod:          maxKickoutPeriod,
-	}, nil
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
 }
 
 func (p *governanceChainCommitteeProtocol) CreateGenesisStates(
@@ -255,9 +303,54 @@ func (p *governanceChainCommitteeProtocol) DelegatesByEpoch(ctx context.Context,
 	if tipEpochNum == epochNum {
 		return p.readActiveBlockProducersByEpoch(ctx, epochNum, false)
 	}
+	if epochNum < tipEpochNum {
+		return p.readArchivedDelegatesByEpoch(ctx, epochNum)
+	}
 	return nil, errors.Errorf("invalid epochNumber %d to get delegates", epochNum)
 }
 
+func (p *governanceChainCommitteeProtocol) DelegateDiff(
+	ctx context.Context,
+	fromEpoch uint64,
+	toEpoch uint64,
+) (state.CandidateList, state.CandidateList, error) {
+	return delegateDiff(ctx, p, fromEpoch, toEpoch)
+}
+
+func (p *governanceChainCommitteeProtocol) RewardAddressesByEpoch(
+	ctx context.Context,
+	epochNum uint64,
+) (map[string]address.Address, error) {
+	return rewardAddressesByEpoch(ctx, p, epochNum)
+}
+
+// IsActiveDelegate returns whether the operator address is one of the delegates of the epoch. The delegate set is cached
+// per epoch and state height, so that repeated queries do not recalculate the delegates
+func (p *governanceChainCommitteeProtocol) IsActiveDelegate(
+	ctx context.Context,
+	addr address.Address,
+	epochNum uint64,
+) (bool, error) {
+	stateHeight, err := p.sr.Height()
+	if err != nil {
+		return false, err
+	}
+	set, ok := p.delegateSet.get(epochNum, stateHeight)
+	if !ok {
+		delegates, err := p.DelegatesByEpoch(ctx, epochNum)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get delegates of epoch %d", epochNum)
+		}
+		set = make(map[string]struct{}, len(delegates))
+		for _, d := range delegates {
+			set[d.Address] = struct{}{}
+		}
+		p.delegateSet.put(epochNum, stateHeight, set)
+	}
+	_, ok = set[addr.String()]
+	return ok, nil
+}
+
 func (p *governanceChainCommitteeProtocol) CandidatesByHeight(ctx context.Context, height uint64) (state.CandidateList, error) {
 	bcCtx := protocol.MustGetBlockchainCtx(ctx)
 	rp := rolldpos.MustGetProtocol(bcCtx.Registry)
@@ -277,6 +370,44 @@ func (p *governanceChainCommitteeProtocol) CandidatesByHeight(ctx context.Contex
 	return nil, errors.Errorf("wrong epochNumber to get candidatesbyHeight, target epochNumber %d can't be less than tip epoch number %d", targetEpochNum, tipEpochNum)
 }
 
+// EffectiveIntensityRate returns the kick-out intensity rate applied to the delegate in the epoch of the given height,
+// 0 if the delegate is not on the kick-out list
+func (p *governanceChainCommitteeProtocol) EffectiveIntensityRate(
+	ctx context.Context,
+	height uint64,
+	delegate address.Address,
+) (uint32, error) {
+	bcCtx := protocol.MustGetBlockchainCtx(ctx)
+	hu := config.NewHeightUpgrade(&bcCtx.Genesis)
+	rp := rolldpos.MustGetProtocol(bcCtx.Registry)
+	epochNum := rp.GetEpochNum(height)
+	epochStartHeight := rp.GetEpochHeight(epochNum)
+	if hu.IsPre(config.Easter, epochStartHeight) {
+		return 0, nil
+	}
+	stateTipHeight, err := p.sr.Height()
+	if err != nil {
+		return 0, err
+	}
+	tipEpochNum := rp.GetEpochNum(stateTipHeight)
+	if epochNum > tipEpochNum+1 {
+		return 0, errors.Errorf("invalid epochNumber %d to get intensity rate, tip epoch number is %d", epochNum, tipEpochNum)
+	}
+	var unqualifiedList *vote.Blacklist
+	if p.indexer != nil {
+		unqualifiedList, err = p.indexer.KickoutList(epochStartHeight)
+		if err != nil && errors.Cause(err) != ErrIndexerNotExist {
+			return 0, err
+		}
+	}
+	if unqualifiedList == nil {
+		if unqualifiedList, err = p.readKickoutList(ctx, epochNum, epochNum > tipEpochNum); err != nil {
+			return 0, err
+		}
+	}
+	return effectiveIntensityRate(unqualifiedList, delegate.String(), p.intensityEscalation), nil
+}
+
 func (p *governanceChainCommitteeProtocol) ReadState(
 	ctx context.Context,
 	sm protocol.StateReader,
@@ -422,7 +553,7 @@ func (p *governanceChainCommitteeProtocol) readCandidatesByHeight(ctx context.Co
 		return nil, errors.Wrap(err, "failed to read kick-out list")
 	}
 	// recalculate the voting power for blacklist delegates
-	return filterCandidates(candidates, unqualifiedList, epochStartHeight)
+	return filterCandidates(candidates, unqualifiedList, epochStartHeight, p.intensityEscalation)
 }
 
 func (p *governanceChainCommitteeProtocol) readCandidatesFromIndexer(ctx context.Context, epochStartHeight uint64) (state.CandidateList, error) {
@@ -441,7 +572,7 @@ func (p *governanceChainCommitteeProtocol) readCandidatesFromIndexer(ctx context
 		return nil, err
 	}
 	// recalculate the voting power for blacklist delegates
-	return filterCandidates(candidates, kickoutList, epochStartHeight)
+	return filterCandidates(candidates, kickoutList, epochStartHeight, p.intensityEscalation)
 }
 
 func (p *governanceChainCommitteeProtocol) readBlockProducersByEpoch(ctx context.Context, epochNum uint64, readFromNext bool) (state.CandidateList, error) {
@@ -449,7 +580,8 @@ func (p *governanceChainCommitteeProtocol) readBlockProducersByEpoch(ctx context
 	if err != nil {
 		return nil, err
 	}
-	return p.calculateBlockProducer(candidates)
+	rp := rolldpos.MustGetProtocol(protocol.MustGetBlockchainCtx(ctx).Registry)
+	return p.calculateBlockProducer(ctx, candidates, rp.GetEpochHeight(epochNum))
 }
 
 func (p *governanceChainCommitteeProtocol) readBPFromIndexer(ctx context.Context, epochStartHeight uint64) (state.CandidateList, error) {
@@ -457,7 +589,7 @@ func (p *governanceChainCommitteeProtocol) readBPFromIndexer(ctx context.Context
 	if err != nil {
 		return nil, err
 	}
-	return p.calculateBlockProducer(candidates)
+	return p.calculateBlockProducer(ctx, candidates, epochStartHeight)
 }
 
 func (p *governanceChainCommitteeProtocol) readActiveBlockProducersByEpoch(ctx context.Context, epochNum uint64, readFromNext bool) (state.CandidateList, error) {
@@ -479,6 +611,24 @@ func (p *governanceChainCommitteeProtocol) readABPFromIndexer(ctx context.Contex
 	return p.calculateActiveBlockProducer(ctx, blockProducers, epochStartHeight)
 }
 
+// readArchivedDelegatesByEpoch reads the delegates of a past epoch from the candidate list and kick-out list archived
+// in the indexer, so that they are exactly as they were instead of being recalculated against the current state
+func (p *governanceChainCommitteeProtocol) readArchivedDelegatesByEpoch(ctx context.Context, epochNum uint64) (state.CandidateList, error) {
+	if p.indexer == nil {
+		return nil, errors.Wrapf(ErrEpochNotArchived, "no candidate indexer to read epoch %d", epochNum)
+	}
+	bcCtx := protocol.MustGetBlockchainCtx(ctx)
+	rp := rolldpos.MustGetProtocol(bcCtx.Registry)
+	delegates, err := p.readABPFromIndexer(ctx, rp.GetEpochHeight(epochNum))
+	if errors.Cause(err) == ErrIndexerNotExist {
+		return nil, errors.Wrapf(ErrEpochNotArchived, "epoch %d", epochNum)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read archived delegates in epoch %d", epochNum)
+	}
+	return delegates, nil
+}
+
 func (p *governanceChainCommitteeProtocol) readCandidates(ctx context.Context, epochStartHeight uint64, readFromNext bool) (state.CandidateList, error) {
 	bcCtx := protocol.MustGetBlockchainCtx(ctx)
 	hu := config.NewHeightUpgrade(&bcCtx.Genesis)
@@ -692,14 +842,15 @@ func filterCandidates(
 	candidates state.CandidateList,
 	unqualifiedList *vote.Blacklist,
 	epochStartHeight uint64,
+	escalation IntensityEscalation,
 ) (state.CandidateList, error) {
 	candidatesMap := make(map[string]*state.Candidate)
 	updatedVotingPower := make(map[string]*big.Int)
-	intensityRate := float64(uint32(100)-unqualifiedList.IntensityRate) / float64(100)
 	for _, cand := range candidates {
 		filterCand := cand.Clone()
-		if _, ok := unqualifiedList.BlacklistInfos[cand.Address]; ok {
+		if rate := effectiveIntensityRate(unqualifiedList, cand.Address, escalation); rate != 0 {
 			// if it is an unqualified delegate, multiply the voting power with kick-out intensity rate
+			intensityRate := float64(uint32(100)-rate) / float64(100)
 			votingPower := new(big.Float).SetInt(filterCand.Votes)
 			filterCand.Votes, _ = votingPower.Mul(votingPower, big.NewFloat(intensityRate)).Int(nil)
 		}
@@ -716,10 +867,33 @@ func filterCandidates(
 	return verifiedCandidates, nil
 }
 
-// calculateBlockProducer calculates block producer by given candidate list
+// effectiveIntensityRate returns the kick-out intensity rate applied to the delegate, 0 if it is not listed
+func effectiveIntensityRate(unqualifiedList *vote.Blacklist, delegate string, escalation IntensityEscalation) uint32 {
+	offenses, ok := unqualifiedList.BlacklistInfos[delegate]
+	if !ok {
+		return 0
+	}
+	if escalation == nil {
+		return unqualifiedList.IntensityRate
+	}
+	return escalation(unqualifiedList.IntensityRate, offenses)
+}
+
+// calculateBlockProducer calculates block producer by given candidate list of the epoch starting at epochStartHeight
 func (p *governanceChainCommitteeProtocol) calculateBlockProducer(
+	ctx context.Context,
 	candidates state.CandidateList,
+	epochStartHeight uint64,
 ) (state.CandidateList, error) {
+	bcCtx := protocol.MustGetBlockchainCtx(ctx)
+	hu := config.NewHeightUpgrade(&bcCtx.Genesis)
+	if hu.IsPost(config.Greenland, epochStartHeight) {
+		// sort a copy so that candidates with equal votes are selected deterministically
+		sorted := make(state.CandidateList, len(candidates))
+		copy(sorted, candidates)
+		sorted.SortByVotes()
+		candidates = sorted
+	}
 	var blockProducers state.CandidateList
 	for i, candidate := range candidates {
 		if uint64(i) >= p.numCandidateDelegates {
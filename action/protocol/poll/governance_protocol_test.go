@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
 	"github.com/iotexproject/go-pkgs/hash"
@@ -337,6 +338,10 @@ func TestHandle(t *testing.T) {
 	receipt, err := p.Handle(ctx, selp.Action(), nil)
 	require.NoError(err)
 	require.Nil(receipt)
+	// Case 1.1: matching action type without state manager
+	receipt, err = p.Handle(ctx, action.NewPutPollResult(1, 721, nil), nil)
+	require.Equal(protocol.ErrNilStateManager, errors.Cause(err))
+	require.Nil(receipt)
 	// Case 2: all right
 	p2, ctx2, sm2, _, err := initConstruct(ctrl)
 	require.NoError(err)
@@ -599,6 +604,287 @@ func TestCandidatesByHeight(t *testing.T) {
 
 }
 
+func TestEffectiveIntensityRate(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	p, ctx, sm, _, err := initConstruct(ctrl)
+	require.NoError(err)
+	gp, ok := p.(*governanceChainCommitteeProtocol)
+	require.True(ok)
+
+	require.NoError(setNextEpochBlacklist(sm, nil, 721, &vote.Blacklist{
+		BlacklistInfos: map[string]uint32{
+			identityset.Address(1).String(): 1, // first offense
+			identityset.Address(2).String(): 3, // repeat offender
+		},
+		IntensityRate: 80,
+	}))
+
+	// without escalation the rate is applied uniformly
+	for _, test := range []struct {
+		delegate address.Address
+		rate     uint32
+	}{
+		{identityset.Address(3), 0},
+		{identityset.Address(1), 80},
+		{identityset.Address(2), 80},
+	} {
+		rate, err := gp.EffectiveIntensityRate(ctx, 721, test.delegate)
+		require.NoError(err)
+		require.Equal(test.rate, rate)
+	}
+
+	// with escalation the repeat offender gets a higher rate
+	gp.intensityEscalation = LinearIntensityEscalation(5)
+	for _, test := range []struct {
+		delegate address.Address
+		rate     uint32
+	}{
+		{identityset.Address(3), 0},
+		{identityset.Address(1), 80},
+		{identityset.Address(2), 90},
+	} {
+		rate, err := gp.EffectiveIntensityRate(ctx, 721, test.delegate)
+		require.NoError(err)
+		require.Equal(test.rate, rate)
+	}
+
+	// the rate reported is the one applied to the voting power
+	candidates, err := gp.CandidatesByHeight(ctx, 721)
+	require.NoError(err)
+	votes := make(map[string]int64)
+	for _, c := range candidates {
+		votes[c.Address] = c.Votes.Int64()
+	}
+	require.Equal(int64(20), votes[identityset.Address(3).String()])
+	require.Equal(int64(6), votes[identityset.Address(1).String()])
+	require.Equal(int64(2), votes[identityset.Address(2).String()])
+
+	// the rate is capped at 100
+	require.Equal(uint32(100), LinearIntensityEscalation(30)(80, 3))
+
+	// cannot query beyond the next epoch
+	_, err = gp.EffectiveIntensityRate(ctx, 2161, identityset.Address(1))
+	require.Error(err)
+}
+
+func TestDelegatesByEpochFromArchive(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := config.Default
+	cfg.Genesis.EasterBlockHeight = 1
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 36, 20)
+	require.NoError(registry.Register("rolldpos", rp))
+	ctx := protocol.WithBlockchainCtx(
+		context.Background(),
+		protocol.BlockchainCtx{
+			Genesis:  cfg.Genesis,
+			Registry: registry,
+		},
+	)
+	// the state reader is only asked for its height, the archived epochs must not be read from the current state
+	sr := mock_chainmanager.NewMockStateReader(ctrl)
+	sr.EXPECT().Height().Return(rp.GetEpochHeight(4), nil).AnyTimes()
+	indexer, err := NewCandidateIndexer(db.NewMemKVStore())
+	require.NoError(err)
+	p := &governanceChainCommitteeProtocol{
+		numCandidateDelegates: 2,
+		numDelegates:          2,
+		sr:                    sr,
+		indexer:               indexer,
+	}
+
+	candidates := state.CandidateList{
+		{Address: identityset.Address(1).String(), Votes: big.NewInt(30), RewardAddress: "rewardAddress1"},
+		{Address: identityset.Address(2).String(), Votes: big.NewInt(22), RewardAddress: "rewardAddress2"},
+		{Address: identityset.Address(3).String(), Votes: big.NewInt(20), RewardAddress: "rewardAddress3"},
+		{Address: identityset.Address(4).String(), Votes: big.NewInt(10), RewardAddress: "rewardAddress4"},
+	}
+	archives := []struct {
+		epochNum  uint64
+		blackList *vote.Blacklist
+		expected  []string
+	}{
+		{
+			2,
+			&vote.Blacklist{BlacklistInfos: map[string]uint32{}, IntensityRate: 90},
+			[]string{identityset.Address(1).String(), identityset.Address(2).String()},
+		},
+		{
+			3,
+			&vote.Blacklist{
+				BlacklistInfos: map[string]uint32{
+					identityset.Address(1).String(): 1,
+					identityset.Address(2).String(): 1,
+				},
+				IntensityRate: 90,
+			},
+			[]string{identityset.Address(3).String(), identityset.Address(4).String()},
+		},
+	}
+	for _, a := range archives {
+		height := rp.GetEpochHeight(a.epochNum)
+		require.NoError(indexer.PutCandidateList(height, &candidates))
+		require.NoError(indexer.PutKickoutList(height, a.blackList))
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, a := range archives {
+			delegates, err := p.DelegatesByEpoch(ctx, a.epochNum)
+			require.NoError(err)
+			var addrs []string
+			for _, d := range delegates {
+				addrs = append(addrs, d.Address)
+			}
+			require.ElementsMatch(a.expected, addrs)
+		}
+	}
+
+	// epoch 1 predates the archive
+	_, err = p.DelegatesByEpoch(ctx, 1)
+	require.Equal(ErrEpochNotArchived, errors.Cause(err))
+
+	// no indexer
+	p.indexer = nil
+	_, err = p.DelegatesByEpoch(ctx, 2)
+	require.Equal(ErrEpochNotArchived, errors.Cause(err))
+}
+
+func TestDelegateDiff(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := config.Default
+	cfg.Genesis.EasterBlockHeight = 1
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 36, 20)
+	require.NoError(registry.Register("rolldpos", rp))
+	ctx := protocol.WithBlockchainCtx(
+		context.Background(),
+		protocol.BlockchainCtx{
+			Genesis:  cfg.Genesis,
+			Registry: registry,
+		},
+	)
+	sr := mock_chainmanager.NewMockStateReader(ctrl)
+	sr.EXPECT().Height().Return(rp.GetEpochHeight(5), nil).AnyTimes()
+	indexer, err := NewCandidateIndexer(db.NewMemKVStore())
+	require.NoError(err)
+	p := &governanceChainCommitteeProtocol{
+		numCandidateDelegates: 3,
+		numDelegates:          3,
+		sr:                    sr,
+		indexer:               indexer,
+	}
+
+	candidates := state.CandidateList{
+		{Address: identityset.Address(1).String(), Votes: big.NewInt(30), RewardAddress: "rewardAddress1"},
+		{Address: identityset.Address(2).String(), Votes: big.NewInt(22), RewardAddress: "rewardAddress2"},
+		{Address: identityset.Address(3).String(), Votes: big.NewInt(20), RewardAddress: "rewardAddress3"},
+		{Address: identityset.Address(4).String(), Votes: big.NewInt(10), RewardAddress: "rewardAddress4"},
+		{Address: identityset.Address(5).String(), Votes: big.NewInt(5), RewardAddress: "rewardAddress5"},
+	}
+	blackLists := map[uint64]*vote.Blacklist{
+		2: {BlacklistInfos: map[string]uint32{}, IntensityRate: 90},
+		3: {
+			BlacklistInfos: map[string]uint32{
+				identityset.Address(1).String(): 1,
+				identityset.Address(2).String(): 1,
+			},
+			IntensityRate: 90,
+		},
+	}
+	for epochNum, bl := range blackLists {
+		height := rp.GetEpochHeight(epochNum)
+		require.NoError(indexer.PutCandidateList(height, &candidates))
+		require.NoError(indexer.PutKickoutList(height, bl))
+	}
+	addrs := func(l state.CandidateList) []string {
+		var a []string
+		for _, d := range l {
+			a = append(a, d.Address)
+		}
+		return a
+	}
+
+	// the blacklist of epoch 3 replaces delegates 1 and 2 with 4 and 5
+	added, removed, err := p.DelegateDiff(ctx, 2, 3)
+	require.NoError(err)
+	require.ElementsMatch([]string{identityset.Address(4).String(), identityset.Address(5).String()}, addrs(added))
+	require.ElementsMatch([]string{identityset.Address(1).String(), identityset.Address(2).String()}, addrs(removed))
+	added, removed, err = p.DelegateDiff(ctx, 3, 2)
+	require.NoError(err)
+	require.ElementsMatch([]string{identityset.Address(1).String(), identityset.Address(2).String()}, addrs(added))
+	require.ElementsMatch([]string{identityset.Address(4).String(), identityset.Address(5).String()}, addrs(removed))
+
+	// no change
+	added, removed, err = p.DelegateDiff(ctx, 2, 2)
+	require.NoError(err)
+	require.Empty(added)
+	require.Empty(removed)
+
+	// the delegates of an epoch are not available
+	_, _, err = p.DelegateDiff(ctx, 1, 2)
+	require.Equal(ErrEpochNotArchived, errors.Cause(err))
+}
+
+func TestRewardAddressesByEpoch(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := config.Default
+	cfg.Genesis.EasterBlockHeight = 1
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 36, 20)
+	require.NoError(registry.Register("rolldpos", rp))
+	ctx := protocol.WithBlockchainCtx(
+		context.Background(),
+		protocol.BlockchainCtx{
+			Genesis:  cfg.Genesis,
+			Registry: registry,
+		},
+	)
+	sr := mock_chainmanager.NewMockStateReader(ctrl)
+	sr.EXPECT().Height().Return(rp.GetEpochHeight(5), nil).AnyTimes()
+	indexer, err := NewCandidateIndexer(db.NewMemKVStore())
+	require.NoError(err)
+	p := &governanceChainCommitteeProtocol{
+		numCandidateDelegates: 3,
+		numDelegates:          3,
+		sr:                    sr,
+		indexer:               indexer,
+	}
+
+	// the third delegate has no reward address set, the fourth is not in the active set
+	candidates := state.CandidateList{
+		{Address: identityset.Address(1).String(), Votes: big.NewInt(30), RewardAddress: identityset.Address(11).String()},
+		{Address: identityset.Address(2).String(), Votes: big.NewInt(22), RewardAddress: identityset.Address(12).String()},
+		{Address: identityset.Address(3).String(), Votes: big.NewInt(20)},
+		{Address: identityset.Address(4).String(), Votes: big.NewInt(10), RewardAddress: identityset.Address(14).String()},
+	}
+	height := rp.GetEpochHeight(2)
+	require.NoError(indexer.PutCandidateList(height, &candidates))
+	require.NoError(indexer.PutKickoutList(height, &vote.Blacklist{BlacklistInfos: map[string]uint32{}, IntensityRate: 90}))
+
+	addrs, err := p.RewardAddressesByEpoch(ctx, 2)
+	require.NoError(err)
+	require.Equal(map[string]address.Address{
+		identityset.Address(1).String(): identityset.Address(11),
+		identityset.Address(2).String(): identityset.Address(12),
+		identityset.Address(3).String(): identityset.Address(3),
+	}, addrs)
+
+	// the delegates of an epoch are not available
+	_, err = p.RewardAddressesByEpoch(ctx, 1)
+	require.Equal(ErrEpochNotArchived, errors.Cause(err))
+}
+
 func TestDelegatesByEpoch(t *testing.T) {
 	require := require.New(t)
 	ctrl := gomock.NewController(t)
@@ -683,3 +969,106 @@ func TestDelegatesByEpoch(t *testing.T) {
 	require.Equal(1, len(delegates5)) // exclude all of them
 	require.Equal(identityset.Address(4).String(), delegates5[0].Address)
 }
+
+func TestIsActiveDelegate(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := config.Default
+	cfg.Genesis.EasterBlockHeight = 1
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 36, 20)
+	require.NoError(registry.Register("rolldpos", rp))
+	ctx := protocol.WithBlockchainCtx(
+		context.Background(),
+		protocol.BlockchainCtx{
+			Genesis:  cfg.Genesis,
+			Registry: registry,
+		},
+	)
+	sr := mock_chainmanager.NewMockStateReader(ctrl)
+	sr.EXPECT().Height().Return(rp.GetEpochHeight(5), nil).AnyTimes()
+	indexer, err := NewCandidateIndexer(db.NewMemKVStore())
+	require.NoError(err)
+	p := &governanceChainCommitteeProtocol{
+		numCandidateDelegates: 3,
+		numDelegates:          3,
+		sr:                    sr,
+		indexer:               indexer,
+	}
+
+	// the first candidate is on the kick-out list, such that its voting power falls below the fourth one
+	candidates := state.CandidateList{
+		{Address: identityset.Address(1).String(), Votes: big.NewInt(30)},
+		{Address: identityset.Address(2).String(), Votes: big.NewInt(22)},
+		{Address: identityset.Address(3).String(), Votes: big.NewInt(20)},
+		{Address: identityset.Address(4).String(), Votes: big.NewInt(10)},
+	}
+	height := rp.GetEpochHeight(2)
+	require.NoError(indexer.PutCandidateList(height, &candidates))
+	require.NoError(indexer.PutKickoutList(height, &vote.Blacklist{
+		BlacklistInfos: map[string]uint32{identityset.Address(1).String(): 1},
+		IntensityRate:  90,
+	}))
+
+	for _, e := range []struct {
+		addr   address.Address
+		active bool
+	}{
+		{identityset.Address(2), true},
+		{identityset.Address(4), true},
+		// blacklisted and excluded
+		{identityset.Address(1), false},
+		// not a candidate
+		{identityset.Address(5), false},
+	} {
+		active, err := p.IsActiveDelegate(ctx, e.addr, 2)
+		require.NoError(err)
+		require.Equal(e.active, active)
+	}
+
+	// the delegates of an epoch are not available
+	_, err = p.IsActiveDelegate(ctx, identityset.Address(2), 1)
+	require.Equal(ErrEpochNotArchived, errors.Cause(err))
+}
+
+func TestCalculateBlockProducer(t *testing.T) {
+	require := require.New(t)
+
+	cfg := config.Default
+	cfg.Genesis.GreenlandBlockHeight = 10
+	ctx := protocol.WithBlockchainCtx(
+		context.Background(),
+		protocol.BlockchainCtx{
+			Genesis: cfg.Genesis,
+		},
+	)
+	p := &governanceChainCommitteeProtocol{
+		numCandidateDelegates: 2,
+	}
+	var sorted state.CandidateList
+	for i := 1; i <= 3; i++ {
+		sorted = append(sorted, &state.Candidate{
+			Address: identityset.Address(i).String(),
+			Votes:   big.NewInt(10),
+		})
+	}
+	sorted.SortByVotes()
+	// the candidates in the reverse order of SortByVotes
+	candidates := make(state.CandidateList, 0, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		candidates = append(candidates, sorted[i])
+	}
+
+	// before Greenland, candidates are selected in the given order
+	bp, err := p.calculateBlockProducer(ctx, candidates, 9)
+	require.NoError(err)
+	require.Equal(candidates[:2], bp)
+	// since Greenland, equal-vote candidates are selected by SortByVotes
+	bp, err = p.calculateBlockProducer(ctx, candidates, 10)
+	require.NoError(err)
+	require.Equal(sorted[:2], bp)
+	// the given list is not reordered
+	require.Equal(sorted[2], candidates[0])
+}
@@ -79,6 +79,18 @@ func (p *lifeLongDelegatesProtocol) DelegatesByEpoch(ctx context.Context, epochN
 	return p.readActiveBlockProducersByEpoch(ctx, epochNum)
 }
 
+func (p *lifeLongDelegatesProtocol) DelegateDiff(ctx context.Context, fromEpoch, toEpoch uint64) (state.CandidateList, state.CandidateList, error) {
+	return delegateDiff(ctx, p, fromEpoch, toEpoch)
+}
+
+func (p *lifeLongDelegatesProtocol) RewardAddressesByEpoch(ctx context.Context, epochNum uint64) (map[string]address.Address, error) {
+	return rewardAddressesByEpoch(ctx, p, epochNum)
+}
+
+func (p *lifeLongDelegatesProtocol) IsActiveDelegate(ctx context.Context, addr address.Address, epochNum uint64) (bool, error) {
+	return isActiveDelegate(ctx, p, addr, epochNum)
+}
+
 func (p *lifeLongDelegatesProtocol) CandidatesByHeight(ctx context.Context, height uint64) (state.CandidateList, error) {
 	return p.delegates, nil
 }
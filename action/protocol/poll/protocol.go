@@ -11,6 +11,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-election/committee"
 	"github.com/pkg/errors"
 
@@ -41,6 +42,9 @@ var ErrDelegatesNotAsExpected = errors.New("delegates are not as expected")
 // ErrDelegatesNotExist is an error that the delegates cannot be prepared
 var ErrDelegatesNotExist = errors.New("delegates cannot be found")
 
+// ErrEpochNotArchived is an error that the delegates of a past epoch are not archived in the candidate indexer
+var ErrEpochNotArchived = errors.New("epoch predates the archived delegates")
+
 // CandidatesByHeight returns the candidates of a given height
 type CandidatesByHeight func(protocol.StateReader, uint64) ([]*state.Candidate, error)
 
@@ -59,11 +63,36 @@ type GetBlockTime func(uint64) (time.Time, error)
 // ProductivityByEpoch returns the number of produced blocks per delegate in an epoch
 type ProductivityByEpoch func(context.Context, uint64) (uint64, map[string]uint64, error)
 
+// IntensityEscalation returns the kick-out intensity rate applied to a delegate, given the intensity rate of the
+// blacklist and the number of times the delegate is listed in the kick-out period
+type IntensityEscalation func(uint32, uint32) uint32
+
+// LinearIntensityEscalation raises the intensity rate by step for every repeated offense, capped at 100
+func LinearIntensityEscalation(step uint32) IntensityEscalation {
+	return func(rate uint32, offenses uint32) uint32 {
+		if offenses > 1 {
+			rate += step * (offenses - 1)
+		}
+		if rate > 100 {
+			rate = 100
+		}
+		return rate
+	}
+}
+
 // Protocol defines the protocol of handling votes
 type Protocol interface {
 	protocol.Protocol
 	protocol.GenesisStateCreator
 	DelegatesByEpoch(context.Context, uint64) (state.CandidateList, error)
+	// DelegateDiff returns the delegates which joined and left from the first epoch to the second one
+	DelegateDiff(context.Context, uint64, uint64) (state.CandidateList, state.CandidateList, error)
+	// RewardAddressesByEpoch returns the reward addresses of the delegates of the epoch keyed by owner address, which is
+	// the owner itself if no reward address is set
+	RewardAddressesByEpoch(context.Context, uint64) (map[string]address.Address, error)
+	// IsActiveDelegate returns whether the address is one of the delegates of the epoch. The address is matched against
+	// the address of the delegate candidate, which is the operator address
+	IsActiveDelegate(context.Context, address.Address, uint64) (bool, error)
 	CandidatesByHeight(context.Context, uint64) (state.CandidateList, error)
 	// CalculateCandidatesByHeight calculates candidate and returns candidates by chain height
 	CalculateCandidatesByHeight(context.Context, uint64) (state.CandidateList, error)
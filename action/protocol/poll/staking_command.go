@@ -90,6 +90,12 @@ func (sc *stakingCommand) CreatePostSystemActions(ctx context.Context) ([]action
 }
 
 func (sc *stakingCommand) Handle(ctx context.Context, act action.Action, sm protocol.StateManager) (*action.Receipt, error) {
+	if _, ok := act.(*action.PutPollResult); !ok {
+		return nil, nil
+	}
+	if sm == nil {
+		return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to handle put poll result")
+	}
 	if sc.stakingV1 == nil {
 		return handle(ctx, act, sm, sc.candIndexer, sc.addr.String())
 	}
@@ -135,6 +141,22 @@ func (sc *stakingCommand) DelegatesByEpoch(ctx context.Context, epochNum uint64)
 	return sc.stakingV1.DelegatesByEpoch(ctx, epochNum)
 }
 
+// DelegateDiff returns the delegates which joined and left from the first epoch to the second one
+func (sc *stakingCommand) DelegateDiff(ctx context.Context, fromEpoch, toEpoch uint64) (state.CandidateList, state.CandidateList, error) {
+	return delegateDiff(ctx, sc, fromEpoch, toEpoch)
+}
+
+// RewardAddressesByEpoch returns the reward addresses of the delegates of the epoch keyed by owner address
+func (sc *stakingCommand) RewardAddressesByEpoch(ctx context.Context, epochNum uint64) (map[string]address.Address, error) {
+	return rewardAddressesByEpoch(ctx, sc, epochNum)
+}
+
+// IsActiveDelegate returns whether the operator address is one of the delegates of the epoch
+func (sc *stakingCommand) IsActiveDelegate(ctx context.Context, addr address.Address, epochNum uint64) (bool, error) {
+	// TODO: handle V2
+	return sc.stakingV1.IsActiveDelegate(ctx, addr, epochNum)
+}
+
 // CandidatesByHeight returns candidate list from state factory according to height
 func (sc *stakingCommand) CandidatesByHeight(ctx context.Context, height uint64) (state.CandidateList, error) {
 	// TODO: handle V2
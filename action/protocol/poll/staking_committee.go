@@ -232,6 +232,21 @@ func (sc *stakingCommittee) DelegatesByEpoch(ctx context.Context, epochNum uint6
 	return sc.governanceStaking.DelegatesByEpoch(ctx, epochNum)
 }
 
+// DelegateDiff returns the delegates which joined and left from the first epoch to the second one
+func (sc *stakingCommittee) DelegateDiff(ctx context.Context, fromEpoch, toEpoch uint64) (state.CandidateList, state.CandidateList, error) {
+	return delegateDiff(ctx, sc, fromEpoch, toEpoch)
+}
+
+// RewardAddressesByEpoch returns the reward addresses of the delegates of the epoch keyed by owner address
+func (sc *stakingCommittee) RewardAddressesByEpoch(ctx context.Context, epochNum uint64) (map[string]address.Address, error) {
+	return rewardAddressesByEpoch(ctx, sc, epochNum)
+}
+
+// IsActiveDelegate returns whether the operator address is one of the delegates of the epoch
+func (sc *stakingCommittee) IsActiveDelegate(ctx context.Context, addr address.Address, epochNum uint64) (bool, error) {
+	return sc.governanceStaking.IsActiveDelegate(ctx, addr, epochNum)
+}
+
 // CandidatesByHeight returns candidate list from state factory according to height
 func (sc *stakingCommittee) CandidatesByHeight(ctx context.Context, height uint64) (state.CandidateList, error) {
 	return sc.governanceStaking.CandidatesByHeight(ctx, height)
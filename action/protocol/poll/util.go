@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-proto/golang/iotextypes"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -46,13 +47,15 @@ func validateDelegates(cs state.CandidateList) error {
 }
 
 func handle(ctx context.Context, act action.Action, sm protocol.StateManager, indexer *CandidateIndexer, protocolAddr string) (*action.Receipt, error) {
-	actionCtx := protocol.MustGetActionCtx(ctx)
-	blkCtx := protocol.MustGetBlockCtx(ctx)
-
 	r, ok := act.(*action.PutPollResult)
 	if !ok {
 		return nil, nil
 	}
+	if sm == nil {
+		return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to handle put poll result")
+	}
+	actionCtx := protocol.MustGetActionCtx(ctx)
+	blkCtx := protocol.MustGetBlockCtx(ctx)
 	zap.L().Debug("Handle PutPollResult Action", zap.Uint64("height", r.Height()))
 
 	if err := setCandidates(ctx, sm, indexer, r.Candidates(), r.Height()); err != nil {
@@ -103,6 +106,72 @@ func validate(ctx context.Context, p Protocol, act action.Action) error {
 	return nil
 }
 
+func delegateDiff(ctx context.Context, p Protocol, fromEpoch, toEpoch uint64) (state.CandidateList, state.CandidateList, error) {
+	from, err := p.DelegatesByEpoch(ctx, fromEpoch)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get delegates of epoch %d", fromEpoch)
+	}
+	to, err := p.DelegatesByEpoch(ctx, toEpoch)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get delegates of epoch %d", toEpoch)
+	}
+	fromSet := make(map[string]struct{}, len(from))
+	for _, d := range from {
+		fromSet[d.Address] = struct{}{}
+	}
+	toSet := make(map[string]struct{}, len(to))
+	for _, d := range to {
+		toSet[d.Address] = struct{}{}
+	}
+	var added, removed state.CandidateList
+	for _, d := range to {
+		if _, ok := fromSet[d.Address]; !ok {
+			added = append(added, d)
+		}
+	}
+	for _, d := range from {
+		if _, ok := toSet[d.Address]; !ok {
+			removed = append(removed, d)
+		}
+	}
+	return added, removed, nil
+}
+
+func isActiveDelegate(ctx context.Context, p Protocol, addr address.Address, epochNum uint64) (bool, error) {
+	delegates, err := p.DelegatesByEpoch(ctx, epochNum)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get delegates of epoch %d", epochNum)
+	}
+	addrStr := addr.String()
+	for _, d := range delegates {
+		if d.Address == addrStr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func rewardAddressesByEpoch(ctx context.Context, p Protocol, epochNum uint64) (map[string]address.Address, error) {
+	delegates, err := p.DelegatesByEpoch(ctx, epochNum)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get delegates of epoch %d", epochNum)
+	}
+	rewardAddrs := make(map[string]address.Address, len(delegates))
+	for _, d := range delegates {
+		// the reward goes to the owner if no reward address is set
+		addrStr := d.RewardAddress
+		if addrStr == "" {
+			addrStr = d.Address
+		}
+		addr, err := address.FromString(addrStr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid reward address of delegate %s", d.Address)
+		}
+		rewardAddrs[d.Address] = addr
+	}
+	return rewardAddrs, nil
+}
+
 func createPostSystemActions(ctx context.Context, p Protocol) ([]action.Envelope, error) {
 	blkCtx := protocol.MustGetBlockCtx(ctx)
 	bcCtx := protocol.MustGetBlockchainCtx(ctx)
@@ -17,6 +17,8 @@ import (
 var (
 	// ErrUnimplemented indicates a method is not implemented yet
 	ErrUnimplemented = errors.New("method is unimplemented")
+	// ErrNilStateManager indicates an action is handled without a state manager
+	ErrNilStateManager = errors.New("state manager is nil")
 )
 
 const (
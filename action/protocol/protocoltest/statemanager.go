@@ -0,0 +1,141 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+// Package protocoltest provides conformance tests which implementations of the interfaces in package protocol,
+// including the mocks used by the protocol tests, can run to verify the guarantees the protocols rely on
+package protocoltest
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/state"
+)
+
+const _testNameSpace = "StateManagerTest"
+
+type testState []byte
+
+func (s testState) Serialize() ([]byte, error) { return []byte(s), nil }
+
+func (s *testState) Deserialize(data []byte) error {
+	*s = append((*s)[:0], data...)
+	return nil
+}
+
+// TestStateManager verifies that the state managers created by newSM, which must be empty, implement the guarantees
+// of protocol.StateManager: read-your-writes, snapshot isolation, and revert
+func TestStateManager(t *testing.T, newSM func() protocol.StateManager) {
+	t.Run("ReadYourWrites", func(t *testing.T) {
+		require := require.New(t)
+		sm := newSM()
+		requireState(require, sm, "key", nil)
+
+		put(require, sm, "key", "v1")
+		requireState(require, sm, "key", []byte("v1"))
+		put(require, sm, "key", "v2")
+		requireState(require, sm, "key", []byte("v2"))
+		put(require, sm, "other", "v3")
+		requireState(require, sm, "key", []byte("v2"))
+		requireState(require, sm, "other", []byte("v3"))
+
+		del(require, sm, "key")
+		requireState(require, sm, "key", nil)
+		requireState(require, sm, "other", []byte("v3"))
+		put(require, sm, "key", "v4")
+		requireState(require, sm, "key", []byte("v4"))
+	})
+
+	t.Run("SnapshotIsolation", func(t *testing.T) {
+		require := require.New(t)
+		sm := newSM()
+		put(require, sm, "updated", "v1")
+		put(require, sm, "deleted", "v1")
+		s1 := sm.Snapshot()
+
+		// the writes after a snapshot are visible right away
+		put(require, sm, "updated", "v2")
+		del(require, sm, "deleted")
+		put(require, sm, "created", "v2")
+		requireState(require, sm, "updated", []byte("v2"))
+		requireState(require, sm, "deleted", nil)
+		requireState(require, sm, "created", []byte("v2"))
+
+		// a nested snapshot is reverted to without affecting the earlier one
+		s2 := sm.Snapshot()
+		require.NotEqual(s1, s2)
+		put(require, sm, "updated", "v3")
+		put(require, sm, "deleted", "v3")
+		require.NoError(sm.Revert(s2))
+		requireState(require, sm, "updated", []byte("v2"))
+		requireState(require, sm, "deleted", nil)
+		requireState(require, sm, "created", []byte("v2"))
+
+		require.NoError(sm.Revert(s1))
+		requireState(require, sm, "updated", []byte("v1"))
+		requireState(require, sm, "deleted", []byte("v1"))
+		requireState(require, sm, "created", nil)
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		require := require.New(t)
+		sm := newSM()
+		s1 := sm.Snapshot()
+		put(require, sm, "key", "v1")
+		s2 := sm.Snapshot()
+		put(require, sm, "key", "v2")
+
+		// reverting to a snapshot discards the snapshots taken after it
+		require.NoError(sm.Revert(s1))
+		requireState(require, sm, "key", nil)
+		require.Error(sm.Revert(s2))
+		require.Error(sm.Revert(-1))
+
+		// the state manager keeps working after a revert
+		put(require, sm, "key", "v3")
+		requireState(require, sm, "key", []byte("v3"))
+		s3 := sm.Snapshot()
+		del(require, sm, "key")
+		require.NoError(sm.Revert(s3))
+		requireState(require, sm, "key", []byte("v3"))
+	})
+}
+
+func put(require *require.Assertions, sm protocol.StateManager, key, value string) {
+	_, err := sm.PutState(
+		testState(value),
+		protocol.NamespaceOption(_testNameSpace),
+		protocol.KeyOption([]byte(key)),
+	)
+	require.NoError(err)
+}
+
+func del(require *require.Assertions, sm protocol.StateManager, key string) {
+	_, err := sm.DelState(
+		protocol.NamespaceOption(_testNameSpace),
+		protocol.KeyOption([]byte(key)),
+	)
+	require.NoError(err)
+}
+
+// requireState checks the state of the key, where a nil value means the state does not exist
+func requireState(require *require.Assertions, sm protocol.StateManager, key string, value []byte) {
+	var s testState
+	_, err := sm.State(
+		&s,
+		protocol.NamespaceOption(_testNameSpace),
+		protocol.KeyOption([]byte(key)),
+	)
+	if value == nil {
+		require.Equal(state.ErrStateNotExist, errors.Cause(err), "key %s", key)
+		return
+	}
+	require.NoError(err, "key %s", key)
+	require.Equal(value, []byte(s), "key %s", key)
+}
@@ -42,29 +42,49 @@ var (
 // ProductivityByEpoch returns the number of produced blocks per delegate in an epoch
 type ProductivityByEpoch func(context.Context, uint64) (uint64, map[string]uint64, error)
 
+// WeightSnapshotByEpoch returns the weights of the candidates frozen at the start of an epoch, keyed by candidate address
+type WeightSnapshotByEpoch func(protocol.StateReader, uint64) (map[string]*big.Int, error)
+
+// Option is optional setting for rewarding protocol
+type Option func(*Protocol)
+
+// WeightSnapshotOption splits the epoch reward by the candidate weights frozen at the start of the epoch instead of the
+// live votes of the candidates
+func WeightSnapshotOption(weightSnapshotByEpoch WeightSnapshotByEpoch) Option {
+	return func(p *Protocol) {
+		p.weightSnapshotByEpoch = weightSnapshotByEpoch
+	}
+}
+
 // Protocol defines the protocol of the rewarding fund and the rewarding process. It allows the admin to config the
 // reward amount, users to donate tokens to the fund, block producers to grant them block and epoch reward and,
 // beneficiaries to claim the balance into their personal account.
 type Protocol struct {
-	productivityByEpoch ProductivityByEpoch
-	keyPrefix           []byte
-	addr                address.Address
+	productivityByEpoch   ProductivityByEpoch
+	weightSnapshotByEpoch WeightSnapshotByEpoch
+	keyPrefix             []byte
+	addr                  address.Address
 }
 
 // NewProtocol instantiates a rewarding protocol instance.
 func NewProtocol(
 	productivityByEpoch ProductivityByEpoch,
+	opts ...Option,
 ) *Protocol {
 	h := hash.Hash160b([]byte(protocolID))
 	addr, err := address.FromBytes(h[:])
 	if err != nil {
 		log.L().Panic("Error when constructing the address of rewarding protocol", zap.Error(err))
 	}
-	return &Protocol{
+	p := &Protocol{
 		productivityByEpoch: productivityByEpoch,
 		keyPrefix:           h[:],
 		addr:                addr,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // FindProtocol finds the registered protocol from registry
@@ -133,6 +153,14 @@ func (p *Protocol) Handle(
 	act action.Action,
 	sm protocol.StateManager,
 ) (*action.Receipt, error) {
+	switch act.(type) {
+	case *action.DepositToRewardingFund, *action.ClaimFromRewardingFund, *action.GrantReward:
+		if sm == nil {
+			return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to handle rewarding action")
+		}
+	default:
+		return nil, nil
+	}
 	// TODO: simplify the boilerplate
 	switch act := act.(type) {
 	case *action.DepositToRewardingFund:
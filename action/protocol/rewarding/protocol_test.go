@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -308,8 +309,8 @@ func TestProtocol_Handle(t *testing.T) {
 	)
 
 	// Deposit
-	db := action.DepositToRewardingFundBuilder{}
-	deposit := db.SetAmount(big.NewInt(1000000)).Build()
+	builder := action.DepositToRewardingFundBuilder{}
+	deposit := builder.SetAmount(big.NewInt(1000000)).Build()
 	eb1 := action.EnvelopeBuilder{}
 	e1 := eb1.SetNonce(0).
 		SetGasPrice(big.NewInt(0)).
@@ -438,3 +439,22 @@ func TestProtocol_Handle(t *testing.T) {
 		return nil
 	}).AnyTimes()
 }
+
+func TestProtocol_HandleNilStateManager(t *testing.T) {
+	p := NewProtocol(nil)
+	ctx := context.Background()
+
+	// non-matching action type returns before touching the state
+	tsf, err := action.NewTransfer(1, big.NewInt(1), identityset.Address(28).String(), nil, 100000, big.NewInt(0))
+	require.NoError(t, err)
+	receipt, err := p.Handle(ctx, tsf, nil)
+	require.NoError(t, err)
+	require.Nil(t, receipt)
+
+	// matching action type without state manager
+	builder := action.DepositToRewardingFundBuilder{}
+	deposit := builder.SetAmount(big.NewInt(1)).Build()
+	receipt, err = p.Handle(ctx, &deposit, nil)
+	require.Equal(t, protocol.ErrNilStateManager, errors.Cause(err))
+	require.Nil(t, receipt)
+}
@@ -167,6 +167,11 @@ func (p *Protocol) GrantEpochReward(
 		}
 	}
 	candidates := bcCtx.Candidates
+	if p.weightSnapshotByEpoch != nil {
+		if candidates, err = p.withFrozenWeights(sm, epochNum, candidates); err != nil {
+			return nil, err
+		}
+	}
 	addrs, amounts, err := p.splitEpochReward(epochStartHeight, sm, candidates, a.epochReward, a.numDelegatesForEpochReward, exemptAddrs, uqd)
 	if err != nil {
 		return nil, err
@@ -364,6 +369,32 @@ func (p *Protocol) updateRewardHistory(sm protocol.StateManager, prefix []byte,
 	return p.putState(sm, append(prefix, indexBytes[:]...), &rewardHistory{})
 }
 
+// withFrozenWeights replaces the votes of the candidates with the weights frozen at the start of the epoch, candidates
+// absent from the snapshot get no weight. The candidates are returned as is if no snapshot was taken for the epoch
+func (p *Protocol) withFrozenWeights(
+	sr protocol.StateReader,
+	epochNum uint64,
+	candidates []*state.Candidate,
+) ([]*state.Candidate, error) {
+	weights, err := p.weightSnapshotByEpoch(sr, epochNum)
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return candidates, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get candidate weights of epoch %d", epochNum)
+	}
+	frozen := make([]*state.Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		c := candidate.Clone()
+		c.Votes = big.NewInt(0)
+		if w, ok := weights[candidate.Address]; ok {
+			c.Votes.Set(w)
+		}
+		frozen = append(frozen, c)
+	}
+	return frozen, nil
+}
+
 func (p *Protocol) splitEpochReward(
 	epochStartHeight uint64,
 	sm protocol.StateManager,
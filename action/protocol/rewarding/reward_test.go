@@ -427,3 +427,42 @@ func TestProtocol_NoRewardAddr(t *testing.T) {
 	assert.Equal(t, identityset.Address(1).String(), rl.Addr)
 	assert.Equal(t, "5", rl.Amount)
 }
+
+func TestProtocol_WithFrozenWeights(t *testing.T) {
+	r := require.New(t)
+	candidates := []*state.Candidate{
+		{
+			Address:       identityset.Address(1).String(),
+			Votes:         big.NewInt(1000),
+			RewardAddress: identityset.Address(1).String(),
+		},
+		{
+			Address:       identityset.Address(2).String(),
+			Votes:         big.NewInt(2000),
+			RewardAddress: identityset.Address(2).String(),
+		},
+	}
+	p := NewProtocol(nil, WeightSnapshotOption(
+		func(_ protocol.StateReader, epochNum uint64) (map[string]*big.Int, error) {
+			if epochNum != 2 {
+				return nil, state.ErrStateNotExist
+			}
+			return map[string]*big.Int{identityset.Address(1).String(): big.NewInt(10)}, nil
+		},
+	))
+
+	// live votes are used if there is no snapshot
+	frozen, err := p.withFrozenWeights(nil, 1, candidates)
+	r.NoError(err)
+	r.Equal(candidates, frozen)
+
+	frozen, err = p.withFrozenWeights(nil, 2, candidates)
+	r.NoError(err)
+	r.Equal(2, len(frozen))
+	r.Equal(big.NewInt(10), frozen[0].Votes)
+	r.Zero(frozen[1].Votes.Sign())
+	r.Equal(candidates[1].RewardAddress, frozen[1].RewardAddress)
+	// the live candidates are untouched
+	r.Equal(big.NewInt(1000), candidates[0].Votes)
+	r.Equal(big.NewInt(2000), candidates[1].Votes)
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/pkg/log"
+	"github.com/iotexproject/iotex-core/state"
+)
+
+type (
+	// VoteMismatch is a candidate whose recorded votes or self-stake differ from those computed from its buckets
+	VoteMismatch struct {
+		Candidate         address.Address
+		Votes             *big.Int
+		ExpectedVotes     *big.Int
+		SelfStake         *big.Int
+		ExpectedSelfStake *big.Int
+	}
+
+	// IndexKind is the kind of a bucket index
+	IndexKind string
+
+	// IndexIssue is an entry of a bucket index that does not agree with the buckets, either an index of a bucket that
+	// does not exist or does not belong to the indexed address (dangling), or a bucket missing from the index of its
+	// owner or candidate
+	IndexIssue struct {
+		Kind IndexKind
+		// Address is the address the index is keyed by, the voter for the voter candidate index
+		Address address.Address
+		Index   uint64
+	}
+
+	// AuditReport enumerates the inconsistencies found in the staking state
+	AuditReport struct {
+		VoteMismatches []VoteMismatch
+		// DanglingIndexes are the index entries of buckets that do not exist or belong to another address
+		DanglingIndexes []IndexIssue
+		// MissingIndexes are the buckets not in the voter or candidate index they belong to
+		MissingIndexes []IndexIssue
+		// OrphanedBuckets are the indexes of the buckets staked to a candidate not registered
+		OrphanedBuckets []uint64
+		// BucketsBeyondCount are the indexes of the buckets not below the total bucket count, which are overwritten by
+		// the next buckets created
+		BucketsBeyondCount []uint64
+		// TotalStake is the sum of the amounts of the buckets not unstaked
+		TotalStake *big.Int
+	}
+
+	// Auditor receives the audit report of the staking state at the start of each epoch
+	Auditor func(epoch uint64, report *AuditReport)
+)
+
+// the kinds of bucket indexes
+const (
+	VoterIndex     IndexKind = "voter"
+	CandidateIndex IndexKind = "candidate"
+	VoterCandIndex IndexKind = "voterCandidate"
+)
+
+// AuditOption audits the staking state at the start of each epoch and passes the report to the auditor, so that
+// operators get an early warning of state corruption. The audit does not mutate the state, and a failure of it is
+// logged rather than failing the block
+func AuditOption(auditor Auditor) Option {
+	return func(p *Protocol) error {
+		if auditor == nil {
+			return errors.New("nil auditor")
+		}
+		p.auditor = auditor
+		return nil
+	}
+}
+
+// IsClean returns true if the audit found no inconsistency
+func (r *AuditReport) IsClean() bool {
+	return len(r.VoteMismatches) == 0 && len(r.DanglingIndexes) == 0 && len(r.MissingIndexes) == 0 &&
+		len(r.OrphanedBuckets) == 0 && len(r.BucketsBeyondCount) == 0
+}
+
+// AuditState checks the staking state for inconsistencies without mutating it: the votes and self-stake of each
+// candidate against its buckets, the bucket indexes against the buckets, the buckets of unregistered candidates, and
+// the buckets against the total bucket count. The votes are computed with the vote weight in effect in ctx.
+// The state is only read by key, so that the audit also runs on the working set of a block. The candidates are those
+// of the candidate center, the buckets are read by index up to the total bucket count and beyond it until an index
+// does not exist, and the indexes are read for the candidates and for the owners and candidates of the buckets
+func (p *Protocol) AuditState(ctx context.Context, sr protocol.StateReader) (*AuditReport, error) {
+	all, err := p.inMemCandidates.All()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get candidates")
+	}
+	registered := make(map[string]bool, len(all))
+	cands := make(CandidateList, 0, len(all))
+	for _, c := range all {
+		stored, err := getCandidate(sr, c.Owner)
+		switch errors.Cause(err) {
+		case nil:
+			registered[c.Owner.String()] = true
+			cands = append(cands, stored)
+		case state.ErrStateNotExist:
+		default:
+			return nil, errors.Wrapf(err, "failed to get candidate %s", c.Owner.String())
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		return cands[i].Owner.String() < cands[j].Owner.String()
+	})
+	count, err := getTotalBucketCount(sr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get total bucket count")
+	}
+
+	report := &AuditReport{TotalStake: big.NewInt(0)}
+	buckets := make(map[uint64]*VoteBucket)
+	var bucketIndexes []uint64
+	for index := uint64(0); ; index++ {
+		vb, err := getBucket(sr, index)
+		if errors.Cause(err) == state.ErrStateNotExist {
+			if index >= count {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get bucket %d", index)
+		}
+		// the bucket is audited under the index of its key
+		buckets[index] = vb
+		bucketIndexes = append(bucketIndexes, index)
+	}
+
+	indexes := make(map[IndexKind]map[string]map[uint64]bool)
+	for _, kind := range []IndexKind{VoterIndex, CandidateIndex} {
+		indexes[kind] = make(map[string]map[uint64]bool)
+	}
+	type indexEntry struct {
+		kind             IndexKind
+		voter, candidate address.Address
+		indices          BucketIndices
+	}
+	var entries []indexEntry
+	read := make(map[string]bool)
+	// readIndex reads the index of the voter if candidate is nil, of the candidate if voter is nil, or else of both
+	readIndex := func(kind IndexKind, voter, candidate address.Address) error {
+		var key []byte
+		switch kind {
+		case VoterIndex:
+			key = addrKeyWithPrefix(voter, _voterIndex)
+		case CandidateIndex:
+			key = addrKeyWithPrefix(candidate, _candIndex)
+		default:
+			key = voterCandKey(voter, candidate)
+		}
+		if read[string(key)] {
+			return nil
+		}
+		read[string(key)] = true
+		bis, err := getBucketIndices(sr, key)
+		switch errors.Cause(err) {
+		case nil:
+			entries = append(entries, indexEntry{kind: kind, voter: voter, candidate: candidate, indices: *bis})
+			return nil
+		case state.ErrStateNotExist:
+			return nil
+		default:
+			return errors.Wrapf(err, "failed to get bucket indices of key %x", key)
+		}
+	}
+	for _, c := range cands {
+		if err := readIndex(CandidateIndex, nil, c.Owner); err != nil {
+			return nil, err
+		}
+	}
+	for _, index := range bucketIndexes {
+		vb := buckets[index]
+		if err := readIndex(VoterIndex, vb.Owner, nil); err != nil {
+			return nil, err
+		}
+		if err := readIndex(CandidateIndex, nil, vb.Candidate); err != nil {
+			return nil, err
+		}
+		if err := readIndex(VoterCandIndex, vb.Owner, vb.Candidate); err != nil {
+			return nil, err
+		}
+	}
+
+	// dangling indexes
+	for _, e := range entries {
+		addr := e.voter
+		if e.kind == CandidateIndex {
+			addr = e.candidate
+		}
+		for _, index := range e.indices {
+			if indexed, ok := indexes[e.kind]; ok {
+				if indexed[addr.String()] == nil {
+					indexed[addr.String()] = make(map[uint64]bool)
+				}
+				indexed[addr.String()][index] = true
+			}
+			vb, ok := buckets[index]
+			if !ok ||
+				(e.voter != nil && !address.Equal(vb.Owner, e.voter)) ||
+				(e.candidate != nil && !address.Equal(vb.Candidate, e.candidate)) {
+				report.DanglingIndexes = append(report.DanglingIndexes, IndexIssue{
+					Kind:    e.kind,
+					Address: addr,
+					Index:   index,
+				})
+			}
+		}
+	}
+
+	// missing indexes, orphaned buckets and buckets beyond the count. The voter candidate index is not checked for
+	// missing entries, as the buckets created before it was introduced are not indexed
+	for _, index := range bucketIndexes {
+		vb := buckets[index]
+		if !indexes[VoterIndex][vb.Owner.String()][index] {
+			report.MissingIndexes = append(report.MissingIndexes, IndexIssue{
+				Kind:    VoterIndex,
+				Address: vb.Owner,
+				Index:   index,
+			})
+		}
+		if !indexes[CandidateIndex][vb.Candidate.String()][index] {
+			report.MissingIndexes = append(report.MissingIndexes, IndexIssue{
+				Kind:    CandidateIndex,
+				Address: vb.Candidate,
+				Index:   index,
+			})
+		}
+		candidate := vb.Candidate.String()
+		ok, checked := registered[candidate]
+		if !checked {
+			// a candidate missing from the candidate center is looked up by key
+			switch _, err := getCandidate(sr, vb.Candidate); errors.Cause(err) {
+			case nil:
+				ok = true
+			case state.ErrStateNotExist:
+			default:
+				return nil, errors.Wrapf(err, "failed to get candidate %s", candidate)
+			}
+			registered[candidate] = ok
+		}
+		if !ok {
+			report.OrphanedBuckets = append(report.OrphanedBuckets, index)
+		}
+		if index >= count {
+			report.BucketsBeyondCount = append(report.BucketsBeyondCount, index)
+		}
+		if vb.UnstakeStartTime.Unix() == 0 {
+			report.TotalStake.Add(report.TotalStake, vb.StakedAmount)
+		}
+	}
+
+	// vote mismatches
+	for _, c := range cands {
+		votes, selfStake := big.NewInt(0), big.NewInt(0)
+		for _, index := range bucketIndexes {
+			vb := buckets[index]
+			if !address.Equal(vb.Candidate, c.Owner) || vb.UnstakeStartTime.Unix() != 0 {
+				continue
+			}
+			isSelfStake := index == c.SelfStakeBucketIdx && c.SelfStake.Sign() > 0
+			votes.Add(votes, p.calculateVoteWeight(ctx, vb, isSelfStake))
+			if index == c.SelfStakeBucketIdx {
+				selfStake = vb.StakedAmount
+			}
+		}
+		if c.Votes.Cmp(votes) != 0 || c.SelfStake.Cmp(selfStake) != 0 {
+			report.VoteMismatches = append(report.VoteMismatches, VoteMismatch{
+				Candidate:         c.Owner,
+				Votes:             c.Votes,
+				ExpectedVotes:     votes,
+				SelfStake:         c.SelfStake,
+				ExpectedSelfStake: selfStake,
+			})
+		}
+	}
+	return report, nil
+}
+
+// audit runs the audit of the epoch and passes the report to the auditor
+func (p *Protocol) audit(ctx context.Context, sr protocol.StateReader, epoch uint64) {
+	report, err := p.AuditState(ctx, sr)
+	if err != nil {
+		log.L().Error("Failed to audit staking state", zap.Uint64("epoch", epoch), zap.Error(err))
+		return
+	}
+	if !report.IsClean() {
+		log.L().Warn("Staking state is inconsistent",
+			zap.Uint64("epoch", epoch),
+			zap.Int("voteMismatches", len(report.VoteMismatches)),
+			zap.Int("danglingIndexes", len(report.DanglingIndexes)),
+			zap.Int("missingIndexes", len(report.MissingIndexes)),
+			zap.Int("orphanedBuckets", len(report.OrphanedBuckets)),
+			zap.Int("bucketsBeyondCount", len(report.BucketsBeyondCount)))
+	}
+	p.auditor(epoch, report)
+}
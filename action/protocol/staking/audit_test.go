@@ -0,0 +1,155 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
+	"github.com/iotexproject/iotex-core/blockchain/genesis"
+	"github.com/iotexproject/iotex-core/test/identityset"
+)
+
+func TestProtocol_AuditState(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	var (
+		reports []*AuditReport
+		epochs  []uint64
+	)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, AuditOption(func(epoch uint64, report *AuditReport) {
+		epochs = append(epochs, epoch)
+		reports = append(reports, report)
+	}))
+	r.NoError(err)
+	ctx := context.Background()
+
+	// a consistent state of 2 candidates, each with a self-stake bucket and a voter bucket
+	owner1, owner2, voter := identityset.Address(1), identityset.Address(2), identityset.Address(3)
+	cands := make(map[string]*Candidate)
+	for i, owner := range []address.Address{owner1, owner2} {
+		c := &Candidate{
+			Owner:     owner,
+			Operator:  identityset.Address(i + 11),
+			Reward:    owner,
+			Name:      []string{"test1", "test2"}[i],
+			Votes:     big.NewInt(0),
+			SelfStake: big.NewInt(0),
+		}
+		for _, staker := range []address.Address{owner, voter} {
+			vb := NewVoteBucket(owner, staker, big.NewInt(100), 7, time.Now(), true)
+			index, err := putBucketAndIndex(sm, vb)
+			r.NoError(err)
+			r.NoError(putVoterCandBucketIndex(sm, staker, owner, index))
+			selfStake := address.Equal(staker, owner)
+			if selfStake {
+				c.SelfStakeBucketIdx = index
+				c.SelfStake = vb.StakedAmount
+			}
+			c.Votes.Add(c.Votes, p.calculateVoteWeight(ctx, vb, selfStake))
+		}
+		r.NoError(putCandidate(sm, c))
+		r.NoError(p.inMemCandidates.Upsert(c))
+		cands[owner.String()] = c
+	}
+	report, err := p.AuditState(ctx, sm)
+	r.NoError(err)
+	r.True(report.IsClean())
+	r.Equal(big.NewInt(400), report.TotalStake)
+
+	// a candidate with wrong votes
+	c := cands[owner1.String()]
+	votes := new(big.Int).Set(c.Votes)
+	c.Votes = new(big.Int).Add(votes, big.NewInt(1))
+	r.NoError(putCandidate(sm, c))
+	// a bucket deleted without its indexes (bucket 3, voter's bucket of owner2)
+	r.NoError(delBucket(sm, 3))
+	// a bucket not indexed
+	unindexed, err := putBucket(sm, NewVoteBucket(owner2, voter, big.NewInt(10), 7, time.Now(), true))
+	r.NoError(err)
+	// a bucket of an unregistered candidate
+	orphaned, err := putBucketAndIndex(sm, NewVoteBucket(identityset.Address(4), voter, big.NewInt(10), 7, time.Now(), true))
+	r.NoError(err)
+	// a bucket beyond the total bucket count
+	beyond, err := getTotalBucketCount(sm)
+	r.NoError(err)
+	_, err = sm.PutState(
+		NewVoteBucket(owner1, owner1, big.NewInt(10), 7, time.Now(), true),
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(bucketKey(beyond)))
+	r.NoError(err)
+
+	report, err = p.AuditState(ctx, sm)
+	r.NoError(err)
+	r.False(report.IsClean())
+	// the votes of owner2 lack the deleted bucket
+	r.Len(report.VoteMismatches, 2)
+	expectedVotes := map[string]*big.Int{
+		owner1.String(): new(big.Int).Add(votes, p.calculateVoteWeight(ctx, NewVoteBucket(owner1, owner1, big.NewInt(10), 7, time.Now(), true), false)),
+		owner2.String(): new(big.Int).Sub(cands[owner2.String()].Votes, p.calculateVoteWeight(ctx, NewVoteBucket(owner2, voter, big.NewInt(100), 7, time.Now(), true), false)),
+	}
+	expectedVotes[owner2.String()].Add(expectedVotes[owner2.String()], p.calculateVoteWeight(ctx, NewVoteBucket(owner2, voter, big.NewInt(10), 7, time.Now(), true), false))
+	for _, m := range report.VoteMismatches {
+		c := cands[m.Candidate.String()]
+		r.Equal(c.Votes, m.Votes)
+		r.Equal(expectedVotes[m.Candidate.String()], m.ExpectedVotes)
+		r.Equal(c.SelfStake, m.SelfStake)
+		r.Equal(c.SelfStake, m.ExpectedSelfStake)
+	}
+	r.ElementsMatch([]IndexIssue{
+		{Kind: VoterIndex, Address: voter, Index: 3},
+		{Kind: CandidateIndex, Address: owner2, Index: 3},
+		{Kind: VoterCandIndex, Address: voter, Index: 3},
+	}, report.DanglingIndexes)
+	r.ElementsMatch([]IndexIssue{
+		{Kind: VoterIndex, Address: voter, Index: unindexed},
+		{Kind: CandidateIndex, Address: owner2, Index: unindexed},
+		{Kind: VoterIndex, Address: owner1, Index: beyond},
+		{Kind: CandidateIndex, Address: owner1, Index: beyond},
+	}, report.MissingIndexes)
+	r.Equal([]uint64{orphaned}, report.OrphanedBuckets)
+	r.Equal([]uint64{beyond}, report.BucketsBeyondCount)
+	r.Equal(big.NewInt(330), report.TotalStake)
+
+	// the audit runs at the start of each epoch, an epoch lasts 10 blocks
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	blkCtx := func(height uint64) context.Context {
+		ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+			Genesis:  genesis.Default,
+			Registry: registry,
+		})
+		return protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+	}
+	r.NoError(p.CreatePreStates(blkCtx(5), sm))
+	r.Empty(reports)
+	r.NoError(p.CreatePreStates(blkCtx(11), sm))
+	r.Equal([]uint64{2}, epochs)
+	r.Equal(report, reports[0])
+}
@@ -143,6 +143,37 @@ func delCandBucketIndex(sm protocol.StateManager, addr address.Address, index ui
 	return delBucketIndex(sm, addrKeyWithPrefix(addr, _candIndex), index)
 }
 
+// voterCandKey is the prefix followed by the candidate and the voter address, so that the buckets of a voter staked
+// to a candidate are looked up without scanning all the buckets of the voter
+func voterCandKey(voter, candidate address.Address) []byte {
+	return append(addrKeyWithPrefix(candidate, _voterCandIndex), voter.Bytes()...)
+}
+
+func getVoterCandBucketIndices(sr protocol.StateReader, voter, candidate address.Address) (*BucketIndices, error) {
+	return getBucketIndices(sr, voterCandKey(voter, candidate))
+}
+
+func putVoterCandBucketIndex(sm protocol.StateManager, voter, candidate address.Address, index uint64) error {
+	return putBucketIndex(sm, voterCandKey(voter, candidate), index)
+}
+
+func delVoterCandBucketIndex(sm protocol.StateManager, voter, candidate address.Address, index uint64) error {
+	return delBucketIndex(sm, voterCandKey(voter, candidate), index)
+}
+
+// putAllVoterCandBucketIndices indexes all the buckets by voter and candidate, in the order of the bucket indexes. It
+// backfills the index at Greenland, as the buckets created before are not indexed
+func putAllVoterCandBucketIndices(sm protocol.StateManager) error {
+	return forEachBucket(sm, func(vb *VoteBucket) error {
+		if err := putVoterCandBucketIndex(sm, vb.Owner, vb.Candidate, vb.Index); err != nil {
+			return errors.Wrapf(err, "failed to put bucket index for voter %s and candidate %s", vb.Owner.String(), vb.Candidate.String())
+		}
+		return nil
+	})
+}
+
+// addrKeyWithPrefix is the 1-byte tag followed by the address bytes, the keys of voter and candidate bucket indices
+// have a fixed length like the bucket keys, so range scans over them are ordered by address
 func addrKeyWithPrefix(addr address.Address, prefix byte) []byte {
 	k := addr.Bytes()
 	key := make([]byte, len(k)+1)
@@ -10,6 +10,7 @@ import (
 	"math/big"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/iotexproject/iotex-address/address"
@@ -18,19 +19,22 @@ import (
 
 	"github.com/iotexproject/iotex-core/action/protocol"
 	"github.com/iotexproject/iotex-core/action/protocol/staking/stakingpb"
+	"github.com/iotexproject/iotex-core/pkg/util/byteutil"
 	"github.com/iotexproject/iotex-core/state"
 )
 
 type (
 	// Candidate represents the candidate
 	Candidate struct {
-		Owner              address.Address
-		Operator           address.Address
-		Reward             address.Address
-		Name               string
-		Votes              *big.Int
-		SelfStakeBucketIdx uint64
-		SelfStake          *big.Int
+		Owner                address.Address
+		Operator             address.Address
+		Reward               address.Address
+		Name                 string
+		Votes                *big.Int
+		SelfStakeBucketIdx   uint64
+		SelfStake            *big.Int
+		LastUpdateHeight     uint64
+		OperatorUpdateHeight uint64
 	}
 
 	// CandidateList is a list of candidates which is sortable
@@ -38,8 +42,14 @@ type (
 
 	// RegistrationConsts are the registration fee and min self stake
 	RegistrationConsts struct {
-		Fee          *big.Int
-		MinSelfStake *big.Int
+		Fee                  *big.Int
+		MinSelfStake         *big.Int
+		MinSelfStakeDuration time.Duration
+	}
+
+	// registrationCount stores the number of candidates registered in the current epoch
+	registrationCount struct {
+		count uint64
 	}
 )
 
@@ -49,13 +59,15 @@ func (d *Candidate) Clone() *Candidate {
 	s := new(big.Int).Set(d.SelfStake)
 
 	return &Candidate{
-		Owner:              d.Owner,
-		Operator:           d.Operator,
-		Reward:             d.Reward,
-		Name:               d.Name,
-		Votes:              v,
-		SelfStakeBucketIdx: d.SelfStakeBucketIdx,
-		SelfStake:          s,
+		Owner:                d.Owner,
+		Operator:             d.Operator,
+		Reward:               d.Reward,
+		Name:                 d.Name,
+		Votes:                v,
+		SelfStakeBucketIdx:   d.SelfStakeBucketIdx,
+		SelfStake:            s,
+		LastUpdateHeight:     d.LastUpdateHeight,
+		OperatorUpdateHeight: d.OperatorUpdateHeight,
 	}
 }
 
@@ -103,7 +115,8 @@ func (d *Candidate) SubSelfStake(amount *big.Int) error {
 	return nil
 }
 
-// Serialize serializes candidate to bytes
+// Serialize serializes candidate to bytes. The fields added by upgrades are left out of the bytes while zero and are
+// written only since the upgrade, so that the candidates keep their bytes, and the state root, before the upgrade
 func (d *Candidate) Serialize() ([]byte, error) {
 	pb, err := d.toProto()
 	if err != nil {
@@ -128,13 +141,15 @@ func (d *Candidate) toProto() (*stakingpb.Candidate, error) {
 	}
 
 	return &stakingpb.Candidate{
-		OwnerAddress:       d.Owner.String(),
-		OperatorAddress:    d.Operator.String(),
-		RewardAddress:      d.Reward.String(),
-		Name:               d.Name,
-		Votes:              d.Votes.String(),
-		SelfStakeBucketIdx: d.SelfStakeBucketIdx,
-		SelfStake:          d.SelfStake.String(),
+		OwnerAddress:         d.Owner.String(),
+		OperatorAddress:      d.Operator.String(),
+		RewardAddress:        d.Reward.String(),
+		Name:                 d.Name,
+		Votes:                d.Votes.String(),
+		SelfStakeBucketIdx:   d.SelfStakeBucketIdx,
+		SelfStake:            d.SelfStake.String(),
+		LastUpdateHeight:     d.LastUpdateHeight,
+		OperatorUpdateHeight: d.OperatorUpdateHeight,
 	}, nil
 }
 
@@ -171,6 +186,9 @@ func (d *Candidate) fromProto(pb *stakingpb.Candidate) error {
 	if !ok {
 		return ErrInvalidAmount
 	}
+	// fields added after the initial release default to zero value for legacy records
+	d.LastUpdateHeight = pb.GetLastUpdateHeight()
+	d.OperatorUpdateHeight = pb.GetOperatorUpdateHeight()
 	return nil
 }
 
@@ -293,3 +311,34 @@ func getCandidateByName(sr protocol.StateReader, name string) (*Candidate, error
 	}
 	return nil, nil
 }
+
+// Deserialize deserializes bytes into registration count
+func (rc *registrationCount) Deserialize(data []byte) error {
+	rc.count = byteutil.BytesToUint64BigEndian(data)
+	return nil
+}
+
+// Serialize serializes registration count into bytes
+func (rc *registrationCount) Serialize() ([]byte, error) {
+	return byteutil.Uint64ToBytesBigEndian(rc.count), nil
+}
+
+func getRegistrationCount(sr protocol.StateReader) (uint64, error) {
+	var rc registrationCount
+	_, err := sr.State(
+		&rc,
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(RegistrationCountKey))
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return 0, nil
+	}
+	return rc.count, err
+}
+
+func putRegistrationCount(sm protocol.StateManager, count uint64) error {
+	_, err := sm.PutState(
+		&registrationCount{count: count},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(RegistrationCountKey))
+	return err
+}
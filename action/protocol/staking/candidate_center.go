@@ -8,6 +8,7 @@ package staking
 
 import (
 	"github.com/iotexproject/iotex-address/address"
+	"github.com/pkg/errors"
 )
 
 type (
@@ -17,6 +18,21 @@ type (
 		ownerMap         map[string]*Candidate
 		operatorMap      map[string]*Candidate
 		selfStkBucketMap map[uint64]*Candidate
+		journal          *candidateJournal
+	}
+
+	// candidateJournal records the changes of candidates since the oldest snapshot of the state manager, so that a
+	// snapshot costs as much as the changes after it rather than a copy of all candidates
+	candidateJournal struct {
+		changes []candidateChange
+		// number of changes recorded at each snapshot, keyed by snapshot id
+		snapshots map[int]int
+	}
+
+	// candidateChange is the candidate of an owner before a change, nil if the owner had no candidate
+	candidateChange struct {
+		owner string
+		prev  *Candidate
 	}
 )
 
@@ -27,6 +43,9 @@ func NewCandidateCenter() *CandidateCenter {
 		ownerMap:         make(map[string]*Candidate),
 		operatorMap:      make(map[string]*Candidate),
 		selfStkBucketMap: make(map[uint64]*Candidate),
+		journal: &candidateJournal{
+			snapshots: make(map[int]int),
+		},
 	}
 }
 
@@ -87,6 +106,30 @@ func (m CandidateCenter) GetByOwner(owner address.Address) *Candidate {
 	return nil
 }
 
+// GetByOperator returns the candidate by operator
+func (m CandidateCenter) GetByOperator(operator address.Address) *Candidate {
+	if operator == nil {
+		return nil
+	}
+	if d, ok := m.operatorMap[operator.String()]; ok {
+		return d.Clone()
+	}
+	return nil
+}
+
+// ResolveCandidate returns the candidate by name, or by owner if the identifier is an address
+func (m CandidateCenter) ResolveCandidate(identifier string) (*Candidate, error) {
+	if d := m.GetByName(identifier); d != nil {
+		return d, nil
+	}
+	if owner, err := address.FromString(identifier); err == nil {
+		if d := m.GetByOwner(owner); d != nil {
+			return d, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrCandidateNotExist, "no candidate of name or owner %s", identifier)
+}
+
 // GetBySelfStakingIndex returns the candidate by self-staking index
 func (m CandidateCenter) GetBySelfStakingIndex(index uint64) *Candidate {
 	if d, ok := m.selfStkBucketMap[index]; ok {
@@ -101,32 +144,88 @@ func (m CandidateCenter) Upsert(d *Candidate) error {
 		return err
 	}
 
-	if c, ok := m.ownerMap[d.Owner.String()]; ok {
-		delete(m.nameMap, c.Name)
-		delete(m.operatorMap, c.Operator.String())
-		delete(m.selfStkBucketMap, c.SelfStakeBucketIdx)
-	}
-
-	m.nameMap[d.Name] = d
-	m.ownerMap[d.Owner.String()] = d
-	m.operatorMap[d.Operator.String()] = d
-	m.selfStkBucketMap[d.SelfStakeBucketIdx] = d
+	m.record(d.Owner.String())
+	m.remove(d.Owner.String())
+	m.put(d)
 	return nil
 }
 
 // Delete deletes the candidate by name
 func (m CandidateCenter) Delete(owner address.Address) {
-	d, ok := m.ownerMap[owner.String()]
+	if _, ok := m.ownerMap[owner.String()]; !ok {
+		return
+	}
+
+	m.record(owner.String())
+	m.remove(owner.String())
+}
+
+// Snapshot marks the candidates at the snapshot of the state manager
+func (m CandidateCenter) Snapshot(snapshot int) {
+	m.journal.snapshots[snapshot] = len(m.journal.changes)
+}
+
+// Revert restores the candidates to those at the snapshot of the state manager, by undoing the changes after it in
+// reverse order. The snapshots taken after it are dropped
+func (m CandidateCenter) Revert(snapshot int) error {
+	pos, ok := m.journal.snapshots[snapshot]
+	if !ok {
+		return errors.Errorf("failed to get candidates at snapshot %d", snapshot)
+	}
+	for i := len(m.journal.changes) - 1; i >= pos; i-- {
+		change := m.journal.changes[i]
+		m.remove(change.owner)
+		if change.prev != nil {
+			m.put(change.prev)
+		}
+	}
+	m.journal.changes = m.journal.changes[:pos]
+	for k := range m.journal.snapshots {
+		if k > snapshot {
+			delete(m.journal.snapshots, k)
+		}
+	}
+	return nil
+}
+
+func (m CandidateCenter) clearSnapshots() {
+	m.journal.changes = nil
+	for k := range m.journal.snapshots {
+		delete(m.journal.snapshots, k)
+	}
+}
+
+// record saves the candidate of the owner before a change, if there is a snapshot to revert to
+func (m CandidateCenter) record(owner string) {
+	if len(m.journal.snapshots) == 0 {
+		return
+	}
+	var prev *Candidate
+	if c, ok := m.ownerMap[owner]; ok {
+		prev = c.Clone()
+	}
+	m.journal.changes = append(m.journal.changes, candidateChange{owner: owner, prev: prev})
+}
+
+func (m CandidateCenter) remove(owner string) {
+	d, ok := m.ownerMap[owner]
 	if !ok {
 		return
 	}
 
 	delete(m.nameMap, d.Name)
-	delete(m.ownerMap, d.Owner.String())
+	delete(m.ownerMap, owner)
 	delete(m.operatorMap, d.Operator.String())
 	delete(m.selfStkBucketMap, d.SelfStakeBucketIdx)
 }
 
+func (m CandidateCenter) put(d *Candidate) {
+	m.nameMap[d.Name] = d
+	m.ownerMap[d.Owner.String()] = d
+	m.operatorMap[d.Operator.String()] = d
+	m.selfStkBucketMap[d.SelfStakeBucketIdx] = d
+}
+
 func (m CandidateCenter) checkCollision(d *Candidate) error {
 	if c, ok := m.nameMap[d.Name]; ok {
 		if c.Owner.String() != d.Owner.String() {
@@ -11,9 +11,11 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/iotexproject/iotex-core/action/protocol/staking/stakingpb"
 	"github.com/iotexproject/iotex-core/pkg/unit"
 	"github.com/iotexproject/iotex-core/state"
 	"github.com/iotexproject/iotex-core/test/identityset"
@@ -161,6 +163,21 @@ func TestCandCenter(t *testing.T) {
 		}
 	}
 
+	// test resolve by name or owner
+	for _, v := range testCandidates {
+		d, err := m.ResolveCandidate(v.d.Name)
+		r.NoError(err)
+		r.Equal(v.d, d)
+		d, err = m.ResolveCandidate(v.d.Owner.String())
+		r.NoError(err)
+		r.Equal(v.d, d)
+	}
+	for _, id := range []string{"", "xxx", identityset.Address(22).String(), testCandidates[0].d.Operator.String()} {
+		d, err := m.ResolveCandidate(id)
+		r.Nil(d)
+		r.Equal(ErrCandidateNotExist, errors.Cause(err))
+	}
+
 	// cannot insert candidate with conflicting name/operator/self-staking index
 	old := testCandidates[0].d
 	conflict := m.GetByName(old.Name)
@@ -197,6 +214,48 @@ func TestCandCenter(t *testing.T) {
 	}
 }
 
+func TestCandCenterSnapshot(t *testing.T) {
+	r := require.New(t)
+
+	m := NewCandidateCenter()
+	c0, c1 := testCandidates[0].d.Clone(), testCandidates[1].d.Clone()
+	r.NoError(m.Upsert(c0.Clone()))
+	// nothing is recorded without a snapshot
+	r.Empty(m.journal.changes)
+
+	m.Snapshot(0)
+	r.NoError(m.Upsert(c1.Clone()))
+	renamed := c0.Clone()
+	renamed.Name = "xxx"
+	renamed.Votes = big.NewInt(100)
+	r.NoError(m.Upsert(renamed.Clone()))
+	m.Snapshot(1)
+	m.Delete(c0.Owner)
+	// only the changes are recorded, not a copy of all candidates per snapshot
+	r.Equal(3, len(m.journal.changes))
+
+	r.NoError(m.Revert(1))
+	r.Equal(2, m.Size())
+	r.Equal(renamed, m.GetByOwner(c0.Owner))
+	r.False(m.ContainsName(c0.Name))
+
+	r.NoError(m.Revert(0))
+	r.Equal(1, m.Size())
+	r.Equal(c0, m.GetByName(c0.Name))
+	r.False(m.ContainsName("xxx"))
+	r.False(m.ContainsOwner(c1.Owner))
+	r.False(m.ContainsOperator(c1.Operator))
+	r.False(m.ContainsSelfStakingBucket(c1.SelfStakeBucketIdx))
+	r.Empty(m.journal.changes)
+	// the snapshot taken after the reverted one is dropped
+	r.Error(m.Revert(1))
+
+	m.clearSnapshots()
+	r.Error(m.Revert(0))
+	r.NoError(m.Upsert(c1.Clone()))
+	r.Empty(m.journal.changes)
+}
+
 func TestGetPutCandidate(t *testing.T) {
 	require := require.New(t)
 
@@ -221,3 +280,44 @@ func TestGetPutCandidate(t *testing.T) {
 		require.Equal(state.ErrStateNotExist, errors.Cause(err))
 	}
 }
+
+func TestDeserializeLegacyCandidate(t *testing.T) {
+	r := require.New(t)
+
+	// a candidate serialized before the field lastUpdateHeight was added
+	pb := &stakingpb.Candidate{
+		OwnerAddress:       identityset.Address(1).String(),
+		OperatorAddress:    identityset.Address(2).String(),
+		RewardAddress:      identityset.Address(3).String(),
+		Name:               "legacy",
+		Votes:              "100",
+		SelfStakeBucketIdx: 7,
+		SelfStake:          "1200000",
+	}
+	legacy, err := proto.Marshal(pb)
+	r.NoError(err)
+
+	d := &Candidate{}
+	r.NoError(d.Deserialize(legacy))
+	r.Equal(identityset.Address(1).String(), d.Owner.String())
+	r.Equal(identityset.Address(2).String(), d.Operator.String())
+	r.Equal(identityset.Address(3).String(), d.Reward.String())
+	r.Equal("legacy", d.Name)
+	r.Equal(big.NewInt(100), d.Votes)
+	r.Equal(uint64(7), d.SelfStakeBucketIdx)
+	r.Equal(big.NewInt(1200000), d.SelfStake)
+	// new fields default to zero value
+	r.Zero(d.LastUpdateHeight)
+
+	// a candidate without new fields serializes to the same bytes
+	ser, err := d.Serialize()
+	r.NoError(err)
+	r.Equal(legacy, ser)
+
+	d.LastUpdateHeight = 10
+	ser, err = d.Serialize()
+	r.NoError(err)
+	d1 := &Candidate{}
+	r.NoError(d1.Deserialize(ser))
+	r.Equal(d, d1)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-election/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/pkg/log"
+	"github.com/iotexproject/iotex-core/pkg/util/byteutil"
+	"github.com/iotexproject/iotex-core/state"
+)
+
+// _electionImportKey is the key of the time at which the election result is imported
+var _electionImportKey = append([]byte{_const}, []byte("electionImport")...)
+
+// electionImport records the time at which the election result is imported
+type electionImport struct {
+	timestamp int64
+}
+
+// Deserialize deserializes bytes into election import
+func (ei *electionImport) Deserialize(data []byte) error {
+	ei.timestamp = int64(byteutil.BytesToUint64BigEndian(data))
+	return nil
+}
+
+// Serialize serializes election import into bytes
+func (ei *electionImport) Serialize() ([]byte, error) {
+	return byteutil.Uint64ToBytesBigEndian(uint64(ei.timestamp)), nil
+}
+
+// ImportFromElectionResult converts the delegates of the election result into native candidates, each delegate has a
+// self-stake bucket of its votes. It is a one-time migration from the poll of the election committee to native staking,
+// once the result is imported, a later call is a no-op. The delegates are imported in the order of their names, such
+// that the resulting state is the same on every node. It is run before Greenland, the self-stake buckets are indexed
+// by voter and candidate at Greenland like the other buckets created before it
+func (p *Protocol) ImportFromElectionResult(sm protocol.StateManager, r *types.ElectionResult, blkTime time.Time) error {
+	var ei electionImport
+	_, err := sm.State(
+		&ei,
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(_electionImportKey))
+	switch errors.Cause(err) {
+	case nil:
+		log.L().Debug("Election result has been imported", zap.Int64("timestamp", ei.timestamp))
+		return nil
+	case state.ErrStateNotExist:
+	default:
+		return errors.Wrap(err, "failed to get election import")
+	}
+
+	cands := make([]*Candidate, 0, len(r.Delegates()))
+	for _, d := range r.Delegates() {
+		c, err := candidateFromDelegate(d)
+		if err != nil {
+			log.L().Debug("Skip delegate", zap.String("name", string(d.Name())), zap.Error(err))
+			continue
+		}
+		cands = append(cands, c)
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].Name != cands[j].Name {
+			return cands[i].Name < cands[j].Name
+		}
+		return bytes.Compare(cands[i].Owner.Bytes(), cands[j].Owner.Bytes()) < 0
+	})
+
+	// imported also checks the collisions among the delegates
+	imported := NewCandidateCenter()
+	for _, c := range cands {
+		if p.inMemCandidates.ContainsOwner(c.Owner) || imported.ContainsOwner(c.Owner) {
+			return errors.Wrapf(ErrAlreadyExist, "failed to import delegate %s", c.Name)
+		}
+		if err := p.inMemCandidates.checkCollision(c); err != nil {
+			return errors.Wrapf(err, "failed to import delegate %s", c.Name)
+		}
+		bucket := NewVoteBucket(c.Owner, c.Owner, c.SelfStake, 0, blkTime, false)
+		if c.SelfStakeBucketIdx, err = putBucketAndIndex(sm, bucket); err != nil {
+			return errors.Wrapf(err, "failed to put self-stake bucket of delegate %s", c.Name)
+		}
+		if err := imported.Upsert(c); err != nil {
+			return errors.Wrapf(err, "failed to import delegate %s", c.Name)
+		}
+		if err := putCandidate(sm, c); err != nil {
+			return errors.Wrapf(err, "failed to put delegate %s", c.Name)
+		}
+	}
+	if _, err := sm.PutState(
+		&electionImport{timestamp: blkTime.Unix()},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(_electionImportKey)); err != nil {
+		return errors.Wrap(err, "failed to put election import")
+	}
+
+	for _, c := range cands {
+		if err := p.inMemCandidates.Upsert(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// candidateFromDelegate converts the delegate into a candidate owned and operated by its operator address, with the
+// votes of the delegate as its votes and self-stake
+func candidateFromDelegate(d *types.Candidate) (*Candidate, error) {
+	name := string(d.Name())
+	if !IsValidCandidateName(name) {
+		return nil, ErrInvalidCanName
+	}
+	operator, err := address.FromString(string(d.OperatorAddress()))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid operator address")
+	}
+	reward, err := address.FromString(string(d.RewardAddress()))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid reward address")
+	}
+	votes := d.Score()
+	if votes == nil || votes.Sign() <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	return &Candidate{
+		Owner:     operator,
+		Operator:  operator,
+		Reward:    reward,
+		Name:      name,
+		Votes:     new(big.Int).Set(votes),
+		SelfStake: new(big.Int).Set(votes),
+	}, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-election/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/blockchain/genesis"
+)
+
+func TestProtocol_ImportFromElectionResult(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	blkTime := time.Now()
+	r := types.NewElectionResultForTest(blkTime)
+	newState := func() (protocol.StateManager, *Protocol) {
+		sm := newMockStateManager(ctrl)
+		_, err := sm.PutState(
+			&totalBucketCount{count: 0},
+			protocol.NamespaceOption(StakingNameSpace),
+			protocol.KeyOption(TotalBucketKey),
+		)
+		require.NoError(err)
+		p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+		require.NoError(err)
+		return sm, p
+	}
+
+	sm, p := newState()
+	require.NoError(p.ImportFromElectionResult(sm, r, blkTime))
+	require.NotZero(p.inMemCandidates.Size())
+	require.Equal(len(r.Delegates()), p.inMemCandidates.Size())
+	for _, d := range r.Delegates() {
+		operator, err := address.FromString(string(d.OperatorAddress()))
+		require.NoError(err)
+		c := p.inMemCandidates.GetByOwner(operator)
+		require.NotNil(c)
+		require.Equal(string(d.Name()), c.Name)
+		require.Equal(operator, c.Operator)
+		require.Equal(string(d.RewardAddress()), c.Reward.String())
+		require.Equal(d.Score(), c.Votes)
+		require.Equal(d.Score(), c.SelfStake)
+
+		// the candidate center matches the state
+		cand, err := getCandidate(sm, operator)
+		require.NoError(err)
+		require.Equal(c.Name, cand.Name)
+		require.Equal(c.SelfStakeBucketIdx, cand.SelfStakeBucketIdx)
+		require.Equal(0, c.Votes.Cmp(cand.Votes))
+		bucket, err := getBucket(sm, c.SelfStakeBucketIdx)
+		require.NoError(err)
+		require.Equal(operator, bucket.Owner)
+		require.Equal(operator, bucket.Candidate)
+		require.Equal(d.Score(), bucket.StakedAmount)
+		require.Equal(blkTime.Unix(), bucket.CreateTime.Unix())
+	}
+	digest, err := p.StateDigest(sm)
+	require.NoError(err)
+	count, err := getTotalBucketCount(sm)
+	require.NoError(err)
+
+	// importing again is a no-op
+	require.NoError(p.ImportFromElectionResult(sm, r, blkTime.Add(time.Hour)))
+	require.Equal(len(r.Delegates()), p.inMemCandidates.Size())
+	c, err := getTotalBucketCount(sm)
+	require.NoError(err)
+	require.Equal(count, c)
+	d, err := p.StateDigest(sm)
+	require.NoError(err)
+	require.Equal(digest, d)
+
+	// the import results in the same state on every node
+	sm2, p2 := newState()
+	require.NoError(p2.ImportFromElectionResult(sm2, r, blkTime))
+	d, err = p2.StateDigest(sm2)
+	require.NoError(err)
+	require.Equal(digest, d)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
+	"github.com/iotexproject/iotex-core/action/protocol/vote"
+	"github.com/iotexproject/iotex-core/action/protocol/vote/candidatesutil"
+	"github.com/iotexproject/iotex-core/state"
+)
+
+// IsCandidateEligible returns whether the candidate of the owner can be elected in the epoch, and the reason if not.
+// A candidate is disqualified if it is not registered, its self-stake bucket is unstaked or does not match its
+// self-stake, its self-stake bucket is locked for less than the minimum duration, its self-stake is below the minimum,
+// or it is in the kick-out list of the epoch
+func (p *Protocol) IsCandidateEligible(ctx context.Context, owner address.Address, epochNum uint64) (bool, string, error) {
+	c, verified, err := p.CandidateWithVerifiedSelfStake(p.sr, owner)
+	switch errors.Cause(err) {
+	case nil:
+	case state.ErrStateNotExist:
+		return false, fmt.Sprintf("candidate %s is not registered", owner), nil
+	default:
+		return false, "", err
+	}
+	if !verified {
+		return false, fmt.Sprintf("self-stake bucket %d does not match the self-stake %s", c.SelfStakeBucketIdx, c.SelfStake), nil
+	}
+	bucket, err := getBucket(p.sr, c.SelfStakeBucketIdx)
+	switch errors.Cause(err) {
+	case nil:
+		if bucket.UnstakeStartTime.Unix() != 0 {
+			return false, fmt.Sprintf("self-stake bucket %d is unstaked", c.SelfStakeBucketIdx), nil
+		}
+		if !bucket.AutoStake && bucket.StakedDuration < p.config.RegistrationConsts.MinSelfStakeDuration {
+			return false, fmt.Sprintf("self-stake bucket %d is locked for %s, below the minimum %s", c.SelfStakeBucketIdx, bucket.StakedDuration, p.config.RegistrationConsts.MinSelfStakeDuration), nil
+		}
+	case state.ErrStateNotExist:
+		// the self-stake is 0, which is below the minimum
+	default:
+		return false, "", errors.Wrapf(err, "failed to fetch self-stake bucket of candidate %s", owner)
+	}
+	if c.SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) < 0 {
+		return false, fmt.Sprintf("self-stake %s is below the minimum %s", c.SelfStake, p.config.RegistrationConsts.MinSelfStake), nil
+	}
+	kickoutList, err := p.kickoutList(ctx, epochNum)
+	if err != nil {
+		return false, "", err
+	}
+	if kickoutList != nil {
+		if _, ok := kickoutList.BlacklistInfos[owner.String()]; ok {
+			return false, fmt.Sprintf("candidate %s is in the kick-out list of epoch %d", owner, epochNum), nil
+		}
+	}
+	return true, "", nil
+}
+
+// kickoutList returns the kick-out list in effect in the epoch, nil if there is none
+func (p *Protocol) kickoutList(ctx context.Context, epochNum uint64) (*vote.Blacklist, error) {
+	bcCtx := protocol.MustGetBlockchainCtx(ctx)
+	rp := rolldpos.MustGetProtocol(bcCtx.Registry)
+	epochStartHeight := rp.GetEpochHeight(epochNum)
+	if epochStartHeight < bcCtx.Genesis.EasterBlockHeight {
+		// there is no kick-out list before Easter
+		return nil, nil
+	}
+	tipHeight, err := p.sr.Height()
+	if err != nil {
+		return nil, err
+	}
+	var opts []protocol.StateOption
+	if epochStartHeight < rp.GetEpochHeight(rp.GetEpochNum(tipHeight)) {
+		// read historical data
+		opts = append(opts, protocol.BlockHeightOption(epochStartHeight))
+	}
+	kickoutList, _, err := candidatesutil.KickoutListFromDB(p.sr, false, opts...)
+	switch errors.Cause(err) {
+	case nil:
+		return kickoutList, nil
+	case state.ErrStateNotExist:
+		return nil, nil
+	default:
+		return nil, errors.Wrapf(err, "failed to get kick-out list of epoch %d", epochNum)
+	}
+}
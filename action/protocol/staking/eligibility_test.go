@@ -0,0 +1,136 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
+	"github.com/iotexproject/iotex-core/action/protocol/vote"
+	"github.com/iotexproject/iotex-core/action/protocol/vote/candidatesutil"
+	"github.com/iotexproject/iotex-core/blockchain/genesis"
+	"github.com/iotexproject/iotex-core/pkg/unit"
+	"github.com/iotexproject/iotex-core/test/identityset"
+	"github.com/iotexproject/iotex-core/test/mock/mock_chainmanager"
+)
+
+func TestProtocol_IsCandidateEligible(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	sm.(*mock_chainmanager.MockStateManager).EXPECT().Height().Return(uint64(15), nil).AnyTimes()
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	// an epoch lasts 10 blocks, the kick-out list takes effect from epoch 2
+	g := genesis.Default
+	g.EasterBlockHeight = 11
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+		Genesis:  g,
+		Registry: registry,
+	})
+
+	minSelfStake := unit.ConvertIotxToRau(1200000)
+	register := func(i int, bucketAmount, selfStake *big.Int, unstaked bool) {
+		owner := identityset.Address(i)
+		bucket := NewVoteBucket(owner, owner, bucketAmount, 91, time.Now(), true)
+		if unstaked {
+			bucket.UnstakeStartTime = time.Now()
+		}
+		idx, err := putBucketAndIndex(sm, bucket)
+		r.NoError(err)
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 10),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i),
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: idx,
+			SelfStake:          selfStake,
+		}))
+	}
+	register(1, minSelfStake, minSelfStake, false)
+	register(2, minSelfStake, minSelfStake, false)
+	register(3, unit.ConvertIotxToRau(100), unit.ConvertIotxToRau(100), false)
+	register(4, minSelfStake, minSelfStake, true)
+	register(5, minSelfStake, new(big.Int).Add(minSelfStake, big.NewInt(1)), false)
+	kickoutKey := candidatesutil.ConstructKey(candidatesutil.CurKickoutKey)
+	_, err = sm.PutState(
+		&vote.Blacklist{
+			BlacklistInfos: map[string]uint32{identityset.Address(2).String(): 1},
+			IntensityRate:  90,
+		},
+		protocol.KeyOption(kickoutKey[:]),
+		protocol.NamespaceOption(protocol.SystemNamespace),
+	)
+	r.NoError(err)
+
+	tests := []struct {
+		owner    int
+		epochNum uint64
+		eligible bool
+		reason   string
+	}{
+		{1, 2, true, ""},
+		{2, 2, false, fmt.Sprintf("candidate %s is in the kick-out list of epoch 2", identityset.Address(2))},
+		// there is no kick-out list before Easter
+		{2, 1, true, ""},
+		{3, 2, false, fmt.Sprintf("self-stake %s is below the minimum %s", unit.ConvertIotxToRau(100), minSelfStake)},
+		{4, 2, false, "self-stake bucket 3 is unstaked"},
+		{5, 2, false, fmt.Sprintf("self-stake bucket 4 does not match the self-stake %s",
+			new(big.Int).Add(minSelfStake, big.NewInt(1)))},
+		{6, 2, false, fmt.Sprintf("candidate %s is not registered", identityset.Address(6))},
+	}
+	for _, test := range tests {
+		eligible, reason, err := p.IsCandidateEligible(ctx, identityset.Address(test.owner), test.epochNum)
+		r.NoError(err)
+		r.Equal(test.eligible, eligible)
+		r.Equal(test.reason, reason)
+	}
+
+	// a self-stake bucket locked for less than the minimum duration is ineligible, unless it is auto-staked
+	p.config.RegistrationConsts.MinSelfStakeDuration = 92 * 24 * time.Hour
+	owner := identityset.Address(7)
+	bucket := NewVoteBucket(owner, owner, minSelfStake, 91, time.Now(), false)
+	idx, err := putBucketAndIndex(sm, bucket)
+	r.NoError(err)
+	r.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              owner,
+		Operator:           identityset.Address(17),
+		Reward:             owner,
+		Name:               "test7",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: idx,
+		SelfStake:          minSelfStake,
+	}))
+	eligible, reason, err := p.IsCandidateEligible(ctx, owner, 2)
+	r.NoError(err)
+	r.False(eligible)
+	r.Equal(fmt.Sprintf("self-stake bucket %d is locked for 2184h0m0s, below the minimum 2208h0m0s", idx), reason)
+	eligible, _, err = p.IsCandidateEligible(ctx, identityset.Address(1), 2)
+	r.NoError(err)
+	r.True(eligible)
+}
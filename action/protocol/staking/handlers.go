@@ -46,6 +46,61 @@ const (
 	HandleCandidateRegister = "candidateRegister"
 	// HandleCandidateUpdate is the handler name of candidateUpdate
 	HandleCandidateUpdate = "candidateUpdate"
+	// HandleRegistrationFee is the log topic of the registration fee paid when registering a candidate
+	HandleRegistrationFee = "registrationFee"
+)
+
+var _stakingMethods = map[string]struct{}{
+	HandleCreateStake:       {},
+	HandleUnstake:           {},
+	HandleWithdrawStake:     {},
+	HandleChangeCandidate:   {},
+	HandleTransferStake:     {},
+	HandleDepositToStake:    {},
+	HandleRestake:           {},
+	HandleCandidateRegister: {},
+	HandleCandidateUpdate:   {},
+}
+
+// The receipt statuses of the staking failures which iotex-proto has no status for are allocated from the range
+// [ReceiptStatusPrivateStart, ReceiptStatusPrivateEnd), which is private to the staking protocol. iotex-proto allocates
+// its statuses far below the range, a status added to iotex-proto later on keeps its value in iotex-proto, and the
+// private one is left as is for the receipts already produced
+const (
+	// ReceiptStatusPrivateStart is the first receipt status private to the staking protocol
+	ReceiptStatusPrivateStart iotextypes.ReceiptStatus = 10000
+	// ReceiptStatusPrivateEnd is the end of the receipt statuses private to the staking protocol, exclusive
+	ReceiptStatusPrivateEnd iotextypes.ReceiptStatus = 11000
+)
+
+const (
+	// ReceiptStatusErrExceedMaxCandidateNumber is the receipt status when the number of active candidates reaches the limit
+	ReceiptStatusErrExceedMaxCandidateNumber = ReceiptStatusPrivateStart + iota
+	// ReceiptStatusErrCandidateNotRegistered is the receipt status when staking to a candidate which has not been
+	// registered yet, the candidate should be registered with a candidateRegister action first
+	ReceiptStatusErrCandidateNotRegistered
+	// ReceiptStatusErrChangeCooldown is the receipt status when changing a candidate field before its cooldown ends
+	ReceiptStatusErrChangeCooldown
+	// ReceiptStatusErrExceedRegistrationsPerEpoch is the receipt status when the number of candidates registered in
+	// the current epoch reaches the limit
+	ReceiptStatusErrExceedRegistrationsPerEpoch
+	// ReceiptStatusErrCandidateConflict is the receipt status from Greenland on when the operator address is used by
+	// another candidate
+	ReceiptStatusErrCandidateConflict
+	// ReceiptStatusErrPaused is the receipt status when a staking action is sent during an emergency pause
+	ReceiptStatusErrPaused
+	// ReceiptStatusErrSelfStakeBucketDisallowed is the receipt status from Greenland on when the action cannot process
+	// a self-staking bucket, other issues of bucket type are reported by ReceiptStatus_ErrInvalidBucketType
+	ReceiptStatusErrSelfStakeBucketDisallowed
+	// ReceiptStatusErrSelfStakeDurationTooShort is the receipt status when a self-stake bucket is neither auto-staked
+	// nor locked for the minimum self-stake duration
+	ReceiptStatusErrSelfStakeDurationTooShort
+	// ReceiptStatusErrInvalidCanName is the receipt status when registering or renaming a candidate with a name that
+	// violates the name rules
+	ReceiptStatusErrInvalidCanName
+	// ReceiptStatusErrInvalidBlockTime is the receipt status from Greenland on when the block timestamp is not set or
+	// precedes the times of the bucket
+	ReceiptStatusErrInvalidBlockTime
 )
 
 type fetchError struct {
@@ -53,9 +108,29 @@ type fetchError struct {
 	failureStatus iotextypes.ReceiptStatus
 }
 
+// handleError is a failure found by the handler after fetching the caller, the action is settled with failureStatus
+type handleError struct {
+	err           error
+	failureStatus iotextypes.ReceiptStatus
+}
+
+// handlePaused rejects the staking action during an emergency pause, only the gas fee is charged
+func (p *Protocol) handlePaused(ctx context.Context, sm protocol.StateManager) (*action.Receipt, error) {
+	_, gasFee, fetchErr := fetchCaller(ctx, sm, big.NewInt(0))
+	if fetchErr != nil {
+		if fetchErr.failureStatus == iotextypes.ReceiptStatus_Failure {
+			return nil, fetchErr.err
+		}
+		log.L().Debug("Error when fetching caller", zap.Error(fetchErr.err))
+		return p.settleAction(ctx, sm, uint64(fetchErr.failureStatus), gasFee)
+	}
+	log.L().Debug("Staking action is rejected during emergency pause",
+		zap.Uint64("height", protocol.MustGetBlockCtx(ctx).BlockHeight))
+	return p.settleAction(ctx, sm, uint64(ReceiptStatusErrPaused), gasFee)
+}
+
 func (p *Protocol) handleCreateStake(ctx context.Context, act *action.CreateStake, sm protocol.StateManager) (*action.Receipt, error) {
 	actionCtx := protocol.MustGetActionCtx(ctx)
-	blkCtx := protocol.MustGetBlockCtx(ctx)
 
 	staker, gasFee, fetchErr := fetchCaller(ctx, sm, act.Amount())
 	if fetchErr != nil {
@@ -69,17 +144,36 @@ func (p *Protocol) handleCreateStake(ctx context.Context, act *action.CreateStak
 	// Create new bucket and bucket index
 	candidate := p.inMemCandidates.GetByName(act.Candidate())
 	if candidate == nil {
-		log.L().Debug("Error when finding candidate in candidate center", zap.Error(ErrInvalidCanName))
-		return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrCandidateNotExist), gasFee)
-	}
-	bucket := NewVoteBucket(candidate.Owner, actionCtx.Caller, act.Amount(), act.Duration(), blkCtx.BlockTimeStamp, act.AutoStake())
+		if p.config.AutoRegisterCandidate {
+			return p.handleCreateStakeWithRegistration(ctx, act, sm, staker, gasFee)
+		}
+		if !p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+			log.L().Debug("Error when finding candidate in candidate center", zap.Error(ErrInvalidCanName))
+			return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrCandidateNotExist), gasFee)
+		}
+		log.L().Debug("Candidate is not registered, register it with a candidateRegister action first",
+			zap.String("name", act.Candidate()),
+			zap.Error(ErrInvalidCanName))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrCandidateNotRegistered), gasFee)
+	}
+	now := p.clock(ctx)
+	if handleErr := p.checkBlockTime(ctx, now); handleErr != nil {
+		log.L().Debug("Error when creating bucket", zap.Error(handleErr.err))
+		return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+	}
+	// the lock of the bucket counts down from now on, unless it is auto-staked, in which case the duration is the lock
+	// left once auto-stake is turned off
+	bucket := NewVoteBucket(candidate.Owner, actionCtx.Caller, act.Amount(), act.Duration(), now, act.AutoStake())
 	bucketIdx, err := putBucketAndIndex(sm, bucket)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to put bucket")
 	}
+	if err := p.putVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, bucketIdx); err != nil {
+		return nil, errors.Wrap(err, "failed to put voter and candidate index")
+	}
 
 	// update candidate
-	weightedVote := p.calculateVoteWeight(bucket, false)
+	weightedVote := p.calculateVoteWeight(ctx, bucket, false)
 	if err := candidate.AddVote(weightedVote); err != nil {
 		return nil, errors.Wrapf(err, "failed to add vote for candidate %s", candidate.Owner.String())
 	}
@@ -107,10 +201,118 @@ func (p *Protocol) handleCreateStake(ctx context.Context, act *action.CreateStak
 	return receipt, nil
 }
 
-func (p *Protocol) handleUnstake(ctx context.Context, act *action.Unstake, sm protocol.StateManager) (*action.Receipt, error) {
+// handleCreateStakeWithRegistration registers the caller as the named candidate, and uses the stake as its self-stake
+func (p *Protocol) handleCreateStakeWithRegistration(
+	ctx context.Context,
+	act *action.CreateStake,
+	sm protocol.StateManager,
+	staker *state.Account,
+	gasFee *big.Int,
+) (*action.Receipt, error) {
 	actionCtx := protocol.MustGetActionCtx(ctx)
 	blkCtx := protocol.MustGetBlockCtx(ctx)
 
+	registrationFee := new(big.Int).Set(p.config.RegistrationConsts.Fee)
+	required := new(big.Int).Add(act.Amount(), registrationFee)
+	if required.Add(required, gasFee).Cmp(staker.Balance) == 1 {
+		log.L().Debug("Error when registering candidate", zap.Error(state.ErrNotEnoughBalance))
+		return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrNotEnoughBalance), gasFee)
+	}
+	if !p.isValidCandidateName(act.Candidate(), blkCtx.BlockHeight) {
+		log.L().Debug("Error when registering candidate", zap.String("name", act.Candidate()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrInvalidCanName), gasFee)
+	}
+
+	owner := actionCtx.Caller
+	if p.inMemCandidates.ContainsOwner(owner) || p.inMemCandidates.ContainsOperator(owner) {
+		log.L().Debug("Caller already registered another candidate", zap.String("caller", owner.String()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrCandidateNotRegistered), gasFee)
+	}
+	exceed, err := p.exceedMaxCandidates()
+	if err != nil {
+		return nil, err
+	}
+	if exceed {
+		log.L().Debug("Error when registering candidate", zap.Uint64("maxCandidates", p.config.MaxCandidates))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrExceedMaxCandidateNumber), gasFee)
+	}
+	exceed, err = p.exceedRegistrationsPerEpoch(sm)
+	if err != nil {
+		return nil, err
+	}
+	if exceed {
+		log.L().Debug("Error when registering candidate", zap.Uint64("maxRegistrationsPerEpoch", p.config.MaxRegistrationsPerEpoch))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrExceedRegistrationsPerEpoch), gasFee)
+	}
+	if !p.selfStakeLockedLongEnough(act.Duration(), act.AutoStake()) {
+		log.L().Debug("Error when registering candidate", zap.Uint32("duration", act.Duration()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrSelfStakeDurationTooShort), gasFee)
+	}
+
+	now := p.clock(ctx)
+	if handleErr := p.checkBlockTime(ctx, now); handleErr != nil {
+		log.L().Debug("Error when registering candidate", zap.Error(handleErr.err))
+		return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+	}
+	bucket := NewVoteBucket(owner, owner, act.Amount(), act.Duration(), now, act.AutoStake())
+	bucketIdx, err := putBucketAndIndex(sm, bucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to put bucket")
+	}
+	if err := p.putVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, bucketIdx); err != nil {
+		return nil, errors.Wrap(err, "failed to put voter and candidate index")
+	}
+
+	c := &Candidate{
+		Owner:              owner,
+		Operator:           owner,
+		Reward:             owner,
+		Name:               act.Candidate(),
+		Votes:              p.calculateVoteWeight(ctx, bucket, true),
+		SelfStakeBucketIdx: bucketIdx,
+		SelfStake:          act.Amount(),
+	}
+	if p.isGreenland(blkCtx.BlockHeight) {
+		c.LastUpdateHeight = blkCtx.BlockHeight
+	}
+	if err := putCandidate(sm, c); err != nil {
+		return nil, err
+	}
+	if err := p.countRegistration(sm); err != nil {
+		return nil, errors.Wrap(err, "failed to count registration")
+	}
+
+	// update staker balance
+	if err := staker.SubBalance(act.Amount()); err != nil {
+		return nil, errors.Wrapf(err, "failed to update the balance of staker %s", owner.String())
+	}
+	// put updated staker's account state to trie
+	if err := accountutil.StoreAccount(sm, owner.String(), staker); err != nil {
+		return nil, errors.Wrapf(err, "failed to store account %s", owner.String())
+	}
+
+	// put registrationFee to reward pool
+	if err := p.depositGas(ctx, sm, registrationFee); err != nil {
+		return nil, errors.Wrap(err, "failed to deposit gas")
+	}
+
+	logs := append(
+		p.registrationLogs(ctx, owner, owner, bucketIdx, registrationFee),
+		p.createLog(ctx, HandleCreateStake, owner, owner, byteutil.Uint64ToBytes(bucketIdx)),
+	)
+	receipt, err := p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_Success), gasFee, logs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to settle action")
+	}
+	if err := p.inMemCandidates.Upsert(c); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (p *Protocol) handleUnstake(ctx context.Context, act *action.Unstake, sm protocol.StateManager) (*action.Receipt, error) {
+	actionCtx := protocol.MustGetActionCtx(ctx)
+
 	_, gasFee, fetchErr := fetchCaller(ctx, sm, big.NewInt(0))
 	if fetchErr != nil {
 		if fetchErr.failureStatus == iotextypes.ReceiptStatus_Failure {
@@ -130,7 +332,12 @@ func (p *Protocol) handleUnstake(ctx context.Context, act *action.Unstake, sm pr
 	}
 
 	// update bucket
-	bucket.UnstakeStartTime = blkCtx.BlockTimeStamp
+	now := p.clock(ctx)
+	if handleErr := p.checkBucketTime(ctx, now, bucket); handleErr != nil {
+		log.L().Debug("Error when unstaking bucket", zap.Error(handleErr.err))
+		return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+	}
+	bucket.UnstakeStartTime = now
 	if err := updateBucket(sm, act.BucketIndex(), bucket); err != nil {
 		return nil, errors.Wrapf(err, "failed to update bucket for voter %s", bucket.Owner)
 	}
@@ -139,7 +346,7 @@ func (p *Protocol) handleUnstake(ctx context.Context, act *action.Unstake, sm pr
 	if candidate == nil {
 		return nil, errors.Wrap(ErrInvalidOwner, "cannot find candidate in candidate center")
 	}
-	weightedVote := p.calculateVoteWeight(bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
+	weightedVote := p.calculateVoteWeight(ctx, bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
 	if err := candidate.SubVote(weightedVote); err != nil {
 		return nil, errors.Wrapf(err, "failed to subtract vote for candidate %s", bucket.Candidate.String())
 	}
@@ -164,7 +371,6 @@ func (p *Protocol) handleUnstake(ctx context.Context, act *action.Unstake, sm pr
 
 func (p *Protocol) handleWithdrawStake(ctx context.Context, act *action.WithdrawStake, sm protocol.StateManager) (*action.Receipt, error) {
 	actionCtx := protocol.MustGetActionCtx(ctx)
-	blkCtx := protocol.MustGetBlockCtx(ctx)
 
 	withdrawer, gasFee, fetchErr := fetchCaller(ctx, sm, big.NewInt(0))
 	if fetchErr != nil {
@@ -190,9 +396,14 @@ func (p *Protocol) handleWithdrawStake(ctx context.Context, act *action.Withdraw
 		log.L().Debug("Error when withdrawing bucket", zap.Error(err))
 		return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrWithdrawBeforeUnstake), gasFee)
 	}
-	if blkCtx.BlockTimeStamp.Before(bucket.UnstakeStartTime.Add(p.config.WithdrawWaitingPeriod)) {
+	now := p.clock(ctx)
+	if handleErr := p.checkBucketTime(ctx, now, bucket); handleErr != nil {
+		log.L().Debug("Error when withdrawing bucket", zap.Error(handleErr.err))
+		return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+	}
+	if now.Before(bucket.UnstakeStartTime.Add(p.config.WithdrawWaitingPeriod)) {
 		err := fmt.Errorf("stake is not ready to withdraw, current time %s, required time %s",
-			blkCtx.BlockTimeStamp, bucket.UnstakeStartTime.Add(p.config.WithdrawWaitingPeriod))
+			now, bucket.UnstakeStartTime.Add(p.config.WithdrawWaitingPeriod))
 		log.L().Debug("Error when withdrawing bucket", zap.Error(err))
 		return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrWithdrawBeforeMaturity), gasFee)
 	}
@@ -207,6 +418,9 @@ func (p *Protocol) handleWithdrawStake(ctx context.Context, act *action.Withdraw
 	if err := delVoterBucketIndex(sm, bucket.Owner, act.BucketIndex()); err != nil {
 		return nil, errors.Wrapf(err, "failed to delete bucket index for voter %s", bucket.Owner.String())
 	}
+	if err := p.delVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, act.BucketIndex()); err != nil {
+		return nil, errors.Wrapf(err, "failed to delete bucket index for voter %s and candidate %s", bucket.Owner.String(), bucket.Candidate.String())
+	}
 
 	// update withdrawer balance
 	if err := withdrawer.AddBalance(bucket.StakedAmount); err != nil {
@@ -260,13 +474,19 @@ func (p *Protocol) handleChangeCandidate(ctx context.Context, act *action.Change
 	if err := putCandBucketIndex(sm, candidate.Owner, act.BucketIndex()); err != nil {
 		return nil, errors.Wrapf(err, "failed to put candidate bucket index for candidate %s", candidate.Owner.String())
 	}
+	if err := p.delVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, act.BucketIndex()); err != nil {
+		return nil, errors.Wrapf(err, "failed to delete bucket index for voter %s and candidate %s", bucket.Owner.String(), bucket.Candidate.String())
+	}
+	if err := p.putVoterCandIndex(ctx, sm, bucket.Owner, candidate.Owner, act.BucketIndex()); err != nil {
+		return nil, errors.Wrapf(err, "failed to put bucket index for voter %s and candidate %s", bucket.Owner.String(), candidate.Owner.String())
+	}
 	// update bucket
 	bucket.Candidate = candidate.Owner
 	if err := updateBucket(sm, act.BucketIndex(), bucket); err != nil {
 		return nil, errors.Wrapf(err, "failed to update bucket for voter %s", bucket.Owner)
 	}
 
-	weightedVotes := p.calculateVoteWeight(bucket, false)
+	weightedVotes := p.calculateVoteWeight(ctx, bucket, false)
 
 	// update previous candidate
 	if err := prevCandidate.SubVote(weightedVotes); err != nil {
@@ -326,6 +546,12 @@ func (p *Protocol) handleTransferStake(ctx context.Context, act *action.Transfer
 	if err := putVoterBucketIndex(sm, act.VoterAddress(), act.BucketIndex()); err != nil {
 		return nil, errors.Wrapf(err, "failed to put candidate bucket index for voter %s", act.VoterAddress().String())
 	}
+	if err := p.delVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, act.BucketIndex()); err != nil {
+		return nil, errors.Wrapf(err, "failed to delete bucket index for voter %s and candidate %s", bucket.Owner.String(), bucket.Candidate.String())
+	}
+	if err := p.putVoterCandIndex(ctx, sm, act.VoterAddress(), bucket.Candidate, act.BucketIndex()); err != nil {
+		return nil, errors.Wrapf(err, "failed to put bucket index for voter %s and candidate %s", act.VoterAddress().String(), bucket.Candidate.String())
+	}
 
 	// update bucket
 	bucket.Owner = act.VoterAddress()
@@ -367,7 +593,7 @@ func (p *Protocol) handleDepositToStake(ctx context.Context, act *action.Deposit
 		return nil, errors.Wrap(ErrInvalidOwner, "cannot find candidate in candidate center")
 	}
 
-	prevWeightedVotes := p.calculateVoteWeight(bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
+	prevWeightedVotes := p.calculateVoteWeight(ctx, bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
 	// update bucket
 	bucket.StakedAmount.Add(bucket.StakedAmount, act.Amount())
 	if err := updateBucket(sm, act.BucketIndex(), bucket); err != nil {
@@ -378,7 +604,7 @@ func (p *Protocol) handleDepositToStake(ctx context.Context, act *action.Deposit
 	if err := candidate.SubVote(prevWeightedVotes); err != nil {
 		return nil, errors.Wrapf(err, "failed to subtract vote for candidate %s", bucket.Candidate.String())
 	}
-	weightedVotes := p.calculateVoteWeight(bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
+	weightedVotes := p.calculateVoteWeight(ctx, bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
 	if err := candidate.AddVote(weightedVotes); err != nil {
 		return nil, errors.Wrapf(err, "failed to add vote for candidate %s", bucket.Candidate.String())
 	}
@@ -437,8 +663,22 @@ func (p *Protocol) handleRestake(ctx context.Context, act *action.Restake, sm pr
 		return nil, errors.Wrap(ErrInvalidOwner, "cannot find candidate in candidate center")
 	}
 
-	prevWeightedVotes := p.calculateVoteWeight(bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
-	// update bucket
+	selfStake := p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex())
+	if selfStake && !p.selfStakeLockedLongEnough(act.Duration(), act.AutoStake()) {
+		log.L().Debug("Error when restaking self-stake bucket", zap.Uint32("duration", act.Duration()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrSelfStakeDurationTooShort), gasFee)
+	}
+	prevWeightedVotes := p.calculateVoteWeight(ctx, bucket, selfStake)
+	// update bucket, from Greenland on the lock of an auto-stake bucket starts counting down when auto-stake is turned
+	// off, before it the lock counted from the stake start time
+	if bucket.AutoStake && !act.AutoStake() && p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		now := p.clock(ctx)
+		if handleErr := p.checkBucketTime(ctx, now, bucket); handleErr != nil {
+			log.L().Debug("Error when restaking bucket", zap.Error(handleErr.err))
+			return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+		}
+		bucket.StakeStartTime = now.UTC()
+	}
 	bucket.StakedDuration = time.Duration(act.Duration()) * 24 * time.Hour
 	bucket.AutoStake = act.AutoStake()
 	if err := updateBucket(sm, act.BucketIndex(), bucket); err != nil {
@@ -449,7 +689,7 @@ func (p *Protocol) handleRestake(ctx context.Context, act *action.Restake, sm pr
 	if err := candidate.SubVote(prevWeightedVotes); err != nil {
 		return nil, errors.Wrapf(err, "failed to subtract vote for candidate %s", bucket.Candidate.String())
 	}
-	weightedVotes := p.calculateVoteWeight(bucket, p.inMemCandidates.ContainsSelfStakingBucket(act.BucketIndex()))
+	weightedVotes := p.calculateVoteWeight(ctx, bucket, selfStake)
 	if err := candidate.AddVote(weightedVotes); err != nil {
 		return nil, errors.Wrapf(err, "failed to add vote for candidate %s", bucket.Candidate.String())
 	}
@@ -483,29 +723,70 @@ func (p *Protocol) handleCandidateRegister(ctx context.Context, act *action.Cand
 		return p.settleAction(ctx, sm, uint64(fetchErr.failureStatus), gasFee)
 	}
 
+	if !p.isValidCandidateName(act.Name(), blkCtx.BlockHeight) {
+		log.L().Debug("Error when registering candidate", zap.String("name", act.Name()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrInvalidCanName), gasFee)
+	}
+
 	owner := actCtx.Caller
 	if act.OwnerAddress() != nil {
 		owner = act.OwnerAddress()
 	}
-	bucket := NewVoteBucket(owner, owner, act.Amount(), act.Duration(), blkCtx.BlockTimeStamp, act.AutoStake())
+	if p.isGreenland(blkCtx.BlockHeight) && p.operatorConflict(act.OperatorAddress(), owner) {
+		log.L().Debug("Error when registering candidate", zap.String("operator", act.OperatorAddress().String()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrCandidateConflict), gasFee)
+	}
+
+	exceed, err := p.exceedMaxCandidates()
+	if err != nil {
+		return nil, err
+	}
+	if exceed {
+		log.L().Debug("Error when registering candidate", zap.Uint64("maxCandidates", p.config.MaxCandidates))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrExceedMaxCandidateNumber), gasFee)
+	}
+	exceed, err = p.exceedRegistrationsPerEpoch(sm)
+	if err != nil {
+		return nil, err
+	}
+	if exceed {
+		log.L().Debug("Error when registering candidate", zap.Uint64("maxRegistrationsPerEpoch", p.config.MaxRegistrationsPerEpoch))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrExceedRegistrationsPerEpoch), gasFee)
+	}
+
+	now := p.clock(ctx)
+	if handleErr := p.checkBlockTime(ctx, now); handleErr != nil {
+		log.L().Debug("Error when registering candidate", zap.Error(handleErr.err))
+		return p.settleAction(ctx, sm, uint64(handleErr.failureStatus), gasFee)
+	}
+	bucket := NewVoteBucket(owner, owner, act.Amount(), act.Duration(), now, act.AutoStake())
 	bucketIdx, err := putBucketAndIndex(sm, bucket)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to put bucket")
 	}
+	if err := p.putVoterCandIndex(ctx, sm, bucket.Owner, bucket.Candidate, bucketIdx); err != nil {
+		return nil, errors.Wrap(err, "failed to put voter and candidate index")
+	}
 
 	c := &Candidate{
 		Owner:              owner,
 		Operator:           act.OperatorAddress(),
 		Reward:             act.RewardAddress(),
 		Name:               act.Name(),
-		Votes:              p.calculateVoteWeight(bucket, true),
+		Votes:              p.calculateVoteWeight(ctx, bucket, true),
 		SelfStakeBucketIdx: bucketIdx,
 		SelfStake:          act.Amount(),
 	}
+	if p.isGreenland(blkCtx.BlockHeight) {
+		c.LastUpdateHeight = blkCtx.BlockHeight
+	}
 
 	if err := putCandidate(sm, c); err != nil {
 		return nil, err
 	}
+	if err := p.countRegistration(sm); err != nil {
+		return nil, errors.Wrap(err, "failed to count registration")
+	}
 
 	// update caller balance
 	if err := caller.SubBalance(act.Amount()); err != nil {
@@ -521,8 +802,8 @@ func (p *Protocol) handleCandidateRegister(ctx context.Context, act *action.Cand
 		return nil, errors.Wrap(err, "failed to deposit gas")
 	}
 
-	log := p.createLog(ctx, HandleCandidateRegister, owner, actCtx.Caller, byteutil.Uint64ToBytes(bucketIdx))
-	receipt, err := p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_Success), gasFee, log)
+	logs := p.registrationLogs(ctx, owner, actCtx.Caller, bucketIdx, registrationFee)
+	receipt, err := p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_Success), gasFee, logs...)
 	if err != nil {
 		return nil, err
 	}
@@ -535,6 +816,7 @@ func (p *Protocol) handleCandidateRegister(ctx context.Context, act *action.Cand
 
 func (p *Protocol) handleCandidateUpdate(ctx context.Context, act *action.CandidateUpdate, sm protocol.StateManager) (*action.Receipt, error) {
 	actCtx := protocol.MustGetActionCtx(ctx)
+	blkCtx := protocol.MustGetBlockCtx(ctx)
 
 	_, gasFee, fetchErr := fetchCaller(ctx, sm, new(big.Int))
 	if fetchErr != nil {
@@ -552,17 +834,38 @@ func (p *Protocol) handleCandidateUpdate(ctx context.Context, act *action.Candid
 		return p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_ErrCandidateNotExist), gasFee)
 	}
 
+	if len(act.Name()) != 0 && !p.isValidCandidateName(act.Name(), blkCtx.BlockHeight) {
+		log.L().Debug("Error when updating candidate", zap.String("name", act.Name()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrInvalidCanName), gasFee)
+	}
+
+	operatorChanged := act.OperatorAddress() != nil && !address.Equal(act.OperatorAddress(), c.Operator)
+	if operatorChanged && remainingCooldown(c.OperatorUpdateHeight, p.config.OperatorChangeCooldown, blkCtx.BlockHeight) > 0 {
+		log.L().Debug("Error when updating candidate", zap.Uint64("operatorUpdateHeight", c.OperatorUpdateHeight))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrChangeCooldown), gasFee)
+	}
+	if operatorChanged && p.isGreenland(blkCtx.BlockHeight) && p.operatorConflict(act.OperatorAddress(), c.Owner) {
+		log.L().Debug("Error when updating candidate", zap.String("operator", act.OperatorAddress().String()))
+		return p.settleAction(ctx, sm, uint64(ReceiptStatusErrCandidateConflict), gasFee)
+	}
+
 	if len(act.Name()) != 0 {
 		c.Name = act.Name()
 	}
 
-	if act.OperatorAddress() != nil {
+	if operatorChanged {
 		c.Operator = act.OperatorAddress()
+		if p.isGreenland(blkCtx.BlockHeight) {
+			c.OperatorUpdateHeight = blkCtx.BlockHeight
+		}
 	}
 
 	if act.RewardAddress() != nil {
 		c.Reward = act.RewardAddress()
 	}
+	if p.isGreenland(blkCtx.BlockHeight) {
+		c.LastUpdateHeight = blkCtx.BlockHeight
+	}
 
 	if err := putCandidate(sm, c); err != nil {
 		return nil, err
@@ -580,6 +883,38 @@ func (p *Protocol) handleCandidateUpdate(ctx context.Context, act *action.Candid
 	return receipt, nil
 }
 
+// operatorConflict returns true if the operator address is used by a candidate other than the one of given owner. Before
+// Greenland the conflict is not reported by a receipt status, the action fails when the candidate center rejects it
+func (p *Protocol) operatorConflict(operator, owner address.Address) bool {
+	c := p.inMemCandidates.GetByOperator(operator)
+	return c != nil && !address.Equal(c.Owner, owner)
+}
+
+// stakingMethod returns the handler name of the staking action
+func stakingMethod(act action.Action) string {
+	switch act.(type) {
+	case *action.CreateStake:
+		return HandleCreateStake
+	case *action.Unstake:
+		return HandleUnstake
+	case *action.WithdrawStake:
+		return HandleWithdrawStake
+	case *action.ChangeCandidate:
+		return HandleChangeCandidate
+	case *action.TransferStake:
+		return HandleTransferStake
+	case *action.DepositToStake:
+		return HandleDepositToStake
+	case *action.Restake:
+		return HandleRestake
+	case *action.CandidateRegister:
+		return HandleCandidateRegister
+	case *action.CandidateUpdate:
+		return HandleCandidateUpdate
+	}
+	return ""
+}
+
 // settleAccount deposits gas fee and updates caller's nonce
 func (p *Protocol) settleAction(
 	ctx context.Context,
@@ -594,6 +929,15 @@ func (p *Protocol) settleAction(
 	if blkCtx.GasLimit < actionCtx.IntrinsicGas {
 		return nil, errors.Wrap(action.ErrHitGasLimit, "block gas limit exceeded")
 	}
+	// every log created by createLog and its variants ends up here
+	for _, l := range logs {
+		if uint64(len(l.Data)) > p.maxLogDataSize {
+			return nil, errors.Wrapf(ErrLogDataTooLarge, "log data size %d exceeds the limit %d", len(l.Data), p.maxLogDataSize)
+		}
+		if len(l.Topics) > MaxLogTopics {
+			return nil, errors.Wrapf(ErrTooManyLogTopics, "%d log topics exceed the limit %d", len(l.Topics), MaxLogTopics)
+		}
+	}
 	if err := p.depositGas(ctx, sm, gasFee); err != nil {
 		return nil, errors.Wrap(err, "failed to deposit gas")
 	}
@@ -654,11 +998,37 @@ func (p *Protocol) fetchBucket(
 			err:           errors.New("self staking bucket cannot be processed"),
 			failureStatus: iotextypes.ReceiptStatus_ErrInvalidBucketType,
 		}
+		if p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+			fetchErr.failureStatus = ReceiptStatusErrSelfStakeBucketDisallowed
+		}
 		return nil, fetchErr
 	}
 	return bucket, nil
 }
 
+// checkBlockTime rejects the action from Greenland on if the time of the block is not set, see validateBlockTime
+func (p *Protocol) checkBlockTime(ctx context.Context, now time.Time) *handleError {
+	if !p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		return nil
+	}
+	if err := validateBlockTime(now); err != nil {
+		return &handleError{err: err, failureStatus: ReceiptStatusErrInvalidBlockTime}
+	}
+	return nil
+}
+
+// checkBucketTime rejects the action from Greenland on if the time of the block precedes the times of the bucket, see
+// validateBucketTime
+func (p *Protocol) checkBucketTime(ctx context.Context, now time.Time, bucket *VoteBucket) *handleError {
+	if !p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		return nil
+	}
+	if err := validateBucketTime(now, bucket); err != nil {
+		return &handleError{err: err, failureStatus: ReceiptStatusErrInvalidBlockTime}
+	}
+	return nil
+}
+
 func (p *Protocol) createLog(
 	ctx context.Context,
 	handlerName string,
@@ -684,6 +1054,39 @@ func (p *Protocol) createLog(
 	}
 }
 
+// registrationLogs creates the log of the candidate registration with the self-stake bucket, followed by the log of the
+// registration fee since Greenland
+func (p *Protocol) registrationLogs(
+	ctx context.Context,
+	candidateAddr,
+	callerAddr address.Address,
+	bucketIdx uint64,
+	fee *big.Int,
+) []*action.Log {
+	logs := []*action.Log{
+		p.createLog(ctx, HandleCandidateRegister, candidateAddr, callerAddr, byteutil.Uint64ToBytes(bucketIdx)),
+	}
+	if p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		logs = append(logs, p.createRegistrationFeeLog(ctx, candidateAddr, callerAddr, fee))
+	}
+	return logs
+}
+
+// createRegistrationFeeLog creates the log of the registration fee paid by the caller, the data is the address bytes of
+// the fee destination followed by the big-endian bytes of the fee
+func (p *Protocol) createRegistrationFeeLog(
+	ctx context.Context,
+	candidateAddr,
+	callerAddr address.Address,
+	fee *big.Int,
+) *action.Log {
+	dest, amount := p.feeDestination.Bytes(), fee.Bytes()
+	data := make([]byte, 0, len(dest)+len(amount))
+	data = append(data, dest...)
+	data = append(data, amount...)
+	return p.createLog(ctx, HandleRegistrationFee, candidateAddr, callerAddr, data)
+}
+
 func putBucketAndIndex(sm protocol.StateManager, bucket *VoteBucket) (uint64, error) {
 	index, err := putBucket(sm, bucket)
 	if err != nil {
@@ -700,6 +1103,33 @@ func putBucketAndIndex(sm protocol.StateManager, bucket *VoteBucket) (uint64, er
 	return index, nil
 }
 
+// putVoterCandIndex indexes the bucket by voter and candidate from Greenland on, the buckets created before are
+// indexed at Greenland by putAllVoterCandBucketIndices
+func (p *Protocol) putVoterCandIndex(
+	ctx context.Context,
+	sm protocol.StateManager,
+	voter, candidate address.Address,
+	index uint64,
+) error {
+	if !p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		return nil
+	}
+	return putVoterCandBucketIndex(sm, voter, candidate, index)
+}
+
+// delVoterCandIndex deletes the bucket from the index by voter and candidate, which exists from Greenland on
+func (p *Protocol) delVoterCandIndex(
+	ctx context.Context,
+	sm protocol.StateManager,
+	voter, candidate address.Address,
+	index uint64,
+) error {
+	if !p.isGreenland(protocol.MustGetBlockCtx(ctx).BlockHeight) {
+		return nil
+	}
+	return delVoterCandBucketIndex(sm, voter, candidate, index)
+}
+
 func fetchCaller(ctx context.Context, sm protocol.StateReader, amount *big.Int) (*state.Account, *big.Int, *fetchError) {
 	actionCtx := protocol.MustGetActionCtx(ctx)
 
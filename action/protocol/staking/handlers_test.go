@@ -8,6 +8,7 @@ package staking
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 	"testing"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/iotexproject/go-pkgs/byteutil"
 	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-proto/golang/iotextypes"
@@ -25,6 +27,7 @@ import (
 	accountutil "github.com/iotexproject/iotex-core/action/protocol/account/util"
 	"github.com/iotexproject/iotex-core/blockchain/genesis"
 	"github.com/iotexproject/iotex-core/pkg/unit"
+	"github.com/iotexproject/iotex-core/state"
 	"github.com/iotexproject/iotex-core/test/identityset"
 )
 
@@ -42,7 +45,7 @@ func TestProtocol_HandleCreateStake(t *testing.T) {
 	require.NoError(err)
 
 	// create protocol
-	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, GreenlandHeightOption(5))
 	require.NoError(err)
 
 	// set up candidate
@@ -97,6 +100,20 @@ func TestProtocol_HandleCreateStake(t *testing.T) {
 			10000,
 			iotextypes.ReceiptStatus_ErrCandidateNotExist,
 		},
+		{
+			100,
+			"notExist",
+			"10000000000000000000",
+			1,
+			false,
+			big.NewInt(unit.Qev),
+			10000,
+			1,
+			5,
+			time.Now(),
+			10000,
+			ReceiptStatusErrCandidateNotRegistered,
+		},
 		{
 			100,
 			candidateName,
@@ -166,33 +183,1400 @@ func TestProtocol_HandleCreateStake(t *testing.T) {
 	}
 }
 
-func setupAccount(sm protocol.StateManager, addr address.Address, balance int64) error {
-	if balance < 0 {
-		return errors.New("balance cannot be negative")
+func TestProtocol_HandleCreateStakeStateAccess(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	require.NoError(err)
+	candidate := testCandidates[0].d.Clone()
+	require.NoError(setupCandidate(p, sm, candidate))
+	stakerAddr := identityset.Address(2)
+	require.NoError(setupAccount(sm, stakerAddr, 100))
+
+	type access struct {
+		access    protocol.StateAccess
+		namespace string
+		key       []byte
 	}
-	account, err := accountutil.LoadOrCreateAccount(sm, addr.String())
-	if err != nil {
-		return err
+	var accesses []access
+	traced := protocol.NewTracedStateManager(sm, func(a protocol.StateAccess, ns string, key []byte) {
+		accesses = append(accesses, access{a, ns, key})
+	})
+
+	ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{
+		Caller:       stakerAddr,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+	ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       10000,
+	})
+	act, err := action.NewCreateStake(1, candidate.Name, "10000000000000000000", 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleCreateStake(ctx, act, traced)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+
+	callerKey := hash.BytesToHash160(stakerAddr.Bytes())
+	voterKey := addrKeyWithPrefix(stakerAddr, _voterIndex)
+	candKey := addrKeyWithPrefix(candidate.Owner, _candIndex)
+	vcKey := voterCandKey(stakerAddr, candidate.Owner)
+	require.Equal([]access{
+		// fetch the caller
+		{protocol.StateAccessRead, "", callerKey[:]},
+		// put the bucket and its indices
+		{protocol.StateAccessRead, StakingNameSpace, TotalBucketKey},
+		{protocol.StateAccessRead, StakingNameSpace, bucketKey(0)},
+		{protocol.StateAccessPut, StakingNameSpace, bucketKey(0)},
+		{protocol.StateAccessPut, StakingNameSpace, TotalBucketKey},
+		{protocol.StateAccessRead, StakingNameSpace, voterKey},
+		{protocol.StateAccessPut, StakingNameSpace, voterKey},
+		{protocol.StateAccessRead, StakingNameSpace, candKey},
+		{protocol.StateAccessPut, StakingNameSpace, candKey},
+		{protocol.StateAccessRead, StakingNameSpace, vcKey},
+		{protocol.StateAccessPut, StakingNameSpace, vcKey},
+		// update the candidate and the staker
+		{protocol.StateAccessPut, CandidateNameSpace, candidate.Owner.Bytes()},
+		{protocol.StateAccessPut, "", callerKey[:]},
+		// deposit the gas and increase the nonce
+		{protocol.StateAccessRead, "", callerKey[:]},
+		{protocol.StateAccessPut, "", callerKey[:]},
+		{protocol.StateAccessRead, "", callerKey[:]},
+		{protocol.StateAccessPut, "", callerKey[:]},
+	}, accesses)
+
+	// a nil tracer leaves the state manager as is
+	require.Equal(sm, protocol.NewTracedStateManager(sm, nil))
+}
+
+func TestProtocol_HandleCreateStakeWithRegistration(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	cfg.AutoRegisterCandidate = true
+	p, err := NewProtocol(depositGas, sm, cfg)
+	require.NoError(err)
+
+	stakerAddr := identityset.Address(1)
+	require.NoError(setupAccount(sm, stakerAddr, 1300000))
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       10000,
+	})
+	createStake := func(nonce uint64, name, amount string) *action.Receipt {
+		act, err := action.NewCreateStake(nonce, name, amount, 1, false,
+			nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCreateStake(protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       stakerAddr,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		}), act, sm)
+		require.NoError(err)
+		return r
 	}
-	account.Balance = unit.ConvertIotxToRau(balance)
-	return accountutil.StoreAccount(sm, addr.String(), account)
+
+	// the absent candidate is registered with the stake as its self-stake
+	r := createStake(1, "newcand", cfg.RegistrationConsts.MinSelfStake)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	require.Equal(2, len(r.Logs))
+	require.Equal(hash.Hash256b([]byte(HandleCandidateRegister)), r.Logs[0].Topics[0])
+	require.Equal(hash.Hash256b([]byte(HandleCreateStake)), r.Logs[1].Topics[0])
+	candidate := p.inMemCandidates.GetByName("newcand")
+	require.NotNil(candidate)
+	require.Equal(stakerAddr, candidate.Owner)
+	require.Equal(stakerAddr, candidate.Operator)
+	require.Equal(cfg.RegistrationConsts.MinSelfStake, candidate.SelfStake.String())
+	require.True(p.inMemCandidates.ContainsSelfStakingBucket(candidate.SelfStakeBucketIdx))
+	bucket, err := getBucket(sm, candidate.SelfStakeBucketIdx)
+	require.NoError(err)
+	require.Equal(stakerAddr, bucket.Candidate)
+	candidate, err = getCandidate(sm, stakerAddr)
+	require.NoError(err)
+	require.Equal("newcand", candidate.Name)
+
+	// the caller cannot register a second candidate
+	require.Equal(uint64(ReceiptStatusErrCandidateNotRegistered), createStake(2, "othercand", "1000000000000000000").Status)
+	require.Nil(p.inMemCandidates.GetByName("othercand"))
 }
 
-func setupCandidate(p *Protocol, sm protocol.StateManager, candidate *Candidate) error {
-	if err := putCandidate(sm, candidate); err != nil {
-		return err
+func TestProtocol_HandleCandidateRegisterMaxCandidates(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	// create protocol with a cap of 2 candidates
+	cfg := genesis.Default.Staking
+	cfg.MaxCandidates = 2
+	p, err := NewProtocol(depositGas, sm, cfg)
+	require.NoError(err)
+	require.Equal(uint64(2), p.StakingConfig().MaxCandidates)
+
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	register := func(i int, name string) *action.Receipt {
+		owner := identityset.Address(i)
+		require.NoError(setupAccount(sm, owner, 1300000))
+		act, err := action.NewCandidateRegister(1, name, owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateRegister(protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		}), act, sm)
+		require.NoError(err)
+		return r
 	}
-	p.inMemCandidates.Upsert(candidate)
-	return nil
+
+	// register up to the cap
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(1, "test1").Status)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(2, "test2").Status)
+
+	// the cap is hit
+	require.Equal(uint64(ReceiptStatusErrExceedMaxCandidateNumber), register(3, "test3").Status)
+	require.Nil(p.inMemCandidates.GetByName("test3"))
+
+	// resigning by unstaking the self-stake bucket frees a slot
+	owner := identityset.Address(1)
+	c := p.inMemCandidates.GetByOwner(owner)
+	require.NotNil(c)
+	act, err := action.NewUnstake(2, c.SelfStakeBucketIdx, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleUnstake(protocol.WithActionCtx(ctx, protocol.ActionCtx{
+		Caller:       owner,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        2,
+	}), act, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(4, "test4").Status)
 }
 
-func depositGas(ctx context.Context, sm protocol.StateManager, gasFee *big.Int) error {
-	actionCtx := protocol.MustGetActionCtx(ctx)
-	// Subtract balance from caller
-	acc, err := accountutil.LoadAccount(sm, hash.BytesToHash160(actionCtx.Caller.Bytes()))
-	if err != nil {
-		return err
+func TestProtocol_HandleCandidateRegisterFeeLog(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(2))
+	require.NoError(err)
+
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	register := func(ctx context.Context, p *Protocol, owner address.Address, name string) *action.Receipt {
+		require.NoError(setupAccount(sm, owner, 1300000))
+		act, err := action.NewCandidateRegister(1, name, owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateRegister(protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		}), act, sm)
+		require.NoError(err)
+		require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+		return r
+	}
+
+	// the fee log is emitted since Greenland
+	r := register(ctx, p, identityset.Address(3), "test3")
+	require.Equal(1, len(r.Logs))
+	require.Equal(hash.Hash256b([]byte(HandleCandidateRegister)), r.Logs[0].Topics[0])
+	ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    2,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	owner := identityset.Address(1)
+	r = register(ctx, p, owner, "test1")
+	require.Equal(2, len(r.Logs))
+
+	// the register log is unchanged
+	require.Equal(hash.Hash256b([]byte(HandleCandidateRegister)), r.Logs[0].Topics[0])
+
+	// the fee log records the fee and the reward pool it is deposited into
+	feeLog := r.Logs[1]
+	require.Equal(p.addr.String(), feeLog.Address)
+	require.Equal(3, len(feeLog.Topics))
+	require.Equal(hash.Hash256b([]byte(HandleRegistrationFee)), feeLog.Topics[0])
+	require.Equal(hash.Hash256b(owner.Bytes()), feeLog.Topics[1])
+	require.Equal(hash.Hash256b(owner.Bytes()), feeLog.Topics[2])
+	require.True(len(feeLog.Data) > 20)
+	destination, err := address.FromBytes(feeLog.Data[:20])
+	require.NoError(err)
+	h := hash.Hash160b([]byte("rewarding"))
+	rewardingAddr, err := address.FromBytes(h[:])
+	require.NoError(err)
+	require.Equal(rewardingAddr.String(), destination.String())
+	require.Equal(cfg.RegistrationConsts.Fee, new(big.Int).SetBytes(feeLog.Data[20:]).String())
+
+	// the destination can be overridden
+	treasury := identityset.Address(30)
+	p, err = NewProtocol(depositGas, sm, cfg, RegistrationFeeDestinationOption(treasury), GreenlandHeightOption(2))
+	require.NoError(err)
+	r = register(ctx, p, identityset.Address(2), "test2")
+	require.Equal(treasury.Bytes(), r.Logs[1].Data[:20])
+}
+
+func TestProtocol_HandleCandidateLastUpdateHeight(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(3))
+	require.NoError(err)
+
+	actCtx := func(height uint64, caller address.Address) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		})
+	}
+	register := func(height uint64, owner address.Address, name string) {
+		require.NoError(setupAccount(sm, owner, 1300000))
+		act, err := action.NewCandidateRegister(1, name, owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateRegister(actCtx(height, owner), act, sm)
+		require.NoError(err)
+		require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	}
+	update := func(height uint64, owner address.Address) {
+		act, err := action.NewCandidateUpdate(2, "", "", identityset.Address(20).String(), 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateUpdate(actCtx(height, owner), act, sm)
+		require.NoError(err)
+		require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	}
+	lastUpdateHeight := func(owner address.Address) uint64 {
+		c, err := getCandidate(sm, owner)
+		require.NoError(err)
+		return c.LastUpdateHeight
+	}
+
+	// the height is not written before Greenland, so the candidates keep their serialization
+	owner := identityset.Address(1)
+	register(1, owner, "test1")
+	require.Zero(lastUpdateHeight(owner))
+	update(2, owner)
+	require.Zero(lastUpdateHeight(owner))
+
+	update(3, owner)
+	require.Equal(uint64(3), lastUpdateHeight(owner))
+	owner = identityset.Address(2)
+	register(4, owner, "test2")
+	require.Equal(uint64(4), lastUpdateHeight(owner))
+}
+
+func TestProtocol_FetchBucket(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, GreenlandHeightOption(2))
+	require.NoError(err)
+
+	owner := identityset.Address(1)
+	stakerAddr := identityset.Address(2)
+	require.NoError(setupAccount(sm, owner, 1300000))
+	require.NoError(setupAccount(sm, stakerAddr, 1000))
+	actCtxAt := func(height uint64, caller address.Address, nonce uint64) context.Context {
+		blkCtx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(blkCtx, protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	actCtx := func(caller address.Address, nonce uint64) context.Context {
+		return actCtxAt(2, caller, nonce)
+	}
+	register, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+		genesis.Default.Staking.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleCandidateRegister(actCtx(owner, 1), register, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	create, err := action.NewCreateStake(1, "test1", "100000000000000000000", 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleCreateStake(actCtx(stakerAddr, 1), create, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	selfStakeIdx := p.inMemCandidates.GetByOwner(owner).SelfStakeBucketIdx
+	bucketIdx := selfStakeIdx + 1
+
+	tests := []struct {
+		caller           address.Address
+		index            uint64
+		allowSelfStaking bool
+		status           iotextypes.ReceiptStatus
+	}{
+		{stakerAddr, 100, true, iotextypes.ReceiptStatus_ErrInvalidBucketIndex},
+		{owner, bucketIdx, true, iotextypes.ReceiptStatus_ErrUnauthorizedOperator},
+		{owner, selfStakeIdx, false, ReceiptStatusErrSelfStakeBucketDisallowed},
+		{owner, selfStakeIdx, true, iotextypes.ReceiptStatus_Success},
+		{stakerAddr, bucketIdx, false, iotextypes.ReceiptStatus_Success},
+	}
+	for _, test := range tests {
+		bucket, fetchErr := p.fetchBucket(actCtx(test.caller, 2), sm, test.index, true, test.allowSelfStaking)
+		if test.status == iotextypes.ReceiptStatus_Success {
+			require.Nil(fetchErr)
+			require.Equal(test.index, bucket.Index)
+			continue
+		}
+		require.NotNil(fetchErr)
+		require.Equal(test.status, fetchErr.failureStatus)
+	}
+
+	// the self-staking bucket is reported as an invalid bucket type before Greenland
+	_, fetchErr := p.fetchBucket(actCtxAt(1, owner, 2), sm, selfStakeIdx, true, false)
+	require.NotNil(fetchErr)
+	require.Equal(iotextypes.ReceiptStatus_ErrInvalidBucketType, fetchErr.failureStatus)
+
+	// the handlers report the specific status
+	change, err := action.NewChangeCandidate(2, "test1", selfStakeIdx, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.Handle(actCtx(owner, 2), change, sm)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrSelfStakeBucketDisallowed), r.Status)
+	deposit, err := action.NewDepositToStake(2, bucketIdx, "10000000000000000000", nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.Handle(actCtx(stakerAddr, 2), deposit, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_ErrInvalidBucketType), r.Status)
+}
+
+func TestProtocol_HandleCandidateName(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	cfg.NameConsts = genesis.NameConsts{
+		Height:    5,
+		MaxLength: 6,
+		Charset:   "abc123",
+	}
+	p, err := NewProtocol(depositGas, sm, cfg)
+	require.NoError(err)
+
+	owner := identityset.Address(1)
+	require.NoError(setupAccount(sm, owner, 1300000))
+	ctx := func(height, nonce uint64) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	register := func(height, nonce uint64, name string) uint64 {
+		act, err := action.NewCandidateRegister(nonce, name, owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateRegister(ctx(height, nonce), act, sm)
+		require.NoError(err)
+		return r.Status
+	}
+	update := func(height, nonce uint64, name string) uint64 {
+		act, err := action.NewCandidateUpdate(nonce, name, "", "", 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateUpdate(ctx(height, nonce), act, sm)
+		require.NoError(err)
+		return r.Status
+	}
+
+	// the legacy rules apply before the height
+	require.True(p.isValidCandidateName("abcdefgh", 4))
+	require.False(p.isValidCandidateName("abcdefgh", 5))
+
+	// a too long name and a name of illegal characters are rejected
+	require.Equal(uint64(ReceiptStatusErrInvalidCanName), register(5, 1, "abc123a"))
+	require.Equal(uint64(ReceiptStatusErrInvalidCanName), register(5, 2, "abcd"))
+	require.False(p.inMemCandidates.ContainsOwner(owner))
+	invalid, err := action.NewCandidateRegister(3, "abcd", owner.String(), owner.String(), owner.String(),
+		cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	require.Equal(ErrInvalidCanName, errors.Cause(p.Validate(ctx(5, 3), invalid)))
+
+	// a valid name
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(5, 3, "abc123"))
+	require.Equal("abc123", p.inMemCandidates.GetByOwner(owner).Name)
+
+	// the same rules apply to renaming
+	require.Equal(uint64(ReceiptStatusErrInvalidCanName), update(6, 4, "abc1234"))
+	require.Equal(uint64(ReceiptStatusErrInvalidCanName), update(6, 5, "xyz"))
+	require.Equal("abc123", p.inMemCandidates.GetByOwner(owner).Name)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), update(6, 6, "cab"))
+	c, err := getCandidate(sm, owner)
+	require.NoError(err)
+	require.Equal("cab", c.Name)
+}
+
+func TestProtocol_BucketCreateTimeImmutable(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	require.NoError(err)
+
+	cand := identityset.Address(1)
+	voter := identityset.Address(2)
+	require.NoError(setupAccount(sm, voter, 1000))
+	createTime := time.Now().Add(-30 * 24 * time.Hour)
+	index, err := putBucketAndIndex(sm, NewVoteBucket(cand, voter, big.NewInt(100), 7, createTime, true))
+	require.NoError(err)
+	require.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              cand,
+		Operator:           cand,
+		Reward:             cand,
+		Name:               "test1",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: 100,
+		SelfStake:          big.NewInt(0),
+	}))
+	ctime, err := p.BucketCreateTime(sm, index)
+	require.NoError(err)
+	require.True(createTime.Equal(ctime))
+
+	ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{
+		Caller:       voter,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+	ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	deposit, err := action.NewDepositToStake(1, index, "10", nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleDepositToStake(ctx, deposit, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	restake, err := action.NewRestake(2, index, 14, true, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleRestake(ctx, restake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+
+	// the bucket is updated but the create time is unchanged
+	bucket, err := getBucket(sm, index)
+	require.NoError(err)
+	require.Equal(big.NewInt(110), bucket.StakedAmount)
+	require.Equal(14*24*time.Hour, bucket.StakedDuration)
+	ctime, err = p.BucketCreateTime(sm, index)
+	require.NoError(err)
+	require.True(createTime.Equal(ctime))
+
+	// the create time cannot be changed
+	bucket.CreateTime = time.Now()
+	require.Equal(ErrBucketCreateTimeChanged, errors.Cause(updateBucket(sm, index, bucket)))
+	_, err = p.BucketCreateTime(sm, index+1)
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+}
+
+type heightStateReader struct {
+	protocol.StateReader
+	height uint64
+}
+
+func (sr *heightStateReader) Height() (uint64, error) {
+	return sr.height, nil
+}
+
+func TestProtocol_CandidateChangeCooldown(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	cfg.OperatorChangeCooldown = 100
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(10))
+	require.NoError(err)
+	require.Equal(uint64(100), p.StakingConfig().OperatorChangeCooldown)
+
+	owner := identityset.Address(1)
+	require.NoError(setupAccount(sm, owner, 1300000))
+	actCtx := func(height, nonce uint64) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	register, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+		cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleCandidateRegister(actCtx(1, 1), register, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	updateOperator := func(height, nonce uint64, operator address.Address) *action.Receipt {
+		act, err := action.NewCandidateUpdate(nonce, "", operator.String(), "", 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateUpdate(actCtx(height, nonce), act, sm)
+		require.NoError(err)
+		return r
+	}
+	cooldown := func(height uint64) uint64 {
+		c, err := p.CandidateChangeCooldown(&heightStateReader{sm, height}, owner)
+		require.NoError(err)
+		return c.Operator
+	}
+
+	// the operator has never been changed
+	require.Zero(cooldown(5))
+
+	// the change height is not recorded before Greenland, so no cooldown applies
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), updateOperator(5, 2, identityset.Address(13)).Status)
+	require.Zero(p.inMemCandidates.GetByOwner(owner).OperatorUpdateHeight)
+	require.Zero(cooldown(6))
+
+	// change the operator at height 10
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), updateOperator(10, 3, identityset.Address(11)).Status)
+	require.Equal(uint64(10), p.inMemCandidates.GetByOwner(owner).OperatorUpdateHeight)
+
+	// the remaining cooldown decreases with height
+	require.Equal(uint64(89), cooldown(20))
+	require.Equal(uint64(49), cooldown(60))
+	require.Equal(uint64(1), cooldown(108))
+	require.Zero(cooldown(109))
+	require.Zero(cooldown(200))
+
+	// the operator cannot be changed again within the cooldown
+	require.Equal(uint64(ReceiptStatusErrChangeCooldown), updateOperator(60, 4, identityset.Address(12)).Status)
+	require.Equal(identityset.Address(11), p.inMemCandidates.GetByOwner(owner).Operator)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), updateOperator(110, 5, identityset.Address(12)).Status)
+	require.Equal(identityset.Address(12), p.inMemCandidates.GetByOwner(owner).Operator)
+	require.Equal(uint64(99), cooldown(110))
+
+	// unknown candidate
+	_, err = p.CandidateChangeCooldown(&heightStateReader{sm, 110}, identityset.Address(2))
+	require.Error(err)
+}
+
+func TestProtocol_HandleOperatorConflict(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(2))
+	require.NoError(err)
+
+	owner1 := identityset.Address(1)
+	owner2 := identityset.Address(2)
+	operator1 := identityset.Address(11)
+	operator2 := identityset.Address(12)
+	require.NoError(setupAccount(sm, owner1, 1300000))
+	require.NoError(setupAccount(sm, owner2, 1300000))
+	actCtx := func(height uint64, caller address.Address, nonce uint64) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	register := func(owner, operator address.Address, name string) *action.Receipt {
+		act, err := action.NewCandidateRegister(1, name, operator.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateRegister(actCtx(2, owner, 1), act, sm)
+		require.NoError(err)
+		return r
+	}
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(owner1, operator1, "test1").Status)
+	require.Equal(operator1, p.inMemCandidates.GetByOperator(operator1).Operator)
+	require.Nil(p.inMemCandidates.GetByOperator(operator2))
+
+	// duplicate operator at registration
+	require.Equal(uint64(ReceiptStatusErrCandidateConflict), register(owner2, operator1, "test2").Status)
+	require.Nil(p.inMemCandidates.GetByOwner(owner2))
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), register(owner2, operator2, "test2").Status)
+
+	update := func(nonce uint64, operator address.Address) *action.Receipt {
+		act, err := action.NewCandidateUpdate(nonce, "", operator.String(), "", 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCandidateUpdate(actCtx(2, owner2, nonce), act, sm)
+		require.NoError(err)
+		return r
+	}
+	// duplicate operator at update
+	require.Equal(uint64(ReceiptStatusErrCandidateConflict), update(2, operator1).Status)
+	require.Equal(operator2, p.inMemCandidates.GetByOwner(owner2).Operator)
+	require.Equal(owner1, p.inMemCandidates.GetByOperator(operator1).Owner)
+
+	// a candidate can reuse its own operator
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), update(3, operator2).Status)
+	require.Equal(owner2, p.inMemCandidates.GetByOperator(operator2).Owner)
+	// and the operator of a candidate can be changed to a free one
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), update(4, identityset.Address(13)).Status)
+	require.Nil(p.inMemCandidates.GetByOperator(operator2))
+
+	// before Greenland the candidate center rejects the duplicate operator
+	owner3 := identityset.Address(3)
+	require.NoError(setupAccount(sm, owner3, 1300000))
+	act, err := action.NewCandidateRegister(1, "test3", operator1.String(), owner3.String(), owner3.String(),
+		cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	_, err = p.handleCandidateRegister(actCtx(1, owner3, 1), act, sm)
+	require.Equal(ErrInvalidOperator, errors.Cause(err))
+}
+
+func TestProtocol_HandleMinSelfStakeDuration(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	cfg.RegistrationConsts.MinSelfStakeDuration = 30
+	p, err := NewProtocol(depositGas, sm, cfg)
+	require.NoError(err)
+
+	owner := identityset.Address(1)
+	require.NoError(setupAccount(sm, owner, 1300000))
+	blkCtx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	ctx := protocol.WithActionCtx(blkCtx, protocol.ActionCtx{
+		Caller:       owner,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+	newRegister := func(duration uint32, autoStake bool) *action.CandidateRegister {
+		register, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, duration, autoStake, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		return register
+	}
+
+	// a self-stake locked for too short is rejected, unless it is auto-staked
+	require.Equal(ErrInvalidDuration, errors.Cause(p.validateCandidateRegister(ctx, newRegister(29, false))))
+	require.NoError(p.validateCandidateRegister(ctx, newRegister(29, true)))
+	require.NoError(p.validateCandidateRegister(ctx, newRegister(30, false)))
+
+	r, err := p.handleCandidateRegister(ctx, newRegister(30, false), sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	c := p.inMemCandidates.GetByOwner(owner)
+	require.NotNil(c)
+
+	// the self-stake bucket cannot be restaked below the minimum duration
+	restake := func(nonce uint64, duration uint32, autoStake bool) (*action.Receipt, error) {
+		act, err := action.NewRestake(nonce, c.SelfStakeBucketIdx, duration, autoStake, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		return p.handleRestake(protocol.WithActionCtx(blkCtx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		}), act, sm)
+	}
+	r, err = restake(2, 7, false)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrSelfStakeDurationTooShort), r.Status)
+	bucket, err := getBucket(sm, c.SelfStakeBucketIdx)
+	require.NoError(err)
+	require.Equal(30*24*time.Hour, bucket.StakedDuration)
+	r, err = restake(3, 7, true)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err = getBucket(sm, c.SelfStakeBucketIdx)
+	require.NoError(err)
+	require.Equal(7*24*time.Hour, bucket.StakedDuration)
+	require.True(bucket.AutoStake)
+}
+
+func TestProtocol_HandleRestakeAutoStakeOff(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	now := time.Unix(1600000000, 0)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, ClockOption(func(context.Context) time.Time { return now }), GreenlandHeightOption(2))
+	require.NoError(err)
+	candidate := testCandidates[0].d.Clone()
+	require.NoError(setupCandidate(p, sm, candidate))
+	staker := identityset.Address(2)
+	require.NoError(setupAccount(sm, staker, 1000))
+	actCtx := func(height, nonce uint64) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: now,
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       staker,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+
+	createTime := now
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		create, err := action.NewCreateStake(nonce, candidate.Name, unit.ConvertIotxToRau(100).String(), 7, true, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleCreateStake(actCtx(1, nonce), create, sm)
+		require.NoError(err)
+		require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	}
+	bucket, err := getBucket(sm, 0)
+	require.NoError(err)
+	require.True(now.Equal(bucket.StakeStartTime))
+
+	// the auto-stake bucket stays locked long after its duration
+	now = now.Add(30 * 24 * time.Hour)
+	require.Equal(BucketLocked, bucket.status(now, 0))
+
+	// before Greenland, turning auto-stake off keeps the stake start time
+	restake, err := action.NewRestake(3, 0, 7, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleRestake(actCtx(1, 3), restake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err = getBucket(sm, 0)
+	require.NoError(err)
+	require.False(bucket.AutoStake)
+	require.True(createTime.Equal(bucket.StakeStartTime))
+	require.Equal(BucketUnlocked, bucket.status(now, 0))
+
+	// from Greenland on, turning auto-stake off starts the countdown of the full duration
+	restake, err = action.NewRestake(4, 1, 7, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleRestake(actCtx(2, 4), restake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err = getBucket(sm, 1)
+	require.NoError(err)
+	require.False(bucket.AutoStake)
+	require.True(now.Equal(bucket.StakeStartTime))
+	require.Equal(7*24*time.Hour, bucket.remainingLock(now))
+	require.Equal(BucketLocked, bucket.status(now.Add(7*24*time.Hour-time.Second), 0))
+	require.Equal(BucketUnlocked, bucket.status(now.Add(7*24*time.Hour), 0))
+
+	// restaking a bucket without auto-stake keeps its countdown
+	now = now.Add(24 * time.Hour)
+	restake, err = action.NewRestake(5, 1, 7, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleRestake(actCtx(2, 5), restake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err = getBucket(sm, 1)
+	require.NoError(err)
+	require.Equal(6*24*time.Hour, bucket.remainingLock(now))
+}
+
+func TestProtocol_Clock(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	// the handlers go by the injected clock instead of the timestamp of the block
+	now := time.Unix(1600000000, 0)
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, ClockOption(func(context.Context) time.Time { return now }))
+	require.NoError(err)
+	_, err = NewProtocol(depositGas, sm, cfg, ClockOption(nil))
+	require.Error(err)
+
+	owner := identityset.Address(1)
+	staker := identityset.Address(2)
+	require.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              owner,
+		Operator:           identityset.Address(11),
+		Reward:             owner,
+		Name:               "test1",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: 100,
+		SelfStake:          big.NewInt(0),
+	}))
+	require.NoError(setupAccount(sm, staker, 1000))
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Unix(0, 0),
+		GasLimit:       1000000,
+	})
+	actCtx := func(nonce uint64) context.Context {
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       staker,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+
+	create, err := action.NewCreateStake(1, "test1", unit.ConvertIotxToRau(100).String(), 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleCreateStake(actCtx(1), create, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err := getBucket(sm, 0)
+	require.NoError(err)
+	require.True(now.Equal(bucket.CreateTime))
+	require.True(now.Equal(bucket.StakeStartTime))
+
+	now = now.Add(time.Hour)
+	unstake, err := action.NewUnstake(2, 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleUnstake(actCtx(2), unstake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	bucket, err = getBucket(sm, 0)
+	require.NoError(err)
+	require.True(now.Equal(bucket.UnstakeStartTime))
+	require.Equal(BucketUnstaking, bucket.status(now, cfg.WithdrawWaitingPeriod))
+
+	withdraw := func(nonce uint64) uint64 {
+		act, err := action.NewWithdrawStake(nonce, 0, nil, 10000, big.NewInt(unit.Qev))
+		require.NoError(err)
+		r, err := p.handleWithdrawStake(actCtx(nonce), act, sm)
+		require.NoError(err)
+		return r.Status
+	}
+	// the bucket matures when the waiting period has passed on the clock
+	now = bucket.UnstakeStartTime.Add(cfg.WithdrawWaitingPeriod - time.Second)
+	require.Equal(uint64(iotextypes.ReceiptStatus_ErrWithdrawBeforeMaturity), withdraw(3))
+	now = now.Add(time.Second)
+	require.Equal(BucketWithdrawable, bucket.status(now, cfg.WithdrawWaitingPeriod))
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), withdraw(4))
+	_, err = getBucket(sm, 0)
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+}
+
+func TestProtocol_HandleInvalidBlockTime(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(2))
+	require.NoError(err)
+
+	owner := identityset.Address(1)
+	staker := identityset.Address(2)
+	require.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              owner,
+		Operator:           identityset.Address(11),
+		Reward:             owner,
+		Name:               "test1",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: 100,
+		SelfStake:          big.NewInt(0),
+	}))
+	require.NoError(setupAccount(sm, staker, 1000))
+	actCtx := func(nonce uint64, ts time.Time) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    2,
+			BlockTimeStamp: ts,
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       staker,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	create, err := action.NewCreateStake(1, "test1", unit.ConvertIotxToRau(100).String(), 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	unstake, err := action.NewUnstake(2, 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	withdraw, err := action.NewWithdrawStake(3, 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+
+	// a zero timestamp is rejected
+	for _, ts := range []time.Time{{}, time.Unix(0, 0)} {
+		r, err := p.handleCreateStake(actCtx(1, ts), create, sm)
+		require.NoError(err)
+		require.Equal(uint64(ReceiptStatusErrInvalidBlockTime), r.Status)
+	}
+	_, err = getBucket(sm, 0)
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+	now := time.Unix(1600000000, 0)
+	r, err := p.handleCreateStake(actCtx(1, now), create, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+
+	// a timestamp going backwards is rejected, without changing the bucket
+	r, err = p.handleUnstake(actCtx(2, now.Add(-time.Second)), unstake, sm)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrInvalidBlockTime), r.Status)
+	r, err = p.handleUnstake(actCtx(2, time.Unix(0, 0)), unstake, sm)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrInvalidBlockTime), r.Status)
+	bucket, err := getBucket(sm, 0)
+	require.NoError(err)
+	require.Equal(int64(0), bucket.UnstakeStartTime.Unix())
+
+	unstakeTime := now.Add(time.Hour)
+	r, err = p.handleUnstake(actCtx(2, unstakeTime), unstake, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	r, err = p.handleWithdrawStake(actCtx(3, unstakeTime.Add(-time.Second)), withdraw, sm)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrInvalidBlockTime), r.Status)
+	r, err = p.handleWithdrawStake(actCtx(3, time.Time{}), withdraw, sm)
+	require.NoError(err)
+	require.Equal(uint64(ReceiptStatusErrInvalidBlockTime), r.Status)
+
+	// a sane timestamp before maturity is still reported by the receipt
+	r, err = p.handleWithdrawStake(actCtx(3, unstakeTime), withdraw, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_ErrWithdrawBeforeMaturity), r.Status)
+	r, err = p.handleWithdrawStake(actCtx(3, unstakeTime.Add(cfg.WithdrawWaitingPeriod)), withdraw, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+
+	// the timestamps are not checked before Greenland
+	ctx := protocol.WithBlockCtx(actCtx(4, time.Time{}), protocol.BlockCtx{
+		BlockHeight: 1,
+		GasLimit:    1000000,
+	})
+	r, err = p.handleCreateStake(ctx, create, sm)
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+}
+
+func TestProtocol_BucketsByVoterAndCandidate(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	now := time.Unix(1600000000, 0)
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg, ClockOption(func(context.Context) time.Time { return now }), GreenlandHeightOption(2))
+	require.NoError(err)
+
+	owner1 := identityset.Address(1)
+	owner2 := identityset.Address(2)
+	for i, owner := range []address.Address{owner1, owner2} {
+		require.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: uint64(100 + i),
+			SelfStake:          big.NewInt(0),
+		}))
+	}
+	staker := identityset.Address(3)
+	receiver := identityset.Address(4)
+	require.NoError(setupAccount(sm, staker, 1000))
+	blkCtx := func(height uint64) context.Context {
+		return protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: now,
+			GasLimit:       1000000,
+		})
+	}
+	actCtxAt := func(height, nonce uint64) context.Context {
+		return protocol.WithActionCtx(blkCtx(height), protocol.ActionCtx{
+			Caller:       staker,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+	actCtx := func(nonce uint64) context.Context {
+		return actCtxAt(2, nonce)
+	}
+	checkStatus := func(r *action.Receipt, err error) {
+		require.NoError(err)
+		require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+	}
+	checkIndexes := func(voter, candidate address.Address, expected []uint64) {
+		indexes, err := p.BucketsByVoterAndCandidate(sm, voter, candidate)
+		require.NoError(err)
+		require.Equal(expected, indexes)
+	}
+
+	// the bucket created before Greenland is not indexed until the index is backfilled at Greenland
+	create, err := action.NewCreateStake(1, "test1", unit.ConvertIotxToRau(100).String(), 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err := p.handleCreateStake(actCtxAt(1, 1), create, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner1, nil)
+	require.NoError(p.CreatePreStates(blkCtx(2), sm))
+	checkIndexes(staker, owner1, []uint64{0})
+
+	// create adds the bucket to the index
+	create, err = action.NewCreateStake(2, "test1", unit.ConvertIotxToRau(100).String(), 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleCreateStake(actCtx(2), create, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner1, []uint64{0, 1})
+	checkIndexes(staker, owner2, nil)
+
+	// change candidate moves the bucket to the new candidate
+	change, err := action.NewChangeCandidate(3, "test2", 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleChangeCandidate(actCtx(3), change, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner1, []uint64{1})
+	checkIndexes(staker, owner2, []uint64{0})
+
+	// transfer moves the bucket to the new owner
+	transfer, err := action.NewTransferStake(4, receiver.String(), 1, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleTransferStake(actCtx(4), transfer, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner1, nil)
+	checkIndexes(receiver, owner1, []uint64{1})
+
+	// withdraw removes the bucket
+	unstake, err := action.NewUnstake(5, 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleUnstake(actCtx(5), unstake, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner2, []uint64{0})
+	now = now.Add(cfg.WithdrawWaitingPeriod)
+	withdraw, err := action.NewWithdrawStake(6, 0, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	r, err = p.handleWithdrawStake(actCtx(6), withdraw, sm)
+	checkStatus(r, err)
+	checkIndexes(staker, owner2, nil)
+	checkIndexes(receiver, owner1, []uint64{1})
+}
+
+func TestProtocol_MaxLogDataSize(t *testing.T) {
+	require := require.New(t)
+
+	owner := identityset.Address(1)
+	staker := identityset.Address(2)
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	ctx = protocol.WithActionCtx(ctx, protocol.ActionCtx{
+		Caller:       staker,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+	// the log of a stake creation carries the 8-byte index of the bucket
+	act, err := action.NewCreateStake(1, "test1", "100000000000000000000", 1, false, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+
+	for _, test := range []struct {
+		maxSize uint64
+		err     error
+	}{
+		{DefaultMaxLogDataSize, nil},
+		{8, nil},
+		{7, ErrLogDataTooLarge},
+		{0, ErrLogDataTooLarge},
+	} {
+		ctrl := gomock.NewController(t)
+		sm := newMockStateManager(ctrl)
+		_, err := sm.PutState(
+			&totalBucketCount{count: 0},
+			protocol.NamespaceOption(StakingNameSpace),
+			protocol.KeyOption(TotalBucketKey),
+		)
+		require.NoError(err)
+		require.NoError(setupAccount(sm, staker, 1000))
+		p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, MaxLogDataSizeOption(test.maxSize))
+		require.NoError(err)
+		require.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           owner,
+			Reward:             owner,
+			Name:               "test1",
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: 1,
+			SelfStake:          big.NewInt(0),
+		}))
+		r, err := p.handleCreateStake(ctx, act, sm)
+		require.Equal(test.err, errors.Cause(err))
+		if test.err == nil {
+			require.Equal(uint64(iotextypes.ReceiptStatus_Success), r.Status)
+			require.Equal(byteutil.Uint64ToBytes(0), r.Logs[0].Data)
+		} else {
+			require.Nil(r)
+		}
+		ctrl.Finish()
+	}
+}
+
+func TestProtocol_MaxLogTopics(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+	owner := identityset.Address(1)
+	require.NoError(setupAccount(sm, owner, 1000))
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	require.NoError(err)
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	ctx = protocol.WithActionCtx(ctx, protocol.ActionCtx{
+		Caller:       owner,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+
+	// the logs created by the handlers are within the cap
+	l := p.createLog(ctx, HandleCreateStake, identityset.Address(2), owner, nil)
+	require.True(len(l.Topics) <= MaxLogTopics)
+
+	for _, test := range []struct {
+		topics int
+		err    error
+	}{
+		{MaxLogTopics, nil},
+		{MaxLogTopics + 1, ErrTooManyLogTopics},
+	} {
+		l := p.createLog(ctx, HandleCreateStake, nil, owner, nil)
+		for len(l.Topics) < test.topics {
+			l.Topics = append(l.Topics, hash.Hash256b([]byte{byte(len(l.Topics))}))
+		}
+		r, err := p.settleAction(ctx, sm, uint64(iotextypes.ReceiptStatus_Success), big.NewInt(0), l)
+		require.Equal(test.err, errors.Cause(err))
+		if test.err == nil {
+			require.Equal(test.topics, len(r.Logs[0].Topics))
+		} else {
+			require.Nil(r)
+		}
+	}
+}
+
+func setupAccount(sm protocol.StateManager, addr address.Address, balance int64) error {
+	if balance < 0 {
+		return errors.New("balance cannot be negative")
+	}
+	account, err := accountutil.LoadOrCreateAccount(sm, addr.String())
+	if err != nil {
+		return err
+	}
+	account.Balance = unit.ConvertIotxToRau(balance)
+	return accountutil.StoreAccount(sm, addr.String(), account)
+}
+
+func setupCandidate(p *Protocol, sm protocol.StateManager, candidate *Candidate) error {
+	if err := putCandidate(sm, candidate); err != nil {
+		return err
+	}
+	p.inMemCandidates.Upsert(candidate)
+	return nil
+}
+
+func depositGas(ctx context.Context, sm protocol.StateManager, gasFee *big.Int) error {
+	actionCtx := protocol.MustGetActionCtx(ctx)
+	// Subtract balance from caller
+	acc, err := accountutil.LoadAccount(sm, hash.BytesToHash160(actionCtx.Caller.Bytes()))
+	if err != nil {
+		return err
+	}
+	acc.Balance = big.NewInt(0).Sub(acc.Balance, gasFee)
+	return accountutil.StoreAccount(sm, actionCtx.Caller.String(), acc)
+}
+
+func TestPrivateReceiptStatus(t *testing.T) {
+	require := require.New(t)
+
+	for _, status := range []iotextypes.ReceiptStatus{
+		ReceiptStatusErrExceedMaxCandidateNumber,
+		ReceiptStatusErrCandidateNotRegistered,
+		ReceiptStatusErrChangeCooldown,
+		ReceiptStatusErrExceedRegistrationsPerEpoch,
+		ReceiptStatusErrCandidateConflict,
+		ReceiptStatusErrPaused,
+		ReceiptStatusErrSelfStakeBucketDisallowed,
+		ReceiptStatusErrSelfStakeDurationTooShort,
+		ReceiptStatusErrInvalidCanName,
+		ReceiptStatusErrInvalidBlockTime,
+	} {
+		require.True(status >= ReceiptStatusPrivateStart && status < ReceiptStatusPrivateEnd)
+		_, ok := iotextypes.ReceiptStatus_name[int32(status)]
+		require.False(ok)
+	}
+	for status := range iotextypes.ReceiptStatus_name {
+		require.True(iotextypes.ReceiptStatus(status) < ReceiptStatusPrivateStart)
 	}
-	acc.Balance = big.NewInt(0).Sub(acc.Balance, gasFee)
-	return accountutil.StoreAccount(sm, actionCtx.Caller.String(), acc)
 }
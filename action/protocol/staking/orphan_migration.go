@@ -0,0 +1,73 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/pkg/log"
+)
+
+// migrateOrphanedBuckets moves the orphaned buckets to the default candidate and adds their votes to it. The buckets
+// which have been unstaked are moved as well, but no longer count as votes. The migration runs before the actions of
+// the block and has no receipt, so each migrated bucket is only logged by the node
+func (p *Protocol) migrateOrphanedBuckets(ctx context.Context, sm protocol.StateManager) error {
+	buckets, err := p.OrphanedBuckets(sm)
+	if err != nil {
+		return err
+	}
+	if len(buckets) == 0 {
+		return nil
+	}
+	candidate := p.inMemCandidates.GetByOwner(p.orphanCandidate)
+	if candidate == nil {
+		log.L().Warn("The default candidate of orphaned buckets is not registered",
+			zap.String("candidate", p.orphanCandidate.String()), zap.Int("buckets", len(buckets)))
+		return nil
+	}
+
+	for _, bucket := range buckets {
+		orphaned := bucket.Candidate
+		if err := delCandBucketIndex(sm, orphaned, bucket.Index); err != nil {
+			return errors.Wrapf(err, "failed to delete bucket index for candidate %s", orphaned.String())
+		}
+		if err := p.delVoterCandIndex(ctx, sm, bucket.Owner, orphaned, bucket.Index); err != nil {
+			return errors.Wrapf(err, "failed to delete bucket index for voter %s and candidate %s", bucket.Owner.String(), orphaned.String())
+		}
+		bucket.Candidate = candidate.Owner
+		if err := updateBucket(sm, bucket.Index, bucket); err != nil {
+			return errors.Wrapf(err, "failed to update bucket %d", bucket.Index)
+		}
+		if err := putCandBucketIndex(sm, candidate.Owner, bucket.Index); err != nil {
+			return errors.Wrapf(err, "failed to put bucket index for candidate %s", candidate.Owner.String())
+		}
+		if err := p.putVoterCandIndex(ctx, sm, bucket.Owner, candidate.Owner, bucket.Index); err != nil {
+			return errors.Wrapf(err, "failed to put bucket index for voter %s and candidate %s", bucket.Owner.String(), candidate.Owner.String())
+		}
+		if bucket.UnstakeStartTime.Unix() == 0 {
+			if err := candidate.AddVote(p.calculateVoteWeight(ctx, bucket, false)); err != nil {
+				return errors.Wrapf(err, "failed to add vote for candidate %s", candidate.Owner.String())
+			}
+		}
+
+		log.L().Info("Migrated orphaned bucket to the default candidate",
+			zap.Uint64("bucket", bucket.Index),
+			zap.String("orphaned", orphaned.String()),
+			zap.String("candidate", candidate.Owner.String()))
+	}
+	if err := putCandidate(sm, candidate); err != nil {
+		return errors.Wrapf(err, "failed to put state of candidate %s", candidate.Owner.String())
+	}
+	if err := p.inMemCandidates.Upsert(candidate); err != nil {
+		return errors.Wrapf(err, "failed to put candidate %s to the candidate center", candidate.Owner.String())
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
+	"github.com/iotexproject/iotex-core/blockchain/genesis"
+	"github.com/iotexproject/iotex-core/pkg/unit"
+	"github.com/iotexproject/iotex-core/test/identityset"
+)
+
+func TestProtocol_MigrateOrphanedBuckets(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	g := genesis.Default
+	_, err = NewProtocol(depositGas, sm, g.Staking, OrphanMigrationOption(nil))
+	r.Error(err)
+	p, err := NewProtocol(depositGas, sm, g.Staking, OrphanMigrationOption(identityset.Address(1)))
+	r.NoError(err)
+
+	// an epoch lasts 10 blocks, epoch 2 starts at height 11
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	now := time.Now()
+	blkCtx := func(height uint64) context.Context {
+		ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+			Genesis:  g,
+			Registry: registry,
+		})
+		return protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: now,
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		owner := identityset.Address(i + 1)
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(100),
+			SelfStakeBucketIdx: uint64(100 + i),
+			SelfStake:          big.NewInt(0),
+		}))
+	}
+	defaultCand, removed := identityset.Address(1), identityset.Address(2)
+	voters := []*VoteBucket{
+		NewVoteBucket(removed, identityset.Address(3), unit.ConvertIotxToRau(100), 7, now, true),
+		NewVoteBucket(removed, identityset.Address(4), unit.ConvertIotxToRau(200), 91, now, false),
+		NewVoteBucket(removed, identityset.Address(5), unit.ConvertIotxToRau(300), 0, now, false),
+	}
+	// the last bucket has been unstaked, which is moved but no longer counts as votes
+	voters[2].UnstakeStartTime = now.Add(-time.Hour).UTC()
+	expectedVotes := big.NewInt(100)
+	for i, b := range voters {
+		_, err = putBucketAndIndex(sm, b)
+		r.NoError(err)
+		if i < 2 {
+			expectedVotes.Add(expectedVotes, p.calculateVoteWeight(blkCtx(11), b, false))
+		}
+	}
+
+	// remove the candidate which still has voter buckets
+	r.NoError(delCandidate(sm, removed))
+	p.inMemCandidates.Delete(removed)
+	orphaned, err := p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Len(orphaned, 3)
+
+	// the buckets are not migrated in the middle of an epoch
+	r.NoError(p.CreatePreStates(blkCtx(5), sm))
+	orphaned, err = p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Len(orphaned, 3)
+
+	r.NoError(p.CreatePreStates(blkCtx(11), sm))
+	orphaned, err = p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Empty(orphaned)
+	for i, b := range voters {
+		bucket, err := getBucket(sm, uint64(i))
+		r.NoError(err)
+		r.Equal(defaultCand, bucket.Candidate)
+		r.Equal(b.StakedAmount, bucket.StakedAmount)
+	}
+	indices, err := getCandBucketIndices(sm, defaultCand)
+	r.NoError(err)
+	r.Equal(BucketIndices{0, 1, 2}, *indices)
+	_, err = getCandBucketIndices(sm, removed)
+	r.Error(err)
+
+	// the votes are added to the default candidate, in the state and the candidate center
+	c, err := getCandidate(sm, defaultCand)
+	r.NoError(err)
+	r.Equal(expectedVotes, c.Votes)
+	r.Equal(expectedVotes, p.inMemCandidates.GetByOwner(defaultCand).Votes)
+}
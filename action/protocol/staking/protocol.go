@@ -7,8 +7,12 @@
 package staking
 
 import (
+	"bytes"
+	"container/heap"
 	"context"
+	"math"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -20,7 +24,9 @@ import (
 
 	"github.com/iotexproject/iotex-core/action"
 	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
 	"github.com/iotexproject/iotex-core/blockchain/genesis"
+	"github.com/iotexproject/iotex-core/pkg/util/byteutil"
 	"github.com/iotexproject/iotex-core/state"
 )
 
@@ -28,11 +34,21 @@ const (
 	// protocolID is the protocol ID
 	protocolID = "staking"
 
+	// rewardingProtocolID is the ID of the rewarding protocol, whose account holds the reward pool that registration
+	// fees are deposited into
+	rewardingProtocolID = "rewarding"
+
 	// StakingNameSpace is the bucket name for staking state
 	StakingNameSpace = "Staking"
 
 	// CandidateNameSpace is the bucket name for candidate state
 	CandidateNameSpace = "Candidate"
+
+	// WeightSnapshotNameSpace is the bucket name for the candidate weights frozen at the start of each epoch
+	WeightSnapshotNameSpace = "StakingWeightSnapshot"
+
+	// StatsNameSpace is the bucket name for the network staking statistics recorded at the start of each epoch
+	StatsNameSpace = "StakingStats"
 )
 
 const (
@@ -43,12 +59,33 @@ const (
 	_bucket
 	_voterIndex
 	_candIndex
+	_voterCandIndex
 )
 
+// DefaultMaxLogDataSize is the default max size of the data of a log in the receipts
+const DefaultMaxLogDataSize = 256
+
+// MaxLogTopics is the max number of topics of a log in the receipts, the same as the logs of the EVM. The logs of the
+// staking handlers have the handler name, the candidate and the voter as topics
+const MaxLogTopics = 4
+
+// UnrankedCandidate is the rank of a candidate not in the delegate ordering, because it is not registered or its
+// self-stake is below the minimum
+const UnrankedCandidate = -1
+
+// _bucketKeyLen is the length of a bucket key, the 1-byte tag followed by the 8-byte big-endian bucket index
+const _bucketKeyLen = 9
+
 // Errors
 var (
-	ErrAlreadyExist = errors.New("candidate already exist")
-	TotalBucketKey  = append([]byte{_const}, []byte("totalBucket")...)
+	ErrAlreadyExist            = errors.New("candidate already exist")
+	ErrCandidateNotExist       = errors.New("candidate does not exist")
+	ErrBucketCreateTimeChanged = errors.New("bucket create time cannot be changed")
+	ErrLogDataTooLarge         = errors.New("log data is too large")
+	ErrTooManyLogTopics        = errors.New("log has too many topics")
+	ErrNoSelfStakeBucket       = errors.New("candidate has no self-staking bucket")
+	TotalBucketKey             = append([]byte{_const}, []byte("totalBucket")...)
+	RegistrationCountKey       = append([]byte{_const}, []byte("registrationCount")...)
 )
 
 // Protocol defines the protocol of handling staking
@@ -58,28 +95,225 @@ type Protocol struct {
 	depositGas      DepositGas
 	sr              protocol.StateReader
 	config          Configuration
+	voteWeightUnit  time.Duration
+	weightSnapshot  bool
+	feeDestination  address.Address
+	rounding        VoteWeightRounding
+	clock           Clock
+	maxLogDataSize  uint64
+	// the candidate which orphaned buckets are migrated to, nil if the migration is off
+	orphanCandidate address.Address
+	// auditor receives the audit report at the start of each epoch, nil if the audit is off
+	auditor Auditor
+	// greenlandHeight is the height the Greenland upgrade of the staking rules takes effect
+	greenlandHeight uint64
+}
+
+// Option is optional setting for staking protocol
+type Option func(*Protocol) error
+
+// EpochVoteWeightOption measures the remaining lock time of buckets in epochs of the given duration instead of days
+// when calculating vote weight. The duration is fixed for the life of the chain, so that the weight subtracted from a
+// candidate for a bucket always equals the weight added for it, even if the epoch length of the rolldpos protocol
+// changes at a height upgrade
+func EpochVoteWeightOption(epochDuration time.Duration) Option {
+	return func(p *Protocol) error {
+		if epochDuration <= 0 {
+			return errors.Errorf("invalid epoch duration %s", epochDuration)
+		}
+		p.voteWeightUnit = epochDuration
+		return nil
+	}
+}
+
+// WeightSnapshotOption freezes the weighted votes of all candidates at the start of each epoch, which can be read
+// back by WeightSnapshotByEpoch, along with the network statistics read back by NetworkStats
+func WeightSnapshotOption() Option {
+	return func(p *Protocol) error {
+		p.weightSnapshot = true
+		return nil
+	}
+}
+
+// OrphanMigrationOption migrates the orphaned buckets to the default candidate of given owner at the start of each
+// epoch, so that the votes of the voters are not stranded when their candidate is removed
+func OrphanMigrationOption(defaultCandidate address.Address) Option {
+	return func(p *Protocol) error {
+		if defaultCandidate == nil {
+			return errors.New("nil default candidate")
+		}
+		p.orphanCandidate = defaultCandidate
+		return nil
+	}
+}
+
+// MaxLogDataSizeOption sets the max size of the data of a log in the receipts, which is DefaultMaxLogDataSize by
+// default. A handler fails with ErrLogDataTooLarge rather than producing a receipt with a larger log
+func MaxLogDataSizeOption(size uint64) Option {
+	return func(p *Protocol) error {
+		p.maxLogDataSize = size
+		return nil
+	}
+}
+
+// ClockOption sets the clock of the handlers, which is BlockTimeClock by default. It is meant for testing the
+// time-dependent logic without fabricating the block context each time
+func ClockOption(clock Clock) Option {
+	return func(p *Protocol) error {
+		if clock == nil {
+			return errors.New("nil clock")
+		}
+		p.clock = clock
+		return nil
+	}
+}
+
+// VoteWeightRoundingOption sets the rounding mode of the weighted vote amount, which is RoundFloor by default
+func VoteWeightRoundingOption(rounding VoteWeightRounding) Option {
+	return func(p *Protocol) error {
+		switch rounding {
+		case RoundFloor, RoundHalfUp, RoundCeil:
+			p.rounding = rounding
+			return nil
+		default:
+			return errors.Errorf("invalid vote weight rounding mode %d", rounding)
+		}
+	}
+}
+
+// RegistrationFeeDestinationOption sets the address recorded as the destination of the registration fee in the
+// receipt logs, if depositGas deposits the fee elsewhere than the reward pool
+func RegistrationFeeDestinationOption(addr address.Address) Option {
+	return func(p *Protocol) error {
+		if addr == nil {
+			return errors.New("empty registration fee destination")
+		}
+		p.feeDestination = addr
+		return nil
+	}
+}
+
+// ProtocolAddressOption runs the protocol at the given address instead of the one derived from the protocol ID, e.g.
+// for a second instance of staking on a sidechain. The address is the contract address of the receipts and the address
+// of the logs of the staking actions, it cannot be that of the rewarding protocol, which holds the reward pool
+func ProtocolAddressOption(addr address.Address) Option {
+	return func(p *Protocol) error {
+		if addr == nil {
+			return errors.New("empty protocol address")
+		}
+		h := hash.Hash160b([]byte(rewardingProtocolID))
+		if bytes.Equal(addr.Bytes(), h[:]) {
+			return errors.Errorf("protocol address %s is the rewarding protocol address", addr.String())
+		}
+		p.addr = addr
+		return nil
+	}
+}
+
+// GasScheduleOption overrides the intrinsic gas of staking actions from the given height on, the actions are keyed by
+// their handler names, e.g. HandleCreateStake. A schedule of a greater height takes precedence
+func GasScheduleOption(height uint64, gas map[string]uint64) Option {
+	return func(p *Protocol) error {
+		schedule := GasSchedule{
+			Height: height,
+			Gas:    make(map[string]uint64, len(gas)),
+		}
+		for method, g := range gas {
+			if _, ok := _stakingMethods[method]; !ok {
+				return errors.Errorf("invalid staking action %s in gas schedule", method)
+			}
+			schedule.Gas[method] = g
+		}
+		p.config.GasSchedules = append(p.config.GasSchedules, schedule)
+		sort.SliceStable(p.config.GasSchedules, func(i, j int) bool {
+			return p.config.GasSchedules[i].Height < p.config.GasSchedules[j].Height
+		})
+		return nil
+	}
+}
+
+// GreenlandHeightOption applies the staking rules of the Greenland upgrade from the given height on, which is the
+// GreenlandBlockHeight of the genesis. Without the option the blocks are processed with the rules before the upgrade
+func GreenlandHeightOption(height uint64) Option {
+	return func(p *Protocol) error {
+		p.greenlandHeight = height
+		return nil
+	}
+}
+
+// GasSchedule is the intrinsic gas of staking actions effective from a height
+type GasSchedule struct {
+	Height uint64
+	Gas    map[string]uint64
 }
 
 // Configuration is the staking protocol configuration.
 type Configuration struct {
-	VoteWeightCalConsts   genesis.VoteWeightCalConsts
-	RegistrationConsts    RegistrationConsts
-	WithdrawWaitingPeriod time.Duration
-	MinStakeAmount        *big.Int
-	BootstrapCandidates   []genesis.BootstrapCandidate
+	VoteWeightCalConsts      genesis.VoteWeightCalConsts
+	RegistrationConsts       RegistrationConsts
+	NameConsts               genesis.NameConsts
+	WithdrawWaitingPeriod    time.Duration
+	MinStakeAmount           *big.Int
+	MaxCandidates            uint64
+	AutoRegisterCandidate    bool
+	OperatorChangeCooldown   uint64
+	MaxRegistrationsPerEpoch uint64
+	BootstrapCandidates      []genesis.BootstrapCandidate
+	GenesisBuckets           []genesis.GenesisBucket
+	EmergencyPauses          []genesis.EmergencyPause
+	GasSchedules             []GasSchedule
+}
+
+// StakingConfigView is an immutable snapshot of the consensus-relevant staking parameters
+type StakingConfigView struct {
+	VoteWeightCalConsts      genesis.VoteWeightCalConsts
+	RegistrationFee          *big.Int
+	MinSelfStake             *big.Int
+	WithdrawWaitingPeriod    time.Duration
+	MinStakeAmount           *big.Int
+	MaxCandidates            uint64
+	AutoRegisterCandidate    bool
+	OperatorChangeCooldown   uint64
+	MaxRegistrationsPerEpoch uint64
+}
+
+// CandidateCooldown is the number of blocks until each cooldown-governed field of a candidate can be changed again,
+// 0 if it can be changed in the next block
+type CandidateCooldown struct {
+	Operator uint64
+}
+
+// CandidateVoteChange is a hypothetical change of the votes of the candidate of given owner
+type CandidateVoteChange struct {
+	Owner address.Address
+	Delta *big.Int
 }
 
 // DepositGas deposits gas to some pool
 type DepositGas func(ctx context.Context, sm protocol.StateManager, amount *big.Int) error
 
+// Clock returns the time the handlers stamp buckets with and check the maturity of buckets against
+type Clock func(ctx context.Context) time.Time
+
+// BlockTimeClock is the default clock, which returns the timestamp of the block in the context
+func BlockTimeClock(ctx context.Context) time.Time {
+	return protocol.MustGetBlockCtx(ctx).BlockTimeStamp
+}
+
 // NewProtocol instantiates the protocol of staking
-func NewProtocol(depositGas DepositGas, sr protocol.StateReader, cfg genesis.Staking) (*Protocol, error) {
+func NewProtocol(depositGas DepositGas, sr protocol.StateReader, cfg genesis.Staking, opts ...Option) (*Protocol, error) {
 	h := hash.Hash160b([]byte(protocolID))
 	addr, err := address.FromBytes(h[:])
 	if err != nil {
 		return nil, err
 	}
 
+	h = hash.Hash160b([]byte(rewardingProtocolID))
+	rewardingAddr, err := address.FromBytes(h[:])
+	if err != nil {
+		return nil, err
+	}
+
 	minStakeAmount, ok := new(big.Int).SetString(cfg.MinStakeAmount, 10)
 	if !ok {
 		return nil, ErrInvalidAmount
@@ -95,29 +329,56 @@ func NewProtocol(depositGas DepositGas, sr protocol.StateReader, cfg genesis.Sta
 		return nil, ErrInvalidAmount
 	}
 
-	return &Protocol{
+	if cfg.VoteWeightCalConsts.SelfStakeBonusRate < 0 {
+		return nil, errors.Errorf("invalid self-stake bonus rate %f", cfg.VoteWeightCalConsts.SelfStakeBonusRate)
+	}
+
+	p := &Protocol{
 		addr:            addr,
 		inMemCandidates: NewCandidateCenter(),
 		config: Configuration{
 			VoteWeightCalConsts: cfg.VoteWeightCalConsts,
 			RegistrationConsts: RegistrationConsts{
-				Fee:          regFee,
-				MinSelfStake: minSelfStake,
+				Fee:                  regFee,
+				MinSelfStake:         minSelfStake,
+				MinSelfStakeDuration: time.Duration(cfg.RegistrationConsts.MinSelfStakeDuration) * 24 * time.Hour,
 			},
-			WithdrawWaitingPeriod: cfg.WithdrawWaitingPeriod,
-			MinStakeAmount:        minStakeAmount,
-			BootstrapCandidates:   cfg.BootstrapCandidates,
+			NameConsts:               cfg.NameConsts,
+			WithdrawWaitingPeriod:    cfg.WithdrawWaitingPeriod,
+			MinStakeAmount:           minStakeAmount,
+			MaxCandidates:            cfg.MaxCandidates,
+			AutoRegisterCandidate:    cfg.AutoRegisterCandidate,
+			OperatorChangeCooldown:   cfg.OperatorChangeCooldown,
+			MaxRegistrationsPerEpoch: cfg.MaxRegistrationsPerEpoch,
+			BootstrapCandidates:      cfg.BootstrapCandidates,
+			GenesisBuckets:           cfg.GenesisBuckets,
+			EmergencyPauses:          cfg.EmergencyPauses,
 		},
-		depositGas: depositGas,
-		sr:         sr,
-	}, nil
+		depositGas:      depositGas,
+		sr:              sr,
+		feeDestination:  rewardingAddr,
+		clock:           BlockTimeClock,
+		maxLogDataSize:  DefaultMaxLogDataSize,
+		greenlandHeight: math.MaxUint64,
+		voteWeightUnit:  24 * time.Hour,
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
 }
 
-// CreateGenesisStates is used to setup BootstrapCandidates from genesis config.
+// CreateGenesisStates is used to setup BootstrapCandidates and GenesisBuckets from genesis config.
 func (p *Protocol) CreateGenesisStates(
 	ctx context.Context,
 	sm protocol.StateManager,
 ) error {
+	if p.config.MaxCandidates > 0 && uint64(len(p.config.BootstrapCandidates)) > p.config.MaxCandidates {
+		return errors.Errorf("number of bootstrap candidates %d exceeds the limit %d",
+			len(p.config.BootstrapCandidates), p.config.MaxCandidates)
+	}
 	for _, bc := range p.config.BootstrapCandidates {
 		owner, err := address.FromString(bc.OwnerAddress)
 		if err != nil {
@@ -148,7 +409,7 @@ func (p *Protocol) CreateGenesisStates(
 			Operator:           operator,
 			Reward:             reward,
 			Name:               bc.Name,
-			Votes:              p.calculateVoteWeight(bucket, true),
+			Votes:              p.calculateVoteWeight(ctx, bucket, true),
 			SelfStakeBucketIdx: bucketIdx,
 			SelfStake:          selfStake,
 		}
@@ -162,9 +423,52 @@ func (p *Protocol) CreateGenesisStates(
 			return err
 		}
 	}
+	for i, gb := range p.config.GenesisBuckets {
+		if err := p.createGenesisBucket(ctx, sm, gb); err != nil {
+			return errors.Wrapf(err, "failed to create genesis bucket %d", i)
+		}
+	}
+	if p.isGreenland(0) {
+		// there is no block at Greenland to backfill the index at
+		return putAllVoterCandBucketIndices(sm)
+	}
 	return nil
 }
 
+// createGenesisBucket puts the pre-allocated bucket and adds its votes to the candidate. No fee is charged and no
+// balance of the owner is locked, the bucket is indexed after the self-staking buckets of the bootstrap candidates
+func (p *Protocol) createGenesisBucket(ctx context.Context, sm protocol.StateManager, gb genesis.GenesisBucket) error {
+	owner, err := address.FromString(gb.OwnerAddress)
+	if err != nil {
+		return err
+	}
+	amount, ok := new(big.Int).SetString(gb.Amount, 10)
+	if !ok || amount.Sign() <= 0 {
+		return errors.Wrapf(ErrInvalidAmount, "amount %s", gb.Amount)
+	}
+	if err := validateDuration(gb.Duration); err != nil {
+		return err
+	}
+	candidate := p.inMemCandidates.GetByName(gb.CandidateName)
+	if candidate == nil {
+		return errors.Wrapf(ErrInvalidCanName, "candidate %s does not exist", gb.CandidateName)
+	}
+	// the bucket is created at the timestamp of the genesis block, so that every node creates the same genesis states
+	createTime := protocol.MustGetBlockCtx(ctx).BlockTimeStamp
+	bucket := NewVoteBucket(candidate.Owner, owner, amount, gb.Duration, createTime, gb.AutoStake)
+	// an index is never reused, putBucket fails on an existing bucket
+	if _, err := putBucketAndIndex(sm, bucket); err != nil {
+		return err
+	}
+	if err := candidate.AddVote(p.calculateVoteWeight(ctx, bucket, false)); err != nil {
+		return err
+	}
+	if err := putCandidate(sm, candidate); err != nil {
+		return err
+	}
+	return p.inMemCandidates.Upsert(candidate)
+}
+
 // Start starts the protocol
 func (p *Protocol) Start(ctx context.Context) error {
 	cands, err := getAllCandidates(p.sr)
@@ -181,8 +485,69 @@ func (p *Protocol) Start(ctx context.Context) error {
 	return nil
 }
 
+// CreatePreStates subscribes candidate center to the snapshots of the state manager, so that it is reverted
+// together with the state manager, and resets the registration count at the start of each epoch
+func (p *Protocol) CreatePreStates(ctx context.Context, sm protocol.StateManager) error {
+	if notifier, ok := sm.(protocol.SnapshotNotifier); ok {
+		p.inMemCandidates.clearSnapshots()
+		notifier.Subscribe(p.inMemCandidates)
+	}
+	if blkCtx, ok := protocol.GetBlockCtx(ctx); ok && blkCtx.BlockHeight == p.greenlandHeight {
+		if err := p.recalculateVotes(ctx, sm); err != nil {
+			return errors.Wrap(err, "failed to recalculate votes at Greenland")
+		}
+		if err := putAllVoterCandBucketIndices(sm); err != nil {
+			return errors.Wrap(err, "failed to index buckets by voter and candidate at Greenland")
+		}
+	}
+	if p.config.MaxRegistrationsPerEpoch == 0 && !p.weightSnapshot && p.orphanCandidate == nil && p.auditor == nil {
+		return nil
+	}
+	blkCtx := protocol.MustGetBlockCtx(ctx)
+	bcCtx := protocol.MustGetBlockchainCtx(ctx)
+	rp := rolldpos.MustGetProtocol(bcCtx.Registry)
+	epochNum := rp.GetEpochNum(blkCtx.BlockHeight)
+	if blkCtx.BlockHeight != rp.GetEpochHeight(epochNum) {
+		return nil
+	}
+	if p.orphanCandidate != nil {
+		// migrate before the weights are frozen, so that the snapshot counts the migrated votes
+		if err := p.migrateOrphanedBuckets(ctx, sm); err != nil {
+			return err
+		}
+	}
+	if p.weightSnapshot {
+		if err := p.snapshotWeights(sm, epochNum); err != nil {
+			return err
+		}
+		if err := p.snapshotStats(sm, epochNum); err != nil {
+			return err
+		}
+	}
+	if p.auditor != nil {
+		p.audit(ctx, sm, epochNum)
+	}
+	if p.config.MaxRegistrationsPerEpoch == 0 {
+		return nil
+	}
+	return putRegistrationCount(sm, 0)
+}
+
 // Handle handles a staking message
 func (p *Protocol) Handle(ctx context.Context, act action.Action, sm protocol.StateManager) (*action.Receipt, error) {
+	switch act.(type) {
+	case *action.CreateStake, *action.Unstake, *action.WithdrawStake, *action.ChangeCandidate, *action.TransferStake,
+		*action.DepositToStake, *action.Restake, *action.CandidateRegister, *action.CandidateUpdate:
+		if sm == nil {
+			return nil, errors.Wrap(protocol.ErrNilStateManager, "failed to handle staking action")
+		}
+	default:
+		return nil, nil
+	}
+	ctx = p.withScheduledGas(ctx, act)
+	if p.paused(ctx) {
+		return p.handlePaused(ctx, sm)
+	}
 	switch act := act.(type) {
 	case *action.CreateStake:
 		return p.handleCreateStake(ctx, act, sm)
@@ -208,6 +573,9 @@ func (p *Protocol) Handle(ctx context.Context, act action.Action, sm protocol.St
 
 // Validate validates a staking message
 func (p *Protocol) Validate(ctx context.Context, act action.Action) error {
+	if err := p.validateScheduledGas(ctx, act); err != nil {
+		return err
+	}
 	switch act := act.(type) {
 	case *action.CreateStake:
 		return p.validateCreateStake(ctx, act)
@@ -231,20 +599,587 @@ func (p *Protocol) Validate(ctx context.Context, act action.Action) error {
 	return nil
 }
 
-// ActiveCandidates returns all active candidates in candidate center
-func (p *Protocol) ActiveCandidates(context.Context) (state.CandidateList, error) {
+// ActiveCandidates returns all active candidates in candidate center. Since Greenland, candidates with equal votes are
+// ordered by SortByVotes, the same as the block producers are selected
+func (p *Protocol) ActiveCandidates(ctx context.Context) (state.CandidateList, error) {
+	cand, err := p.activeCandidates()
+	if err != nil {
+		return nil, err
+	}
+	list, err := cand.toStateCandidateList()
+	if err != nil {
+		return nil, err
+	}
+	if blkCtx, ok := protocol.GetBlockCtx(ctx); ok && p.isGreenland(blkCtx.BlockHeight) {
+		list.SortByVotes()
+	}
+	return list, nil
+}
+
+// StakingConfig returns a snapshot of the staking parameters active at the current tip
+func (p *Protocol) StakingConfig() StakingConfigView {
+	return StakingConfigView{
+		VoteWeightCalConsts:      p.config.VoteWeightCalConsts,
+		RegistrationFee:          new(big.Int).Set(p.config.RegistrationConsts.Fee),
+		MinSelfStake:             new(big.Int).Set(p.config.RegistrationConsts.MinSelfStake),
+		WithdrawWaitingPeriod:    p.config.WithdrawWaitingPeriod,
+		MinStakeAmount:           new(big.Int).Set(p.config.MinStakeAmount),
+		MaxCandidates:            p.config.MaxCandidates,
+		AutoRegisterCandidate:    p.config.AutoRegisterCandidate,
+		OperatorChangeCooldown:   p.config.OperatorChangeCooldown,
+		MaxRegistrationsPerEpoch: p.config.MaxRegistrationsPerEpoch,
+	}
+}
+
+// BucketVoteWeight returns the weighted vote the bucket of given index contributes to its candidate
+func (p *Protocol) BucketVoteWeight(ctx context.Context, sr protocol.StateReader, index uint64) (*big.Int, error) {
+	bucket, err := getBucket(sr, index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch bucket by index %d", index)
+	}
+	return p.calculateVoteWeight(ctx, bucket, p.inMemCandidates.ContainsSelfStakingBucket(index)), nil
+}
+
+// SelfStakeBucket returns the index and the self-staking bucket of the candidate of given owner. It returns
+// ErrNoSelfStakeBucket if the candidate has no self-stake, e.g. its self-staking bucket is unstaked or withdrawn
+func (p *Protocol) SelfStakeBucket(sr protocol.StateReader, owner address.Address) (uint64, *VoteBucket, error) {
+	c, err := getCandidate(sr, owner)
+	if err != nil {
+		if errors.Cause(err) == state.ErrStateNotExist {
+			return 0, nil, errors.Wrapf(ErrCandidateNotExist, "candidate of owner %s", owner.String())
+		}
+		return 0, nil, errors.Wrapf(err, "failed to get candidate of owner %s", owner.String())
+	}
+	if c.SelfStake.Sign() == 0 {
+		return 0, nil, errors.Wrapf(ErrNoSelfStakeBucket, "candidate %s", c.Name)
+	}
+	bucket, err := getBucket(sr, c.SelfStakeBucketIdx)
+	switch errors.Cause(err) {
+	case nil:
+	case state.ErrStateNotExist:
+		return 0, nil, errors.Wrapf(ErrNoSelfStakeBucket, "self-staking bucket %d of candidate %s does not exist",
+			c.SelfStakeBucketIdx, c.Name)
+	default:
+		return 0, nil, errors.Wrapf(err, "failed to fetch bucket by index %d", c.SelfStakeBucketIdx)
+	}
+	return c.SelfStakeBucketIdx, bucket, nil
+}
+
+// BucketCreateTime returns the time the bucket of given index was created, it stays the same over the life of the bucket
+func (p *Protocol) BucketCreateTime(sr protocol.StateReader, index uint64) (time.Time, error) {
+	bucket, err := getBucket(sr, index)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to fetch bucket by index %d", index)
+	}
+	return bucket.CreateTime, nil
+}
+
+// BucketsByIndexes returns the buckets of the given indexes, aligned with indexes. A bucket that does not exist is
+// left nil and reported in the returned error, which lists all missing indexes, while the others are still returned
+func (p *Protocol) BucketsByIndexes(sr protocol.StateReader, indexes []uint64) ([]*VoteBucket, error) {
+	return getBuckets(sr, indexes)
+}
+
+// BucketsByStatus returns all buckets in the given status at the given time
+func (p *Protocol) BucketsByStatus(sr protocol.StateReader, status BucketStatus, now time.Time) ([]*VoteBucket, error) {
+	buckets, err := getBucketsWithCond(sr, func(vb *VoteBucket) bool {
+		return vb.status(now, p.config.WithdrawWaitingPeriod) == status
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get buckets in status %d", status)
+	}
+	return buckets, nil
+}
+
+// OrphanedBuckets returns all buckets whose candidate is not registered, e.g. after the candidate is removed. Such
+// buckets earn no votes, so their voters should be notified to restake to another candidate
+func (p *Protocol) OrphanedBuckets(sr protocol.StateReader) ([]*VoteBucket, error) {
+	// the buckets and candidates are read by key, such that the lookup also runs on the working set of a block
+	registered := make(map[string]bool)
+	var buckets []*VoteBucket
+	if err := forEachBucket(sr, func(vb *VoteBucket) error {
+		owner := vb.Candidate.String()
+		ok, checked := registered[owner]
+		if !checked {
+			_, err := getCandidate(sr, vb.Candidate)
+			switch errors.Cause(err) {
+			case nil:
+				ok = true
+			case state.ErrStateNotExist:
+				ok = false
+			default:
+				return errors.Wrapf(err, "failed to get candidate %s", owner)
+			}
+			registered[owner] = ok
+		}
+		if !ok {
+			buckets = append(buckets, vb)
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to get orphaned buckets")
+	}
+	return buckets, nil
+}
+
+// StateDigest returns a hash over the whole staking state, the candidates, buckets and bucket indexes, such that two
+// nodes can compare their staking states without comparing the whole state
+func (p *Protocol) StateDigest(sr protocol.StateReader) ([]byte, error) {
+	var buf bytes.Buffer
+	// the namespaces are in sorted order
+	for _, ns := range []string{CandidateNameSpace, StakingNameSpace} {
+		keys, values, err := readNamespace(sr, ns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read namespace %s", ns)
+		}
+		writeDigestField(&buf, []byte(ns))
+		for i := range keys {
+			writeDigestField(&buf, keys[i])
+			writeDigestField(&buf, values[i])
+		}
+	}
+	h := hash.Hash256b(buf.Bytes())
+	return h[:], nil
+}
+
+// readNamespace returns all the keys and values of the namespace, sorted by key
+func readNamespace(sr protocol.StateReader, ns string) ([][]byte, [][]byte, error) {
+	var keys, values [][]byte
+	_, _, err := sr.States(
+		protocol.NamespaceOption(ns),
+		protocol.FilterOption(func(k, v []byte) bool {
+			keys = append(keys, append([]byte(nil), k...))
+			values = append(values, append([]byte(nil), v...))
+			// the key and value are recorded here, no need to return them in the iterator
+			return false
+		}, nil, nil))
+	if err != nil && errors.Cause(err) != state.ErrStateNotExist {
+		return nil, nil, err
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+	sortedKeys := make([][]byte, len(keys))
+	sortedValues := make([][]byte, len(values))
+	for i, j := range order {
+		sortedKeys[i], sortedValues[i] = keys[j], values[j]
+	}
+	return sortedKeys, sortedValues, nil
+}
+
+// writeDigestField writes the length-prefixed data, so that the boundaries of the fields are unambiguous
+func writeDigestField(buf *bytes.Buffer, data []byte) {
+	buf.Write(byteutil.Uint64ToBytesBigEndian(uint64(len(data))))
+	buf.Write(data)
+}
+
+// AllSelfStakeBuckets returns the self-stake buckets of all candidates keyed by owner address, candidates without
+// self-stake are skipped
+func (p *Protocol) AllSelfStakeBuckets(sr protocol.StateReader) (map[string]*VoteBucket, error) {
 	list, err := p.inMemCandidates.All()
 	if err != nil {
 		return nil, err
 	}
+	buckets := make(map[string]*VoteBucket, len(list))
+	for _, c := range list {
+		if c.SelfStake == nil || c.SelfStake.Sign() == 0 {
+			continue
+		}
+		bucket, err := getBucket(sr, c.SelfStakeBucketIdx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch self-stake bucket of candidate %s", c.Owner.String())
+		}
+		buckets[c.Owner.String()] = bucket
+	}
+	return buckets, nil
+}
 
-	cand := make(CandidateList, 0, len(list))
-	for i := range list {
-		if list[i].SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) >= 0 {
-			cand = append(cand, list[i])
+// CandidateWithVerifiedSelfStake returns the candidate of given owner, along with whether its self-stake agrees with
+// the amount of its self-stake bucket
+func (p *Protocol) CandidateWithVerifiedSelfStake(sr protocol.StateReader, owner address.Address) (*Candidate, bool, error) {
+	c := p.inMemCandidates.GetByOwner(owner)
+	if c == nil {
+		return nil, false, errors.Wrapf(state.ErrStateNotExist, "candidate %s does not exist", owner)
+	}
+	selfStake := big.NewInt(0)
+	bucket, err := getBucket(sr, c.SelfStakeBucketIdx)
+	switch errors.Cause(err) {
+	case nil:
+		selfStake = bucket.StakedAmount
+	case state.ErrStateNotExist:
+		// self-stake bucket has been withdrawn
+	default:
+		return nil, false, errors.Wrapf(err, "failed to fetch self-stake bucket of candidate %s", owner)
+	}
+	return c, c.SelfStake.Cmp(selfStake) == 0, nil
+}
+
+// BucketsByVoterAndCandidate returns the indexes of the buckets owned by the voter and staked to the candidate of given
+// owner, in the order they were indexed. The index is built at Greenland, so nothing is returned before it
+func (p *Protocol) BucketsByVoterAndCandidate(sr protocol.StateReader, voter, candidate address.Address) ([]uint64, error) {
+	indices, err := getVoterCandBucketIndices(sr, voter, candidate)
+	switch errors.Cause(err) {
+	case nil:
+		return *indices, nil
+	case state.ErrStateNotExist:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// Delegator is an owner of buckets staked to a candidate, with the total amount staked by its buckets
+type Delegator struct {
+	Owner  address.Address
+	Amount *big.Int
+}
+
+// delegatorHeap is a min-heap of delegators, the one with the smallest amount on top, ties broken by the larger owner
+// address, so that the top n delegators are kept by popping the top once the heap grows past n
+type delegatorHeap []*Delegator
+
+func (h delegatorHeap) Len() int { return len(h) }
+
+func (h delegatorHeap) Less(i, j int) bool {
+	if c := h[i].Amount.Cmp(h[j].Amount); c != 0 {
+		return c < 0
+	}
+	return h[i].Owner.String() > h[j].Owner.String()
+}
+
+func (h delegatorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *delegatorHeap) Push(x interface{}) { *h = append(*h, x.(*Delegator)) }
+
+func (h *delegatorHeap) Pop() interface{} {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
+
+// TopDelegators returns the n owners staking the most to the candidate of given owner, in the descending order of the
+// amount they stake, ties in the ascending order of the owner address. The amounts of the buckets of an owner are
+// summed up, unstaked buckets no longer back the candidate and are skipped
+func (p *Protocol) TopDelegators(sr protocol.StateReader, candidate address.Address, n int) ([]Delegator, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	amounts := make(map[string]*Delegator)
+	var decodeErr error
+	// the buckets are summed up while iterating the states, none of them is returned in the iterator
+	_, _, err := sr.States(
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.FilterOption(func(k, v []byte) bool {
+			if decodeErr != nil || !bytes.HasPrefix(k, []byte{_bucket}) {
+				return false
+			}
+			vb := &VoteBucket{}
+			if decodeErr = vb.Deserialize(v); decodeErr != nil {
+				return false
+			}
+			if !address.Equal(vb.Candidate, candidate) || vb.UnstakeStartTime.Unix() != 0 {
+				return false
+			}
+			d, ok := amounts[vb.Owner.String()]
+			if !ok {
+				d = &Delegator{Owner: vb.Owner, Amount: big.NewInt(0)}
+				amounts[vb.Owner.String()] = d
+			}
+			d.Amount.Add(d.Amount, vb.StakedAmount)
+			return false
+		}, bucketKey(0), []byte{_bucket + 1}))
+	if err != nil && errors.Cause(err) != state.ErrStateNotExist {
+		return nil, errors.Wrapf(err, "failed to iterate buckets of candidate %s", candidate.String())
+	}
+	if decodeErr != nil {
+		return nil, errors.Wrap(decodeErr, "failed to deserialize bucket")
+	}
+
+	h := make(delegatorHeap, 0, n+1)
+	for _, d := range amounts {
+		heap.Push(&h, d)
+		if h.Len() > n {
+			heap.Pop(&h)
+		}
+	}
+	top := make([]Delegator, h.Len())
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = *heap.Pop(&h).(*Delegator)
+	}
+	return top, nil
+}
+
+// IterateBucketsByCandidate streams the buckets grouped by candidate, in the order of the candidate bucket index keys,
+// and the buckets of a candidate in the order of its bucket indices. An error returned by fn aborts the iteration
+func (p *Protocol) IterateBucketsByCandidate(sr protocol.StateReader, fn func(address.Address, *VoteBucket) error) error {
+	cands, err := getAllCandidates(sr)
+	if err != nil {
+		return errors.Wrap(err, "failed to get candidates")
+	}
+	// candidate bucket index keys are ordered by the owner address bytes
+	sort.Slice(cands, func(i, j int) bool {
+		return bytes.Compare(cands[i].Owner.Bytes(), cands[j].Owner.Bytes()) < 0
+	})
+	for _, c := range cands {
+		indices, err := getCandBucketIndices(sr, c.Owner)
+		if errors.Cause(err) == state.ErrStateNotExist {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get bucket indices of candidate %s", c.Owner.String())
+		}
+		buckets, err := getBucketsWithIndices(sr, *indices)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get buckets of candidate %s", c.Owner.String())
+		}
+		for _, b := range buckets {
+			if err := fn(c.Owner, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BucketWithWeight is a bucket with the weighted vote it contributes to its candidate
+type BucketWithWeight struct {
+	*VoteBucket
+	Status BucketStatus
+	Weight *big.Int
+}
+
+// CandidateBuckets returns the buckets backing the candidate of given owner, in the order of its bucket indices, with
+// their status at the given time and their weighted votes. An unstaked bucket weighs 0, so that the weights sum up to
+// the votes of the candidate
+func (p *Protocol) CandidateBuckets(
+	ctx context.Context,
+	sr protocol.StateReader,
+	owner address.Address,
+	blkTime time.Time,
+) ([]BucketWithWeight, error) {
+	c, err := getCandidate(sr, owner)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get candidate %s", owner.String())
+	}
+	indices, err := getCandBucketIndices(sr, owner)
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get bucket indices of candidate %s", owner.String())
+	}
+	buckets, err := getBucketsWithIndices(sr, *indices)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get buckets of candidate %s", owner.String())
+	}
+	weighted := make([]BucketWithWeight, 0, len(buckets))
+	for _, b := range buckets {
+		status := b.status(blkTime, p.config.WithdrawWaitingPeriod)
+		weight := big.NewInt(0)
+		if status != BucketUnstaking && status != BucketWithdrawable {
+			weight = p.calculateVoteWeight(ctx, b, c.SelfStakeBucketIdx == b.Index)
+		}
+		weighted = append(weighted, BucketWithWeight{
+			VoteBucket: b,
+			Status:     status,
+			Weight:     weight,
+		})
+	}
+	return weighted, nil
+}
+
+// CandidateChangeCooldown returns the remaining cooldown of each cooldown-governed field of the candidate of given
+// owner, counted from the next block
+func (p *Protocol) CandidateChangeCooldown(sr protocol.StateReader, owner address.Address) (*CandidateCooldown, error) {
+	c, err := getCandidate(sr, owner)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get candidate %s", owner.String())
+	}
+	height, err := sr.Height()
+	if err != nil {
+		return nil, err
+	}
+	return &CandidateCooldown{
+		Operator: remainingCooldown(c.OperatorUpdateHeight, p.config.OperatorChangeCooldown, height+1),
+	}, nil
+}
+
+// SimulateDelegateSet returns the delegates that would be selected if the given vote changes were applied. The
+// changes are applied to copies of the candidates read from sr, the state is never written
+func (p *Protocol) SimulateDelegateSet(
+	ctx context.Context,
+	sr protocol.StateReader,
+	changes []CandidateVoteChange,
+) (state.CandidateList, error) {
+	cands, err := getAllCandidates(sr)
+	if err != nil {
+		return nil, err
+	}
+	candMap := make(map[string]*Candidate, len(cands))
+	for _, c := range cands {
+		candMap[c.Owner.String()] = c
+	}
+	for _, change := range changes {
+		if change.Owner == nil || change.Delta == nil {
+			return nil, errors.Wrap(action.ErrInvalidAmount, "invalid vote change")
+		}
+		c, ok := candMap[change.Owner.String()]
+		if !ok {
+			return nil, errors.Wrapf(ErrCandidateNotExist, "failed to simulate vote change of %s", change.Owner.String())
+		}
+		c.Votes.Add(c.Votes, change.Delta)
+		if c.Votes.Sign() < 0 {
+			c.Votes.SetInt64(0)
+		}
+	}
+
+	active := make(state.CandidateList, 0, len(cands))
+	for _, c := range cands {
+		if c.SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) >= 0 {
+			active = append(active, c.toStateCandidate())
+		}
+	}
+	active.SortByVotes()
+	delegates := make(state.CandidateList, 0, len(active))
+	num := protocol.MustGetBlockchainCtx(ctx).Genesis.NumCandidateDelegates
+	for i, c := range active {
+		if uint64(i) >= num {
+			break
+		}
+		if c.Votes.Sign() == 0 {
+			continue
 		}
+		delegates = append(delegates, c)
 	}
-	return cand.toStateCandidateList()
+	return delegates, nil
+}
+
+// MarginalStakeForEntry returns the amount to stake for the candidate of given owner so that its weighted votes
+// overtake those of the lowest delegate, or zero if the candidate is in the delegate set already. The amount is that
+// of a bucket created at blkTime without lock duration, which has the lowest weight, so the amount suffices for a
+// bucket of any duration
+func (p *Protocol) MarginalStakeForEntry(
+	ctx context.Context,
+	sr protocol.StateReader,
+	candidateOwner address.Address,
+	blkTime time.Time,
+) (*big.Int, error) {
+	num := protocol.MustGetBlockchainCtx(ctx).Genesis.NumCandidateDelegates
+	if num == 0 {
+		return nil, errors.New("the delegate set is empty")
+	}
+	cands, err := getAllCandidates(sr)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		target *state.Candidate
+		found  bool
+	)
+	active := make(state.CandidateList, 0, len(cands))
+	for _, c := range cands {
+		isTarget := address.Equal(c.Owner, candidateOwner)
+		found = found || isTarget
+		if c.SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) < 0 {
+			continue
+		}
+		sc := c.toStateCandidate()
+		if isTarget {
+			target = sc
+		}
+		active = append(active, sc)
+	}
+	if !found {
+		return nil, errors.Wrapf(ErrCandidateNotExist, "failed to get candidate %s", candidateOwner.String())
+	}
+	if target == nil {
+		return nil, errors.Errorf("candidate %s does not have enough self-stake", candidateOwner.String())
+	}
+	active.SortByVotes()
+
+	rank := 0
+	for active[rank] != target {
+		rank++
+	}
+	var votes *big.Int
+	switch {
+	case uint64(rank) < num && target.Votes.Sign() > 0:
+		return big.NewInt(0), nil
+	case uint64(rank) < num:
+		// a candidate without votes is not selected even if the delegate set is not full
+		votes = big.NewInt(1)
+	default:
+		votes = new(big.Int).Sub(active[num-1].Votes, target.Votes)
+		votes.Add(votes, big.NewInt(1))
+	}
+
+	bucket := NewVoteBucket(candidateOwner, candidateOwner, votes, 0, blkTime, false)
+	// start from the amount scaled down by the weight, and round up until the weighted votes are enough
+	weighted := p.calculateVoteWeight(ctx, bucket, false)
+	amount := new(big.Int).Mul(votes, votes)
+	amount.Quo(amount, weighted)
+	for {
+		bucket.StakedAmount = amount
+		if p.calculateVoteWeight(ctx, bucket, false).Cmp(votes) >= 0 {
+			return amount, nil
+		}
+		amount.Add(amount, big.NewInt(1))
+	}
+}
+
+// CandidateRank returns the 1-based rank of the candidate of given owner in the delegate ordering of the epoch, and the
+// number of candidates in the ordering. The candidates whose self-stake meets the minimum are ordered by the consensus
+// rules of SortByVotes, using the weights frozen at the start of the epoch if WeightSnapshotOption is enabled and the
+// snapshot exists, or the current weights otherwise. The rank is UnrankedCandidate if the candidate is not in the
+// ordering
+func (p *Protocol) CandidateRank(
+	ctx context.Context,
+	sr protocol.StateReader,
+	owner address.Address,
+	epoch uint64,
+) (int, int, error) {
+	cands, err := getAllCandidates(sr)
+	if err != nil {
+		return 0, 0, err
+	}
+	var weights map[string]*big.Int
+	if p.weightSnapshot {
+		weights, err = p.WeightSnapshotByEpoch(sr, epoch)
+		switch errors.Cause(err) {
+		case nil, state.ErrStateNotExist:
+		default:
+			return 0, 0, err
+		}
+	}
+
+	active := make(state.CandidateList, 0, len(cands))
+	for _, c := range cands {
+		if c.SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) < 0 {
+			continue
+		}
+		sc := c.toStateCandidate()
+		if weights != nil {
+			votes, ok := weights[sc.Address]
+			if !ok {
+				// registered after the snapshot
+				votes = big.NewInt(0)
+			}
+			sc.Votes = new(big.Int).Set(votes)
+		}
+		active = append(active, sc)
+	}
+	active.SortByVotes()
+	for i, c := range active {
+		if c.Address == owner.String() {
+			return i + 1, len(active), nil
+		}
+	}
+	return UnrankedCandidate, len(active), nil
 }
 
 // ReadState read the state on blockchain via protocol
@@ -295,6 +1230,184 @@ func (p *Protocol) ForceRegister(r *protocol.Registry) error {
 	return r.ForceRegister(protocolID, p)
 }
 
-func (p *Protocol) calculateVoteWeight(v *VoteBucket, selfStake bool) *big.Int {
-	return calculateVoteWeight(p.config.VoteWeightCalConsts, v, selfStake)
+func (p *Protocol) activeCandidates() (CandidateList, error) {
+	list, err := p.inMemCandidates.All()
+	if err != nil {
+		return nil, err
+	}
+
+	cand := make(CandidateList, 0, len(list))
+	for i := range list {
+		if list[i].SelfStake.Cmp(p.config.RegistrationConsts.MinSelfStake) >= 0 {
+			cand = append(cand, list[i])
+		}
+	}
+	return cand, nil
+}
+
+// exceedRegistrationsPerEpoch returns true if no more candidate can be registered in the current epoch
+func (p *Protocol) exceedRegistrationsPerEpoch(sr protocol.StateReader) (bool, error) {
+	if p.config.MaxRegistrationsPerEpoch == 0 {
+		return false, nil
+	}
+	count, err := getRegistrationCount(sr)
+	if err != nil {
+		return false, err
+	}
+	return count >= p.config.MaxRegistrationsPerEpoch, nil
+}
+
+// countRegistration increments the number of candidates registered in the current epoch
+func (p *Protocol) countRegistration(sm protocol.StateManager) error {
+	if p.config.MaxRegistrationsPerEpoch == 0 {
+		return nil
+	}
+	count, err := getRegistrationCount(sm)
+	if err != nil {
+		return err
+	}
+	return putRegistrationCount(sm, count+1)
+}
+
+// exceedMaxCandidates returns true if no more candidate can be registered
+func (p *Protocol) exceedMaxCandidates() (bool, error) {
+	if p.config.MaxCandidates == 0 {
+		return false, nil
+	}
+	active, err := p.activeCandidates()
+	if err != nil {
+		return false, err
+	}
+	return uint64(len(active)) >= p.config.MaxCandidates, nil
+}
+
+// calculateVoteWeight returns the weighted votes of the bucket at the height of ctx. Since Greenland the weight is
+// calculated with big.Float, and in float64 before
+func (p *Protocol) calculateVoteWeight(ctx context.Context, v *VoteBucket, selfStake bool) *big.Int {
+	if !p.isGreenland(voteWeightHeight(ctx)) {
+		return calculateLegacyVoteWeight(p.config.VoteWeightCalConsts, v, selfStake, p.voteWeightUnit)
+	}
+	return calculateVoteWeight(p.config.VoteWeightCalConsts, v, selfStake, p.voteWeightUnit, p.rounding)
+}
+
+// voteWeightHeight returns the height of the block being processed, or the tip height when reading the state
+func voteWeightHeight(ctx context.Context) uint64 {
+	if blkCtx, ok := protocol.GetBlockCtx(ctx); ok {
+		return blkCtx.BlockHeight
+	}
+	if bcCtx, ok := protocol.GetBlockchainCtx(ctx); ok {
+		return bcCtx.Tip.Height
+	}
+	return 0
+}
+
+// recalculateVotes recalculates the votes of all the candidates in the candidate center from their staked buckets at
+// the height of ctx. It is run at Greenland, such that the votes added with the float64 weight are not subtracted with
+// the big.Float weight
+func (p *Protocol) recalculateVotes(ctx context.Context, sm protocol.StateManager) error {
+	all, err := p.inMemCandidates.All()
+	if err != nil {
+		return errors.Wrap(err, "failed to get candidates")
+	}
+	cands := make(CandidateList, 0, len(all))
+	candMap := make(map[string]*Candidate, len(all))
+	for _, c := range all {
+		// the candidate center is updated by Upsert below, so that the change is recorded for a revert
+		c = c.Clone()
+		c.Votes = big.NewInt(0)
+		cands = append(cands, c)
+		candMap[c.Owner.String()] = c
+	}
+	if err := forEachBucket(sm, func(vb *VoteBucket) error {
+		c, ok := candMap[vb.Candidate.String()]
+		if !ok || vb.UnstakeStartTime.Unix() != 0 {
+			return nil
+		}
+		isSelfStake := vb.Index == c.SelfStakeBucketIdx && c.SelfStake.Sign() > 0
+		c.Votes.Add(c.Votes, p.calculateVoteWeight(ctx, vb, isSelfStake))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, c := range cands {
+		if err := putCandidate(sm, c); err != nil {
+			return errors.Wrapf(err, "failed to put state of candidate %s", c.Owner.String())
+		}
+		if err := p.inMemCandidates.Upsert(c); err != nil {
+			return errors.Wrapf(err, "failed to put candidate %s to the candidate center", c.Owner.String())
+		}
+	}
+	return nil
+}
+
+// paused returns true if the height of the block is in an emergency pause of the staking actions
+func (p *Protocol) paused(ctx context.Context) bool {
+	height := protocol.MustGetBlockCtx(ctx).BlockHeight
+	for _, pause := range p.config.EmergencyPauses {
+		if height >= pause.StartHeight && (pause.EndHeight == 0 || height < pause.EndHeight) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGreenland returns true if the staking rules of the Greenland upgrade apply at the given height
+func (p *Protocol) isGreenland(height uint64) bool {
+	return height >= p.greenlandHeight
+}
+
+// withScheduledGas replaces the intrinsic gas of the action in the context if it is repriced by the gas schedules at
+// the height of the block
+func (p *Protocol) withScheduledGas(ctx context.Context, act action.Action) context.Context {
+	if len(p.config.GasSchedules) == 0 {
+		return ctx
+	}
+	gas, ok := p.scheduledGas(protocol.MustGetBlockCtx(ctx).BlockHeight, act)
+	if !ok {
+		return ctx
+	}
+	actionCtx := protocol.MustGetActionCtx(ctx)
+	actionCtx.IntrinsicGas = gas
+	return protocol.WithActionCtx(ctx, actionCtx)
+}
+
+// validateScheduledGas checks the gas limit of the action covers its intrinsic gas repriced by the gas schedules, as
+// the repriced gas is charged in place of the intrinsic gas the action is sent with
+func (p *Protocol) validateScheduledGas(ctx context.Context, act action.Action) error {
+	gas, ok := p.scheduledGas(validationHeight(ctx), act)
+	if !ok {
+		return nil
+	}
+	limited, ok := act.(interface{ GasLimit() uint64 })
+	if ok && limited.GasLimit() < gas {
+		return errors.Wrapf(action.ErrOutOfGas, "gas limit %d is lower than the scheduled gas %d", limited.GasLimit(), gas)
+	}
+	return nil
+}
+
+// scheduledGas returns the intrinsic gas of the action at the height if it is repriced by the gas schedules
+func (p *Protocol) scheduledGas(height uint64, act action.Action) (uint64, bool) {
+	method := stakingMethod(act)
+	var (
+		gas      uint64
+		repriced bool
+	)
+	for _, schedule := range p.config.GasSchedules {
+		if schedule.Height > height {
+			break
+		}
+		if g, ok := schedule.Gas[method]; ok {
+			gas, repriced = g, true
+		}
+	}
+	return gas, repriced
+}
+
+// remainingCooldown returns the number of blocks from height until a field last changed at lastChange can be changed
+// again, a field that has never been changed has no cooldown
+func remainingCooldown(lastChange, cooldown, height uint64) uint64 {
+	if lastChange == 0 || lastChange+cooldown <= height {
+		return 0
+	}
+	return lastChange + cooldown - height
 }
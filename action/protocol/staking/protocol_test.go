@@ -7,18 +7,27 @@
 package staking
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/iotexproject/iotex-core/action"
 	"github.com/iotexproject/iotex-core/action/protocol"
+	accountutil "github.com/iotexproject/iotex-core/action/protocol/account/util"
+	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
 	"github.com/iotexproject/iotex-core/blockchain/genesis"
 	"github.com/iotexproject/iotex-core/pkg/unit"
+	"github.com/iotexproject/iotex-core/state"
 	"github.com/iotexproject/iotex-core/test/identityset"
 )
 
@@ -147,3 +156,1729 @@ func TestProtocol(t *testing.T) {
 		}
 	}
 }
+
+func TestProtocol_HandleNilStateManager(t *testing.T) {
+	r := require.New(t)
+
+	p, err := NewProtocol(depositGas, nil, genesis.Default.Staking)
+	r.NoError(err)
+	ctx := context.Background()
+
+	// non-matching action type returns before touching the state
+	tsf, err := action.NewTransfer(1, big.NewInt(1), identityset.Address(28).String(), nil, 100000, big.NewInt(0))
+	r.NoError(err)
+	receipt, err := p.Handle(ctx, tsf, nil)
+	r.NoError(err)
+	r.Nil(receipt)
+
+	// matching action type without state manager
+	act, err := action.NewCreateStake(1, "test1", "100", 1, false, nil, 100000, big.NewInt(0))
+	r.NoError(err)
+	receipt, err = p.Handle(ctx, act, nil)
+	r.Equal(protocol.ErrNilStateManager, errors.Cause(err))
+	r.Nil(receipt)
+}
+
+func TestProtocol_GasSchedule(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := NewProtocol(depositGas, sm, genesis.Default.Staking, GasScheduleOption(10, map[string]uint64{"transfer": 1}))
+	r.Error(err)
+	// restake is repriced at height 10, and again at height 20
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking,
+		GasScheduleOption(20, map[string]uint64{HandleRestake: 3000}),
+		GasScheduleOption(10, map[string]uint64{HandleRestake: 5000}),
+	)
+	r.NoError(err)
+
+	caller := identityset.Address(1)
+	r.NoError(setupAccount(sm, caller, 1000))
+	restake, err := action.NewRestake(1, 0, 7, true, nil, 100000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	unstake, err := action.NewUnstake(1, 0, nil, 100000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	for _, test := range []struct {
+		height uint64
+		act    action.Action
+		gas    uint64
+	}{
+		{9, restake, 10000},
+		{10, restake, 5000},
+		{19, restake, 5000},
+		{20, restake, 3000},
+		{20, unstake, 10000},
+	} {
+		ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		})
+		ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    test.height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		before, err := accountutil.LoadAccount(sm, hash.BytesToHash160(caller.Bytes()))
+		r.NoError(err)
+		receipt, err := p.Handle(ctx, test.act, sm)
+		r.NoError(err)
+		// the bucket does not exist, the action fails after charging the gas
+		r.Equal(uint64(iotextypes.ReceiptStatus_ErrInvalidBucketIndex), receipt.Status)
+		r.Equal(test.gas, receipt.GasConsumed)
+		after, err := accountutil.LoadAccount(sm, hash.BytesToHash160(caller.Bytes()))
+		r.NoError(err)
+		fee := new(big.Int).Mul(big.NewInt(unit.Qev), new(big.Int).SetUint64(test.gas))
+		r.Equal(fee, new(big.Int).Sub(before.Balance, after.Balance))
+	}
+
+	// the gas limit must cover the scheduled gas
+	restake, err = action.NewRestake(1, 0, 7, true, nil, 4000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	validate := func(height uint64) error {
+		return p.Validate(protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight: height,
+		}), restake)
+	}
+	r.Equal(action.ErrOutOfGas, errors.Cause(validate(10)))
+	r.NoError(validate(20))
+}
+
+func TestProtocol_StakingConfig(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	cfg := genesis.Default.Staking
+	stk, err := NewProtocol(nil, sm, cfg)
+	r.NoError(err)
+
+	view := stk.StakingConfig()
+	r.Equal(cfg.VoteWeightCalConsts, view.VoteWeightCalConsts)
+	r.Equal(cfg.RegistrationConsts.Fee, view.RegistrationFee.String())
+	r.Equal(cfg.RegistrationConsts.MinSelfStake, view.MinSelfStake.String())
+	r.Equal(cfg.WithdrawWaitingPeriod, view.WithdrawWaitingPeriod)
+	r.Equal(cfg.MinStakeAmount, view.MinStakeAmount.String())
+
+	// modifying the view does not affect the protocol
+	view.RegistrationFee.SetInt64(0)
+	view.MinStakeAmount.SetInt64(0)
+	r.Equal(cfg.RegistrationConsts.Fee, stk.StakingConfig().RegistrationFee.String())
+	r.Equal(cfg.MinStakeAmount, stk.StakingConfig().MinStakeAmount.String())
+}
+
+func TestProtocol_BucketVoteWeight(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	_, err = p.BucketVoteWeight(context.Background(), sm, 0)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+
+	// register a candidate, whose votes come from its self-stake bucket only
+	owner := identityset.Address(1)
+	r.NoError(setupAccount(sm, owner, 1300000))
+	ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{
+		Caller:       owner,
+		GasPrice:     big.NewInt(unit.Qev),
+		IntrinsicGas: 10000,
+		Nonce:        1,
+	})
+	ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	act, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+		genesis.Default.Staking.RegistrationConsts.MinSelfStake, 91, true, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	_, err = p.handleCandidateRegister(ctx, act, sm)
+	r.NoError(err)
+	c := p.inMemCandidates.GetByOwner(owner)
+	r.NotNil(c)
+
+	weight, err := p.BucketVoteWeight(context.Background(), sm, c.SelfStakeBucketIdx)
+	r.NoError(err)
+	r.Equal(c.Votes, weight)
+	bucket, err := getBucket(sm, c.SelfStakeBucketIdx)
+	r.NoError(err)
+	// the self-stake bonus is accounted for
+	r.Equal(1, weight.Cmp(p.calculateVoteWeight(context.Background(), bucket, false)))
+}
+
+func TestProtocol_EpochVoteWeight(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	g := genesis.Default
+	timeBased, err := NewProtocol(depositGas, sm, g.Staking)
+	r.NoError(err)
+	_, err = NewProtocol(depositGas, sm, g.Staking, EpochVoteWeightOption(0))
+	r.Error(err)
+
+	// an epoch of 1 day weighs the same as days, a shorter epoch counts more units of the remaining lock time
+	dayEpoch, err := NewProtocol(depositGas, sm, g.Staking, EpochVoteWeightOption(24*time.Hour))
+	r.NoError(err)
+	hourEpoch, err := NewProtocol(depositGas, sm, g.Staking, EpochVoteWeightOption(2*time.Hour))
+	r.NoError(err)
+
+	bucket := NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(1000000), 91, time.Now(), true)
+	for _, height := range []uint64{1, 99, 100, 101} {
+		// the weight does not depend on the height, nor on the rolldpos protocol
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{BlockHeight: height})
+		for _, selfStake := range []bool{false, true} {
+			weight := timeBased.calculateVoteWeight(ctx, bucket, selfStake)
+			r.Equal(weight, dayEpoch.calculateVoteWeight(ctx, bucket, selfStake))
+			r.Equal(1, hourEpoch.calculateVoteWeight(ctx, bucket, selfStake).Cmp(weight))
+			r.Equal(hourEpoch.calculateVoteWeight(ctx, bucket, selfStake), hourEpoch.calculateVoteWeight(context.Background(), bucket, selfStake))
+		}
+	}
+}
+
+func TestProtocol_AllSelfStakeBuckets(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	buckets, err := p.AllSelfStakeBuckets(sm)
+	r.NoError(err)
+	r.Equal(0, len(buckets))
+
+	// the third candidate has withdrawn its self-stake bucket
+	selfStakes := []int64{1200000, 1300000, 0}
+	for i, selfStake := range selfStakes {
+		owner := identityset.Address(i + 1)
+		amount := unit.ConvertIotxToRau(selfStake)
+		idx := uint64(1000)
+		if selfStake > 0 {
+			idx, err = putBucketAndIndex(sm, NewVoteBucket(owner, owner, amount, 91, time.Now(), true))
+			r.NoError(err)
+		}
+		// a bucket other than the self-stake one
+		_, err = putBucketAndIndex(sm, NewVoteBucket(owner, identityset.Address(10), big.NewInt(100), 1, time.Now(), true))
+		r.NoError(err)
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: idx,
+			SelfStake:          amount,
+		}))
+	}
+
+	buckets, err = p.AllSelfStakeBuckets(sm)
+	r.NoError(err)
+	r.Equal(2, len(buckets))
+	for i, selfStake := range selfStakes {
+		owner := identityset.Address(i + 1)
+		c := p.inMemCandidates.GetByOwner(owner)
+		r.NotNil(c)
+		bucket, ok := buckets[owner.String()]
+		if selfStake == 0 {
+			r.False(ok)
+			continue
+		}
+		r.True(ok)
+		r.Equal(c.SelfStakeBucketIdx, bucket.Index)
+		r.Equal(owner, bucket.Owner)
+		r.Equal(unit.ConvertIotxToRau(selfStake), bucket.StakedAmount)
+	}
+}
+
+func TestProtocol_BucketsByIndexes(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	// no bucket at all
+	buckets, err := p.BucketsByIndexes(sm, []uint64{1})
+	r.Error(err)
+	r.Equal([]*VoteBucket{nil}, buckets)
+
+	for i := 0; i < 5; i++ {
+		_, err := putBucketAndIndex(sm, NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(int64(i+1)), 7, time.Now(), true))
+		r.NoError(err)
+	}
+	r.NoError(delBucket(sm, 3))
+
+	indexes := []uint64{4, 3, 0, 9, 2, 4}
+	buckets, err = p.BucketsByIndexes(sm, indexes)
+	r.Equal(len(indexes), len(buckets))
+	for i, index := range indexes {
+		if index == 3 || index == 9 {
+			r.Nil(buckets[i])
+			continue
+		}
+		r.Equal(index, buckets[i].Index)
+		r.Equal(big.NewInt(int64(index+1)), buckets[i].StakedAmount)
+	}
+	misses, ok := err.(bucketFetchErrors)
+	r.True(ok)
+	r.Equal(2, len(misses))
+	r.Equal(state.ErrStateNotExist, errors.Cause(misses[3]))
+	r.Equal(state.ErrStateNotExist, errors.Cause(misses[9]))
+	r.Contains(err.Error(), "bucket 3:")
+	r.Contains(err.Error(), "bucket 9:")
+
+	buckets, err = p.BucketsByIndexes(sm, []uint64{1, 0})
+	r.NoError(err)
+	r.Equal(uint64(1), buckets[0].Index)
+	r.Equal(uint64(0), buckets[1].Index)
+}
+
+func TestProtocol_CandidateBuckets(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	cfg := genesis.Default.Staking
+	p, err := NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+
+	owner := identityset.Address(1)
+	voter := identityset.Address(2)
+	r.NoError(setupAccount(sm, owner, 1300000))
+	r.NoError(setupAccount(sm, voter, 10000))
+	now := time.Now()
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: now,
+		GasLimit:       1000000,
+	})
+	actCtx := func(caller address.Address, nonce uint64) context.Context {
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+
+	_, err = p.CandidateBuckets(ctx, sm, owner, now)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+
+	register, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+		cfg.RegistrationConsts.MinSelfStake, 91, true, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	receipt, err := p.handleCandidateRegister(actCtx(owner, 1), register, sm)
+	r.NoError(err)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	stakes := []struct {
+		amount    int64
+		duration  uint32
+		autoStake bool
+	}{
+		{100, 0, false},
+		{200, 7, true},
+		{300, 30, false},
+	}
+	for i, s := range stakes {
+		act, err := action.NewCreateStake(uint64(i+1), "test1", unit.ConvertIotxToRau(s.amount).String(), s.duration,
+			s.autoStake, nil, 10000, big.NewInt(unit.Qev))
+		r.NoError(err)
+		receipt, err := p.handleCreateStake(actCtx(voter, uint64(i+1)), act, sm)
+		r.NoError(err)
+		r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	}
+	// the unstaked bucket no longer counts
+	unstake, err := action.NewUnstake(4, 1, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	receipt, err = p.handleUnstake(actCtx(voter, 4), unstake, sm)
+	r.NoError(err)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+
+	buckets, err := p.CandidateBuckets(ctx, sm, owner, now)
+	r.NoError(err)
+	r.Len(buckets, 4)
+	sum := big.NewInt(0)
+	for i, b := range buckets {
+		r.Equal(uint64(i), b.Index)
+		r.Equal(owner, b.Candidate)
+		if i == 0 {
+			// the self-stake bucket is weighted as such
+			r.Equal(owner, b.Owner)
+			r.Equal(p.calculateVoteWeight(ctx, b.VoteBucket, true), b.Weight)
+			r.Equal(1, b.Weight.Cmp(p.calculateVoteWeight(ctx, b.VoteBucket, false)))
+		} else {
+			s := stakes[i-1]
+			r.Equal(voter, b.Owner)
+			r.Equal(unit.ConvertIotxToRau(s.amount), b.StakedAmount)
+			r.Equal(time.Duration(s.duration)*24*time.Hour, b.StakedDuration)
+			r.Equal(s.autoStake, b.AutoStake)
+		}
+		if i == 1 {
+			r.Equal(BucketUnstaking, b.Status)
+			r.Zero(b.Weight.Sign())
+		} else {
+			r.Equal(BucketLocked, b.Status)
+			r.Equal(p.calculateVoteWeight(ctx, b.VoteBucket, i == 0), b.Weight)
+		}
+		sum.Add(sum, b.Weight)
+	}
+	c, err := getCandidate(sm, owner)
+	r.NoError(err)
+	r.Equal(c.Votes, sum)
+}
+
+func TestProtocol_BucketsByStatus(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	cfg := genesis.Default.Staking
+	cfg.WithdrawWaitingPeriod = 3 * 24 * time.Hour
+	p, err := NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+
+	now := time.Now()
+	buckets, err := p.BucketsByStatus(sm, BucketUnstaking, now)
+	r.NoError(err)
+	r.Equal(0, len(buckets))
+
+	cand := identityset.Address(1)
+	voter := identityset.Address(2)
+	tests := []struct {
+		ctime       time.Time
+		autoStake   bool
+		unstakeTime time.Time
+		status      BucketStatus
+	}{
+		{now, false, time.Unix(0, 0), BucketLocked},
+		{now.Add(-30 * 24 * time.Hour), true, time.Unix(0, 0), BucketLocked},
+		{now.Add(-30 * 24 * time.Hour), false, time.Unix(0, 0), BucketUnlocked},
+		{now.Add(-30 * 24 * time.Hour), false, now.Add(-time.Hour), BucketUnstaking},
+		{now.Add(-30 * 24 * time.Hour), false, now.Add(-24 * time.Hour), BucketUnstaking},
+		{now.Add(-30 * 24 * time.Hour), false, now.Add(-4 * 24 * time.Hour), BucketWithdrawable},
+	}
+	for _, test := range tests {
+		bucket := NewVoteBucket(cand, voter, big.NewInt(100), 7, test.ctime, test.autoStake)
+		bucket.UnstakeStartTime = test.unstakeTime.UTC()
+		_, err = putBucket(sm, bucket)
+		r.NoError(err)
+	}
+
+	for _, status := range []BucketStatus{BucketLocked, BucketUnlocked, BucketUnstaking, BucketWithdrawable} {
+		buckets, err := p.BucketsByStatus(sm, status, now)
+		r.NoError(err)
+		var expected []uint64
+		for i, test := range tests {
+			if test.status == status {
+				expected = append(expected, uint64(i))
+			}
+		}
+		indexes := make([]uint64, 0, len(buckets))
+		for _, b := range buckets {
+			indexes = append(indexes, b.Index)
+			r.Equal(status, b.status(now, cfg.WithdrawWaitingPeriod))
+		}
+		r.Equal(expected, indexes)
+	}
+}
+
+func TestProtocol_OrphanedBuckets(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	buckets, err := p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Empty(buckets)
+
+	voter := identityset.Address(3)
+	var owners []address.Address
+	for i := 0; i < 2; i++ {
+		owner := identityset.Address(i + 1)
+		owners = append(owners, owner)
+		r.NoError(putCandidate(sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: uint64(2 * i),
+			SelfStake:          big.NewInt(100),
+		}))
+		// the self-stake bucket and a voter bucket of each candidate
+		for _, staker := range []address.Address{owner, voter} {
+			_, err = putBucket(sm, NewVoteBucket(owner, staker, big.NewInt(100), 7, time.Now(), true))
+			r.NoError(err)
+		}
+	}
+	buckets, err = p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Empty(buckets)
+
+	// the buckets of a removed candidate are orphaned
+	r.NoError(delCandidate(sm, owners[0]))
+	buckets, err = p.OrphanedBuckets(sm)
+	r.NoError(err)
+	r.Len(buckets, 2)
+	for i, b := range buckets {
+		r.Equal(uint64(i), b.Index)
+		r.Equal(owners[0], b.Candidate)
+	}
+	r.Equal(owners[0], buckets[0].Owner)
+	r.Equal(voter, buckets[1].Owner)
+}
+
+func TestProtocol_TopDelegators(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	cand, other := identityset.Address(1), identityset.Address(2)
+	top, err := p.TopDelegators(sm, cand, 3)
+	r.NoError(err)
+	r.Empty(top)
+
+	for _, b := range []struct {
+		cand, owner address.Address
+		amount      int64
+	}{
+		{cand, identityset.Address(3), 100},
+		{cand, identityset.Address(4), 150},
+		{cand, identityset.Address(3), 100},
+		{cand, identityset.Address(5), 50},
+		{cand, identityset.Address(6), 200},
+		{cand, identityset.Address(5), 100},
+		{cand, identityset.Address(7), 10},
+		// buckets staked to another candidate do not count
+		{other, identityset.Address(7), 1000},
+		{other, identityset.Address(4), 1000},
+	} {
+		_, err = putBucket(sm, NewVoteBucket(b.cand, b.owner, big.NewInt(b.amount), 7, time.Now(), true))
+		r.NoError(err)
+	}
+	// an unstaked bucket does not count
+	vb := NewVoteBucket(cand, identityset.Address(7), big.NewInt(1000), 7, time.Now(), true)
+	vb.UnstakeStartTime = time.Now().UTC()
+	_, err = putBucket(sm, vb)
+	r.NoError(err)
+
+	expected := []struct {
+		owner  address.Address
+		amount int64
+	}{
+		{identityset.Address(3), 200},
+		{identityset.Address(6), 200},
+		{identityset.Address(4), 150},
+		{identityset.Address(5), 150},
+		{identityset.Address(7), 10},
+	}
+	// ties are ordered by owner address
+	if identityset.Address(6).String() < identityset.Address(3).String() {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	if identityset.Address(5).String() < identityset.Address(4).String() {
+		expected[2], expected[3] = expected[3], expected[2]
+	}
+	for _, n := range []int{0, 1, 3, 5, 10} {
+		top, err = p.TopDelegators(sm, cand, n)
+		r.NoError(err)
+		size := n
+		if size > len(expected) {
+			size = len(expected)
+		}
+		r.Len(top, size)
+		for i, d := range top {
+			r.Equal(expected[i].owner, d.Owner)
+			r.Equal(big.NewInt(expected[i].amount), d.Amount)
+		}
+	}
+}
+
+func TestProtocol_StateDigest(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctime := time.Now()
+	cands := []*Candidate{
+		{
+			Owner:              identityset.Address(1),
+			Operator:           identityset.Address(11),
+			Reward:             identityset.Address(1),
+			Name:               "test1",
+			Votes:              big.NewInt(100),
+			SelfStakeBucketIdx: 0,
+			SelfStake:          big.NewInt(100),
+		},
+		{
+			Owner:              identityset.Address(2),
+			Operator:           identityset.Address(12),
+			Reward:             identityset.Address(2),
+			Name:               "test2",
+			Votes:              big.NewInt(200),
+			SelfStakeBucketIdx: 1,
+			SelfStake:          big.NewInt(200),
+		},
+	}
+	newState := func(reverse bool) (protocol.StateManager, *Protocol) {
+		sm := newMockStateManager(ctrl)
+		_, err := sm.PutState(
+			&totalBucketCount{count: 0},
+			protocol.NamespaceOption(StakingNameSpace),
+			protocol.KeyOption(TotalBucketKey),
+		)
+		r.NoError(err)
+		p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+		r.NoError(err)
+		for _, c := range cands {
+			_, err := putBucketAndIndex(sm, NewVoteBucket(c.Owner, c.Owner, c.SelfStake, 91, ctime, true))
+			r.NoError(err)
+		}
+		for i := range cands {
+			if reverse {
+				i = len(cands) - 1 - i
+			}
+			r.NoError(setupCandidate(p, sm, cands[i].Clone()))
+		}
+		return sm, p
+	}
+
+	sm1, p := newState(false)
+	digest, err := p.StateDigest(sm1)
+	r.NoError(err)
+	r.Equal(32, len(digest))
+	d, err := p.StateDigest(sm1)
+	r.NoError(err)
+	r.Equal(digest, d)
+
+	// equal states have the same digest, regardless of the order they are written
+	sm2, _ := newState(true)
+	d, err = p.StateDigest(sm2)
+	r.NoError(err)
+	r.Equal(digest, d)
+
+	// any mutation changes the digest
+	c := cands[0].Clone()
+	r.NoError(c.AddVote(big.NewInt(1)))
+	r.NoError(putCandidate(sm2, c))
+	d, err = p.StateDigest(sm2)
+	r.NoError(err)
+	r.NotEqual(digest, d)
+
+	sm3, _ := newState(false)
+	_, err = putBucketAndIndex(sm3, NewVoteBucket(cands[0].Owner, identityset.Address(3), big.NewInt(10), 7, ctime, false))
+	r.NoError(err)
+	d, err = p.StateDigest(sm3)
+	r.NoError(err)
+	r.NotEqual(digest, d)
+
+	// an empty state has a digest too
+	empty := newMockStateManager(ctrl)
+	d, err = p.StateDigest(empty)
+	r.NoError(err)
+	r.NotEqual(digest, d)
+}
+
+func TestProtocol_IterateBucketsByCandidate(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	visit := func(cand address.Address, b *VoteBucket) error { return nil }
+	r.NoError(p.IterateBucketsByCandidate(sm, visit))
+
+	// buckets are created interleaved among candidates
+	expected := make(map[string][]uint64)
+	for i := 0; i < 3; i++ {
+		for j := 1; j <= 3; j++ {
+			cand := identityset.Address(j)
+			idx, err := putBucketAndIndex(sm, NewVoteBucket(cand, identityset.Address(10+i), big.NewInt(100), 1, time.Now(), true))
+			r.NoError(err)
+			expected[cand.String()] = append(expected[cand.String()], idx)
+		}
+	}
+	for j := 1; j <= 3; j++ {
+		owner := identityset.Address(j)
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(j + 20),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", j),
+			Votes:              big.NewInt(0),
+			SelfStakeBucketIdx: expected[owner.String()][0],
+			SelfStake:          big.NewInt(100),
+		}))
+	}
+
+	var (
+		order  []string
+		actual = make(map[string][]uint64)
+	)
+	r.NoError(p.IterateBucketsByCandidate(sm, func(cand address.Address, b *VoteBucket) error {
+		r.Equal(cand.String(), b.Candidate.String())
+		if len(order) == 0 || order[len(order)-1] != cand.String() {
+			order = append(order, cand.String())
+		}
+		actual[cand.String()] = append(actual[cand.String()], b.Index)
+		return nil
+	}))
+	// each candidate appears in one contiguous group, ordered by address bytes
+	r.Equal(3, len(order))
+	for i := 1; i < len(order); i++ {
+		prev, err := address.FromString(order[i-1])
+		r.NoError(err)
+		curr, err := address.FromString(order[i])
+		r.NoError(err)
+		r.True(bytes.Compare(prev.Bytes(), curr.Bytes()) < 0)
+	}
+	r.Equal(expected, actual)
+
+	// an error returned by the callback aborts the iteration
+	errStop := errors.New("stop")
+	count := 0
+	err = p.IterateBucketsByCandidate(sm, func(cand address.Address, b *VoteBucket) error {
+		count++
+		if count == 2 {
+			return errStop
+		}
+		return nil
+	})
+	r.Equal(errStop, errors.Cause(err))
+	r.Equal(2, count)
+}
+
+func TestProtocol_SimulateDelegateSet(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+	g := genesis.Default
+	g.NumCandidateDelegates = 2
+	ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{Genesis: g})
+
+	minSelfStake := p.config.RegistrationConsts.MinSelfStake
+	for i, votes := range []int64{300, 200, 100, 500} {
+		owner := identityset.Address(i + 1)
+		selfStake := new(big.Int).Set(minSelfStake)
+		if i == 3 {
+			// not an active candidate
+			selfStake.Sub(selfStake, big.NewInt(1))
+		}
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 21),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(votes),
+			SelfStakeBucketIdx: uint64(i),
+			SelfStake:          selfStake,
+		}))
+	}
+	delegates := func(changes ...CandidateVoteChange) []string {
+		list, err := p.SimulateDelegateSet(ctx, sm, changes)
+		r.NoError(err)
+		addrs := make([]string, 0, len(list))
+		for _, d := range list {
+			addrs = append(addrs, d.Address)
+		}
+		return addrs
+	}
+
+	r.Equal([]string{identityset.Address(1).String(), identityset.Address(2).String()}, delegates())
+
+	// a vote increase promotes candidate 3 into the top set
+	r.Equal(
+		[]string{identityset.Address(1).String(), identityset.Address(3).String()},
+		delegates(CandidateVoteChange{identityset.Address(3), big.NewInt(150)}),
+	)
+	// a candidate whose votes drop to zero is excluded
+	r.Equal(
+		[]string{identityset.Address(2).String(), identityset.Address(3).String()},
+		delegates(CandidateVoteChange{identityset.Address(1), big.NewInt(-1000)}),
+	)
+
+	// the state is not changed
+	c, err := getCandidate(sm, identityset.Address(3))
+	r.NoError(err)
+	r.Equal(big.NewInt(100), c.Votes)
+	r.Equal(big.NewInt(100), p.inMemCandidates.GetByOwner(identityset.Address(3)).Votes)
+	c, err = getCandidate(sm, identityset.Address(1))
+	r.NoError(err)
+	r.Equal(big.NewInt(300), c.Votes)
+	r.Equal([]string{identityset.Address(1).String(), identityset.Address(2).String()}, delegates())
+
+	// invalid changes
+	_, err = p.SimulateDelegateSet(ctx, sm, []CandidateVoteChange{{identityset.Address(9), big.NewInt(1)}})
+	r.Equal(ErrCandidateNotExist, errors.Cause(err))
+	_, err = p.SimulateDelegateSet(ctx, sm, []CandidateVoteChange{{identityset.Address(1), nil}})
+	r.Equal(action.ErrInvalidAmount, errors.Cause(err))
+}
+
+func TestProtocol_MarginalStakeForEntry(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+	g := genesis.Default
+	g.NumCandidateDelegates = 2
+	ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{Genesis: g})
+
+	minSelfStake := p.config.RegistrationConsts.MinSelfStake
+	for i, votes := range []int64{300, 200, 100, 500} {
+		owner := identityset.Address(i + 1)
+		selfStake := new(big.Int).Set(minSelfStake)
+		if i == 3 {
+			// not an active candidate
+			selfStake.Sub(selfStake, big.NewInt(1))
+		}
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 21),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(votes),
+			SelfStakeBucketIdx: uint64(i),
+			SelfStake:          selfStake,
+		}))
+	}
+	now := time.Now()
+
+	// delegates need no more stake
+	for _, i := range []int{1, 2} {
+		amount, err := p.MarginalStakeForEntry(ctx, sm, identityset.Address(i), now)
+		r.NoError(err)
+		r.Zero(amount.Sign())
+	}
+
+	// the margin promotes candidate 3 into the delegate set, and one less only ties with the lowest delegate
+	owner := identityset.Address(3)
+	amount, err := p.MarginalStakeForEntry(ctx, sm, owner, now)
+	r.NoError(err)
+	r.Equal(big.NewInt(101), amount)
+	delta := func(amount *big.Int) *big.Int {
+		return p.calculateVoteWeight(ctx, NewVoteBucket(owner, owner, amount, 0, now, false), false)
+	}
+	list, err := p.SimulateDelegateSet(ctx, sm, []CandidateVoteChange{{owner, delta(amount)}})
+	r.NoError(err)
+	r.Len(list, 2)
+	r.Equal(identityset.Address(1).String(), list[0].Address)
+	r.Equal(owner.String(), list[1].Address)
+	r.Equal(big.NewInt(200), new(big.Int).Add(big.NewInt(100), delta(new(big.Int).Sub(amount, big.NewInt(1)))))
+
+	// a bucket with lock duration weighs more, so the amount suffices for it as well
+	weighted := p.calculateVoteWeight(ctx, NewVoteBucket(owner, owner, amount, 91, now, true), false)
+	r.Equal(1, weighted.Cmp(amount))
+
+	// a candidate not found or without enough self-stake
+	_, err = p.MarginalStakeForEntry(ctx, sm, identityset.Address(9), now)
+	r.Equal(ErrCandidateNotExist, errors.Cause(err))
+	_, err = p.MarginalStakeForEntry(ctx, sm, identityset.Address(4), now)
+	r.Error(err)
+}
+
+func TestProtocol_CandidateRank(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking, WeightSnapshotOption())
+	r.NoError(err)
+	ctx := context.Background()
+
+	// no candidate yet
+	rank, total, err := p.CandidateRank(ctx, sm, identityset.Address(1), 1)
+	r.NoError(err)
+	r.Equal(UnrankedCandidate, rank)
+	r.Zero(total)
+
+	minSelfStake := p.config.RegistrationConsts.MinSelfStake
+	newCandidate := func(i int, votes int64) *Candidate {
+		owner := identityset.Address(i)
+		selfStake := new(big.Int).Set(minSelfStake)
+		if i == 5 {
+			// not an active candidate
+			selfStake.Sub(selfStake, big.NewInt(1))
+		}
+		return &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 20),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i),
+			Votes:              big.NewInt(votes),
+			SelfStakeBucketIdx: uint64(i),
+			SelfStake:          selfStake,
+		}
+	}
+	for i, votes := range []int64{300, 200, 100, 100, 500} {
+		r.NoError(setupCandidate(p, sm, newCandidate(i+1, votes)))
+	}
+	// candidates 3 and 4 tie, and are ordered by their address bytes
+	third, fourth := 3, 4
+	if bytes.Compare(identityset.Address(3).Bytes(), identityset.Address(4).Bytes()) > 0 {
+		third, fourth = 4, 3
+	}
+	tests := []struct {
+		owner int
+		rank  int
+	}{
+		{1, 1},
+		{2, 2},
+		{third, 3},
+		{fourth, 4},
+		{5, UnrankedCandidate},
+		{9, UnrankedCandidate},
+	}
+	for _, test := range tests {
+		rank, total, err := p.CandidateRank(ctx, sm, identityset.Address(test.owner), 1)
+		r.NoError(err)
+		r.Equal(test.rank, rank)
+		r.Equal(4, total)
+	}
+
+	// the ordering of an epoch uses the weights frozen at its start
+	rank4 := 4
+	if fourth != 4 {
+		rank4 = 3
+	}
+	r.NoError(p.snapshotWeights(sm, 1))
+	r.NoError(setupCandidate(p, sm, newCandidate(4, 1000)))
+	rank, total, err = p.CandidateRank(ctx, sm, identityset.Address(4), 1)
+	r.NoError(err)
+	r.Equal(rank4, rank)
+	r.Equal(4, total)
+	rank, total, err = p.CandidateRank(ctx, sm, identityset.Address(4), 2)
+	r.NoError(err)
+	r.Equal(1, rank)
+	r.Equal(4, total)
+}
+
+func TestProtocol_CandidateWithVerifiedSelfStake(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	owner := identityset.Address(1)
+	_, _, err = p.CandidateWithVerifiedSelfStake(sm, owner)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+
+	selfStake := unit.ConvertIotxToRau(1200000)
+	idx, err := putBucketAndIndex(sm, NewVoteBucket(owner, owner, selfStake, 91, time.Now(), true))
+	r.NoError(err)
+	cand := &Candidate{
+		Owner:              owner,
+		Operator:           identityset.Address(11),
+		Reward:             owner,
+		Name:               "test1",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: idx,
+		SelfStake:          selfStake,
+	}
+	r.NoError(setupCandidate(p, sm, cand))
+	c, verified, err := p.CandidateWithVerifiedSelfStake(sm, owner)
+	r.NoError(err)
+	r.True(verified)
+	r.Equal(cand, c)
+
+	// the cached self-stake drifts from the self-stake bucket
+	drifted := cand.Clone()
+	drifted.SelfStake = new(big.Int).Add(selfStake, big.NewInt(1))
+	r.NoError(p.inMemCandidates.Upsert(drifted))
+	c, verified, err = p.CandidateWithVerifiedSelfStake(sm, owner)
+	r.NoError(err)
+	r.False(verified)
+	r.Equal(drifted, c)
+
+	// the self-stake bucket has been withdrawn
+	r.NoError(delBucket(sm, idx))
+	_, verified, err = p.CandidateWithVerifiedSelfStake(sm, owner)
+	r.NoError(err)
+	r.False(verified)
+	drifted = cand.Clone()
+	drifted.SelfStake = big.NewInt(0)
+	r.NoError(p.inMemCandidates.Upsert(drifted))
+	_, verified, err = p.CandidateWithVerifiedSelfStake(sm, owner)
+	r.NoError(err)
+	r.True(verified)
+}
+
+type snapshotStateManager struct {
+	protocol.StateManager
+	listeners []protocol.SnapshotListener
+	snapshot  int
+}
+
+func (sm *snapshotStateManager) Subscribe(l protocol.SnapshotListener) {
+	sm.listeners = append(sm.listeners, l)
+}
+
+func (sm *snapshotStateManager) Snapshot() int {
+	s := sm.snapshot
+	sm.snapshot++
+	for _, l := range sm.listeners {
+		l.Snapshot(s)
+	}
+	return s
+}
+
+func (sm *snapshotStateManager) Revert(snapshot int) error {
+	for _, l := range sm.listeners {
+		if err := l.Revert(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestProtocol_RevertCandidateCenter(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := &snapshotStateManager{StateManager: newMockStateManager(ctrl)}
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+	r.NoError(p.CreatePreStates(context.Background(), sm))
+
+	owner1 := identityset.Address(1)
+	owner2 := identityset.Address(2)
+	r.NoError(setupAccount(sm, owner1, 1300000))
+	r.NoError(setupAccount(sm, owner2, 1300000))
+	register := func(owner address.Address, name string) {
+		ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		})
+		ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    1,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		act, err := action.NewCandidateRegister(1, name, owner.String(), owner.String(), owner.String(),
+			genesis.Default.Staking.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		r.NoError(err)
+		receipt, err := p.Handle(ctx, act, sm)
+		r.NoError(err)
+		r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	}
+
+	register(owner1, "test1")
+	snapshot := sm.Snapshot()
+	register(owner2, "test2")
+	r.True(p.inMemCandidates.ContainsOwner(owner2))
+	r.Equal(2, p.inMemCandidates.Size())
+
+	r.NoError(sm.Revert(snapshot))
+	r.False(p.inMemCandidates.ContainsOwner(owner2))
+	r.False(p.inMemCandidates.ContainsName("test2"))
+	r.False(p.inMemCandidates.ContainsOperator(owner2))
+	r.True(p.inMemCandidates.ContainsOwner(owner1))
+	r.Equal(1, p.inMemCandidates.Size())
+
+	// unknown snapshot
+	r.Error(sm.Revert(snapshot + 1))
+	// snapshots are cleared for a new state manager
+	r.NoError(p.CreatePreStates(context.Background(), sm))
+	r.Error(sm.Revert(snapshot))
+}
+
+func TestProtocol_MaxRegistrationsPerEpoch(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+
+	g := genesis.Default
+	g.Staking.MaxRegistrationsPerEpoch = 2
+	p, err := NewProtocol(depositGas, sm, g.Staking)
+	r.NoError(err)
+	r.Equal(uint64(2), p.StakingConfig().MaxRegistrationsPerEpoch)
+
+	// an epoch lasts 10 blocks, epoch 2 starts at height 11
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	r.Equal(uint64(11), rp.GetEpochHeight(2))
+	blkCtx := func(height uint64) context.Context {
+		ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+			Genesis:  g,
+			Registry: registry,
+		})
+		return protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+	}
+	register := func(height uint64, i int) uint64 {
+		owner := identityset.Address(i)
+		r.NoError(setupAccount(sm, owner, 1300000))
+		act, err := action.NewCandidateRegister(1, fmt.Sprintf("test%d", i), owner.String(), owner.String(), owner.String(),
+			g.Staking.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		r.NoError(err)
+		receipt, err := p.handleCandidateRegister(protocol.WithActionCtx(blkCtx(height), protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        1,
+		}), act, sm)
+		r.NoError(err)
+		return receipt.Status
+	}
+
+	// register up to the cap in epoch 1
+	r.NoError(p.CreatePreStates(blkCtx(1), sm))
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), register(2, 1))
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), register(3, 2))
+	count, err := getRegistrationCount(sm)
+	r.NoError(err)
+	r.Equal(uint64(2), count)
+
+	// the cap is hit for the rest of the epoch
+	r.Equal(uint64(ReceiptStatusErrExceedRegistrationsPerEpoch), register(4, 3))
+	r.NoError(p.CreatePreStates(blkCtx(10), sm))
+	r.Equal(uint64(ReceiptStatusErrExceedRegistrationsPerEpoch), register(10, 3))
+	r.Nil(p.inMemCandidates.GetByOwner(identityset.Address(3)))
+
+	// the count is reset at the start of the next epoch
+	r.NoError(p.CreatePreStates(blkCtx(11), sm))
+	count, err = getRegistrationCount(sm)
+	r.NoError(err)
+	r.Zero(count)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), register(11, 3))
+	r.NotNil(p.inMemCandidates.GetByOwner(identityset.Address(3)))
+	count, err = getRegistrationCount(sm)
+	r.NoError(err)
+	r.Equal(uint64(1), count)
+}
+
+func TestProtocol_WeightSnapshot(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	g := genesis.Default
+	p, err := NewProtocol(depositGas, sm, g.Staking, WeightSnapshotOption())
+	r.NoError(err)
+
+	// an epoch lasts 10 blocks, epoch 2 starts at height 11
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	blkCtx := func(height uint64) context.Context {
+		ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+			Genesis:  g,
+			Registry: registry,
+		})
+		return protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+		})
+	}
+
+	cands := make([]*Candidate, 2)
+	for i := range cands {
+		owner := identityset.Address(i + 1)
+		cands[i] = &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(int64(100 * (i + 1))),
+			SelfStakeBucketIdx: uint64(i),
+			SelfStake:          big.NewInt(0),
+		}
+		r.NoError(setupCandidate(p, sm, cands[i]))
+	}
+
+	// no snapshot is taken in the middle of an epoch
+	r.NoError(p.CreatePreStates(blkCtx(5), sm))
+	_, err = p.WeightSnapshotByEpoch(sm, 1)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+
+	r.NoError(p.CreatePreStates(blkCtx(11), sm))
+	expected := map[string]*big.Int{
+		identityset.Address(1).String(): big.NewInt(100),
+		identityset.Address(2).String(): big.NewInt(200),
+	}
+	weights, err := p.WeightSnapshotByEpoch(sm, 2)
+	r.NoError(err)
+	r.Equal(expected, weights)
+
+	// live changes during the epoch do not affect the snapshot
+	changed := cands[0].Clone()
+	r.NoError(changed.AddVote(big.NewInt(1000)))
+	r.NoError(setupCandidate(p, sm, changed))
+	r.NoError(p.CreatePreStates(blkCtx(15), sm))
+	weights, err = p.WeightSnapshotByEpoch(sm, 2)
+	r.NoError(err)
+	r.Equal(expected, weights)
+
+	// the next epoch picks up the change
+	r.NoError(p.CreatePreStates(blkCtx(21), sm))
+	weights, err = p.WeightSnapshotByEpoch(sm, 3)
+	r.NoError(err)
+	r.Equal(big.NewInt(1100), weights[identityset.Address(1).String()])
+	weights, err = p.WeightSnapshotByEpoch(sm, 2)
+	r.NoError(err)
+	r.Equal(expected, weights)
+}
+
+func TestProtocol_GenesisBuckets(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+
+	cfg := genesis.Default.Staking
+	for i := 0; i < 2; i++ {
+		owner := identityset.Address(i + 1).String()
+		cfg.BootstrapCandidates = append(cfg.BootstrapCandidates, genesis.BootstrapCandidate{
+			OwnerAddress:      owner,
+			OperatorAddress:   identityset.Address(i + 11).String(),
+			RewardAddress:     owner,
+			Name:              fmt.Sprintf("test%d", i+1),
+			SelfStakingTokens: "1200000000000000000000000",
+		})
+	}
+	foundation := identityset.Address(20)
+	cfg.GenesisBuckets = []genesis.GenesisBucket{
+		{OwnerAddress: foundation.String(), CandidateName: "test1", Amount: "1000000000000000000000", Duration: 91, AutoStake: true},
+		{OwnerAddress: foundation.String(), CandidateName: "test2", Amount: "2000000000000000000000", Duration: 0, AutoStake: false},
+		{OwnerAddress: identityset.Address(21).String(), CandidateName: "test1", Amount: "3000000000000000000000", Duration: 1050, AutoStake: true},
+	}
+	p, err := NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+	genesisTime := time.Unix(genesis.Default.Timestamp, 0)
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    0,
+		BlockTimeStamp: genesisTime,
+	})
+	r.NoError(p.CreateGenesisStates(ctx, sm))
+
+	// the genesis buckets are indexed after the self-staking buckets
+	buckets, err := getAllBuckets(sm)
+	r.NoError(err)
+	r.Len(buckets, 5)
+	votes := map[string]*big.Int{}
+	for i, bucket := range buckets {
+		r.Equal(uint64(i), bucket.Index)
+		name := p.inMemCandidates.GetByOwner(bucket.Candidate).Name
+		if votes[name] == nil {
+			votes[name] = big.NewInt(0)
+		}
+		votes[name].Add(votes[name], p.calculateVoteWeight(ctx, bucket, i < 2))
+	}
+	r.Equal(foundation, buckets[2].Owner)
+	for _, bucket := range buckets[2:] {
+		r.True(genesisTime.Equal(bucket.CreateTime))
+	}
+	r.Equal(identityset.Address(2), buckets[3].Candidate)
+	r.False(buckets[3].AutoStake)
+	for name, expected := range votes {
+		c := p.inMemCandidates.GetByName(name)
+		r.Equal(expected, c.Votes)
+		stored, err := getCandidate(sm, c.Owner)
+		r.NoError(err)
+		r.Equal(expected, stored.Votes)
+	}
+	indices, err := getVoterBucketIndices(sm, foundation)
+	r.NoError(err)
+	r.Equal(BucketIndices{2, 3}, *indices)
+
+	// a genesis bucket must vote for a bootstrap candidate
+	cfg.GenesisBuckets = []genesis.GenesisBucket{{OwnerAddress: foundation.String(), CandidateName: "notexist", Amount: "1", Duration: 7, AutoStake: true}}
+	sm = newMockStateManager(ctrl)
+	p, err = NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+	r.Equal(ErrInvalidCanName, errors.Cause(p.CreateGenesisStates(ctx, sm)))
+}
+
+func TestProtocol_GreenlandVoteWeight(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+
+	cfg := genesis.Default.Staking
+	owner := identityset.Address(1)
+	cfg.BootstrapCandidates = []genesis.BootstrapCandidate{{
+		OwnerAddress:      owner.String(),
+		OperatorAddress:   identityset.Address(11).String(),
+		RewardAddress:     owner.String(),
+		Name:              "test1",
+		SelfStakingTokens: "1200000000000000000000000",
+	}}
+	cfg.GenesisBuckets = []genesis.GenesisBucket{
+		{OwnerAddress: identityset.Address(20).String(), CandidateName: "test1", Amount: "1000000000000000000007", Duration: 91, AutoStake: true},
+		{OwnerAddress: identityset.Address(21).String(), CandidateName: "test1", Amount: "3000000000000000000003", Duration: 1050, AutoStake: true},
+	}
+	p, err := NewProtocol(depositGas, sm, cfg, GreenlandHeightOption(5))
+	r.NoError(err)
+	blkCtx := func(height uint64) context.Context {
+		return protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+		})
+	}
+	r.NoError(p.CreateGenesisStates(blkCtx(0), sm))
+
+	// the weight is calculated in float64 before Greenland, and with big.Float since
+	buckets, err := getAllBuckets(sm)
+	r.NoError(err)
+	r.Len(buckets, 3)
+	legacy, votes := big.NewInt(0), big.NewInt(0)
+	for i, bucket := range buckets {
+		l := calculateLegacyVoteWeight(cfg.VoteWeightCalConsts, bucket, i == 0, p.voteWeightUnit)
+		v := calculateVoteWeight(cfg.VoteWeightCalConsts, bucket, i == 0, p.voteWeightUnit, RoundFloor)
+		r.Equal(l, p.calculateVoteWeight(blkCtx(4), bucket, i == 0))
+		r.Equal(v, p.calculateVoteWeight(blkCtx(5), bucket, i == 0))
+		legacy.Add(legacy, l)
+		votes.Add(votes, v)
+	}
+	r.Equal(legacy, p.inMemCandidates.GetByOwner(owner).Votes)
+
+	// the votes are recalculated at Greenland
+	r.NoError(p.CreatePreStates(blkCtx(4), sm))
+	r.Equal(legacy, p.inMemCandidates.GetByOwner(owner).Votes)
+	r.NoError(p.CreatePreStates(blkCtx(5), sm))
+	r.Equal(votes, p.inMemCandidates.GetByOwner(owner).Votes)
+	c, err := getCandidate(sm, owner)
+	r.NoError(err)
+	r.Equal(votes, c.Votes)
+}
+
+func TestProtocol_NetworkStats(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	g := genesis.Default
+	p, err := NewProtocol(depositGas, sm, g.Staking, WeightSnapshotOption())
+	r.NoError(err)
+
+	// an epoch lasts 10 blocks, epoch 2 starts at height 11
+	registry := protocol.NewRegistry()
+	rp := rolldpos.NewProtocol(36, 10, 1)
+	r.NoError(rp.Register(registry))
+	ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+		Genesis:  g,
+		Registry: registry,
+	})
+	ctx = protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    11,
+		BlockTimeStamp: time.Now(),
+	})
+
+	// the first candidate meets the self-stake requirement, the second does not
+	selfStakes := []*big.Int{p.config.RegistrationConsts.MinSelfStake, big.NewInt(1)}
+	for i, selfStake := range selfStakes {
+		owner := identityset.Address(i + 1)
+		r.NoError(setupCandidate(p, sm, &Candidate{
+			Owner:              owner,
+			Operator:           identityset.Address(i + 11),
+			Reward:             owner,
+			Name:               fmt.Sprintf("test%d", i+1),
+			Votes:              big.NewInt(int64(100 * (i + 1))),
+			SelfStakeBucketIdx: uint64(i),
+			SelfStake:          selfStake,
+		}))
+	}
+	amounts := []int64{100, 200, 300}
+	for i, amount := range amounts {
+		bucket := NewVoteBucket(identityset.Address(1), identityset.Address(i+1), big.NewInt(amount), 7, time.Now(), true)
+		if i == 2 {
+			// an unstaked bucket is counted in the buckets, but not in the stake
+			bucket.UnstakeStartTime = time.Now()
+		}
+		_, err = putBucket(sm, bucket)
+		r.NoError(err)
+	}
+
+	// no stats for an epoch without snapshot
+	_, err = p.NetworkStats(sm, 2)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+
+	r.NoError(p.CreatePreStates(ctx, sm))
+	stats, err := p.NetworkStats(sm, 2)
+	r.NoError(err)
+	r.Equal(&StakingStats{
+		TotalStake:         big.NewInt(300),
+		TotalBuckets:       3,
+		ActiveCandidates:   1,
+		TotalWeightedVotes: big.NewInt(300),
+	}, stats)
+	_, err = p.NetworkStats(sm, 3)
+	r.Equal(state.ErrStateNotExist, errors.Cause(err))
+}
+
+func TestProtocol_EmergencyPause(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	cfg := genesis.Default.Staking
+	cfg.EmergencyPauses = []genesis.EmergencyPause{{StartHeight: 10, EndHeight: 20}}
+	p, err := NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+
+	owner := identityset.Address(1)
+	staker := identityset.Address(2)
+	r.NoError(setupAccount(sm, staker, 1000))
+	r.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              owner,
+		Operator:           identityset.Address(11),
+		Reward:             owner,
+		Name:               "test1",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: 0,
+		SelfStake:          big.NewInt(0),
+	}))
+	actCtx := func(height, nonce uint64) context.Context {
+		ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+			BlockHeight:    height,
+			BlockTimeStamp: time.Now(),
+			GasLimit:       1000000,
+		})
+		return protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       staker,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+
+	gasPrice := big.NewInt(unit.Qev)
+	amount := "100000000000000000000"
+	update, err := action.NewCandidateUpdate(1, "test2", owner.String(), owner.String(), 10000, gasPrice)
+	r.NoError(err)
+	must := func(act action.Action, err error) action.Action {
+		r.NoError(err)
+		return act
+	}
+	acts := []action.Action{
+		must(action.NewCreateStake(1, "test1", amount, 7, true, nil, 10000, gasPrice)),
+		must(action.NewUnstake(1, 0, nil, 10000, gasPrice)),
+		must(action.NewWithdrawStake(1, 0, nil, 10000, gasPrice)),
+		must(action.NewChangeCandidate(1, "test1", 0, nil, 10000, gasPrice)),
+		must(action.NewTransferStake(1, owner.String(), 0, nil, 10000, gasPrice)),
+		must(action.NewDepositToStake(1, 0, amount, nil, 10000, gasPrice)),
+		must(action.NewRestake(1, 0, 7, true, nil, 10000, gasPrice)),
+		must(action.NewCandidateRegister(1, "test2", staker.String(), staker.String(), staker.String(), amount, 7, true, nil, 10000, gasPrice)),
+		update,
+	}
+	r.Equal(len(_stakingMethods), len(acts))
+
+	// every staking action is rejected during the pause, without touching the staking states
+	for _, height := range []uint64{10, 19} {
+		for i, act := range acts {
+			receipt, err := p.Handle(actCtx(height, uint64(i+1)), act, sm)
+			r.NoError(err)
+			r.Equal(uint64(ReceiptStatusErrPaused), receipt.Status, stakingMethod(act))
+			r.Empty(receipt.Logs)
+		}
+	}
+	count, err := getTotalBucketCount(sm)
+	r.NoError(err)
+	r.Zero(count)
+	r.Equal(1, p.inMemCandidates.Size())
+	r.Zero(p.inMemCandidates.GetByName("test1").Votes.Sign())
+
+	// the staking actions are handled again once the pause ends
+	for _, height := range []uint64{9, 20} {
+		receipt, err := p.Handle(actCtx(height, 1), acts[0], sm)
+		r.NoError(err)
+		r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	}
+	count, err = getTotalBucketCount(sm)
+	r.NoError(err)
+	r.Equal(uint64(2), count)
+	r.Equal(1, p.inMemCandidates.GetByName("test1").Votes.Sign())
+}
+
+func TestProtocol_SelfStakeBucket(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+	p, err := NewProtocol(depositGas, sm, genesis.Default.Staking)
+	r.NoError(err)
+
+	owner := identityset.Address(1)
+	staker := identityset.Address(2)
+	r.NoError(setupAccount(sm, owner, 1300000))
+	r.NoError(setupAccount(sm, staker, 1000))
+	blkCtx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	actCtx := func(caller address.Address, nonce uint64) context.Context {
+		return protocol.WithActionCtx(blkCtx, protocol.ActionCtx{
+			Caller:       caller,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		})
+	}
+
+	// a bucket created before the registration takes the first index
+	r.NoError(setupCandidate(p, sm, &Candidate{
+		Owner:              staker,
+		Operator:           identityset.Address(12),
+		Reward:             staker,
+		Name:               "test2",
+		Votes:              big.NewInt(0),
+		SelfStakeBucketIdx: 0,
+		SelfStake:          big.NewInt(0),
+	}))
+	create, err := action.NewCreateStake(1, "test2", "100000000000000000000", 1, true, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	receipt, err := p.Handle(actCtx(staker, 1), create, sm)
+	r.NoError(err)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	register, err := action.NewCandidateRegister(1, "test1", owner.String(), owner.String(), owner.String(),
+		genesis.Default.Staking.RegistrationConsts.MinSelfStake, 7, true, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	receipt, err = p.Handle(actCtx(owner, 1), register, sm)
+	r.NoError(err)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+
+	index, bucket, err := p.SelfStakeBucket(sm, owner)
+	r.NoError(err)
+	r.Equal(uint64(1), index)
+	r.Equal(index, bucket.Index)
+	r.Equal(genesis.Default.Staking.RegistrationConsts.MinSelfStake, bucket.StakedAmount.String())
+	r.Equal(owner, bucket.Candidate)
+
+	// the candidate without self-stake
+	_, _, err = p.SelfStakeBucket(sm, staker)
+	r.Equal(ErrNoSelfStakeBucket, errors.Cause(err))
+	// no such candidate
+	_, _, err = p.SelfStakeBucket(sm, identityset.Address(3))
+	r.Equal(ErrCandidateNotExist, errors.Cause(err))
+	// the self-staking bucket is unstaked
+	unstake, err := action.NewUnstake(2, index, nil, 10000, big.NewInt(unit.Qev))
+	r.NoError(err)
+	receipt, err = p.Handle(actCtx(owner, 2), unstake, sm)
+	r.NoError(err)
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	_, _, err = p.SelfStakeBucket(sm, owner)
+	r.Equal(ErrNoSelfStakeBucket, errors.Cause(err))
+}
+
+func TestProtocol_ProtocolAddress(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	r.NoError(err)
+
+	cfg := genesis.Default.Staking
+	_, err = NewProtocol(depositGas, sm, cfg, ProtocolAddressOption(nil))
+	r.Error(err)
+	rewardingHash := hash.Hash160b([]byte(rewardingProtocolID))
+	rewardingAddr, err := address.FromBytes(rewardingHash[:])
+	r.NoError(err)
+	_, err = NewProtocol(depositGas, sm, cfg, ProtocolAddressOption(rewardingAddr))
+	r.Error(err)
+
+	// the default address is derived from the protocol ID
+	p, err := NewProtocol(depositGas, sm, cfg)
+	r.NoError(err)
+	defaultHash := hash.Hash160b([]byte(protocolID))
+	r.Equal(defaultHash[:], p.addr.Bytes())
+
+	custom := identityset.Address(30)
+	p, err = NewProtocol(depositGas, sm, cfg, ProtocolAddressOption(custom))
+	r.NoError(err)
+	owner := identityset.Address(1)
+	r.NoError(setupAccount(sm, owner, 1300000))
+	ctx := protocol.WithBlockCtx(context.Background(), protocol.BlockCtx{
+		BlockHeight:    1,
+		BlockTimeStamp: time.Now(),
+		GasLimit:       1000000,
+	})
+	register := func(nonce uint64, name string) *action.Receipt {
+		act, err := action.NewCandidateRegister(nonce, name, owner.String(), owner.String(), owner.String(),
+			cfg.RegistrationConsts.MinSelfStake, 1, false, nil, 10000, big.NewInt(unit.Qev))
+		r.NoError(err)
+		receipt, err := p.handleCandidateRegister(protocol.WithActionCtx(ctx, protocol.ActionCtx{
+			Caller:       owner,
+			GasPrice:     big.NewInt(unit.Qev),
+			IntrinsicGas: 10000,
+			Nonce:        nonce,
+		}), act, sm)
+		r.NoError(err)
+		return receipt
+	}
+
+	// the receipt and the logs of a successful action carry the custom address
+	receipt := register(1, "test1")
+	r.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+	r.Equal(custom.String(), receipt.ContractAddress)
+	r.Equal(2, len(receipt.Logs))
+	for _, l := range receipt.Logs {
+		r.Equal(custom.String(), l.Address)
+	}
+
+	// so does the receipt of a failed action
+	receipt = register(2, "invalid name")
+	r.Equal(uint64(ReceiptStatusErrInvalidCanName), receipt.Status)
+	r.Equal(custom.String(), receipt.ContractAddress)
+	r.Empty(receipt.Logs)
+}
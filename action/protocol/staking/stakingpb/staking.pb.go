@@ -171,6 +171,8 @@ type Candidate struct {
 	Votes                string   `protobuf:"bytes,5,opt,name=votes,proto3" json:"votes,omitempty"`
 	SelfStakeBucketIdx   uint64   `protobuf:"varint,6,opt,name=selfStakeBucketIdx,proto3" json:"selfStakeBucketIdx,omitempty"`
 	SelfStake            string   `protobuf:"bytes,7,opt,name=selfStake,proto3" json:"selfStake,omitempty"`
+	LastUpdateHeight     uint64   `protobuf:"varint,8,opt,name=lastUpdateHeight,proto3" json:"lastUpdateHeight,omitempty"`
+	OperatorUpdateHeight uint64   `protobuf:"varint,12,opt,name=operatorUpdateHeight,proto3" json:"operatorUpdateHeight,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -250,6 +252,20 @@ func (m *Candidate) GetSelfStake() string {
 	return ""
 }
 
+func (m *Candidate) GetLastUpdateHeight() uint64 {
+	if m != nil {
+		return m.LastUpdateHeight
+	}
+	return 0
+}
+
+func (m *Candidate) GetOperatorUpdateHeight() uint64 {
+	if m != nil {
+		return m.OperatorUpdateHeight
+	}
+	return 0
+}
+
 type Candidates struct {
 	Candidates           []*Candidate `protobuf:"bytes,1,rep,name=candidates,proto3" json:"candidates,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
@@ -289,41 +305,111 @@ func (m *Candidates) GetCandidates() []*Candidate {
 	return nil
 }
 
+type Stats struct {
+	TotalStake           string   `protobuf:"bytes,1,opt,name=totalStake,proto3" json:"totalStake,omitempty"`
+	TotalBuckets         uint64   `protobuf:"varint,2,opt,name=totalBuckets,proto3" json:"totalBuckets,omitempty"`
+	ActiveCandidates     uint64   `protobuf:"varint,3,opt,name=activeCandidates,proto3" json:"activeCandidates,omitempty"`
+	TotalWeightedVotes   string   `protobuf:"bytes,4,opt,name=totalWeightedVotes,proto3" json:"totalWeightedVotes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Stats) Reset()         { *m = Stats{} }
+func (m *Stats) String() string { return proto.CompactTextString(m) }
+func (*Stats) ProtoMessage()    {}
+func (*Stats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_289e7c8aea278311, []int{4}
+}
+
+func (m *Stats) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Stats.Unmarshal(m, b)
+}
+func (m *Stats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Stats.Marshal(b, m, deterministic)
+}
+func (m *Stats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Stats.Merge(m, src)
+}
+func (m *Stats) XXX_Size() int {
+	return xxx_messageInfo_Stats.Size(m)
+}
+func (m *Stats) XXX_DiscardUnknown() {
+	xxx_messageInfo_Stats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Stats proto.InternalMessageInfo
+
+func (m *Stats) GetTotalStake() string {
+	if m != nil {
+		return m.TotalStake
+	}
+	return ""
+}
+
+func (m *Stats) GetTotalBuckets() uint64 {
+	if m != nil {
+		return m.TotalBuckets
+	}
+	return 0
+}
+
+func (m *Stats) GetActiveCandidates() uint64 {
+	if m != nil {
+		return m.ActiveCandidates
+	}
+	return 0
+}
+
+func (m *Stats) GetTotalWeightedVotes() string {
+	if m != nil {
+		return m.TotalWeightedVotes
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Bucket)(nil), "stakingpb.Bucket")
 	proto.RegisterType((*BucketIndices)(nil), "stakingpb.BucketIndices")
 	proto.RegisterType((*Candidate)(nil), "stakingpb.Candidate")
 	proto.RegisterType((*Candidates)(nil), "stakingpb.Candidates")
+	proto.RegisterType((*Stats)(nil), "stakingpb.Stats")
 }
 
 func init() { proto.RegisterFile("staking.proto", fileDescriptor_289e7c8aea278311) }
 
 var fileDescriptor_289e7c8aea278311 = []byte{
-	// 408 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x93, 0xc1, 0x6a, 0x1b, 0x31,
-	0x10, 0x86, 0x51, 0xbd, 0x71, 0xa2, 0x49, 0xdd, 0x86, 0x21, 0x07, 0x11, 0x0a, 0x5d, 0x96, 0x52,
-	0xb6, 0x3d, 0x6c, 0x20, 0xed, 0xa9, 0xb7, 0xb8, 0xa5, 0xd0, 0xab, 0x92, 0x17, 0x90, 0xad, 0x89,
-	0x11, 0xc9, 0x4a, 0x46, 0xd2, 0x36, 0x79, 0x8e, 0xbe, 0x6c, 0xaf, 0x65, 0x25, 0xef, 0xc6, 0x76,
-	0x0a, 0xbe, 0x69, 0x7e, 0xfd, 0x33, 0xe2, 0x9f, 0x6f, 0x17, 0x66, 0x21, 0xaa, 0x7b, 0x63, 0x57,
-	0xcd, 0xda, 0xbb, 0xe8, 0x90, 0x6f, 0xca, 0xf5, 0xe2, 0xe2, 0xfd, 0xca, 0xb9, 0xd5, 0x03, 0x5d,
-	0xa6, 0x8b, 0x45, 0x77, 0x77, 0x19, 0x4d, 0x4b, 0x21, 0xaa, 0x76, 0x9d, 0xbd, 0xd5, 0x9f, 0x09,
-	0x4c, 0xe7, 0xdd, 0xf2, 0x9e, 0x22, 0x9e, 0xc3, 0x91, 0xb1, 0x9a, 0x9e, 0x04, 0x2b, 0x59, 0x5d,
-	0xc8, 0x5c, 0xe0, 0x67, 0x38, 0x5b, 0x2a, 0xab, 0x8d, 0x56, 0x91, 0xae, 0xb5, 0xf6, 0x14, 0x82,
-	0x78, 0x55, 0xb2, 0x9a, 0xcb, 0x17, 0x3a, 0x56, 0xf0, 0xba, 0x7f, 0x9a, 0xf4, 0x75, 0xeb, 0x3a,
-	0x1b, 0xc5, 0x24, 0xf9, 0x76, 0x34, 0xfc, 0x08, 0x6f, 0x72, 0xfd, 0xa3, 0xf3, 0x2a, 0x1a, 0x67,
-	0x45, 0x51, 0xb2, 0x7a, 0x26, 0xf7, 0x54, 0xfc, 0x06, 0xb0, 0xf4, 0xa4, 0x22, 0xdd, 0x9a, 0x96,
-	0xc4, 0x51, 0xc9, 0xea, 0xd3, 0xab, 0x8b, 0x26, 0xc7, 0x69, 0x86, 0x38, 0xcd, 0xed, 0x10, 0x47,
-	0x6e, 0xb9, 0x71, 0xbe, 0x79, 0xe3, 0x26, 0x2a, 0x1f, 0x53, 0xff, 0xf4, 0x60, 0xff, 0x5e, 0x07,
-	0xfe, 0x84, 0xb3, 0xce, 0xee, 0x4d, 0x39, 0x3e, 0x38, 0xe5, 0x45, 0x0f, 0xbe, 0x03, 0xae, 0xba,
-	0xe8, 0x6e, 0x7a, 0x55, 0x9c, 0x94, 0xac, 0x3e, 0x91, 0xcf, 0x42, 0xbf, 0x73, 0xf7, 0x68, 0xc9,
-	0x0b, 0x9e, 0x56, 0x95, 0x8b, 0xea, 0x13, 0xcc, 0x32, 0x93, 0x5f, 0x56, 0x9b, 0x25, 0x05, 0x14,
-	0x70, 0x6c, 0xf2, 0x51, 0xb0, 0x72, 0x52, 0x17, 0x72, 0x28, 0xab, 0xbf, 0x0c, 0xf8, 0xf7, 0x81,
-	0x43, 0x0f, 0x20, 0x4d, 0x18, 0x40, 0xb1, 0x0c, 0x60, 0x5b, 0xc3, 0x1a, 0xde, 0xba, 0x35, 0x79,
-	0x15, 0x9d, 0xdf, 0xe5, 0xb9, 0x2f, 0xe3, 0x07, 0x98, 0x79, 0x7a, 0x54, 0x5e, 0x0f, 0xbe, 0xcc,
-	0x73, 0x57, 0x44, 0x84, 0xc2, 0xaa, 0x96, 0x12, 0x46, 0x2e, 0xd3, 0xb9, 0x8f, 0xf5, 0xdb, 0x45,
-	0x0a, 0x89, 0x1b, 0x97, 0xb9, 0xc0, 0x06, 0x30, 0xd0, 0xc3, 0x5d, 0x4a, 0xbe, 0xc9, 0xa7, 0x9f,
-	0x12, 0x9a, 0x42, 0xfe, 0xe7, 0xa6, 0x5f, 0xdd, 0xa8, 0xa6, 0xdd, 0x73, 0xf9, 0x2c, 0x54, 0x73,
-	0x80, 0x31, 0x78, 0xc0, 0xaf, 0x00, 0xe3, 0xe7, 0x98, 0x97, 0x74, 0x7a, 0x75, 0xde, 0x8c, 0x3f,
-	0x42, 0x33, 0x5a, 0xe5, 0x96, 0x6f, 0x31, 0x4d, 0x08, 0xbf, 0xfc, 0x0b, 0x00, 0x00, 0xff, 0xff,
-	0xc4, 0x39, 0x2e, 0xe7, 0x41, 0x03, 0x00, 0x00,
+	// 506 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x85, 0x53, 0xc1, 0x6e, 0xd3, 0x40,
+	0x10, 0x55, 0x1a, 0x27, 0x8d, 0x27, 0x09, 0x58, 0xa3, 0x1c, 0xac, 0x0a, 0x01, 0xb2, 0x10, 0x2a,
+	0x1c, 0x5c, 0x29, 0xe5, 0xc4, 0xad, 0x01, 0x21, 0xe0, 0xb8, 0x2d, 0x70, 0xde, 0xd8, 0xdb, 0x60,
+	0x35, 0xf1, 0x5a, 0xeb, 0x75, 0xdb, 0xef, 0xe0, 0x27, 0xf8, 0x36, 0xfe, 0x82, 0xdd, 0xd9, 0xd8,
+	0x89, 0x9d, 0x48, 0xbd, 0x58, 0x9e, 0x37, 0x6f, 0xc6, 0xfb, 0xde, 0x5b, 0xc3, 0xb4, 0xd4, 0xfc,
+	0x2e, 0xcb, 0x57, 0x71, 0xa1, 0xa4, 0x96, 0xe8, 0x6f, 0xcb, 0x62, 0x79, 0xf6, 0x6a, 0x25, 0xe5,
+	0x6a, 0x2d, 0x2e, 0xa8, 0xb1, 0xac, 0x6e, 0x2f, 0x74, 0xb6, 0x11, 0xa6, 0xbd, 0x29, 0x1c, 0x37,
+	0xfa, 0xd3, 0x87, 0xe1, 0xa2, 0x4a, 0xee, 0x84, 0xc6, 0x19, 0x0c, 0xb2, 0x3c, 0x15, 0x8f, 0x61,
+	0xef, 0x75, 0xef, 0xdc, 0x63, 0xae, 0xc0, 0xf7, 0x10, 0x24, 0x3c, 0x4f, 0xb3, 0x94, 0x6b, 0x71,
+	0x95, 0xa6, 0x4a, 0x94, 0x65, 0x78, 0x62, 0x08, 0x3e, 0x3b, 0xc0, 0x31, 0x82, 0x89, 0xfd, 0xb4,
+	0x48, 0xaf, 0x36, 0xb2, 0xca, 0x75, 0xd8, 0x27, 0x5e, 0x0b, 0xc3, 0xb7, 0xf0, 0xcc, 0xd5, 0x9f,
+	0x2b, 0xc5, 0x75, 0x26, 0xf3, 0xd0, 0x33, 0xac, 0x29, 0xeb, 0xa0, 0xf8, 0x11, 0x20, 0x51, 0xc2,
+	0x2c, 0xbf, 0x31, 0x27, 0x0e, 0x07, 0x86, 0x33, 0x9e, 0x9f, 0xc5, 0x4e, 0x4e, 0x5c, 0xcb, 0x89,
+	0x6f, 0x6a, 0x39, 0x6c, 0x8f, 0x8d, 0x8b, 0xed, 0x37, 0xae, 0x35, 0x57, 0x9a, 0xe6, 0x87, 0x4f,
+	0xce, 0x77, 0x26, 0xf0, 0x0b, 0x04, 0x55, 0xde, 0xd9, 0x72, 0xfa, 0xe4, 0x96, 0x83, 0x19, 0x7c,
+	0x01, 0x3e, 0xaf, 0xb4, 0xbc, 0xb6, 0x68, 0x38, 0x32, 0x0b, 0x46, 0x6c, 0x07, 0x58, 0xcf, 0xe5,
+	0x43, 0x2e, 0x54, 0xe8, 0x93, 0x55, 0xae, 0x88, 0xde, 0xc1, 0xd4, 0x65, 0xf2, 0xcd, 0x18, 0x9c,
+	0x88, 0x12, 0x43, 0x38, 0xcd, 0xdc, 0xab, 0x09, 0xa7, 0x6f, 0xc2, 0xa9, 0xcb, 0xe8, 0xdf, 0x09,
+	0xf8, 0x9f, 0xea, 0x1c, 0x6c, 0x00, 0xb4, 0xa1, 0x0e, 0xaa, 0xe7, 0x02, 0xd8, 0xc7, 0xf0, 0x1c,
+	0x9e, 0xcb, 0x42, 0x18, 0x97, 0xa5, 0x6a, 0xe7, 0xd9, 0x85, 0xf1, 0x0d, 0x4c, 0x95, 0x78, 0xe0,
+	0x2a, 0xad, 0x79, 0x2e, 0xcf, 0x36, 0x88, 0x08, 0x5e, 0xce, 0x8d, 0x39, 0x1e, 0x35, 0xe9, 0xdd,
+	0xca, 0xba, 0x97, 0xda, 0x9c, 0x76, 0xe0, 0x64, 0x51, 0x81, 0x31, 0x60, 0x29, 0xd6, 0xb7, 0xa4,
+	0x7c, 0xab, 0x2f, 0x7d, 0xa4, 0x68, 0x3c, 0x76, 0xa4, 0x63, 0xad, 0x6b, 0x50, 0xf2, 0xde, 0x67,
+	0x3b, 0xc0, 0x5e, 0xcc, 0x35, 0x2f, 0xf5, 0x8f, 0xc2, 0x2a, 0xff, 0x2a, 0xb2, 0xd5, 0x6f, 0x4d,
+	0xfe, 0x7a, 0xec, 0x00, 0xc7, 0x39, 0xcc, 0x6a, 0x71, 0x2d, 0xfe, 0x84, 0xf8, 0x47, 0x7b, 0xdf,
+	0xbd, 0x91, 0x1f, 0x80, 0x79, 0x42, 0x30, 0x36, 0xcf, 0x71, 0x30, 0x89, 0x16, 0x00, 0x8d, 0xd5,
+	0x25, 0x7e, 0x30, 0x17, 0xb4, 0xa9, 0x28, 0x96, 0xf1, 0x7c, 0x16, 0x37, 0xbf, 0x5e, 0xdc, 0x50,
+	0xd9, 0x1e, 0x2f, 0xfa, 0xdb, 0x83, 0x81, 0x39, 0xbf, 0x2e, 0xf1, 0x25, 0x80, 0x96, 0x9a, 0xaf,
+	0x9d, 0x3c, 0x97, 0xd4, 0x1e, 0x62, 0xb3, 0xa4, 0xca, 0xf9, 0xe1, 0x42, 0xf2, 0x58, 0x0b, 0xb3,
+	0x1e, 0xf0, 0x44, 0x67, 0xf7, 0x62, 0x77, 0x2e, 0x0a, 0xc9, 0x78, 0xd0, 0xc5, 0xad, 0xfb, 0x34,
+	0xfb, 0x8b, 0xe4, 0x89, 0xf4, 0x27, 0x05, 0xe4, 0x52, 0x3b, 0xd2, 0x59, 0x0e, 0xe9, 0x7a, 0x5f,
+	0xfe, 0x07, 0xfc, 0xc5, 0x0c, 0x95, 0x5d, 0x04, 0x00, 0x00,
 }
@@ -9,6 +9,8 @@ package staking
 import (
 	"context"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -27,8 +29,13 @@ var (
 	ErrInvalidOperator     = errors.New("invalid operator address")
 	ErrInvalidSelfStkIndex = errors.New("invalid self-staking bucket index")
 	ErrMissingField        = errors.New("missing data field")
+	ErrInvalidDuration     = errors.New("invalid staking duration")
+	ErrInvalidBlockTime    = errors.New("invalid block timestamp")
 )
 
+// MaxStakeDuration is the maximum staked duration of a vote bucket in days, self-staking buckets are not limited
+const MaxStakeDuration = 1050
+
 func (p *Protocol) validateCreateStake(ctx context.Context, act *action.CreateStake) error {
 	if act == nil {
 		return ErrNilAction
@@ -39,6 +46,9 @@ func (p *Protocol) validateCreateStake(ctx context.Context, act *action.CreateSt
 	if act.Amount().Cmp(p.config.MinStakeAmount) == -1 {
 		return errors.Wrap(ErrInvalidAmount, "stake amount is less than the minimum requirement")
 	}
+	if err := validateDuration(act.Duration()); err != nil {
+		return err
+	}
 	if act.GasPrice().Sign() < 0 {
 		return errors.Wrap(action.ErrGasPrice, "negative value")
 	}
@@ -98,6 +108,9 @@ func (p *Protocol) validateDepositToStake(ctx context.Context, act *action.Depos
 	if act == nil {
 		return ErrNilAction
 	}
+	if act.Amount().Sign() <= 0 {
+		return errors.Wrap(ErrInvalidAmount, "deposit amount must be positive")
+	}
 	if act.GasPrice().Sign() < 0 {
 		return errors.Wrap(action.ErrGasPrice, "negative value")
 	}
@@ -108,6 +121,9 @@ func (p *Protocol) validateRestake(ctx context.Context, act *action.Restake) err
 	if act == nil {
 		return ErrNilAction
 	}
+	if err := validateDuration(act.Duration()); err != nil {
+		return err
+	}
 	if act.GasPrice().Sign() < 0 {
 		return errors.Wrap(action.ErrGasPrice, "negative value")
 	}
@@ -123,8 +139,11 @@ func (p *Protocol) validateCandidateRegister(ctx context.Context, act *action.Ca
 	if act.GasPrice().Sign() < 0 {
 		return errors.Wrap(action.ErrGasPrice, "negative value")
 	}
+	if err := validateGasLimit(act); err != nil {
+		return err
+	}
 
-	if !IsValidCandidateName(act.Name()) {
+	if !p.isValidCandidateName(act.Name(), validationHeight(ctx)) {
 		return ErrInvalidCanName
 	}
 
@@ -132,6 +151,10 @@ func (p *Protocol) validateCandidateRegister(ctx context.Context, act *action.Ca
 		return errors.Wrap(ErrInvalidAmount, "self staking amount is not valid")
 	}
 
+	if !p.selfStakeLockedLongEnough(act.Duration(), act.AutoStake()) {
+		return errors.Wrapf(ErrInvalidDuration, "self-stake duration %d days is below the minimum %s", act.Duration(), p.config.RegistrationConsts.MinSelfStakeDuration)
+	}
+
 	owner := actCtx.Caller
 	if act.OwnerAddress() != nil {
 		owner = act.OwnerAddress()
@@ -174,7 +197,7 @@ func (p *Protocol) validateCandidateUpdate(ctx context.Context, act *action.Cand
 	}
 
 	if len(act.Name()) != 0 {
-		if !IsValidCandidateName(act.Name()) {
+		if !p.isValidCandidateName(act.Name(), validationHeight(ctx)) {
 			return ErrInvalidCanName
 		}
 	}
@@ -197,6 +220,99 @@ func (p *Protocol) validateCandidateUpdate(ctx context.Context, act *action.Cand
 	return nil
 }
 
+// validateDuration checks the staked duration of a vote bucket in days does not exceed MaxStakeDuration
+func validateDuration(duration uint32) error {
+	if duration > MaxStakeDuration {
+		return errors.Wrapf(ErrInvalidDuration, "duration %d exceeds the limit %d", duration, MaxStakeDuration)
+	}
+	return nil
+}
+
+// selfStakeLockedLongEnough checks a self-stake bucket of the duration in days is auto-staked or locked for at least
+// MinSelfStakeDuration
+func (p *Protocol) selfStakeLockedLongEnough(duration uint32, autoStake bool) bool {
+	if autoStake {
+		return true
+	}
+	return time.Duration(duration)*24*time.Hour >= p.config.RegistrationConsts.MinSelfStakeDuration
+}
+
+// validateGasLimit checks the gas limit of the action covers its intrinsic gas, which grows with the size of the
+// payload, so that large registrations cannot be sent cheaply
+func validateGasLimit(act interface {
+	GasLimit() uint64
+	IntrinsicGas() (uint64, error)
+}) error {
+	intrinsicGas, err := act.IntrinsicGas()
+	if err != nil {
+		return errors.Wrap(err, "failed to get intrinsic gas")
+	}
+	if act.GasLimit() < intrinsicGas {
+		return errors.Wrapf(action.ErrOutOfGas, "gas limit %d is lower than the intrinsic gas %d", act.GasLimit(), intrinsicGas)
+	}
+	return nil
+}
+
+// validateBlockTime checks that the time of the block is set, the zero Unix time is reserved to mark buckets not
+// unstaked
+func validateBlockTime(now time.Time) error {
+	if now.Unix() <= 0 {
+		return errors.Wrapf(ErrInvalidBlockTime, "block timestamp %s is not set", now)
+	}
+	return nil
+}
+
+// validateBucketTime checks that the time of the block does not precede the times the bucket is stamped with, so that
+// no negative duration is computed from them
+func validateBucketTime(now time.Time, bucket *VoteBucket) error {
+	if err := validateBlockTime(now); err != nil {
+		return err
+	}
+	if now.Before(bucket.CreateTime) {
+		return errors.Wrapf(ErrInvalidBlockTime, "block timestamp %s precedes the create time %s of the bucket",
+			now, bucket.CreateTime)
+	}
+	if now.Before(bucket.StakeStartTime) {
+		return errors.Wrapf(ErrInvalidBlockTime, "block timestamp %s precedes the stake start time %s of the bucket",
+			now, bucket.StakeStartTime)
+	}
+	if bucket.UnstakeStartTime.Unix() != 0 && now.Before(bucket.UnstakeStartTime) {
+		return errors.Wrapf(ErrInvalidBlockTime, "block timestamp %s precedes the unstake start time %s of the bucket",
+			now, bucket.UnstakeStartTime)
+	}
+	return nil
+}
+
+// isValidCandidateName checks the name of a registered or updated candidate against the name rules in effect at the
+// height, the rules of IsValidCandidateName apply before NameConsts.Height
+func (p *Protocol) isValidCandidateName(name string, height uint64) bool {
+	rules := p.config.NameConsts
+	if height < rules.Height {
+		return IsValidCandidateName(name)
+	}
+	if len(name) == 0 || len(name) > rules.MaxLength {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune(rules.Charset, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// validationHeight returns the height of the block the action is validated for, or the height next to the tip if the
+// action is validated outside of a block
+func validationHeight(ctx context.Context) uint64 {
+	if blkCtx, ok := protocol.GetBlockCtx(ctx); ok {
+		return blkCtx.BlockHeight
+	}
+	if bcCtx, ok := protocol.GetBlockchainCtx(ctx); ok {
+		return bcCtx.Tip.Height + 1
+	}
+	return 0
+}
+
 // IsValidCandidateName check if a candidate name string is valid.
 func IsValidCandidateName(s string) bool {
 	if len(s) == 0 || len(s) > 12 {
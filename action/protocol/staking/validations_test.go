@@ -7,6 +7,7 @@
 package staking
 
 import (
+	"bytes"
 	"context"
 	"math/big"
 	"testing"
@@ -15,6 +16,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+
 	"github.com/iotexproject/iotex-core/action"
 	"github.com/iotexproject/iotex-core/action/protocol"
 	"github.com/iotexproject/iotex-core/blockchain/genesis"
@@ -122,6 +125,16 @@ func TestProtocol_ValidateCreateStake(t *testing.T) {
 			1,
 			ErrInvalidAmount,
 		},
+		{
+			cands[0].Name,
+			"200000000000000000000",
+			MaxStakeDuration + 1,
+			false,
+			big.NewInt(unit.Qev),
+			10000,
+			1,
+			ErrInvalidDuration,
+		},
 		{
 			cands[0].Name,
 			"200000000000000000000",
@@ -393,6 +406,10 @@ func TestProtocol_ValidateDepositToStake(t *testing.T) {
 		require.NoError(err)
 		require.Equal(test.errorCause, errors.Cause(p.validateDepositToStake(context.Background(), act)))
 	}
+	// a deposit without amount
+	act := &action.DepositToStake{}
+	require.NoError(act.LoadProto(&iotextypes.StakeAddDeposit{BucketIndex: 1}))
+	require.Equal(ErrInvalidAmount, errors.Cause(p.validateDepositToStake(context.Background(), act)))
 	// test nil action
 	require.Equal(ErrNilAction, errors.Cause(p.validateDepositToStake(context.Background(), nil)))
 }
@@ -430,6 +447,15 @@ func TestProtocol_ValidateRestake(t *testing.T) {
 			1,
 			action.ErrGasPrice,
 		},
+		{1,
+			MaxStakeDuration + 1,
+			true,
+			[]byte("100000000000000000000"),
+			big.NewInt(unit.Qev),
+			10000,
+			1,
+			ErrInvalidDuration,
+		},
 	}
 
 	for _, test := range tests {
@@ -474,96 +500,96 @@ func TestProtocol_ValidateCandidateRegister(t *testing.T) {
 	}{
 		{
 			ctx, "test1", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			nil,
 		},
 		// Case I: ErrGasPrice
 		{ctx, "test1", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(-unit.Qev),
-			10000,
+			20000,
 			1,
 			action.ErrGasPrice,
 		},
 		// Case II: IsValidCandidateName special char
 		{ctx, "!te", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidCanName,
 		},
 		// Case III: amount<minSelfStake
 		{
 			ctx, "test1", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidAmount,
 		},
 		// Case IV: act.OwnerAddress() is not nil,existing owner, but selfstake is not 0
 		{
 			ctx, "test2", cans[1].Operator.String(), cans[1].Reward.String(), cans[1].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOwner,
 		},
 		// Case V: act.OwnerAddress() is not,existing candidate, collide with existing name
 		{
 			ctx, "test", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidCanName,
 		},
 		// Case VI: act.OwnerAddress() is not,existing candidate, collide with existing operator
 		{
 			ctx, "test1", cans[1].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(), "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOperator,
 		},
 		// Case VII: act.OwnerAddress() is not,new candidate, collide with existing name
 		{
 			ctx, "test1", cans[0].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidCanName,
 		},
 		// Case VIII: act.OwnerAddress() is not,new candidate, collide with existing operator
 		{
 			ctx, "2222", cans[0].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOperator,
 		},
 		// Case IX: act.OwnerAddress() is nil,existing owner, but selfstake is not 0
 		{
 			ctx2, "test2", cans[1].Operator.String(), cans[1].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOwner,
 		},
 		// Case X: act.OwnerAddress() is nil,existing candidate, collide with existing name
 		{
 			ctx3, "test", cans[0].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidCanName,
 		},
 		// Case XI: act.OwnerAddress() is nil,existing candidate, collide with existing operator
 		{
 			ctx3, "test1", cans[1].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOperator,
 		},
 		// Case XII: act.OwnerAddress() is nil,new candidate, collide with existing name
 		{
 			ctx, "test1", cans[0].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidCanName,
 		},
 		// Case XIII: act.OwnerAddress() is nil,new candidate, collide with existing operator
 		{
 			ctx, "2222", cans[0].Operator.String(), cans[0].Reward.String(), "", "1200000000000000000000000", uint32(10000), false, []byte("payload"), big.NewInt(unit.Qev),
-			10000,
+			20000,
 			1,
 			ErrInvalidOperator,
 		},
@@ -574,6 +600,15 @@ func TestProtocol_ValidateCandidateRegister(t *testing.T) {
 		require.NoError(err)
 		require.Equal(test.errorCause, errors.Cause(p.validateCandidateRegister(test.ctx, act)), i)
 	}
+	// the gas limit must cover the size of the payload
+	act, err := action.NewCandidateRegister(1, "test1", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(),
+		"1200000000000000000000000", uint32(10000), false, bytes.Repeat([]byte("d"), 101), 20000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	require.Equal(action.ErrOutOfGas, errors.Cause(p.validateCandidateRegister(ctx, act)))
+	act, err = action.NewCandidateRegister(1, "test1", cans[0].Operator.String(), cans[0].Reward.String(), cans[0].Owner.String(),
+		"1200000000000000000000000", uint32(10000), false, bytes.Repeat([]byte("d"), 100), 20000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	require.NoError(p.validateCandidateRegister(ctx, act))
 	// test nil action
 	require.Equal(ErrNilAction, errors.Cause(p.validateCandidateRegister(ctx, nil)))
 }
@@ -648,6 +683,30 @@ func TestProtocol_ValidateCandidateUpdate(t *testing.T) {
 	require.Equal(ErrNilAction, errors.Cause(p.validateCandidateUpdate(ctx, nil)))
 }
 
+func TestProtocol_Validate(t *testing.T) {
+	require := require.New(t)
+	p, cans := initTestProtocol(t)
+	ctx := protocol.WithActionCtx(context.Background(), protocol.ActionCtx{Caller: identityset.Address(2)})
+
+	create, err := action.NewCreateStake(1, cans[0].Name, "100000000000000000000", MaxStakeDuration+1, true, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	restake, err := action.NewRestake(1, 1, MaxStakeDuration+1, true, nil, 10000, big.NewInt(unit.Qev))
+	require.NoError(err)
+	deposit := &action.DepositToStake{}
+	require.NoError(deposit.LoadProto(&iotextypes.StakeAddDeposit{BucketIndex: 1}))
+
+	for _, v := range []struct {
+		act        action.Action
+		errorCause error
+	}{
+		{create, ErrInvalidDuration},
+		{restake, ErrInvalidDuration},
+		{deposit, ErrInvalidAmount},
+	} {
+		require.Equal(v.errorCause, errors.Cause(p.Validate(ctx, v.act)))
+	}
+}
+
 func initTestProtocol(t *testing.T) (*Protocol, []*Candidate) {
 	require := require.New(t)
 	p, err := NewProtocol(nil, nil, genesis.Default.Staking)
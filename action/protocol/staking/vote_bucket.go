@@ -8,8 +8,12 @@ package staking
 
 import (
 	"bytes"
+	"fmt"
 	"math"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -43,9 +47,40 @@ type (
 	totalBucketCount struct {
 		count uint64
 	}
+
+	// VoteWeightRounding is the rounding mode applied to the weighted vote amount
+	VoteWeightRounding int
+
+	// BucketStatus is the lifecycle state of a bucket
+	BucketStatus int
+)
+
+const (
+	// BucketLocked is a bucket within its stake duration, or an auto-stake bucket
+	BucketLocked BucketStatus = iota
+	// BucketUnlocked is a bucket past its stake duration which has not been unstaked yet
+	BucketUnlocked
+	// BucketUnstaking is an unstaked bucket in the withdraw waiting period
+	BucketUnstaking
+	// BucketWithdrawable is an unstaked bucket which can be withdrawn
+	BucketWithdrawable
 )
 
-// NewVoteBucket creates a new vote bucket
+const (
+	// RoundFloor rounds the weighted vote amount down
+	RoundFloor VoteWeightRounding = iota
+	// RoundHalfUp rounds the weighted vote amount to the nearest integer, and halves up
+	RoundHalfUp
+	// RoundCeil rounds the weighted vote amount up
+	RoundCeil
+)
+
+// _voteWeightPrec is the mantissa precision in bits used to calculate the vote weight
+const _voteWeightPrec uint = 256
+
+// NewVoteBucket creates a new vote bucket staked from ctime on. The lock of a bucket without auto-stake counts down from
+// its stake start time, and the bucket is unlocked after the duration. The lock of an auto-stake bucket never counts
+// down, from Greenland on the duration is the period it stays locked for once auto-stake is turned off
 func NewVoteBucket(cand, owner address.Address, amount *big.Int, duration uint32, ctime time.Time, autoStake bool) *VoteBucket {
 	return &VoteBucket{
 		Candidate:        cand,
@@ -113,10 +148,40 @@ func (vb *VoteBucket) fromProto(pb *stakingpb.Bucket) error {
 	return nil
 }
 
+// status returns the state of the bucket at the given time
+func (vb *VoteBucket) status(now time.Time, withdrawWaitingPeriod time.Duration) BucketStatus {
+	if vb.UnstakeStartTime.Unix() != 0 {
+		if now.Before(vb.UnstakeStartTime.Add(withdrawWaitingPeriod)) {
+			return BucketUnstaking
+		}
+		return BucketWithdrawable
+	}
+	if vb.AutoStake || vb.remainingLock(now) > 0 {
+		return BucketLocked
+	}
+	return BucketUnlocked
+}
+
+// remainingLock returns the lock time left at the given time, which is always the staked duration for an auto-stake
+// bucket, and counts down to 0 from the stake start time otherwise
+func (vb *VoteBucket) remainingLock(now time.Time) time.Duration {
+	if vb.AutoStake {
+		return vb.StakedDuration
+	}
+	if remaining := vb.StakeStartTime.Add(vb.StakedDuration).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
 func (vb *VoteBucket) toProto() (*stakingpb.Bucket, error) {
 	if vb.Candidate == nil || vb.Owner == nil || vb.StakedAmount == nil {
 		return nil, ErrMissingField
 	}
+	// reject what Deserialize would reject, so that a stored bucket can always be loaded
+	if vb.StakedAmount.Sign() <= 0 {
+		return nil, ErrInvalidAmount
+	}
 	createTime, err := ptypes.TimestampProto(vb.CreateTime)
 	if err != nil {
 		return nil, err
@@ -216,11 +281,16 @@ func getBucket(sr protocol.StateReader, index uint64) (*VoteBucket, error) {
 }
 
 func updateBucket(sm protocol.StateManager, index uint64, bucket *VoteBucket) error {
-	if _, err := getBucket(sm, index); err != nil {
+	old, err := getBucket(sm, index)
+	if err != nil {
 		return err
 	}
+	// the create time is set once when the bucket is created
+	if !bucket.CreateTime.Equal(old.CreateTime) {
+		return errors.Wrapf(ErrBucketCreateTimeChanged, "bucket %d", index)
+	}
 
-	_, err := sm.PutState(
+	_, err = sm.PutState(
 		bucket,
 		protocol.NamespaceOption(StakingNameSpace),
 		protocol.KeyOption(bucketKey(index)))
@@ -237,6 +307,14 @@ func putBucket(sm protocol.StateManager, bucket *VoteBucket) (uint64, error) {
 	}
 
 	index := tc.Count()
+	// the index is expected to be free, never overwrite an existing bucket
+	switch _, err := getBucket(sm, index); errors.Cause(err) {
+	case nil:
+		return 0, errors.Wrapf(state.ErrStateAlreadyExists, "bucket %d", index)
+	case state.ErrStateNotExist:
+	default:
+		return 0, err
+	}
 	// Add index inside bucket
 	bucket.Index = index
 	if _, err := sm.PutState(
@@ -286,6 +364,106 @@ func getAllBuckets(sr protocol.StateReader) ([]*VoteBucket, error) {
 	return buckets, nil
 }
 
+// forEachBucket calls fn on all the buckets in the order of their indexes. The buckets are read one by one by key, so
+// that it also runs on the working set of a block, which does not support iterating a namespace
+func forEachBucket(sr protocol.StateReader, fn func(*VoteBucket) error) error {
+	total, err := getTotalBucketCount(sr)
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get total bucket count")
+	}
+	for i := uint64(0); i < total; i++ {
+		vb, err := getBucket(sr, i)
+		if errors.Cause(err) == state.ErrStateNotExist {
+			// the bucket is withdrawn
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get bucket %d", i)
+		}
+		if err := fn(vb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getBucketsAfterToken returns at most limit buckets following the token, and the token to resume from.
+// An empty token starts from the first bucket, a non-positive limit returns all remaining buckets
+func getBucketsAfterToken(sr protocol.StateReader, token []byte, limit int) ([]*VoteBucket, []byte, error) {
+	opts := []protocol.StateOption{
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.FilterOption(func(k, v []byte) bool {
+			return bytes.HasPrefix(k, []byte{_bucket})
+		}, bucketKey(0), []byte{_bucket + 1}),
+	}
+	if len(token) > 0 {
+		if _, err := bucketIndexFromKey(token); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid token")
+		}
+		opts = append(opts, protocol.LastKeyOption(token))
+	}
+	_, iter, err := sr.States(opts...)
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := iter.Size()
+	if limit > 0 && limit < size {
+		size = limit
+	}
+	buckets := make([]*VoteBucket, 0, size)
+	for i := 0; i < size; i++ {
+		vb := &VoteBucket{}
+		if err := iter.Next(vb); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to deserialize bucket")
+		}
+		buckets = append(buckets, vb)
+	}
+	if len(buckets) == 0 {
+		return buckets, nil, nil
+	}
+	return buckets, bucketKey(buckets[len(buckets)-1].Index), nil
+}
+
+// getBucketsWithCond returns the buckets satisfying cond, the buckets are filtered while iterating the states so that
+// only the matched ones are collected
+func getBucketsWithCond(sr protocol.StateReader, cond func(*VoteBucket) bool) ([]*VoteBucket, error) {
+	_, iter, err := sr.States(
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.FilterOption(func(k, v []byte) bool {
+			if !bytes.HasPrefix(k, []byte{_bucket}) {
+				return false
+			}
+			vb := &VoteBucket{}
+			if err := vb.Deserialize(v); err != nil {
+				return false
+			}
+			return cond(vb)
+		}, bucketKey(0), []byte{_bucket + 1}))
+	if errors.Cause(err) == state.ErrStateNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]*VoteBucket, 0, iter.Size())
+	for i := 0; i < iter.Size(); i++ {
+		vb := &VoteBucket{}
+		if err := iter.Next(vb); err != nil {
+			return nil, errors.Wrapf(err, "failed to deserialize bucket")
+		}
+		buckets = append(buckets, vb)
+	}
+	return buckets, nil
+}
+
 func getBucketsWithIndices(sr protocol.StateReader, indices BucketIndices) ([]*VoteBucket, error) {
 	buckets := make([]*VoteBucket, 0, len(indices))
 	for _, i := range indices {
@@ -298,12 +476,95 @@ func getBucketsWithIndices(sr protocol.StateReader, indices BucketIndices) ([]*V
 	return buckets, nil
 }
 
+// bucketFetchErrors records the buckets that could not be read, keyed by bucket index
+type bucketFetchErrors map[uint64]error
+
+func (e bucketFetchErrors) Error() string {
+	indexes := make([]uint64, 0, len(e))
+	for i := range e {
+		indexes = append(indexes, i)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	msgs := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		msgs = append(msgs, fmt.Sprintf("bucket %d: %v", i, e[i]))
+	}
+	return "failed to get buckets: " + strings.Join(msgs, "; ")
+}
+
+// getBuckets reads the buckets of the given indexes in one pass over the range of their keys. The returned slice is
+// aligned with indexes, a bucket that does not exist is left nil and reported in the returned bucketFetchErrors; any
+// other error aborts the read
+func getBuckets(sr protocol.StateReader, indexes []uint64) ([]*VoteBucket, error) {
+	buckets := make([]*VoteBucket, len(indexes))
+	if len(indexes) == 0 {
+		return buckets, nil
+	}
+	wanted := make(map[string]struct{}, len(indexes))
+	minIndex, maxIndex := indexes[0], indexes[0]
+	for _, index := range indexes {
+		wanted[string(bucketKey(index))] = struct{}{}
+		if index < minIndex {
+			minIndex = index
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+	_, iter, err := sr.States(
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.FilterOption(func(k, v []byte) bool {
+			_, ok := wanted[string(k)]
+			return ok
+		}, bucketKey(minIndex), bucketKey(maxIndex)))
+	found := make(map[uint64]*VoteBucket, len(indexes))
+	switch errors.Cause(err) {
+	case nil:
+		for i := 0; i < iter.Size(); i++ {
+			vb := &VoteBucket{}
+			if err := iter.Next(vb); err != nil {
+				return nil, errors.Wrapf(err, "failed to deserialize bucket")
+			}
+			found[vb.Index] = vb
+		}
+	case state.ErrStateNotExist:
+	default:
+		return nil, errors.Wrap(err, "failed to get buckets")
+	}
+
+	misses := bucketFetchErrors{}
+	for i, index := range indexes {
+		b, ok := found[index]
+		if !ok {
+			misses[index] = errors.Wrapf(state.ErrStateNotExist, "bucket %d does not exist", index)
+			continue
+		}
+		buckets[i] = b
+	}
+	if len(misses) > 0 {
+		return buckets, misses
+	}
+	return buckets, nil
+}
+
+// bucketKey is the prefix followed by the fixed 8-byte big-endian index, so that the byte order of the keys matches
+// the numeric order of the indexes and range scans over the keys return buckets sorted by index
 func bucketKey(index uint64) []byte {
-	key := []byte{_bucket}
-	return append(key, byteutil.Uint64ToBytesBigEndian(index)...)
+	key := make([]byte, _bucketKeyLen)
+	key[0] = _bucket
+	copy(key[1:], byteutil.Uint64ToBytesBigEndian(index))
+	return key
 }
 
-func calculateVoteWeight(c genesis.VoteWeightCalConsts, v *VoteBucket, selfStake bool) *big.Int {
+func bucketIndexFromKey(key []byte) (uint64, error) {
+	if len(key) != _bucketKeyLen || key[0] != _bucket {
+		return 0, errors.Errorf("invalid bucket key %x", key)
+	}
+	return byteutil.BytesToUint64BigEndian(key[1:]), nil
+}
+
+// calculateLegacyVoteWeight returns the weighted votes of the bucket calculated in float64, as before Greenland
+func calculateLegacyVoteWeight(c genesis.VoteWeightCalConsts, v *VoteBucket, selfStake bool, unit time.Duration) *big.Int {
 	remainingTime := v.StakedDuration.Seconds()
 	weight := float64(1)
 	var m float64
@@ -311,13 +572,114 @@ func calculateVoteWeight(c genesis.VoteWeightCalConsts, v *VoteBucket, selfStake
 		m = c.AutoStake
 	}
 	if remainingTime > 0 {
-		weight += math.Log(math.Ceil(remainingTime/86400)*(1+m)) / math.Log(c.DurationLg) / 100
+		weight += math.Log(math.Ceil(remainingTime/unit.Seconds())*(1+m)) / math.Log(c.DurationLg) / 100
 	}
 	if selfStake {
-		weight *= c.SelfStake
+		weight *= 1 + c.SelfStakeBonusRate
 	}
 
 	amount := new(big.Float).SetInt(v.StakedAmount)
 	weightedAmount, _ := amount.Mul(amount, big.NewFloat(weight)).Int(nil)
 	return weightedAmount
 }
+
+// calculateVoteWeight returns the weighted votes of the bucket, the duration bonus is that of the staked duration in
+// units, multiplied by 1 + AutoStake for an auto-stake bucket. The weight does not follow the remaining lock, so that
+// the votes subtracted from the candidate when the bucket changes are those added when it was staked, the countdown of
+// a bucket without auto-stake only decides when it is unlocked
+func calculateVoteWeight(
+	c genesis.VoteWeightCalConsts,
+	v *VoteBucket,
+	selfStake bool,
+	unit time.Duration,
+	rounding VoteWeightRounding,
+) *big.Int {
+	// the weight is computed with big.Float in software, so that the result does not depend on the platform
+	weight := newVoteWeightFloat().SetInt64(1)
+	if v.StakedDuration > 0 {
+		units := int64(v.StakedDuration / unit)
+		if v.StakedDuration%unit != 0 {
+			units++
+		}
+		x := newVoteWeightFloat().SetInt64(units)
+		if v.AutoStake {
+			m := newVoteWeightFloat().SetFloat64(c.AutoStake)
+			x.Mul(x, m.Add(m, newVoteWeightFloat().SetInt64(1)))
+		}
+		l := bigLog(x)
+		l.Quo(l, bigLog(newVoteWeightFloat().SetFloat64(c.DurationLg)))
+		l.Quo(l, newVoteWeightFloat().SetInt64(100))
+		weight.Add(weight, l)
+	}
+	if selfStake && c.SelfStakeBonusRate != 0 {
+		// the multiplier is summed up in float64, so that a rate of 0.05 gives exactly the float64 1.05
+		weight.Mul(weight, newVoteWeightFloat().SetFloat64(1+c.SelfStakeBonusRate))
+	}
+
+	amount := newVoteWeightFloat().SetInt(v.StakedAmount)
+	return roundVoteWeight(amount.Mul(amount, weight), rounding)
+}
+
+// roundVoteWeight rounds the non-negative weighted amount to an integer
+func roundVoteWeight(f *big.Float, rounding VoteWeightRounding) *big.Int {
+	i, acc := f.Int(nil)
+	if acc != big.Below {
+		return i
+	}
+	switch rounding {
+	case RoundCeil:
+		i.Add(i, big.NewInt(1))
+	case RoundHalfUp:
+		frac := newVoteWeightFloat().Sub(f, newVoteWeightFloat().SetInt(i))
+		if frac.Cmp(big.NewFloat(0.5)) >= 0 {
+			i.Add(i, big.NewInt(1))
+		}
+	}
+	return i
+}
+
+func newVoteWeightFloat() *big.Float {
+	return new(big.Float).SetPrec(_voteWeightPrec)
+}
+
+var (
+	_ln2     *big.Float
+	_ln2Once sync.Once
+)
+
+// bigLog returns the natural logarithm of x > 0
+func bigLog(x *big.Float) *big.Float {
+	// x = mant * 2^exp with mant in [0.5, 1), ln(x) = ln(mant) + exp * ln(2)
+	mant := newVoteWeightFloat()
+	exp := x.MantExp(mant)
+	res := lnSeries(mant)
+	if exp != 0 {
+		_ln2Once.Do(func() {
+			_ln2 = lnSeries(newVoteWeightFloat().SetInt64(2))
+		})
+		ln2 := newVoteWeightFloat().Mul(_ln2, newVoteWeightFloat().SetInt64(int64(exp)))
+		res.Add(res, ln2)
+	}
+	return res
+}
+
+// lnSeries returns ln(y) = 2 * atanh((y-1)/(y+1)), which converges fast for y close to 1
+func lnSeries(y *big.Float) *big.Float {
+	one := newVoteWeightFloat().SetInt64(1)
+	z := newVoteWeightFloat().Quo(
+		newVoteWeightFloat().Sub(y, one),
+		newVoteWeightFloat().Add(y, one),
+	)
+	z2 := newVoteWeightFloat().Mul(z, z)
+	sum := newVoteWeightFloat().Set(z)
+	pow := newVoteWeightFloat().Set(z)
+	for k := int64(3); ; k += 2 {
+		pow.Mul(pow, z2)
+		term := newVoteWeightFloat().Quo(pow, newVoteWeightFloat().SetInt64(k))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(_voteWeightPrec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	return sum.Mul(sum, newVoteWeightFloat().SetInt64(2))
+}
@@ -9,18 +9,25 @@ package staking
 import (
 	"bytes"
 	"context"
+	"math"
 	"math/big"
+	"math/rand"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
 	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/protocoltest"
+	"github.com/iotexproject/iotex-core/blockchain/genesis"
 	"github.com/iotexproject/iotex-core/db"
+	"github.com/iotexproject/iotex-core/pkg/unit"
 	"github.com/iotexproject/iotex-core/state"
 	"github.com/iotexproject/iotex-core/test/identityset"
 	"github.com/iotexproject/iotex-core/test/mock/mock_chainmanager"
@@ -103,9 +110,18 @@ func newMockKVStore(ctrl *gomock.Controller) db.KVStore {
 				return nil, nil, db.ErrBucketNotExist
 			}
 			vns, _ := vmap[ns]
+			// iterate in the order of keys, same as the underlying DB
+			hashes := make([]hash.Hash160, 0, len(kns))
+			for h := range kns {
+				hashes = append(hashes, h)
+			}
+			sort.Slice(hashes, func(i, j int) bool {
+				return bytes.Compare(kns[hashes[i]], kns[hashes[j]]) == -1
+			})
 			checkMin := len(minKey) > 0
 			checkMax := len(maxKey) > 0
-			for h, k := range kns {
+			for _, h := range hashes {
+				k := kns[h]
 				if checkMin && bytes.Compare(k, minKey) == -1 {
 					continue
 				}
@@ -131,6 +147,20 @@ func newMockKVStore(ctrl *gomock.Controller) db.KVStore {
 func newMockStateManager(ctrl *gomock.Controller) protocol.StateManager {
 	sm := mock_chainmanager.NewMockStateManager(ctrl)
 	kv := newMockKVStore(ctrl)
+	// the journal of overwritten values, which are restored on revert
+	type change struct {
+		ns         string
+		key, value []byte
+		existed    bool
+	}
+	var (
+		journal   []change
+		snapshots []int
+	)
+	record := func(ns string, key []byte) {
+		value, err := kv.Get(ns, key)
+		journal = append(journal, change{ns, key, value, err == nil})
+	}
 	sm.EXPECT().State(gomock.Any(), gomock.Any()).DoAndReturn(
 		func(s interface{}, opts ...protocol.StateOption) (uint64, error) {
 			cfg, err := protocol.CreateStateConfig(opts...)
@@ -162,6 +192,7 @@ func newMockStateManager(ctrl *gomock.Controller) protocol.StateManager {
 			if err != nil {
 				return 0, err
 			}
+			record(cfg.Namespace, cfg.Key)
 			return 0, kv.Put(cfg.Namespace, cfg.Key, value)
 		},
 	).AnyTimes()
@@ -171,9 +202,36 @@ func newMockStateManager(ctrl *gomock.Controller) protocol.StateManager {
 			if err != nil {
 				return 0, err
 			}
+			record(cfg.Namespace, cfg.Key)
 			return 0, kv.Delete(cfg.Namespace, cfg.Key)
 		},
 	).AnyTimes()
+	sm.EXPECT().Snapshot().DoAndReturn(
+		func() int {
+			snapshots = append(snapshots, len(journal))
+			return len(snapshots) - 1
+		},
+	).AnyTimes()
+	sm.EXPECT().Revert(gomock.Any()).DoAndReturn(
+		func(snapshot int) error {
+			if snapshot < 0 || snapshot >= len(snapshots) {
+				return errors.Errorf("invalid snapshot %d", snapshot)
+			}
+			for i := len(journal) - 1; i >= snapshots[snapshot]; i-- {
+				c := journal[i]
+				if c.existed {
+					if err := kv.Put(c.ns, c.key, c.value); err != nil {
+						return err
+					}
+				} else if err := kv.Delete(c.ns, c.key); err != nil {
+					return err
+				}
+			}
+			journal = journal[:snapshots[snapshot]]
+			snapshots = snapshots[:snapshot+1]
+			return nil
+		},
+	).AnyTimes()
 	sm.EXPECT().States(gomock.Any()).DoAndReturn(
 		func(opts ...protocol.StateOption) (uint64, state.Iterator, error) {
 			cfg, err := protocol.CreateStateConfig(opts...)
@@ -185,6 +243,12 @@ func newMockStateManager(ctrl *gomock.Controller) protocol.StateManager {
 					return true
 				}
 			}
+			if len(cfg.LastKey) > 0 {
+				cond, lastKey := cfg.Cond, cfg.LastKey
+				cfg.Cond = func(k, v []byte) bool {
+					return bytes.Compare(k, lastKey) == 1 && cond(k, v)
+				}
+			}
 			_, fv, err := kv.Filter(cfg.Namespace, cfg.Cond, cfg.MinKey, cfg.MaxKey)
 			if err != nil {
 				return 0, nil, state.ErrStateNotExist
@@ -196,6 +260,14 @@ func newMockStateManager(ctrl *gomock.Controller) protocol.StateManager {
 	return sm
 }
 
+func TestMockStateManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	protocoltest.TestStateManager(t, func() protocol.StateManager {
+		return newMockStateManager(ctrl)
+	})
+}
+
 func TestGetPutStaking(t *testing.T) {
 	require := require.New(t)
 
@@ -269,3 +341,419 @@ func TestGetPutStaking(t *testing.T) {
 		require.Equal(state.ErrStateNotExist, errors.Cause(err))
 	}
 }
+
+func TestPutBucketExisting(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	// the next index already holds a bucket
+	existing := NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(100), 7, time.Now(), true)
+	_, err = sm.PutState(
+		existing,
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(bucketKey(0)),
+	)
+	require.NoError(err)
+
+	vb := NewVoteBucket(identityset.Address(3), identityset.Address(4), big.NewInt(200), 14, time.Now(), false)
+	_, err = putBucket(sm, vb)
+	require.Equal(state.ErrStateAlreadyExists, errors.Cause(err))
+	_, err = putBucketAndIndex(sm, vb)
+	require.Equal(state.ErrStateAlreadyExists, errors.Cause(err))
+
+	// neither the bucket nor the count is changed
+	b, err := getBucket(sm, 0)
+	require.NoError(err)
+	require.Equal(existing, b)
+	count, err := getTotalBucketCount(sm)
+	require.NoError(err)
+	require.Zero(count)
+	indices, err := getVoterBucketIndices(sm, identityset.Address(4))
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+	require.Nil(indices)
+	indices, err = getCandBucketIndices(sm, identityset.Address(3))
+	require.Equal(state.ErrStateNotExist, errors.Cause(err))
+	require.Nil(indices)
+}
+
+func TestVoteBucketSerializeRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	// the latest time and the longest duration a bucket can hold
+	maxTime := time.Unix(253402300799, 999999999).UTC()
+	maxDuration := uint32(math.MaxInt64 / int64(24*time.Hour))
+
+	r := rand.New(rand.NewSource(20200322))
+	randTime := func() time.Time {
+		return time.Unix(r.Int63n(maxTime.Unix()+1), r.Int63n(1e9)).UTC()
+	}
+	randAmount := func() *big.Int {
+		b := make([]byte, 1+r.Intn(32))
+		r.Read(b)
+		b[0] |= 1
+		return new(big.Int).SetBytes(b)
+	}
+	buckets := []*VoteBucket{
+		// edge cases
+		NewVoteBucket(identityset.Address(0), identityset.Address(1), big.NewInt(1), 0, time.Unix(0, 0), false),
+		NewVoteBucket(identityset.Address(2), identityset.Address(2), unit.ConvertIotxToRau(1200000), maxDuration, maxTime, true),
+		{
+			Index:            math.MaxUint64,
+			Candidate:        identityset.Address(3),
+			Owner:            identityset.Address(4),
+			StakedAmount:     new(big.Int).Lsh(big.NewInt(1), 256),
+			StakedDuration:   time.Duration(maxDuration) * 24 * time.Hour,
+			CreateTime:       maxTime,
+			StakeStartTime:   maxTime,
+			UnstakeStartTime: maxTime,
+			AutoStake:        true,
+		},
+	}
+	for i := 0; i < 1000; i++ {
+		buckets = append(buckets, &VoteBucket{
+			Index:            r.Uint64(),
+			Candidate:        identityset.Address(r.Intn(30)),
+			Owner:            identityset.Address(r.Intn(30)),
+			StakedAmount:     randAmount(),
+			StakedDuration:   time.Duration(r.Int63n(int64(maxDuration)+1)) * 24 * time.Hour,
+			CreateTime:       randTime(),
+			StakeStartTime:   randTime(),
+			UnstakeStartTime: randTime(),
+			AutoStake:        r.Intn(2) == 1,
+		})
+	}
+
+	for _, b := range buckets {
+		data, err := b.Serialize()
+		require.NoError(err)
+		b1 := &VoteBucket{}
+		require.NoError(b1.Deserialize(data))
+		require.Equal(b, b1)
+		data1, err := b1.Serialize()
+		require.NoError(err)
+		require.Equal(data, data1)
+	}
+
+	// zero and negative amounts can neither be stored nor loaded
+	for _, amount := range []*big.Int{big.NewInt(0), big.NewInt(-1)} {
+		b := NewVoteBucket(identityset.Address(0), identityset.Address(1), amount, 1, time.Now(), false)
+		_, err := b.Serialize()
+		require.Equal(ErrInvalidAmount, errors.Cause(err))
+		pb, err := buckets[0].toProto()
+		require.NoError(err)
+		pb.StakedAmount = amount.String()
+		data, err := proto.Marshal(pb)
+		require.NoError(err)
+		require.Equal(ErrInvalidAmount, errors.Cause(b.Deserialize(data)))
+	}
+}
+
+func TestGetBucketsAfterToken(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	buckets, token, err := getBucketsAfterToken(sm, nil, 3)
+	require.NoError(err)
+	require.Zero(len(buckets))
+	require.Nil(token)
+
+	for i := 0; i < 5; i++ {
+		vb := NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(int64(i+1)), 7, time.Now(), true)
+		_, err := putBucketAndIndex(sm, vb)
+		require.NoError(err)
+	}
+
+	// page in two calls
+	page1, token, err := getBucketsAfterToken(sm, nil, 3)
+	require.NoError(err)
+	require.Equal(3, len(page1))
+	require.Equal(bucketKey(2), token)
+	page2, token, err := getBucketsAfterToken(sm, token, 3)
+	require.NoError(err)
+	require.Equal(2, len(page2))
+	require.Equal(bucketKey(4), token)
+	page3, token, err := getBucketsAfterToken(sm, token, 3)
+	require.NoError(err)
+	require.Zero(len(page3))
+	require.Nil(token)
+
+	// no duplicates or gaps
+	all, err := getAllBuckets(sm)
+	require.NoError(err)
+	require.Equal(all, append(page1, page2...))
+	for i, b := range append(page1, page2...) {
+		require.Equal(uint64(i), b.Index)
+	}
+
+	// a bucket deleted between pages does not shift the next page
+	page1, token, err = getBucketsAfterToken(sm, nil, 2)
+	require.NoError(err)
+	require.NoError(delBucket(sm, 0))
+	page2, _, err = getBucketsAfterToken(sm, token, 2)
+	require.NoError(err)
+	require.Equal(uint64(2), page2[0].Index)
+	require.Equal(uint64(3), page2[1].Index)
+}
+
+func TestBucketKeyOrder(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+
+	// insert the buckets around byte boundaries, the higher indexes first
+	for _, start := range []uint64{65535, 255} {
+		_, err := sm.PutState(
+			&totalBucketCount{count: start},
+			protocol.NamespaceOption(StakingNameSpace),
+			protocol.KeyOption(TotalBucketKey),
+		)
+		require.NoError(err)
+		for i := 0; i < 2; i++ {
+			vb := NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(1), 7, time.Now(), true)
+			index, err := putBucket(sm, vb)
+			require.NoError(err)
+			require.Equal(start+uint64(i), index)
+		}
+	}
+	expected := []uint64{255, 256, 65535, 65536}
+
+	// keys have a fixed length and decode back to the index
+	for _, index := range expected {
+		key := bucketKey(index)
+		require.Equal(_bucketKeyLen, len(key))
+		i, err := bucketIndexFromKey(key)
+		require.NoError(err)
+		require.Equal(index, i)
+	}
+	for _, key := range [][]byte{nil, {_bucket}, append(bucketKey(1), 0), append([]byte{_voterIndex}, bucketKey(1)[1:]...)} {
+		_, err := bucketIndexFromKey(key)
+		require.Error(err)
+	}
+
+	// range scan returns the buckets ordered by index
+	all, err := getAllBuckets(sm)
+	require.NoError(err)
+	require.Equal(len(expected), len(all))
+	for i, b := range all {
+		require.Equal(expected[i], b.Index)
+	}
+	var (
+		token []byte
+		paged []uint64
+	)
+	for {
+		page, next, err := getBucketsAfterToken(sm, token, 1)
+		require.NoError(err)
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page[0].Index)
+		token = next
+	}
+	require.Equal(expected, paged)
+	_, _, err = getBucketsAfterToken(sm, []byte{_bucket, 1}, 1)
+	require.Error(err)
+}
+
+func TestGetBuckets(t *testing.T) {
+	require := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	sm := newMockStateManager(ctrl)
+	_, err := sm.PutState(
+		&totalBucketCount{count: 0},
+		protocol.NamespaceOption(StakingNameSpace),
+		protocol.KeyOption(TotalBucketKey),
+	)
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		vb := NewVoteBucket(identityset.Address(1), identityset.Address(2), big.NewInt(int64(i+1)), 7, time.Now(), true)
+		_, err := putBucket(sm, vb)
+		require.NoError(err)
+	}
+
+	// all exist
+	buckets, err := getBuckets(sm, []uint64{2, 0})
+	require.NoError(err)
+	require.Equal(2, len(buckets))
+	require.Equal(uint64(2), buckets[0].Index)
+	require.Equal(uint64(0), buckets[1].Index)
+
+	// mix of existing and missing indexes
+	buckets, err = getBuckets(sm, []uint64{0, 5, 1, 7})
+	require.Error(err)
+	misses, ok := err.(bucketFetchErrors)
+	require.True(ok)
+	require.Equal(2, len(misses))
+	require.Equal(state.ErrStateNotExist, errors.Cause(misses[5]))
+	require.Equal(state.ErrStateNotExist, errors.Cause(misses[7]))
+	require.Equal(4, len(buckets))
+	require.Equal(uint64(0), buckets[0].Index)
+	require.Nil(buckets[1])
+	require.Equal(uint64(1), buckets[2].Index)
+	require.Nil(buckets[3])
+
+	// empty input
+	buckets, err = getBuckets(sm, nil)
+	require.NoError(err)
+	require.Zero(len(buckets))
+}
+
+func TestCalculateVoteWeight(t *testing.T) {
+	require := require.New(t)
+
+	consts := genesis.Default.Staking.VoteWeightCalConsts
+	day := 24 * time.Hour
+
+	// self-stake bonus only, the result is the exact floor of amount * (1 + SelfStakeBonusRate)
+	amount, ok := new(big.Int).SetString("1000000000000000000000000007", 10)
+	require.True(ok)
+	vb := &VoteBucket{StakedAmount: amount}
+	expected := new(big.Rat).Mul(new(big.Rat).SetInt(amount), new(big.Rat).SetFloat64(1+consts.SelfStakeBonusRate))
+	floor := new(big.Int).Quo(expected.Num(), expected.Denom())
+	require.Equal(floor, calculateVoteWeight(consts, vb, true, day, RoundFloor))
+	require.Equal(new(big.Int).Add(floor, big.NewInt(1)), calculateVoteWeight(consts, vb, true, day, RoundCeil))
+	require.Equal(amount, calculateVoteWeight(consts, vb, false, day, RoundCeil))
+
+	// rounding modes
+	for _, test := range []struct {
+		amount              int64
+		floor, halfUp, ceil int64
+	}{
+		{3, 3, 3, 4},     // 3.15
+		{10, 10, 11, 11}, // 10.5
+		{20, 21, 21, 22}, // 21.000000000000000888, as 1.05 is not exact in float64
+	} {
+		vb := &VoteBucket{StakedAmount: big.NewInt(test.amount)}
+		require.Equal(big.NewInt(test.floor), calculateVoteWeight(consts, vb, true, day, RoundFloor))
+		require.Equal(big.NewInt(test.halfUp), calculateVoteWeight(consts, vb, true, day, RoundHalfUp))
+		require.Equal(big.NewInt(test.ceil), calculateVoteWeight(consts, vb, true, day, RoundCeil))
+	}
+
+	// with the duration bonus, float64 math loses the low digits, the integer result is stable
+	vb = &VoteBucket{
+		StakedAmount:   unit.ConvertIotxToRau(100),
+		StakedDuration: 91 * day,
+		AutoStake:      true,
+	}
+	for i := 0; i < 3; i++ {
+		require.Equal("134970164348727292756", calculateVoteWeight(consts, vb, true, day, RoundFloor).String())
+		require.Equal("128543013665454559093", calculateVoteWeight(consts, vb, false, day, RoundFloor).String())
+		require.Equal("128543013665454559094", calculateVoteWeight(consts, vb, false, day, RoundCeil).String())
+	}
+	// a partial unit counts as a whole one
+	vb.StakedDuration = 90*day + time.Second
+	require.Equal("128543013665454559093", calculateVoteWeight(consts, vb, false, day, RoundFloor).String())
+
+	// invalid rounding mode
+	_, err := NewProtocol(nil, nil, genesis.Default.Staking, VoteWeightRoundingOption(VoteWeightRounding(3)))
+	require.Error(err)
+}
+
+func TestCalculateVoteWeightSelfStakeBonus(t *testing.T) {
+	require := require.New(t)
+
+	day := 24 * time.Hour
+	vb := &VoteBucket{
+		StakedAmount:   unit.ConvertIotxToRau(100),
+		StakedDuration: 91 * day,
+		AutoStake:      true,
+	}
+	consts := genesis.Default.Staking.VoteWeightCalConsts
+	plain := calculateVoteWeight(consts, vb, false, day, RoundFloor)
+
+	// a zero bonus weighs the self-stake like any bucket
+	consts.SelfStakeBonusRate = 0
+	require.Equal(plain, calculateVoteWeight(consts, vb, true, day, RoundFloor))
+	// the bonus does not apply to other buckets
+	for _, rate := range []float64{0.05, 0.5, 1} {
+		consts.SelfStakeBonusRate = rate
+		require.Equal(plain, calculateVoteWeight(consts, vb, false, day, RoundFloor))
+	}
+	// the weight grows with the bonus rate, the default rate keeps the weight of the former 1.05 multiplier
+	var last *big.Int
+	for _, test := range []struct {
+		rate   float64
+		weight string
+	}{
+		{0, "128543013665454559093"},
+		{0.05, "134970164348727292756"},
+		{0.5, "192814520498181838639"},
+		{1, "257086027330909118186"},
+	} {
+		consts.SelfStakeBonusRate = test.rate
+		weight := calculateVoteWeight(consts, vb, true, day, RoundFloor)
+		require.Equal(test.weight, weight.String())
+		if last != nil {
+			require.Equal(1, weight.Cmp(last))
+		}
+		last = weight
+	}
+
+	// a negative bonus rate is invalid
+	cfg := genesis.Default.Staking
+	cfg.VoteWeightCalConsts.SelfStakeBonusRate = -0.1
+	_, err := NewProtocol(nil, nil, cfg)
+	require.Error(err)
+}
+
+func TestVoteBucketAutoStake(t *testing.T) {
+	require := require.New(t)
+
+	consts := genesis.Default.Staking.VoteWeightCalConsts
+	day := 24 * time.Hour
+	now := time.Unix(1600000000, 0)
+	cand := identityset.Address(1)
+	owner := identityset.Address(2)
+	fixed := NewVoteBucket(cand, owner, unit.ConvertIotxToRau(100), 7, now, false)
+	auto := NewVoteBucket(cand, owner, unit.ConvertIotxToRau(100), 7, now, true)
+
+	// the auto-stake bucket weighs more with the same duration, and the weights do not change over time
+	fixedWeight := calculateVoteWeight(consts, fixed, false, day, RoundFloor)
+	autoWeight := calculateVoteWeight(consts, auto, false, day, RoundFloor)
+	require.Equal(1, autoWeight.Cmp(fixedWeight))
+	require.Equal(1, fixedWeight.Cmp(fixed.StakedAmount))
+
+	// the lock of the fixed bucket counts down, the one of the auto-stake bucket never does
+	for _, test := range []struct {
+		elapsed     time.Duration
+		fixedLock   time.Duration
+		fixedStatus BucketStatus
+	}{
+		{0, 7 * day, BucketLocked},
+		{3 * day, 4 * day, BucketLocked},
+		{7*day - time.Second, time.Second, BucketLocked},
+		{7 * day, 0, BucketUnlocked},
+		{30 * day, 0, BucketUnlocked},
+	} {
+		at := now.Add(test.elapsed)
+		require.Equal(test.fixedLock, fixed.remainingLock(at))
+		require.Equal(test.fixedStatus, fixed.status(at, 0))
+		require.Equal(7*day, auto.remainingLock(at))
+		require.Equal(BucketLocked, auto.status(at, 0))
+	}
+	require.Equal(fixedWeight, calculateVoteWeight(consts, fixed, false, day, RoundFloor))
+	require.Equal(autoWeight, calculateVoteWeight(consts, auto, false, day, RoundFloor))
+}
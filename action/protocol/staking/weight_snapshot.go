@@ -0,0 +1,142 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package staking
+
+import (
+	"math/big"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/iotexproject/iotex-core/action/protocol"
+	"github.com/iotexproject/iotex-core/action/protocol/staking/stakingpb"
+	"github.com/iotexproject/iotex-core/pkg/util/byteutil"
+	"github.com/iotexproject/iotex-core/state"
+)
+
+// StakingStats is the aggregate staking statistics of the network at the start of an epoch
+type StakingStats struct {
+	// TotalStake is the sum of the amounts of the buckets not unstaked
+	TotalStake *big.Int
+	// TotalBuckets is the number of existing buckets, including the unstaked ones not yet withdrawn
+	TotalBuckets uint64
+	// ActiveCandidates is the number of candidates whose self-stake meets the requirement
+	ActiveCandidates uint64
+	// TotalWeightedVotes is the sum of the weighted votes of all candidates
+	TotalWeightedVotes *big.Int
+}
+
+// Serialize serializes the staking stats into bytes
+func (s *StakingStats) Serialize() ([]byte, error) {
+	return proto.Marshal(&stakingpb.Stats{
+		TotalStake:         s.TotalStake.String(),
+		TotalBuckets:       s.TotalBuckets,
+		ActiveCandidates:   s.ActiveCandidates,
+		TotalWeightedVotes: s.TotalWeightedVotes.String(),
+	})
+}
+
+// Deserialize deserializes bytes into the staking stats
+func (s *StakingStats) Deserialize(buf []byte) error {
+	pb := &stakingpb.Stats{}
+	if err := proto.Unmarshal(buf, pb); err != nil {
+		return errors.Wrap(err, "failed to unmarshal staking stats")
+	}
+	var ok bool
+	if s.TotalStake, ok = new(big.Int).SetString(pb.GetTotalStake(), 10); !ok {
+		return errors.Errorf("invalid total stake %s", pb.GetTotalStake())
+	}
+	if s.TotalWeightedVotes, ok = new(big.Int).SetString(pb.GetTotalWeightedVotes(), 10); !ok {
+		return errors.Errorf("invalid total weighted votes %s", pb.GetTotalWeightedVotes())
+	}
+	s.TotalBuckets = pb.GetTotalBuckets()
+	s.ActiveCandidates = pb.GetActiveCandidates()
+	return nil
+}
+
+// snapshotWeights stores the weighted votes of all candidates under the epoch number
+func (p *Protocol) snapshotWeights(sm protocol.StateManager, epoch uint64) error {
+	all, err := p.inMemCandidates.All()
+	if err != nil {
+		return err
+	}
+	list, err := all.toStateCandidateList()
+	if err != nil {
+		return err
+	}
+	_, err = sm.PutState(
+		&list,
+		protocol.NamespaceOption(WeightSnapshotNameSpace),
+		protocol.KeyOption(byteutil.Uint64ToBytesBigEndian(epoch)))
+	return errors.Wrapf(err, "failed to snapshot candidate weights of epoch %d", epoch)
+}
+
+// WeightSnapshotByEpoch returns the weighted votes of the candidates keyed by owner address, as frozen at the start of
+// the epoch. It returns state.ErrStateNotExist if no snapshot was taken for the epoch
+func (p *Protocol) WeightSnapshotByEpoch(sr protocol.StateReader, epoch uint64) (map[string]*big.Int, error) {
+	var list state.CandidateList
+	if _, err := sr.State(
+		&list,
+		protocol.NamespaceOption(WeightSnapshotNameSpace),
+		protocol.KeyOption(byteutil.Uint64ToBytesBigEndian(epoch))); err != nil {
+		return nil, errors.Wrapf(err, "failed to get candidate weights of epoch %d", epoch)
+	}
+	weights := make(map[string]*big.Int, len(list))
+	for _, c := range list {
+		weights[c.Address] = c.Votes
+	}
+	return weights, nil
+}
+
+// snapshotStats stores the network staking statistics under the epoch number, so that reading them back does not
+// iterate the buckets. The buckets are read one by one by index, as the working set does not support iterating them
+func (p *Protocol) snapshotStats(sm protocol.StateManager, epoch uint64) error {
+	all, err := p.inMemCandidates.All()
+	if err != nil {
+		return err
+	}
+	active, err := p.activeCandidates()
+	if err != nil {
+		return err
+	}
+	stats := StakingStats{
+		TotalStake:         big.NewInt(0),
+		ActiveCandidates:   uint64(len(active)),
+		TotalWeightedVotes: big.NewInt(0),
+	}
+	if err := forEachBucket(sm, func(bucket *VoteBucket) error {
+		stats.TotalBuckets++
+		if bucket.UnstakeStartTime.Unix() == 0 {
+			stats.TotalStake.Add(stats.TotalStake, bucket.StakedAmount)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, c := range all {
+		stats.TotalWeightedVotes.Add(stats.TotalWeightedVotes, c.Votes)
+	}
+	_, err = sm.PutState(
+		&stats,
+		protocol.NamespaceOption(StatsNameSpace),
+		protocol.KeyOption(byteutil.Uint64ToBytesBigEndian(epoch)))
+	return errors.Wrapf(err, "failed to snapshot staking stats of epoch %d", epoch)
+}
+
+// NetworkStats returns the network staking statistics recorded at the start of the epoch. It returns
+// state.ErrStateNotExist if no snapshot was taken for the epoch, which is the case for the epochs before
+// WeightSnapshotOption is enabled
+func (p *Protocol) NetworkStats(sr protocol.StateReader, epoch uint64) (*StakingStats, error) {
+	var stats StakingStats
+	if _, err := sr.State(
+		&stats,
+		protocol.NamespaceOption(StatsNameSpace),
+		protocol.KeyOption(byteutil.Uint64ToBytesBigEndian(epoch))); err != nil {
+		return nil, errors.Wrapf(err, "failed to get staking stats of epoch %d", epoch)
+	}
+	return &stats, nil
+}
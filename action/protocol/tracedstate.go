@@ -0,0 +1,121 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"github.com/iotexproject/iotex-core/state"
+)
+
+// StateAccess is the type of an access to the states
+type StateAccess int
+
+// the accesses reported to a StateTracer
+const (
+	StateAccessRead StateAccess = iota
+	StateAccessReadAll
+	StateAccessPut
+	StateAccessDelete
+)
+
+func (a StateAccess) String() string {
+	switch a {
+	case StateAccessRead:
+		return "State"
+	case StateAccessReadAll:
+		return "States"
+	case StateAccessPut:
+		return "PutState"
+	case StateAccessDelete:
+		return "DelState"
+	default:
+		return "Unknown"
+	}
+}
+
+type (
+	// StateTracer is called before each access to the states through a traced state reader or manager, with the
+	// namespace and the key of the access. The key is empty if the access is not keyed, like reading all the states of
+	// a namespace
+	StateTracer func(access StateAccess, namespace string, key []byte)
+
+	// tracedStateReader reports the reads of the underlying state reader to the tracer
+	tracedStateReader struct {
+		StateReader
+		tracer StateTracer
+	}
+
+	// tracedStateManager is a tracedStateReader over a state manager, which reports the writes and deletions as well
+	tracedStateManager struct {
+		*tracedStateReader
+		sm StateManager
+	}
+)
+
+// NewTracedStateReader returns a state reader which reports the states read through it to the tracer. The state
+// reader is returned as is if the tracer is nil
+func NewTracedStateReader(sr StateReader, tracer StateTracer) StateReader {
+	if tracer == nil {
+		return sr
+	}
+	return &tracedStateReader{
+		StateReader: sr,
+		tracer:      tracer,
+	}
+}
+
+// NewTracedStateManager returns a state manager which reports the states read, written and deleted through it to the
+// tracer, which is meant for debugging which states an action accesses. The state manager is returned as is if the
+// tracer is nil
+func NewTracedStateManager(sm StateManager, tracer StateTracer) StateManager {
+	if tracer == nil {
+		return sm
+	}
+	return &tracedStateManager{
+		tracedStateReader: &tracedStateReader{
+			StateReader: sm,
+			tracer:      tracer,
+		},
+		sm: sm,
+	}
+}
+
+func (sr *tracedStateReader) trace(access StateAccess, opts ...StateOption) {
+	cfg, err := CreateStateConfig(opts...)
+	if err != nil {
+		// the underlying state reader reports the error
+		return
+	}
+	sr.tracer(access, cfg.Namespace, cfg.Key)
+}
+
+func (sr *tracedStateReader) State(s interface{}, opts ...StateOption) (uint64, error) {
+	sr.trace(StateAccessRead, opts...)
+	return sr.StateReader.State(s, opts...)
+}
+
+func (sr *tracedStateReader) States(opts ...StateOption) (uint64, state.Iterator, error) {
+	sr.trace(StateAccessReadAll, opts...)
+	return sr.StateReader.States(opts...)
+}
+
+func (sm *tracedStateManager) Snapshot() int {
+	return sm.sm.Snapshot()
+}
+
+func (sm *tracedStateManager) Revert(snapshot int) error {
+	return sm.sm.Revert(snapshot)
+}
+
+func (sm *tracedStateManager) PutState(s interface{}, opts ...StateOption) (uint64, error) {
+	sm.trace(StateAccessPut, opts...)
+	return sm.sm.PutState(s, opts...)
+}
+
+func (sm *tracedStateManager) DelState(opts ...StateOption) (uint64, error) {
+	sm.trace(StateAccessDelete, opts...)
+	return sm.sm.DelState(opts...)
+}
@@ -8,7 +8,6 @@ package candidatesutil
 
 import (
 	"math/big"
-	"sort"
 
 	"go.uber.org/zap"
 
@@ -212,11 +211,10 @@ func addCandidate(candidateMap map[hash.Hash160]*state.Candidate, encodedAddr st
 
 // storeCandidates puts updated candidates to trie
 func storeCandidates(candidateMap map[hash.Hash160]*state.Candidate, sm protocol.StateManager, blkHeight uint64) error {
-	candidateList, err := state.MapToCandidates(candidateMap)
+	candidateList, err := state.CandidatesToSortedSlice(candidateMap)
 	if err != nil {
 		return errors.Wrap(err, "failed to convert candidate map to candidate list")
 	}
-	sort.Sort(candidateList)
 	candidatesKey := ConstructLegacyKey(blkHeight)
 	_, err = sm.PutState(&candidateList, protocol.LegacyKeyOption(candidatesKey))
 	return err
@@ -56,6 +56,7 @@ func defaultConfig() Genesis {
 			DaytonaBlockHeight:      3238921,
 			EasterBlockHeight:       4200841,
 			FairbankBlockHeight:     4339081,
+			GreenlandBlockHeight:    6544441,
 		},
 		Account: Account{
 			InitBalanceMap: make(map[string]string),
@@ -80,14 +81,18 @@ func defaultConfig() Genesis {
 		},
 		Staking: Staking{
 			VoteWeightCalConsts: VoteWeightCalConsts{
-				DurationLg: 1.2,
-				AutoStake:  1,
-				SelfStake:  1.05,
+				DurationLg:         1.2,
+				AutoStake:          1,
+				SelfStakeBonusRate: 0.05,
 			},
 			RegistrationConsts: RegistrationConsts{
 				Fee:          unit.ConvertIotxToRau(100).String(),
 				MinSelfStake: unit.ConvertIotxToRau(1200000).String(),
 			},
+			NameConsts: NameConsts{
+				MaxLength: 12,
+				Charset:   "abcdefghijklmnopqrstuvwxyz0123456789",
+			},
 			WithdrawWaitingPeriod: 14 * 24 * time.Hour,
 			MinStakeAmount:        unit.ConvertIotxToRau(100).String(),
 			BootstrapCandidates:   []BootstrapCandidate{},
@@ -159,6 +164,9 @@ type (
 		EasterBlockHeight uint64 `yaml:"easterHeight"`
 		// FairbankBlockHeight is the start height to switch to native staking V2
 		FairbankBlockHeight uint64 `yaml:"fairbankHeight"`
+		// GreenlandBlockHeight is the start height of the native staking V2 upgrades, which change the receipts and the
+		// states of staking actions, and the ordering of the delegates
+		GreenlandBlockHeight uint64 `yaml:"greenlandHeight"`
 	}
 	// Account contains the configs for account protocol
 	Account struct {
@@ -233,24 +241,46 @@ type (
 	}
 	// Staking contains the configs for staking protocol
 	Staking struct {
-		VoteWeightCalConsts   VoteWeightCalConsts  `yaml:"voteWeightCalConsts"`
-		RegistrationConsts    RegistrationConsts   `yaml:"registrationConsts"`
-		WithdrawWaitingPeriod time.Duration        `yaml:"withdrawWaitingPeriod"`
-		MinStakeAmount        string               `yaml:"minStakeAmount"`
-		BootstrapCandidates   []BootstrapCandidate `yaml:"bootstrapCandidates"`
+		VoteWeightCalConsts      VoteWeightCalConsts  `yaml:"voteWeightCalConsts"`
+		RegistrationConsts       RegistrationConsts   `yaml:"registrationConsts"`
+		NameConsts               NameConsts           `yaml:"nameConsts"`
+		WithdrawWaitingPeriod    time.Duration        `yaml:"withdrawWaitingPeriod"`
+		MinStakeAmount           string               `yaml:"minStakeAmount"`
+		MaxCandidates            uint64               `yaml:"maxCandidates"`
+		AutoRegisterCandidate    bool                 `yaml:"autoRegisterCandidate"`
+		OperatorChangeCooldown   uint64               `yaml:"operatorChangeCooldown"`
+		MaxRegistrationsPerEpoch uint64               `yaml:"maxRegistrationsPerEpoch"`
+		BootstrapCandidates      []BootstrapCandidate `yaml:"bootstrapCandidates"`
+		GenesisBuckets           []GenesisBucket      `yaml:"genesisBuckets"`
+		EmergencyPauses          []EmergencyPause     `yaml:"emergencyPauses"`
 	}
 
 	// VoteWeightCalConsts contains the configs for calculating vote weight
+	// The weight of the self-stake bucket of a candidate is multiplied by 1 + SelfStakeBonusRate, a rate of 0 weighs
+	// the self-stake like any other bucket. SelfStake is the deprecated multiplier it replaces, a genesis still setting
+	// it gets the bonus rate SelfStake - 1
 	VoteWeightCalConsts struct {
-		DurationLg float64 `yaml:"durationLg"`
-		AutoStake  float64 `yaml:"autoStake"`
-		SelfStake  float64 `yaml:"selfStake"`
+		DurationLg         float64 `yaml:"durationLg"`
+		AutoStake          float64 `yaml:"autoStake"`
+		SelfStakeBonusRate float64 `yaml:"selfStakeBonusRate"`
+		SelfStake          float64 `yaml:"selfStake"`
 	}
 
 	// RegistrationConsts contains the configs for candidate registration
+	// The self-stake bucket of a candidate must be auto-staked or locked for at least MinSelfStakeDuration days, a
+	// duration of 0 disables the requirement
 	RegistrationConsts struct {
-		Fee          string `yaml:"fee"`
-		MinSelfStake string `yaml:"minSelfStake"`
+		Fee                  string `yaml:"fee"`
+		MinSelfStake         string `yaml:"minSelfStake"`
+		MinSelfStakeDuration uint32 `yaml:"minSelfStakeDuration"`
+	}
+
+	// NameConsts contains the rules of candidate names enforced from Height on, a name is 1 to MaxLength bytes long and
+	// consists of the characters in Charset
+	NameConsts struct {
+		Height    uint64 `yaml:"height"`
+		MaxLength int    `yaml:"maxLength"`
+		Charset   string `yaml:"charset"`
 	}
 
 	// BootstrapCandidate is the candidate data need to be provided to bootstrap candidate.
@@ -261,6 +291,22 @@ type (
 		Name              string `yaml:"name"`
 		SelfStakingTokens string `yaml:"selfStakingTokens"`
 	}
+
+	// EmergencyPause is a range of block heights in which all staking actions are rejected, from StartHeight until
+	// right before EndHeight, or without end if EndHeight is 0
+	EmergencyPause struct {
+		StartHeight uint64 `yaml:"startHeight"`
+		EndHeight   uint64 `yaml:"endHeight"`
+	}
+
+	// GenesisBucket is a bucket pre-allocated at genesis, which votes for a bootstrap candidate
+	GenesisBucket struct {
+		OwnerAddress  string `yaml:"ownerAddress"`
+		CandidateName string `yaml:"candidateName"`
+		Amount        string `yaml:"amount"`
+		Duration      uint32 `yaml:"duration"`
+		AutoStake     bool   `yaml:"autoStake"`
+	}
 )
 
 // New constructs a genesis config. It loads the default values, and could be overwritten by values defined in the yaml
@@ -282,6 +328,13 @@ func New() (Genesis, error) {
 	if err := yaml.Get(config.Root).Populate(&genesis); err != nil {
 		return Genesis{}, errors.Wrap(err, "failed to unmarshal yaml genesis to struct")
 	}
+	if consts := &genesis.Staking.VoteWeightCalConsts; consts.SelfStake != 0 {
+		log.L().Warn("selfStake is deprecated, use selfStakeBonusRate instead",
+			zap.Float64("selfStake", consts.SelfStake),
+			zap.Float64("selfStakeBonusRate", consts.SelfStake-1))
+		consts.SelfStakeBonusRate = consts.SelfStake - 1
+		consts.SelfStake = 0
+	}
 	return genesis, nil
 }
 
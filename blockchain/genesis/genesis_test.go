@@ -8,6 +8,9 @@ package genesis
 
 import (
 	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,6 +38,34 @@ func TestHash(t *testing.T) {
 	hash := cfg.Hash()
 	require.Equal("3dfcdee76186b59a9f9abd0ded8e6c093c35bddea23834044550fb68626adb62", hex.EncodeToString(hash[:]))
 }
+func TestSelfStakeDeprecated(t *testing.T) {
+	require := require.New(t)
+	genesisPath = filepath.Join(os.TempDir(), "genesis.yaml")
+	defer func() {
+		require.NoError(os.Remove(genesisPath))
+		genesisPath = ""
+	}()
+
+	require.NoError(ioutil.WriteFile(genesisPath, []byte(`
+staking:
+  voteWeightCalConsts:
+    selfStakeBonusRate: 0.1
+`), 0666))
+	cfg, err := New()
+	require.NoError(err)
+	require.Equal(0.1, cfg.Staking.VoteWeightCalConsts.SelfStakeBonusRate)
+
+	// the deprecated multiplier is mapped to the bonus rate, rather than ignored
+	require.NoError(ioutil.WriteFile(genesisPath, []byte(`
+staking:
+  voteWeightCalConsts:
+    selfStake: 1.2
+`), 0666))
+	cfg, err = New()
+	require.NoError(err)
+	require.InDelta(0.2, cfg.Staking.VoteWeightCalConsts.SelfStakeBonusRate, 1e-9)
+	require.Zero(cfg.Staking.VoteWeightCalConsts.SelfStake)
+}
 func TestAccount_InitBalances(t *testing.T) {
 	require := require.New(t)
 	InitBalanceMap := make(map[string]string, 0)
@@ -237,7 +237,12 @@ func New(
 		stakingProtocol *staking.Protocol
 	)
 	if cfg.Chain.EnableStakingProtocol {
-		stakingProtocol, err = staking.NewProtocol(rewarding.DepositGas, sf, cfg.Genesis.Staking)
+		stakingProtocol, err = staking.NewProtocol(
+			rewarding.DepositGas,
+			sf,
+			cfg.Genesis.Staking,
+			staking.GreenlandHeightOption(cfg.Genesis.GreenlandBlockHeight),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -22,6 +22,7 @@ const (
 	Daytona
 	Easter
 	Fairbank
+	Greenland
 )
 
 type (
@@ -43,6 +44,7 @@ type (
 		daytonaHeight     uint64
 		easterHeight      uint64
 		fairbankHeight    uint64
+		greenlandHeight   uint64
 	}
 )
 
@@ -57,6 +59,7 @@ func NewHeightUpgrade(cfg *genesis.Genesis) HeightUpgrade {
 		cfg.DaytonaBlockHeight,
 		cfg.EasterBlockHeight,
 		cfg.FairbankBlockHeight,
+		cfg.GreenlandBlockHeight,
 	}
 }
 
@@ -80,6 +83,8 @@ func (hu *HeightUpgrade) IsPost(name HeightName, height uint64) bool {
 		h = hu.easterHeight
 	case Fairbank:
 		h = hu.fairbankHeight
+	case Greenland:
+		h = hu.greenlandHeight
 	default:
 		log.Panic("invalid height name!")
 	}
@@ -114,3 +119,6 @@ func (hu *HeightUpgrade) EasterBlockHeight() uint64 { return hu.easterHeight }
 
 // FairbankBlockHeight returns the fairbank height
 func (hu *HeightUpgrade) FairbankBlockHeight() uint64 { return hu.fairbankHeight }
+
+// GreenlandBlockHeight returns the greenland height
+func (hu *HeightUpgrade) GreenlandBlockHeight() uint64 { return hu.greenlandHeight }
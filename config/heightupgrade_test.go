@@ -23,6 +23,7 @@ func TestNewHeightChange(t *testing.T) {
 	require.Equal(5, Daytona)
 	require.Equal(6, Easter)
 	require.Equal(7, Fairbank)
+	require.Equal(8, Greenland)
 
 	cfg := Default
 	cfg.Genesis.PacificBlockHeight = uint64(432001)
@@ -44,6 +45,8 @@ func TestNewHeightChange(t *testing.T) {
 	require.True(hu.IsPost(Easter, uint64(4200841)))
 	require.True(hu.IsPre(Fairbank, uint64(4339080)))
 	require.True(hu.IsPost(Fairbank, uint64(4339081)))
+	require.True(hu.IsPre(Greenland, uint64(6544440)))
+	require.True(hu.IsPost(Greenland, uint64(6544441)))
 	require.Panics(func() {
 		hu.IsPost(-1, 0)
 	})
@@ -56,4 +59,5 @@ func TestNewHeightChange(t *testing.T) {
 	require.Equal(hu.DaytonaBlockHeight(), uint64(3238921))
 	require.Equal(hu.EasterBlockHeight(), uint64(4200841))
 	require.Equal(hu.FairbankBlockHeight(), uint64(4339081))
+	require.Equal(hu.GreenlandBlockHeight(), uint64(6544441))
 }
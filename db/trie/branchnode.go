@@ -16,19 +16,21 @@ import (
 const radix = 256
 
 type branchNode struct {
+	ver    byte
 	hashes map[byte][]byte
 	ser    []byte
+	hash   []byte
 }
 
-func newEmptyBranchNode() *branchNode {
-	return &branchNode{hashes: map[byte][]byte{}}
+func newEmptyBranchNode(ver byte) *branchNode {
+	return &branchNode{ver: ver, hashes: map[byte][]byte{}}
 }
 
 func newBranchNodeAndPutIntoDB(
 	tr Trie,
 	children map[byte]Node,
 ) (*branchNode, error) {
-	bnode := newEmptyBranchNode()
+	bnode := newEmptyBranchNode(tr.nodeVersion())
 	for i, n := range children {
 		if n == nil {
 			continue
@@ -41,8 +43,8 @@ func newBranchNodeAndPutIntoDB(
 	return bnode, nil
 }
 
-func newBranchNodeFromProtoPb(pb *triepb.BranchPb) *branchNode {
-	b := newEmptyBranchNode()
+func newBranchNodeFromProtoPb(pb *triepb.BranchPb, ver byte) *branchNode {
+	b := newEmptyBranchNode(ver)
 	for _, n := range pb.Branches {
 		b.hashes[byte(n.Index)] = n.Path
 	}
@@ -126,16 +128,16 @@ func (b *branchNode) delete(tr Trie, key keyType, offset uint8) (Node, error) {
 	}
 }
 
-func (b *branchNode) upsert(tr Trie, key keyType, offset uint8, value []byte) (Node, error) {
+func (b *branchNode) upsert(tr Trie, key keyType, offset uint8, value []byte, expiry uint64) (Node, error) {
 	trieMtc.WithLabelValues("branchNode", "upsert").Inc()
 	var newChild Node
 	offsetKey := key[offset]
 	child, err := b.child(tr, offsetKey)
 	switch errors.Cause(err) {
 	case nil:
-		newChild, err = child.upsert(tr, key, offset+1, value)
+		newChild, err = child.upsert(tr, key, offset+1, value, expiry)
 	case ErrNotExist:
-		newChild, err = newLeafNodeAndPutIntoDB(tr, key, value)
+		newChild, err = newLeafNodeAndPutIntoDB(tr, key, value, expiry)
 	}
 	if err != nil {
 		return nil, err
@@ -144,11 +146,14 @@ func (b *branchNode) upsert(tr Trie, key keyType, offset uint8, value []byte) (N
 	return b.updateChild(tr, offsetKey, newChild)
 }
 
-func (b *branchNode) search(tr Trie, key keyType, offset uint8) Node {
+func (b *branchNode) search(tr Trie, key keyType, offset uint8) (Node, error) {
 	trieMtc.WithLabelValues("branchNode", "search").Inc()
+	if _, ok := b.hashes[key[offset]]; !ok {
+		return nil, nil
+	}
 	child, err := b.child(tr, key[offset])
-	if errors.Cause(err) == ErrNotExist {
-		return nil
+	if err != nil {
+		return nil, err
 	}
 	return child.search(tr, key, offset+1)
 }
@@ -173,11 +178,23 @@ func (b *branchNode) serialize() []byte {
 	if err != nil {
 		panic("failed to marshal a branch node")
 	}
-	b.ser = ser
+	b.ser = versionedSer(b.ver, ser)
 
 	return b.ser
 }
 
+func (b *branchNode) version() byte {
+	return b.ver
+}
+
+func (b *branchNode) cachedHash() []byte {
+	return b.hash
+}
+
+func (b *branchNode) setCachedHash(h []byte) {
+	b.hash = h
+}
+
 func (b *branchNode) child(tr Trie, key byte) (Node, error) {
 	h, ok := b.hashes[key]
 	if !ok {
@@ -185,23 +202,28 @@ func (b *branchNode) child(tr Trie, key byte) (Node, error) {
 	}
 	child, err := tr.loadNodeFromDB(h)
 	if err != nil {
-		return nil, errors.Errorf("failed to fetch node for key %x", h)
+		return nil, errors.Wrapf(err, "failed to fetch node for key %x", h)
 	}
 	return child, nil
 }
 
+// updateChild returns a new branch node with the child of key replaced, b itself is not modified such that it can be
+// read concurrently
 func (b *branchNode) updateChild(tr Trie, key byte, child Node) (*branchNode, error) {
 	if err := tr.deleteNodeFromDB(b); err != nil {
 		return nil, err
 	}
-	b.ser = nil
-	if child == nil {
-		delete(b.hashes, key)
-	} else {
-		b.hashes[key] = tr.nodeHash(child)
+	bnode := newEmptyBranchNode(tr.nodeVersion())
+	for i, h := range b.hashes {
+		if i != key {
+			bnode.hashes[i] = h
+		}
+	}
+	if child != nil {
+		bnode.hashes[key] = tr.nodeHash(child)
 	}
-	if err := tr.putNodeIntoDB(b); err != nil {
+	if err := tr.putNodeIntoDB(bnode); err != nil {
 		return nil, err
 	}
-	return b, nil
+	return bnode, nil
 }
@@ -10,23 +10,65 @@ import (
 	"bytes"
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/golang/groupcache/lru"
 	"github.com/golang/protobuf/proto"
 	"github.com/iotexproject/iotex-core/db/trie/triepb"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
+// the approximate bytes taken in memory besides the node data, by a node struct and by an entry of a map
+const (
+	branchNodeOverhead = 128
+	cacheEntryOverhead = 64
+)
+
+// DefaultNodeCacheSize is the max number of the preloaded nodes cached by default
+const DefaultNodeCacheSize = 1 << 16
+
 type (
 	// HashFunc defines a function to generate the hash which will be used as key in db
 	HashFunc       func([]byte) []byte
 	branchRootTrie struct {
-		mutex     sync.RWMutex
-		keyLength int
-		kvStore   KVStore
-		hashFunc  HashFunc
-		root      *branchNode
-		rootHash  []byte
-		rootKey   string
+		mutex      sync.RWMutex
+		keyLength  int
+		kvStore    KVStore
+		hashFunc   HashFunc
+		hashFuncs  map[byte]HashFunc
+		version    byte
+		emptyRoots map[byte][]byte
+		keyPrefix  []byte
+		root       *branchNode
+		rootHash   []byte
+		rootKey    string
+		// fallbackStore is read on a miss of kvStore, backfillMutex serializes the writes of the nodes read from it
+		// into kvStore, as nodes are read concurrently on preload
+		fallbackStore KVStore
+		backfillMutex sync.Mutex
+		// mutationStore is the store the mutations write the nodes to, which wraps kvStore while a mutation is
+		// applied to log or defer its writes. kvStore itself is never replaced, as Get reads it concurrently
+		mutationStore KVStore
+		// wal enables the write-ahead log of the mutations
+		wal bool
+		// verifyOnLoad enables the check of the hash of each node loaded against its key
+		verifyOnLoad bool
+		// nodeCache caches the serialized nodes by key, evicting the least recently used beyond nodeCacheSize nodes.
+		// cacheBytes is the estimated memory of the cached nodes
+		cacheMutex    sync.Mutex
+		nodeCache     *lru.Cache
+		nodeCacheSize int
+		cacheBytes    uint64
+		// snapshot holds the *rootSnapshot of the last root set, which is read by Get without locking while a
+		// writer builds the next root. Nodes are not modified once created, so a root is a stable version of the trie
+		snapshot atomic.Value
+	}
+
+	rootSnapshot struct {
+		root     *branchNode
+		rootHash []byte
 	}
 )
 
@@ -44,14 +86,28 @@ func (tr *branchRootTrie) Start(ctx context.Context) error {
 		}
 	}
 
-	return tr.SetRootHash(tr.rootHash)
+	if err := tr.SetRootHash(tr.rootHash); err != nil {
+		return err
+	}
+	if tr.wal {
+		return tr.recoverWAL()
+	}
+	return nil
 }
 
 func (tr *branchRootTrie) Stop(_ context.Context) error {
+	tr.cacheMutex.Lock()
+	tr.nodeCache = nil
+	tr.cacheBytes = 0
+	tr.cacheMutex.Unlock()
+	tr.reportMemory()
 	return nil
 }
 
 func (tr *branchRootTrie) RootHash() []byte {
+	if snap, ok := tr.snapshot.Load().(*rootSnapshot); ok {
+		return snap.rootHash
+	}
 	return tr.rootHash
 }
 
@@ -73,23 +129,37 @@ func (tr *branchRootTrie) SetRootHash(rootHash []byte) error {
 }
 
 func (tr *branchRootTrie) IsEmpty() bool {
-	return tr.isEmptyRootHash(tr.rootHash)
+	return tr.isEmptyRootHash(tr.RootHash())
 }
 
+// Get reads the value of the key under the last root set, it may be called concurrently with the mutations
 func (tr *branchRootTrie) Get(key []byte) ([]byte, error) {
 	trieMtc.WithLabelValues("root", "Get").Inc()
 	kt, err := tr.checkKeyType(key)
 	if err != nil {
 		return nil, err
 	}
-	t := tr.root.search(tr, kt, 0)
-	if t == nil {
-		return nil, ErrNotExist
-	}
-	if l, ok := t.(*leafNode); ok {
-		return l.Value(), nil
+	for {
+		snap, ok := tr.snapshot.Load().(*rootSnapshot)
+		if !ok {
+			return nil, errors.Wrap(ErrInvalidTrie, "trie is not started")
+		}
+		t, err := snap.root.search(tr, kt, 0)
+		if err != nil {
+			// the nodes replaced by a concurrent mutation are deleted, search again under the new root
+			if errors.Cause(err) == ErrNotExist && tr.snapshot.Load().(*rootSnapshot) != snap {
+				continue
+			}
+			return nil, err
+		}
+		if t == nil {
+			return nil, ErrNotExist
+		}
+		if l, ok := t.(*leafNode); ok {
+			return l.Value(), nil
+		}
+		return nil, ErrInvalidTrie
 	}
-	return nil, ErrInvalidTrie
 }
 
 func (tr *branchRootTrie) Delete(key []byte) error {
@@ -98,39 +168,131 @@ func (tr *branchRootTrie) Delete(key []byte) error {
 	if err != nil {
 		return err
 	}
-	child, err := tr.root.child(tr, kt[0])
-	if err != nil {
-		return errors.Wrapf(ErrNotExist, "key %x does not exist", kt)
-	}
-	newChild, err := child.delete(tr, kt, 1)
-	if err != nil {
-		return err
-	}
-	newRoot, err := tr.root.updateChild(tr, kt[0], newChild)
-	if err != nil {
-		return err
-	}
-	tr.resetRoot(newRoot)
+	return tr.applyWithWAL([]txOp{{key: kt, delete: true}}, func() error {
+		newRoot, err := tr.delete(kt)
+		if err != nil {
+			return err
+		}
+		tr.resetRoot(newRoot)
+		return nil
+	})
+}
 
-	return nil
+func (tr *branchRootTrie) DeleteIfExists(key []byte) (bool, error) {
+	switch err := tr.Delete(key); errors.Cause(err) {
+	case nil:
+		return true, nil
+	case ErrNotExist:
+		return false, nil
+	default:
+		return false, err
+	}
 }
 
 func (tr *branchRootTrie) Upsert(key []byte, value []byte) error {
+	return tr.UpsertWithExpiry(key, value, 0)
+}
+
+func (tr *branchRootTrie) UpsertWithExpiry(key []byte, value []byte, expiry uint64) error {
 	trieMtc.WithLabelValues("root", "Upsert").Inc()
 	kt, err := tr.checkKeyType(key)
 	if err != nil {
 		return err
 	}
-	newRoot, err := tr.root.upsert(tr, kt, 0, value)
-	if err != nil {
-		return err
+	return tr.applyWithWAL([]txOp{{key: kt, value: value, expiry: expiry}}, func() error {
+		newRoot, err := tr.upsert(kt, value, expiry)
+		if err != nil {
+			return err
+		}
+		tr.resetRoot(newRoot)
+		return nil
+	})
+}
+
+func (tr *branchRootTrie) SweepExpired(height uint64) (int, error) {
+	trieMtc.WithLabelValues("root", "SweepExpired").Inc()
+	var (
+		expired []keyType
+		stack   = []Node{tr.root}
+	)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if l, ok := n.(*leafNode); ok {
+			if l.expiry != 0 && l.expiry < height {
+				expired = append(expired, l.key)
+			}
+			continue
+		}
+		children, err := n.children(tr)
+		if err != nil {
+			return 0, err
+		}
+		stack = append(stack, children...)
 	}
-	bn, ok := newRoot.(*branchNode)
-	if !ok {
-		panic("unexpected new root")
+	for _, key := range expired {
+		if err := tr.Delete(key); err != nil {
+			return 0, errors.Wrapf(err, "failed to delete expired key %x", key)
+		}
 	}
-	tr.resetRoot(bn)
+	return len(expired), nil
+}
 
+func (tr *branchRootTrie) Preload(keys [][]byte) error {
+	trieMtc.WithLabelValues("root", "Preload").Inc()
+	kts := make([]keyType, len(keys))
+	for i, key := range keys {
+		kt, err := tr.checkKeyType(key)
+		if err != nil {
+			return err
+		}
+		kts[i] = kt
+	}
+	var g errgroup.Group
+	for _, kt := range kts {
+		kt := kt
+		g.Go(func() error {
+			return tr.preloadPath(kt)
+		})
+	}
+	err := g.Wait()
+	tr.reportMemory()
+	return err
+}
+
+// preloadPath caches the nodes along the path of the key, until the key is found or the path ends
+func (tr *branchRootTrie) preloadPath(key keyType) error {
+	var (
+		n      Node = tr.root
+		offset int
+	)
+	for offset < len(key) {
+		var h []byte
+		switch node := n.(type) {
+		case *branchNode:
+			h = node.hashes[key[offset]]
+			offset++
+		case *extensionNode:
+			if !bytes.HasPrefix(key[offset:], node.path) {
+				return nil
+			}
+			h = node.childHash
+			offset += len(node.path)
+		default:
+			return nil
+		}
+		if h == nil {
+			return nil
+		}
+		s, err := tr.nodeData(h)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get key %x", h)
+		}
+		tr.cacheNode(tr.nodeKey(h), s)
+		if n, err = tr.decodeNode(h, s); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -138,8 +300,112 @@ func (tr *branchRootTrie) DB() KVStore {
 	return tr.kvStore
 }
 
+func (tr *branchRootTrie) KeyLength() int {
+	return tr.keyLength
+}
+
+func (tr *branchRootTrie) HashFuncName() string {
+	if tr.version != LegacyNodeVersion {
+		return hashFuncName(tr.hashFuncs[tr.version])
+	}
+	return hashFuncName(tr.hashFunc)
+}
+
+func (tr *branchRootTrie) EstimatedMemoryBytes() uint64 {
+	var size uint64
+	if snap, ok := tr.snapshot.Load().(*rootSnapshot); ok {
+		root := snap.root
+		size += branchNodeOverhead + uint64(len(root.ser)+len(root.hash))
+		for _, h := range root.hashes {
+			size += cacheEntryOverhead + uint64(len(h))
+		}
+	}
+	tr.cacheMutex.Lock()
+	defer tr.cacheMutex.Unlock()
+	return size + tr.cacheBytes
+}
+
+// cacheNode adds the serialized node of the key to nodeCache
+func (tr *branchRootTrie) cacheNode(key []byte, s []byte) {
+	tr.cacheMutex.Lock()
+	defer tr.cacheMutex.Unlock()
+	if tr.nodeCache == nil {
+		tr.nodeCache = lru.New(tr.nodeCacheSize)
+		tr.nodeCache.OnEvicted = func(k lru.Key, v interface{}) {
+			tr.cacheBytes -= cacheEntryOverhead + uint64(len(k.(string))+len(v.([]byte)))
+		}
+	}
+	k := string(key)
+	if _, ok := tr.nodeCache.Get(k); ok {
+		return
+	}
+	tr.nodeCache.Add(k, s)
+	tr.cacheBytes += cacheEntryOverhead + uint64(len(k)+len(s))
+}
+
+// reportMemory updates the memory gauge of the trie
+func (tr *branchRootTrie) reportMemory() {
+	trieMemoryMtc.WithLabelValues(tr.rootKey).Set(float64(tr.EstimatedMemoryBytes()))
+}
+
+func (tr *branchRootTrie) SharedNodeCount(rootA, rootB []byte) (int, int, int, error) {
+	nodesA, err := tr.reachableNodes(rootA)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	nodesB, err := tr.reachableNodes(rootB)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	shared := 0
+	for h := range nodesA {
+		if _, ok := nodesB[h]; ok {
+			shared++
+		}
+	}
+	return shared, len(nodesA) - shared, len(nodesB) - shared, nil
+}
+
+// reachableNodes returns the hashes of the nodes reachable from the root
+func (tr *branchRootTrie) reachableNodes(rootHash []byte) (map[string]struct{}, error) {
+	if len(rootHash) == 0 {
+		rootHash = tr.emptyRootHash()
+	}
+	var (
+		nodes = map[string]struct{}{}
+		stack = [][]byte{rootHash}
+	)
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := nodes[string(h)]; ok {
+			continue
+		}
+		nodes[string(h)] = struct{}{}
+		n, err := tr.loadNodeFromDB(h)
+		if err != nil {
+			return nil, err
+		}
+		switch node := n.(type) {
+		case *branchNode:
+			for _, c := range node.hashes {
+				stack = append(stack, c)
+			}
+		case *extensionNode:
+			stack = append(stack, node.childHash)
+		}
+	}
+	return nodes, nil
+}
+
 func (tr *branchRootTrie) deleteNodeFromDB(tn Node) error {
-	return tr.kvStore.Delete(tr.nodeHash(tn))
+	key := tr.nodeKey(tr.nodeHash(tn))
+	tr.cacheMutex.Lock()
+	if tr.nodeCache != nil {
+		tr.nodeCache.Remove(string(key))
+	}
+	tr.cacheMutex.Unlock()
+	return tr.mutationStore.Delete(key)
 }
 
 func (tr *branchRootTrie) putNodeIntoDB(tn Node) error {
@@ -148,53 +414,186 @@ func (tr *branchRootTrie) putNodeIntoDB(tn Node) error {
 		return nil
 	}
 	s := tn.serialize()
-	return tr.kvStore.Put(h, s)
+	start := time.Now()
+	if err := tr.mutationStore.Put(tr.nodeKey(h), s); err != nil {
+		return err
+	}
+	trieNodeMtc.WithLabelValues("putNanoseconds").Add(float64(time.Since(start)))
+	trieNodeMtc.WithLabelValues("put").Inc()
+	trieNodeMtc.WithLabelValues("putBytes").Add(float64(len(s)))
+	return nil
 }
 
 func (tr *branchRootTrie) loadNodeFromDB(key []byte) (Node, error) {
-	if tr.isEmptyRootHash(key) {
-		return newEmptyBranchNode(), nil
+	for ver, h := range tr.emptyRoots {
+		if bytes.Equal(key, h) {
+			return newEmptyBranchNode(ver), nil
+		}
 	}
-	s, err := tr.kvStore.Get(key)
+	s, err := tr.nodeData(key)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get key %x", key)
 	}
+	n, err := tr.decodeNode(key, s)
+	if err != nil {
+		return nil, err
+	}
+	if tr.verifyOnLoad {
+		hashFunc := tr.hashFunc
+		if ver := n.version(); ver != LegacyNodeVersion {
+			hashFunc = tr.hashFuncs[ver]
+		}
+		if h := hashFunc(s); !bytes.Equal(h, key) {
+			return nil, errors.Wrapf(ErrCorruptedTrie, "node of key %x has hash %x", key, h)
+		}
+	}
+	return n, nil
+}
+
+// nodeData returns the serialized node of the key, from the cache if preloaded
+func (tr *branchRootTrie) nodeData(key []byte) ([]byte, error) {
+	nk := tr.nodeKey(key)
+	tr.cacheMutex.Lock()
+	var (
+		v  interface{}
+		ok bool
+	)
+	if tr.nodeCache != nil {
+		v, ok = tr.nodeCache.Get(string(nk))
+	}
+	tr.cacheMutex.Unlock()
+	if ok {
+		return v.([]byte), nil
+	}
+	s, err := tr.kvStore.Get(nk)
+	if tr.fallbackStore == nil || errors.Cause(err) != ErrNotExist {
+		return s, err
+	}
+	if s, err = tr.fallbackStore.Get(nk); err != nil {
+		return nil, errors.Wrap(err, "failed to get node from fallback store")
+	}
+	tr.backfillMutex.Lock()
+	defer tr.backfillMutex.Unlock()
+	if err := tr.kvStore.Put(nk, s); err != nil {
+		return nil, errors.Wrap(err, "failed to backfill node from fallback store")
+	}
+	return s, nil
+}
+
+func (tr *branchRootTrie) decodeNode(key []byte, s []byte) (Node, error) {
+	ver := LegacyNodeVersion
+	if len(s) > 0 && s[0] <= MaxNodeVersion {
+		ver, s = s[0], s[1:]
+		if _, ok := tr.hashFuncs[ver]; !ok {
+			return nil, errors.Errorf("unsupported node version %d of key %x", ver, key)
+		}
+	}
 	pb := triepb.NodePb{}
 	if err := proto.Unmarshal(s, &pb); err != nil {
 		return nil, err
 	}
 	if pbBranch := pb.GetBranch(); pbBranch != nil {
-		return newBranchNodeFromProtoPb(pbBranch), nil
+		return newBranchNodeFromProtoPb(pbBranch, ver), nil
 	}
 	if pbLeaf := pb.GetLeaf(); pbLeaf != nil {
-		return newLeafNodeFromProtoPb(pbLeaf), nil
+		return newLeafNodeFromProtoPb(pbLeaf, ver), nil
 	}
 	if pbExtend := pb.GetExtend(); pbExtend != nil {
-		return newExtensionNodeFromProtoPb(pbExtend), nil
+		return newExtensionNodeFromProtoPb(pbExtend, ver), nil
 	}
 	return nil, errors.New("invalid node type")
 }
 
 func (tr *branchRootTrie) isEmptyRootHash(h []byte) bool {
-	return bytes.Equal(h, tr.emptyRootHash())
+	for _, root := range tr.emptyRoots {
+		if bytes.Equal(h, root) {
+			return true
+		}
+	}
+	return false
 }
 
 func (tr *branchRootTrie) emptyRootHash() []byte {
-	return tr.nodeHash(newEmptyBranchNode())
+	return tr.emptyRoots[tr.version]
+}
+
+func (tr *branchRootTrie) nodeVersion() byte {
+	return tr.version
 }
 
 func (tr *branchRootTrie) nodeHash(tn Node) []byte {
 	if tn == nil {
 		panic("unexpected nil node to hash")
 	}
-	return tr.hashFunc(tn.serialize())
+	if h := tn.cachedHash(); h != nil {
+		return h
+	}
+	var h []byte
+	s := tn.serialize()
+	start := time.Now()
+	if ver := tn.version(); ver != LegacyNodeVersion {
+		h = tr.hashFuncs[ver](s)
+	} else {
+		h = tr.hashFunc(s)
+	}
+	trieNodeMtc.WithLabelValues("hashNanoseconds").Add(float64(time.Since(start)))
+	trieNodeMtc.WithLabelValues("hash").Inc()
+	tn.setCachedHash(h)
+	return h
+}
+
+// nodeKey returns the key of a node in kvStore
+func (tr *branchRootTrie) nodeKey(h []byte) []byte {
+	if len(tr.keyPrefix) == 0 {
+		return h
+	}
+	key := make([]byte, 0, len(tr.keyPrefix)+len(h))
+	key = append(key, tr.keyPrefix...)
+	return append(key, h...)
+}
+
+func (tr *branchRootTrie) initEmptyRoots() {
+	tr.emptyRoots = map[byte][]byte{
+		LegacyNodeVersion: tr.nodeHash(newEmptyBranchNode(LegacyNodeVersion)),
+	}
+	for ver := range tr.hashFuncs {
+		tr.emptyRoots[ver] = tr.nodeHash(newEmptyBranchNode(ver))
+	}
+}
+
+// upsert inserts the entry under the current root and returns the new root, the root hash is not updated
+func (tr *branchRootTrie) upsert(kt keyType, value []byte, expiry uint64) (*branchNode, error) {
+	newRoot, err := tr.root.upsert(tr, kt, 0, value, expiry)
+	if err != nil {
+		return nil, err
+	}
+	bn, ok := newRoot.(*branchNode)
+	if !ok {
+		panic("unexpected new root")
+	}
+	return bn, nil
+}
+
+// delete deletes the entry under the current root and returns the new root, the root hash is not updated
+func (tr *branchRootTrie) delete(kt keyType) (*branchNode, error) {
+	child, err := tr.root.child(tr, kt[0])
+	if err != nil {
+		return nil, errors.Wrapf(ErrNotExist, "key %x does not exist", kt)
+	}
+	newChild, err := child.delete(tr, kt, 1)
+	if err != nil {
+		return nil, err
+	}
+	return tr.root.updateChild(tr, kt[0], newChild)
 }
 
+// resetRoot sets the new root, and publishes it to the readers
 func (tr *branchRootTrie) resetRoot(newRoot *branchNode) {
 	tr.root = newRoot
 	h := tr.nodeHash(newRoot)
 	tr.rootHash = make([]byte, len(h))
 	copy(tr.rootHash, h)
+	tr.snapshot.Store(&rootSnapshot{root: newRoot, rootHash: tr.rootHash})
 }
 
 func (tr *branchRootTrie) checkKeyType(key []byte) (keyType, error) {
@@ -0,0 +1,79 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/pkg/errors"
+)
+
+const _checkpointKeyPrefix = "checkpoint."
+
+// _checkpointListKey is the reserved key of the names of all checkpoints
+var _checkpointListKey = []byte("checkpoints")
+
+func checkpointKey(name string) []byte {
+	h := hash.Hash160b([]byte(name))
+	return append([]byte(_checkpointKeyPrefix), h[:]...)
+}
+
+func (tr *branchRootTrie) Checkpoint(name string) error {
+	if len(name) == 0 || bytes.IndexByte([]byte(name), 0) >= 0 {
+		return errors.Errorf("invalid checkpoint name %q", name)
+	}
+	names, err := tr.ListCheckpoints()
+	if err != nil {
+		return err
+	}
+	if err := tr.kvStore.Put(checkpointKey(name), tr.RootHash()); err != nil {
+		return errors.Wrapf(err, "failed to save checkpoint %s", name)
+	}
+	i := sort.SearchStrings(names, name)
+	if i < len(names) && names[i] == name {
+		return nil
+	}
+	names = append(names, "")
+	copy(names[i+1:], names[i:])
+	names[i] = name
+	return tr.kvStore.Put(_checkpointListKey, bytes.Join(stringsToBytes(names), []byte{0}))
+}
+
+func (tr *branchRootTrie) LoadCheckpoint(name string) error {
+	root, err := tr.kvStore.Get(checkpointKey(name))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get checkpoint %s", name)
+	}
+	return tr.SetRootHash(root)
+}
+
+func (tr *branchRootTrie) ListCheckpoints() ([]string, error) {
+	data, err := tr.kvStore.Get(_checkpointListKey)
+	switch errors.Cause(err) {
+	case nil:
+	case ErrNotExist:
+		return []string{}, nil
+	default:
+		return nil, errors.Wrap(err, "failed to get checkpoints")
+	}
+	parts := bytes.Split(data, []byte{0})
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		names = append(names, string(p))
+	}
+	return names, nil
+}
+
+func stringsToBytes(s []string) [][]byte {
+	b := make([][]byte, 0, len(s))
+	for _, str := range s {
+		b = append(b, []byte(str))
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveKVStore keeps the deleted nodes, such that old roots can still be loaded
+type archiveKVStore struct {
+	KVStore
+}
+
+func (s *archiveKVStore) Delete([]byte) error {
+	return nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	require := require.New(t)
+
+	trieDB := &archiveKVStore{newInMemKVStore()}
+	tr, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+
+	names, err := tr.ListCheckpoints()
+	require.NoError(err)
+	require.Empty(names)
+	require.Error(tr.Checkpoint(""))
+	require.Equal(ErrNotExist, errors.Cause(tr.LoadCheckpoint("genesis")))
+
+	require.NoError(tr.Checkpoint("genesis"))
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.Upsert(dog, testV[3]))
+	catRoot := tr.RootHash()
+	require.NoError(tr.Checkpoint("before-upgrade-v2"))
+	require.NoError(tr.Upsert(cat, []byte("kitten")))
+	require.NoError(tr.Delete(dog))
+	require.NoError(tr.Upsert(fox, testV[5]))
+	foxRoot := tr.RootHash()
+	require.NoError(tr.Checkpoint("after-upgrade-v2"))
+
+	names, err = tr.ListCheckpoints()
+	require.NoError(err)
+	require.Equal([]string{"after-upgrade-v2", "before-upgrade-v2", "genesis"}, names)
+
+	// load an older checkpoint and the view reverts
+	require.NoError(tr.LoadCheckpoint("before-upgrade-v2"))
+	require.Equal(catRoot, tr.RootHash())
+	v, err := tr.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+	v, err = tr.Get(dog)
+	require.NoError(err)
+	require.Equal(testV[3], v)
+	_, err = tr.Get(fox)
+	require.Equal(ErrNotExist, errors.Cause(err))
+
+	require.NoError(tr.LoadCheckpoint("genesis"))
+	require.True(tr.IsEmpty())
+	_, err = tr.Get(cat)
+	require.Equal(ErrNotExist, errors.Cause(err))
+
+	require.NoError(tr.LoadCheckpoint("after-upgrade-v2"))
+	require.Equal(foxRoot, tr.RootHash())
+	v, err = tr.Get(cat)
+	require.NoError(err)
+	require.Equal([]byte("kitten"), v)
+
+	// saving a checkpoint again overwrites it
+	require.NoError(tr.Checkpoint("genesis"))
+	names, err = tr.ListCheckpoints()
+	require.NoError(err)
+	require.Equal(3, len(names))
+	require.NoError(tr.LoadCheckpoint("before-upgrade-v2"))
+	require.NoError(tr.LoadCheckpoint("genesis"))
+	require.Equal(foxRoot, tr.RootHash())
+
+	// checkpoints are persisted in the KVStore
+	tr, err = NewTrie(KVStoreOption(trieDB), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	names, err = tr.ListCheckpoints()
+	require.NoError(err)
+	require.Equal(3, len(names))
+	require.NoError(tr.LoadCheckpoint("before-upgrade-v2"))
+	require.Equal(catRoot, tr.RootHash())
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Export writes the leaves of the trie to w, one record per leaf made of the uvarint length of the key, the key, the
+// uvarint length of the value, the value and the uvarint expiry. The nodes are loaded one path at a time, such that
+// the memory used does not grow with the size of the trie
+func (tr *branchRootTrie) Export(w io.Writer) error {
+	var (
+		bw    = bufio.NewWriter(w)
+		buf   = make([]byte, binary.MaxVarintLen64)
+		stack = [][]byte{tr.RootHash()}
+	)
+	writeUvarint := func(x uint64) error {
+		_, err := bw.Write(buf[:binary.PutUvarint(buf, x)])
+		return err
+	}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n, err := tr.loadNodeFromDB(h)
+		if err != nil {
+			return err
+		}
+		switch node := n.(type) {
+		case *branchNode:
+			for _, c := range node.hashes {
+				stack = append(stack, c)
+			}
+		case *extensionNode:
+			stack = append(stack, node.childHash)
+		case *leafNode:
+			if err := writeUvarint(uint64(len(node.key))); err != nil {
+				return errors.Wrap(err, "failed to export leaf")
+			}
+			if _, err := bw.Write(node.key); err != nil {
+				return errors.Wrap(err, "failed to export leaf")
+			}
+			if err := writeUvarint(uint64(len(node.value))); err != nil {
+				return errors.Wrap(err, "failed to export leaf")
+			}
+			if _, err := bw.Write(node.value); err != nil {
+				return errors.Wrap(err, "failed to export leaf")
+			}
+			if err := writeUvarint(node.expiry); err != nil {
+				return errors.Wrap(err, "failed to export leaf")
+			}
+		}
+	}
+	return errors.Wrap(bw.Flush(), "failed to export leaf")
+}
+
+// Import upserts the leaves read from r, in the format written by Export, into the trie. Importing into an empty
+// trie the stream exported from another one rebuilds that trie with the same root hash
+func (tr *branchRootTrie) Import(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		keyLen, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read key length")
+		}
+		if keyLen != uint64(tr.keyLength) {
+			return errors.Wrapf(ErrInvalidTrie, "invalid key length %d", keyLen)
+		}
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(br, key); err != nil {
+			return errors.Wrap(err, "failed to read key")
+		}
+		valueLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read value length of key %x", key)
+		}
+		value := make([]byte, valueLen)
+		if _, err := io.ReadFull(br, value); err != nil {
+			return errors.Wrapf(err, "failed to read value of key %x", key)
+		}
+		expiry, err := binary.ReadUvarint(br)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read expiry of key %x", key)
+		}
+		if err := tr.UpsertWithExpiry(key, value, expiry); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	require := require.New(t)
+
+	newTrie := func() Trie {
+		tr, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(8))
+		require.NoError(err)
+		require.NoError(tr.Start(context.Background()))
+		return tr
+	}
+
+	// an empty trie exports nothing
+	tr := newTrie()
+	var buf bytes.Buffer
+	require.NoError(tr.Export(&buf))
+	require.Zero(buf.Len())
+
+	for i, k := range [][]byte{ham, car, cat, rat, egg, dog, fox, cow} {
+		require.NoError(tr.Upsert(k, testV[i]))
+	}
+	require.NoError(tr.UpsertWithExpiry(ant, []byte("expiring"), 100))
+	require.NoError(tr.Export(&buf))
+	data := buf.Bytes()
+
+	tr1 := newTrie()
+	require.NoError(tr1.Import(bytes.NewReader(data)))
+	require.Equal(tr.RootHash(), tr1.RootHash())
+	for i, k := range [][]byte{ham, car, cat, rat, egg, dog, fox, cow} {
+		v, err := tr1.Get(k)
+		require.NoError(err)
+		require.Equal(testV[i], v)
+	}
+	n, err := tr1.SweepExpired(101)
+	require.NoError(err)
+	require.Equal(1, n)
+
+	// a truncated stream
+	tr2 := newTrie()
+	require.Error(tr2.Import(bytes.NewReader(data[:len(data)-1])))
+
+	// keys of a different length
+	tr3, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(4))
+	require.NoError(err)
+	require.NoError(tr3.Start(context.Background()))
+	require.Equal(ErrInvalidTrie, errors.Cause(tr3.Import(bytes.NewReader(data))))
+}
@@ -14,9 +14,11 @@ import (
 
 // extensionNode defines a node with a path and point to a child node
 type extensionNode struct {
+	ver       byte
 	path      []byte
 	childHash []byte
 	ser       []byte
+	hash      []byte
 }
 
 func newExtensionNodeAndPutIntoDB(
@@ -24,15 +26,15 @@ func newExtensionNodeAndPutIntoDB(
 	path []byte,
 	child Node,
 ) (*extensionNode, error) {
-	e := &extensionNode{path: path, childHash: tr.nodeHash(child)}
+	e := &extensionNode{ver: tr.nodeVersion(), path: path, childHash: tr.nodeHash(child)}
 	if err := tr.putNodeIntoDB(e); err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func newExtensionNodeFromProtoPb(pb *triepb.ExtendPb) *extensionNode {
-	return &extensionNode{path: pb.Path, childHash: pb.Value}
+func newExtensionNodeFromProtoPb(pb *triepb.ExtendPb, ver byte) *extensionNode {
+	return &extensionNode{ver: ver, path: pb.Path, childHash: pb.Value}
 }
 
 func (e *extensionNode) Type() NodeType {
@@ -90,7 +92,7 @@ func (e *extensionNode) delete(tr Trie, key keyType, offset uint8) (Node, error)
 	}
 }
 
-func (e *extensionNode) upsert(tr Trie, key keyType, offset uint8, value []byte) (Node, error) {
+func (e *extensionNode) upsert(tr Trie, key keyType, offset uint8, value []byte, expiry uint64) (Node, error) {
 	trieMtc.WithLabelValues("extensionNode", "upsert").Inc()
 	matched := e.commonPrefixLength(key[offset:])
 	if matched == uint8(len(e.path)) {
@@ -98,7 +100,7 @@ func (e *extensionNode) upsert(tr Trie, key keyType, offset uint8, value []byte)
 		if err != nil {
 			return nil, err
 		}
-		newChild, err := child.upsert(tr, key, offset+matched, value)
+		newChild, err := child.upsert(tr, key, offset+matched, value, expiry)
 		if err != nil {
 			return nil, err
 		}
@@ -109,7 +111,7 @@ func (e *extensionNode) upsert(tr Trie, key keyType, offset uint8, value []byte)
 	if err != nil {
 		return nil, err
 	}
-	lnode, err := newLeafNodeAndPutIntoDB(tr, key, value)
+	lnode, err := newLeafNodeAndPutIntoDB(tr, key, value, expiry)
 	if err != nil {
 		return nil, err
 	}
@@ -129,15 +131,15 @@ func (e *extensionNode) upsert(tr Trie, key keyType, offset uint8, value []byte)
 	return newExtensionNodeAndPutIntoDB(tr, key[offset:offset+matched], bnode)
 }
 
-func (e *extensionNode) search(tr Trie, key keyType, offset uint8) Node {
+func (e *extensionNode) search(tr Trie, key keyType, offset uint8) (Node, error) {
 	trieMtc.WithLabelValues("extensionNode", "search").Inc()
 	matched := e.commonPrefixLength(key[offset:])
 	if matched != uint8(len(e.path)) {
-		return nil
+		return nil, nil
 	}
 	child, err := e.child(tr)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	return child.search(tr, key, offset+matched)
@@ -160,11 +162,23 @@ func (e *extensionNode) serialize() []byte {
 	if err != nil {
 		panic("failed to marshal an extension node")
 	}
-	e.ser = ser
+	e.ser = versionedSer(e.ver, ser)
 
 	return e.ser
 }
 
+func (e *extensionNode) version() byte {
+	return e.ver
+}
+
+func (e *extensionNode) cachedHash() []byte {
+	return e.hash
+}
+
+func (e *extensionNode) setCachedHash(h []byte) {
+	e.hash = h
+}
+
 func (e *extensionNode) child(tr Trie) (Node, error) {
 	return tr.loadNodeFromDB(e.childHash)
 }
@@ -177,22 +191,20 @@ func (e *extensionNode) updatePath(tr Trie, path []byte) (*extensionNode, error)
 	if err := tr.deleteNodeFromDB(e); err != nil {
 		return nil, err
 	}
-	e.path = path
-	e.ser = nil
-	if err := tr.putNodeIntoDB(e); err != nil {
+	enode := &extensionNode{ver: tr.nodeVersion(), path: path, childHash: e.childHash}
+	if err := tr.putNodeIntoDB(enode); err != nil {
 		return nil, err
 	}
-	return e, nil
+	return enode, nil
 }
 
 func (e *extensionNode) updateChild(tr Trie, newChild Node) (*extensionNode, error) {
 	if err := tr.deleteNodeFromDB(e); err != nil {
 		return nil, err
 	}
-	e.childHash = tr.nodeHash(newChild)
-	e.ser = nil
-	if err := tr.putNodeIntoDB(e); err != nil {
+	enode := &extensionNode{ver: tr.nodeVersion(), path: e.path, childHash: tr.nodeHash(newChild)}
+	if err := tr.putNodeIntoDB(enode); err != nil {
 		return nil, err
 	}
-	return e, nil
+	return enode, nil
 }
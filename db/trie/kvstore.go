@@ -10,17 +10,20 @@ import (
 	"context"
 )
 
-// KVStore defines an interface for storing trie data as key-value pair
+// KVStore defines an interface for storing trie data as key-value pair. A store is used by one trie at a time, and
+// must be started before any other method is called. The trie writes a node under the hash of its content, so the
+// same key may be put or deleted more than once. To read the trie concurrently with its mutations, the store must be
+// safe for concurrent use
 type KVStore interface {
 	// Start starts the KVStore
 	Start(context.Context) error
 	// Stop stops the KVStore
 	Stop(context.Context) error
-	// Put puts key, value pair into KVStore
+	// Put puts key, value pair into KVStore, overwriting the existing value of the key
 	Put([]byte, []byte) error
-	// Delete deletes record from KVStore by key
+	// Delete deletes record from KVStore by key, deleting a key which does not exist is not an error
 	Delete([]byte) error
-	// Get gets the value from KVStore by key
+	// Get gets the value from KVStore by key, the cause of the error is ErrNotExist if the key does not exist
 	Get([]byte) ([]byte, error)
 }
 
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/iotexproject/iotex-core/config"
+	"github.com/iotexproject/iotex-core/db"
+	"github.com/iotexproject/iotex-core/testutil"
+)
+
+// kvStoreBackend creates a KVStore to run the conformance tests and benchmarks against, along with a func to clean
+// it up. A new backend is added to the list to compare it with the others
+type kvStoreBackend struct {
+	name     string
+	newStore func(testing.TB) (KVStore, func())
+}
+
+var kvStoreBackends = []kvStoreBackend{
+	{"inmem", func(testing.TB) (KVStore, func()) {
+		return newInMemKVStore(), func() {}
+	}},
+	{"memdb", func(tb testing.TB) (KVStore, func()) {
+		store, err := NewKVStore("test", db.NewMemKVStore())
+		require.NoError(tb, err)
+		return store, func() {}
+	}},
+	{"boltdb", func(tb testing.TB) (KVStore, func()) {
+		testPath, err := testutil.PathOfTempFile("test-kvstore.bolt")
+		require.NoError(tb, err)
+		cfg := config.Default.DB
+		cfg.DbPath = testPath
+		store, err := NewKVStore("test", db.NewBoltDB(cfg))
+		require.NoError(tb, err)
+		return store, func() {
+			os.RemoveAll(testPath)
+		}
+	}},
+}
+
+func TestKVStoreConformance(t *testing.T) {
+	for _, backend := range kvStoreBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, cleanup := backend.newStore(t)
+			defer cleanup()
+			testKVStoreConformance(t, store)
+		})
+	}
+}
+
+// testKVStoreConformance checks that the store follows the contract of KVStore
+func testKVStoreConformance(t *testing.T, store KVStore) {
+	require := require.New(t)
+	ctx := context.Background()
+	require.NoError(store.Start(ctx))
+	defer func() {
+		require.NoError(store.Stop(ctx))
+	}()
+
+	k1, k2 := []byte("key1"), []byte("key2")
+	// a missing key
+	_, err := store.Get(k1)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	require.NoError(store.Delete(k1))
+
+	require.NoError(store.Put(k1, []byte("value1")))
+	require.NoError(store.Put(k2, []byte("value2")))
+	v, err := store.Get(k1)
+	require.NoError(err)
+	require.Equal([]byte("value1"), v)
+
+	// put overwrites the existing value
+	require.NoError(store.Put(k1, []byte("value3")))
+	v, err = store.Get(k1)
+	require.NoError(err)
+	require.Equal([]byte("value3"), v)
+
+	// delete does not affect the other keys, and deleting twice is not an error
+	require.NoError(store.Delete(k1))
+	_, err = store.Get(k1)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	require.NoError(store.Delete(k1))
+	v, err = store.Get(k2)
+	require.NoError(err)
+	require.Equal([]byte("value2"), v)
+
+	// a trie on top of the store
+	tr, err := NewTrie(KVStoreOption(store), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	keys := preloadTestKeys(100)
+	for _, k := range keys {
+		require.NoError(tr.Upsert(k, k))
+	}
+	for _, k := range keys[:50] {
+		require.NoError(tr.Delete(k))
+	}
+	for i, k := range keys {
+		v, err := tr.Get(k)
+		if i < 50 {
+			require.Equal(ErrNotExist, errors.Cause(err))
+			continue
+		}
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+}
+
+func BenchmarkKVStore(b *testing.B) {
+	for _, backend := range kvStoreBackends {
+		b.Run(backend.name, func(b *testing.B) {
+			store, cleanup := backend.newStore(b)
+			defer cleanup()
+			benchmarkKVStore(b, store)
+		})
+	}
+}
+
+// benchmarkKVStore runs the standard workload of a trie on top of the store, each op upserts, gets and deletes a
+// batch of random keys
+func benchmarkKVStore(b *testing.B, store KVStore) {
+	const batchSize = 100
+	ctx := context.Background()
+	if err := store.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		if err := store.Stop(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}()
+	tr, err := NewTrie(KVStoreOption(store), KeyLengthOption(8))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := tr.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(0))
+	keys := make([][]byte, batchSize)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range keys {
+			keys[i] = make([]byte, 8)
+			r.Read(keys[i])
+			if err := tr.Upsert(keys[i], keys[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for _, k := range keys {
+			if _, err := tr.Get(k); err != nil {
+				b.Fatal(err)
+			}
+		}
+		// keep half of the keys, such that the trie grows over the iterations
+		for _, k := range keys[:batchSize/2] {
+			if err := tr.Delete(k); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -15,25 +15,29 @@ import (
 )
 
 type leafNode struct {
-	key   keyType
-	value []byte
-	ser   []byte
+	ver    byte
+	key    keyType
+	value  []byte
+	expiry uint64
+	ser    []byte
+	hash   []byte
 }
 
 func newLeafNodeAndPutIntoDB(
 	tr Trie,
 	key keyType,
 	value []byte,
+	expiry uint64,
 ) (*leafNode, error) {
-	l := &leafNode{key: key, value: value}
+	l := &leafNode{ver: tr.nodeVersion(), key: key, value: value, expiry: expiry}
 	if err := tr.putNodeIntoDB(l); err != nil {
 		return nil, err
 	}
 	return l, nil
 }
 
-func newLeafNodeFromProtoPb(pb *triepb.LeafPb) *leafNode {
-	return &leafNode{key: pb.Path, value: pb.Value}
+func newLeafNodeFromProtoPb(pb *triepb.LeafPb, ver byte) *leafNode {
+	return &leafNode{ver: ver, key: pb.Path, value: pb.Value, expiry: pb.Expiry}
 }
 
 func (l *leafNode) Type() NodeType {
@@ -63,13 +67,13 @@ func (l *leafNode) delete(tr Trie, key keyType, offset uint8) (Node, error) {
 	return nil, nil
 }
 
-func (l *leafNode) upsert(tr Trie, key keyType, offset uint8, value []byte) (Node, error) {
+func (l *leafNode) upsert(tr Trie, key keyType, offset uint8, value []byte, expiry uint64) (Node, error) {
 	trieMtc.WithLabelValues("leafNode", "upsert").Inc()
 	matched := commonPrefixLength(l.key[offset:], key[offset:])
 	if offset+matched == uint8(len(key)) {
-		return l.updateValue(tr, value)
+		return l.updateValue(tr, value, expiry)
 	}
-	newl, err := newLeafNodeAndPutIntoDB(tr, key, value)
+	newl, err := newLeafNodeAndPutIntoDB(tr, key, value, expiry)
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +94,13 @@ func (l *leafNode) upsert(tr Trie, key keyType, offset uint8, value []byte) (Nod
 	return newExtensionNodeAndPutIntoDB(tr, l.key[offset:offset+matched], bnode)
 }
 
-func (l *leafNode) search(_ Trie, key keyType, offset uint8) Node {
+func (l *leafNode) search(_ Trie, key keyType, offset uint8) (Node, error) {
 	trieMtc.WithLabelValues("leafNode", "search").Inc()
 	if !bytes.Equal(l.key[offset:], key[offset:]) {
-		return nil
+		return nil, nil
 	}
 
-	return l
+	return l, nil
 }
 
 func (l *leafNode) serialize() []byte {
@@ -107,8 +111,9 @@ func (l *leafNode) serialize() []byte {
 	pb := &triepb.NodePb{
 		Node: &triepb.NodePb_Leaf{
 			Leaf: &triepb.LeafPb{
-				Path:  l.key[:],
-				Value: l.value,
+				Path:   l.key[:],
+				Value:  l.value,
+				Expiry: l.expiry,
 			},
 		},
 	}
@@ -116,20 +121,31 @@ func (l *leafNode) serialize() []byte {
 	if err != nil {
 		panic("failed to marshal a leaf node")
 	}
-	l.ser = ser
+	l.ser = versionedSer(l.ver, ser)
 
 	return l.ser
 }
 
-func (l *leafNode) updateValue(tr Trie, value []byte) (*leafNode, error) {
+func (l *leafNode) version() byte {
+	return l.ver
+}
+
+func (l *leafNode) cachedHash() []byte {
+	return l.hash
+}
+
+func (l *leafNode) setCachedHash(h []byte) {
+	l.hash = h
+}
+
+func (l *leafNode) updateValue(tr Trie, value []byte, expiry uint64) (*leafNode, error) {
 	if err := tr.deleteNodeFromDB(l); err != nil {
 		return nil, err
 	}
-	l.value = value
-	l.ser = nil
-	if err := tr.putNodeIntoDB(l); err != nil {
+	lnode := &leafNode{ver: tr.nodeVersion(), key: l.key, value: value, expiry: expiry}
+	if err := tr.putNodeIntoDB(lnode); err != nil {
 		return nil, err
 	}
 
-	return l, nil
+	return lnode, nil
 }
@@ -8,6 +8,9 @@ package trie
 
 import (
 	"context"
+	"io"
+	"reflect"
+	"runtime"
 
 	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/pkg/errors"
@@ -22,10 +25,29 @@ var (
 		},
 		[]string{"node", "type"},
 	)
+	// trieNodeMtc counts the nodes hashed and written, along with the time spent, to tell whether the mutations are
+	// bound by hashing or by writing into the KVStore
+	trieNodeMtc = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iotex_trie_node",
+			Help: "IoTeX Trie node hashing and writing",
+		},
+		[]string{"type"},
+	)
+	// trieMemoryMtc reports the estimated memory footprint of the nodes held in memory, by the root key of the trie
+	trieMemoryMtc = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iotex_trie_memory_bytes",
+			Help: "IoTeX Trie estimated memory footprint",
+		},
+		[]string{"trie"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(trieMtc)
+	prometheus.MustRegister(trieNodeMtc)
+	prometheus.MustRegister(trieMemoryMtc)
 }
 
 var (
@@ -34,14 +56,33 @@ var (
 
 	// ErrNotExist indicates entry does not exist
 	ErrNotExist = errors.New("not exist in trie")
+
+	// ErrCorruptedTrie indicates the data of a node does not match its key
+	ErrCorruptedTrie = errors.New("corrupted trie node")
 )
 
+// DefaultHashFuncName is the name reported for DefaultHashFunc
+const DefaultHashFuncName = "default"
+
 // DefaultHashFunc implements a default hash function
 func DefaultHashFunc(data []byte) []byte {
 	h := hash.Hash160b(data)
 	return h[:]
 }
 
+// hashFuncName returns DefaultHashFuncName for DefaultHashFunc, or the full name of the function otherwise. Function
+// literals are named after the function enclosing them, like "pkg.Func.func1"
+func hashFuncName(hashFunc HashFunc) string {
+	pc := reflect.ValueOf(hashFunc).Pointer()
+	if pc == reflect.ValueOf(DefaultHashFunc).Pointer() {
+		return DefaultHashFuncName
+	}
+	if f := runtime.FuncForPC(pc); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
 // Trie is the interface of Merkle Patricia Trie
 type Trie interface {
 	// Start starts the trie and the corresponding dependencies
@@ -50,18 +91,50 @@ type Trie interface {
 	Stop(context.Context) error
 	// Upsert inserts a new entry
 	Upsert([]byte, []byte) error
+	// UpsertWithExpiry inserts a new entry which expires after the given height, 0 means never expire
+	UpsertWithExpiry([]byte, []byte, uint64) error
 	// Get retrieves an existing entry
 	Get([]byte) ([]byte, error)
 	// Delete deletes an entry
 	Delete([]byte) error
+	// DeleteIfExists deletes an entry, and returns false if the entry does not exist
+	DeleteIfExists([]byte) (bool, error)
+	// SweepExpired deletes the entries expired at the given height, and returns the number of them
+	SweepExpired(uint64) (int, error)
+	// Begin starts a transaction of mutations which are applied to the trie all together
+	Begin() (TrieTx, error)
+	// Preload loads the nodes along the paths of the keys into memory, such that following access to the keys
+	// does not read the KVStore
+	Preload([][]byte) error
 	// RootHash returns trie's root hash
 	RootHash() []byte
 	// SetRootHash sets a new root to trie
 	SetRootHash([]byte) error
+	// Checkpoint saves the current root hash under the given name
+	Checkpoint(string) error
+	// LoadCheckpoint sets the root to the one saved under the given name, the nodes of that root must have been
+	// kept in the KVStore
+	LoadCheckpoint(string) error
+	// ListCheckpoints returns the names of the saved checkpoints in lexical order
+	ListCheckpoints() ([]string, error)
 	// IsEmpty returns true is this is an empty trie
 	IsEmpty() bool
 	// DB returns the KVStore storing the node data
 	DB() KVStore
+	// KeyLength returns the length of the keys of the trie
+	KeyLength() int
+	// HashFuncName returns the name of the hash func of the nodes written into the trie, DefaultHashFuncName if it
+	// is DefaultHashFunc
+	HashFuncName() string
+	// EstimatedMemoryBytes returns an estimate of the memory held by the nodes loaded into memory, that is the root
+	// and the nodes cached by Preload. Nodes read by Get are not kept, so they do not count
+	EstimatedMemoryBytes() uint64
+	// SharedNodeCount returns the number of nodes reachable from both roots, and those reachable from only one of them
+	SharedNodeCount(rootA, rootB []byte) (shared, uniqueA, uniqueB int, err error)
+	// Export streams the leaves of the trie to the writer
+	Export(io.Writer) error
+	// Import upserts the leaves streamed by Export into the trie
+	Import(io.Reader) error
 	// deleteNodeFromDB deletes the data of node from db
 	deleteNodeFromDB(tn Node) error
 	// putNodeIntoDB puts the data of a node into db
@@ -72,6 +145,8 @@ type Trie interface {
 	isEmptyRootHash([]byte) bool
 	// emptyRootHash returns hash of an empty root
 	emptyRootHash() []byte
+	// nodeVersion returns the version of nodes written into trie
+	nodeVersion() byte
 	// nodeHash returns the hash of a node
 	nodeHash(tn Node) []byte
 }
@@ -135,6 +210,39 @@ func HashFuncOption(hashFunc HashFunc) Option {
 	}
 }
 
+// NodeHashFuncOption sets the hash func for the nodes of given version, such that nodes hashed by
+// different hash funcs could coexist in the same trie
+func NodeHashFuncOption(version byte, hashFunc HashFunc) Option {
+	return func(tr Trie) error {
+		if version == LegacyNodeVersion || version > MaxNodeVersion {
+			return errors.Errorf("invalid node version %d", version)
+		}
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.hashFuncs[version] = hashFunc
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
+// NodeVersionOption sets the version of the nodes written into the trie
+func NodeVersionOption(version byte) Option {
+	return func(tr Trie) error {
+		if version > MaxNodeVersion {
+			return errors.Errorf("invalid node version %d", version)
+		}
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.version = version
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
 // KVStoreOption sets the kvStore for the trie
 func KVStoreOption(kvStore KVStore) Option {
 	return func(tr Trie) error {
@@ -148,23 +256,105 @@ func KVStoreOption(kvStore KVStore) Option {
 	}
 }
 
+// NamespacePrefixOption sets the prefix of the keys of nodes stored in kvStore, which isolates the
+// nodes of the tries sharing one kvStore
+func NamespacePrefixOption(prefix []byte) Option {
+	return func(tr Trie) error {
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.keyPrefix = make([]byte, len(prefix))
+			copy(t.keyPrefix, prefix)
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
+// FallbackStoreOption sets a secondary kvStore to read the nodes missing from kvStore, the nodes read from it are
+// written into kvStore such that subsequent reads are local
+func FallbackStoreOption(fallback KVStore) Option {
+	return func(tr Trie) error {
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.fallbackStore = fallback
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
+// WALOption enables the write-ahead log of the mutations, such that Start recovers the trie to a consistent root after
+// a crash in the middle of writing the nodes. With the log enabled, the new root is saved under the root key after
+// each mutation
+func WALOption() Option {
+	return func(tr Trie) error {
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.wal = true
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
+// VerifyOnLoadOption enables the check of each node loaded from kvStore, whose hash must equal the key it is loaded
+// by, otherwise ErrCorruptedTrie is returned. It costs a hash per node loaded, and is for an untrusted kvStore
+func VerifyOnLoadOption() Option {
+	return func(tr Trie) error {
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.verifyOnLoad = true
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
+// NodeCacheSizeOption sets the max number of the preloaded nodes cached, the least recently used nodes are evicted
+// beyond it
+func NodeCacheSizeOption(size int) Option {
+	return func(tr Trie) error {
+		if size <= 0 {
+			return errors.Errorf("invalid node cache size %d", size)
+		}
+		switch t := tr.(type) {
+		case *branchRootTrie:
+			t.nodeCacheSize = size
+		default:
+			return errors.New("invalid trie type")
+		}
+		return nil
+	}
+}
+
 // NewTrie creates a trie with DB filename
 func NewTrie(options ...Option) (Trie, error) {
 	t := &branchRootTrie{
-		keyLength: 20,
-		hashFunc:  DefaultHashFunc,
+		keyLength:     20,
+		hashFunc:      DefaultHashFunc,
+		hashFuncs:     map[byte]HashFunc{},
+		nodeCacheSize: DefaultNodeCacheSize,
 	}
 	for _, opt := range options {
 		if err := opt(t); err != nil {
 			return nil, err
 		}
 	}
+	if _, ok := t.hashFuncs[t.version]; t.version != LegacyNodeVersion && !ok {
+		return nil, errors.Errorf("hash func of node version %d is not set", t.version)
+	}
+	t.initEmptyRoots()
 	if t.rootHash == nil {
 		t.rootHash = t.emptyRootHash()
 	}
 	if t.kvStore == nil {
 		t.kvStore = newInMemKVStore()
 	}
+	t.mutationStore = t.kvStore
 
 	return t, nil
 }
@@ -7,14 +7,18 @@
 package trie
 
 import (
+	"bytes"
 	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/iotexproject/iotex-core/testutil"
 
 	"github.com/pkg/errors"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 
 	"github.com/iotexproject/go-pkgs/hash"
@@ -639,3 +643,1037 @@ func TestPressure(t *testing.T) {
 	require.NoError(tr.Stop(context.Background()))
 	t.Logf("Warning: test %d entries", c)
 }
+
+func TestMixedNodeVersion(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	hash256 := func(data []byte) []byte {
+		h := hash.Hash256b(data)
+		return h[:]
+	}
+
+	// invalid version options
+	_, err := NewTrie(NodeHashFuncOption(LegacyNodeVersion, hash256))
+	require.Error(err)
+	_, err = NewTrie(NodeHashFuncOption(MaxNodeVersion+1, hash256))
+	require.Error(err)
+	_, err = NewTrie(NodeVersionOption(1))
+	require.Error(err)
+
+	// default version keeps the legacy serialization
+	trieDB := newInMemKVStore()
+	tr, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.Upsert(dog, testV[3]))
+	legacyRoot := tr.RootHash()
+	root, err := tr.loadNodeFromDB(legacyRoot)
+	require.NoError(err)
+	require.Equal(LegacyNodeVersion, root.version())
+	require.True(root.serialize()[0] > MaxNodeVersion)
+	require.Equal(DefaultHashFunc(root.serialize()), legacyRoot)
+	require.NoError(tr.Stop(ctx))
+
+	// open the same trie writing nodes of version 1
+	tr1, err := NewTrie(
+		KVStoreOption(trieDB),
+		KeyLengthOption(8),
+		RootHashOption(legacyRoot),
+		NodeHashFuncOption(1, hash256),
+		NodeVersionOption(1),
+	)
+	require.NoError(err)
+	require.NoError(tr1.Start(ctx))
+	v, err := tr1.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+	require.NoError(tr1.Upsert(egg, testV[4]))
+	newRoot := tr1.RootHash()
+	root, err = tr1.loadNodeFromDB(newRoot)
+	require.NoError(err)
+	require.Equal(byte(1), root.version())
+	require.Equal(byte(1), root.serialize()[0])
+	require.Equal(hash256(root.serialize()), newRoot)
+	for _, e := range []struct {
+		k, v []byte
+	}{
+		{cat, testV[2]}, {dog, testV[3]}, {egg, testV[4]},
+	} {
+		v, err := tr1.Get(e.k)
+		require.NoError(err)
+		require.Equal(e.v, v)
+	}
+
+	// each node is hashed by the hash func of its own version
+	versions := map[byte]int{}
+	stack := [][]byte{newRoot}
+	for len(stack) > 0 {
+		key := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n, err := tr1.loadNodeFromDB(key)
+		require.NoError(err)
+		versions[n.version()]++
+		if n.version() == LegacyNodeVersion {
+			require.Equal(DefaultHashFunc(n.serialize()), key)
+		} else {
+			require.Equal(hash256(n.serialize()), key)
+		}
+		switch node := n.(type) {
+		case *branchNode:
+			for _, h := range node.hashes {
+				stack = append(stack, h)
+			}
+		case *extensionNode:
+			stack = append(stack, node.childHash)
+		}
+	}
+	require.True(versions[LegacyNodeVersion] > 0)
+	require.True(versions[1] > 0)
+
+	// a trie unaware of version 1 cannot load the new root
+	tr2, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8), RootHashOption(newRoot))
+	require.NoError(err)
+	require.Error(tr2.Start(ctx))
+
+	// deleting all entries results in an empty trie
+	require.NoError(tr1.Delete(cat))
+	require.NoError(tr1.Delete(dog))
+	require.NoError(tr1.Delete(egg))
+	require.True(tr1.IsEmpty())
+	require.NoError(tr1.Stop(ctx))
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	trieDB, err := NewKVStore("test", db.NewMemKVStore())
+	require.NoError(err)
+	require.NoError(trieDB.Start(ctx))
+	tr1, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8), NamespacePrefixOption([]byte("tr1")))
+	require.NoError(err)
+	require.NoError(tr1.Start(ctx))
+	tr2, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8), NamespacePrefixOption([]byte("tr2")))
+	require.NoError(err)
+	require.NoError(tr2.Start(ctx))
+
+	// same entries result in same nodes in both tries
+	for _, tr := range []Trie{tr1, tr2} {
+		require.NoError(tr.Upsert(cat, testV[2]))
+		require.NoError(tr.Upsert(dog, testV[3]))
+	}
+	require.Equal(tr1.RootHash(), tr2.RootHash())
+	root := tr1.RootHash()
+	_, err = trieDB.Get(root)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	_, err = trieDB.Get(append([]byte("tr1"), root...))
+	require.NoError(err)
+	_, err = trieDB.Get(append([]byte("tr2"), root...))
+	require.NoError(err)
+
+	// deleting from one trie does not disturb the other
+	require.NoError(tr1.Delete(cat))
+	require.NoError(tr1.Upsert(dog, testV[4]))
+	_, err = tr1.Get(cat)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	v, err := tr2.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+	v, err = tr2.Get(dog)
+	require.NoError(err)
+	require.Equal(testV[3], v)
+	require.Equal(root, tr2.RootHash())
+
+	// reload the other trie from db
+	tr3, err := NewTrie(KVStoreOption(trieDB), KeyLengthOption(8), NamespacePrefixOption([]byte("tr2")), RootHashOption(root))
+	require.NoError(err)
+	require.NoError(tr3.Start(ctx))
+	v, err = tr3.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+}
+
+func TestDeleteIfExists(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, err := NewTrie(KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	defer func() {
+		require.NoError(tr.Stop(ctx))
+	}()
+
+	// absent key in an empty trie
+	deleted, err := tr.DeleteIfExists(cat)
+	require.NoError(err)
+	require.False(deleted)
+	require.True(tr.isEmptyRootHash(tr.RootHash()))
+
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.Upsert(dog, testV[3]))
+	root := tr.RootHash()
+
+	// absent keys sharing a path with present keys do not change the trie
+	for _, key := range [][]byte{rat, egg, ant} {
+		deleted, err = tr.DeleteIfExists(key)
+		require.NoError(err)
+		require.False(deleted)
+		require.Equal(root, tr.RootHash())
+	}
+	// Delete keeps its strict behavior
+	require.Equal(ErrNotExist, errors.Cause(tr.Delete(rat)))
+
+	// present key
+	deleted, err = tr.DeleteIfExists(cat)
+	require.NoError(err)
+	require.True(deleted)
+	_, err = tr.Get(cat)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	v, err := tr.Get(dog)
+	require.NoError(err)
+	require.Equal(testV[3], v)
+
+	// deleting again is a no-op
+	deleted, err = tr.DeleteIfExists(cat)
+	require.NoError(err)
+	require.False(deleted)
+	deleted, err = tr.DeleteIfExists(dog)
+	require.NoError(err)
+	require.True(deleted)
+	require.True(tr.isEmptyRootHash(tr.RootHash()))
+}
+
+func TestSweepExpired(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, err := NewTrie(KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	defer func() {
+		require.NoError(tr.Stop(ctx))
+	}()
+
+	n, err := tr.SweepExpired(100)
+	require.NoError(err)
+	require.Zero(n)
+
+	// non-expiring entries
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.UpsertWithExpiry(ham, testV[0], 0))
+	root := tr.RootHash()
+	n, err = tr.SweepExpired(100)
+	require.NoError(err)
+	require.Zero(n)
+	require.Equal(root, tr.RootHash())
+
+	entries := []struct {
+		k      []byte
+		v      []byte
+		expiry uint64
+	}{
+		{car, testV[1], 10},
+		{rat, testV[2], 20},
+		{egg, testV[4], 30},
+		{dog, testV[3], 20},
+		{fox, testV[5], 40},
+	}
+	for _, e := range entries {
+		require.NoError(tr.UpsertWithExpiry(e.k, e.v, e.expiry))
+		v, err := tr.Get(e.k)
+		require.NoError(err)
+		require.Equal(e.v, v)
+	}
+	// upsert without expiry makes an entry never expire
+	require.NoError(tr.UpsertWithExpiry(cow, testV[6], 10))
+	require.NoError(tr.Upsert(cow, testV[6]))
+
+	for _, test := range []struct {
+		height uint64
+		swept  int
+		alive  [][]byte
+	}{
+		{10, 0, [][]byte{car, rat, egg, dog, fox}},
+		{11, 1, [][]byte{rat, egg, dog, fox}},
+		{21, 2, [][]byte{egg, fox}},
+		{21, 0, [][]byte{egg, fox}},
+		{1000, 2, nil},
+	} {
+		n, err := tr.SweepExpired(test.height)
+		require.NoError(err)
+		require.Equal(test.swept, n)
+		alive := make(map[string]bool)
+		for _, k := range test.alive {
+			alive[string(k)] = true
+		}
+		for _, e := range entries {
+			v, err := tr.Get(e.k)
+			if alive[string(e.k)] {
+				require.NoError(err)
+				require.Equal(e.v, v)
+			} else {
+				require.Equal(ErrNotExist, errors.Cause(err))
+			}
+		}
+		for _, k := range [][]byte{cat, ham, cow} {
+			_, err := tr.Get(k)
+			require.NoError(err)
+		}
+	}
+
+	// the trie of non-expiring entries is restored
+	require.NoError(tr.Delete(cow))
+	require.Equal(root, tr.RootHash())
+}
+
+type countingKVStore struct {
+	KVStore
+	gets     int64
+	puts     int64
+	putBytes int64
+}
+
+func (s *countingKVStore) Get(key []byte) ([]byte, error) {
+	atomic.AddInt64(&s.gets, 1)
+	return s.KVStore.Get(key)
+}
+
+func (s *countingKVStore) Put(key []byte, value []byte) error {
+	atomic.AddInt64(&s.puts, 1)
+	atomic.AddInt64(&s.putBytes, int64(len(value)))
+	return s.KVStore.Put(key, value)
+}
+
+func preloadTestKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		h := hash.Hash160b(byteutil.Uint64ToBytes(uint64(i)))
+		keys[i] = h[:8]
+	}
+	return keys
+}
+
+func TestPreload(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	keys := preloadTestKeys(1000)
+	// the tries do not share a KVStore, because updating one deletes the nodes from the KVStore
+	newTrie := func() (Trie, *countingKVStore) {
+		kv := &countingKVStore{KVStore: newInMemKVStore()}
+		tr, err := NewTrie(KeyLengthOption(8), KVStoreOption(kv))
+		require.NoError(err)
+		require.NoError(tr.Start(ctx))
+		for _, k := range keys {
+			require.NoError(tr.Upsert(k, k))
+		}
+		tr, err = NewTrie(KeyLengthOption(8), KVStoreOption(kv), RootHashOption(tr.RootHash()))
+		require.NoError(err)
+		require.NoError(tr.Start(ctx))
+		return tr, kv
+	}
+	preloaded, kv := newTrie()
+	plain, _ := newTrie()
+	defer func() {
+		require.NoError(preloaded.Stop(ctx))
+		require.NoError(plain.Stop(ctx))
+	}()
+
+	require.Error(preloaded.Preload([][]byte{{1, 2, 3}}))
+	// preload half of the keys and a key not in the trie
+	touched := append(keys[:500:500], []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	require.NoError(preloaded.Preload(touched))
+	atomic.StoreInt64(&kv.gets, 0)
+	for _, k := range keys[:500] {
+		v, err := preloaded.Get(k)
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+	_, err := preloaded.Get(touched[500])
+	require.Equal(ErrNotExist, errors.Cause(err))
+	require.Zero(atomic.LoadInt64(&kv.gets))
+	for _, k := range keys[500:] {
+		v, err := preloaded.Get(k)
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+	require.NotZero(atomic.LoadInt64(&kv.gets))
+
+	// updates give the same result with and without preload
+	for i, k := range touched {
+		v := []byte{byte(i)}
+		require.NoError(preloaded.Upsert(k, v))
+		require.NoError(plain.Upsert(k, v))
+		require.Equal(plain.RootHash(), preloaded.RootHash())
+	}
+	for _, k := range keys[:100] {
+		require.NoError(preloaded.Delete(k))
+		require.NoError(plain.Delete(k))
+		require.Equal(plain.RootHash(), preloaded.RootHash())
+	}
+	for _, k := range touched[100:] {
+		v1, err := preloaded.Get(k)
+		require.NoError(err)
+		v2, err := plain.Get(k)
+		require.NoError(err)
+		require.Equal(v2, v1)
+	}
+}
+
+func TestPreloadCacheBounded(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	keys := preloadTestKeys(1000)
+	kv := &countingKVStore{KVStore: newInMemKVStore()}
+	tr, err := NewTrie(KeyLengthOption(8), KVStoreOption(kv))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	for _, k := range keys {
+		require.NoError(tr.Upsert(k, k))
+	}
+	_, err = NewTrie(NodeCacheSizeOption(0))
+	require.Error(err)
+	tr, err = NewTrie(KeyLengthOption(8), KVStoreOption(kv), RootHashOption(tr.RootHash()), NodeCacheSizeOption(16))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	defer func() {
+		require.NoError(tr.Stop(ctx))
+	}()
+
+	base := tr.EstimatedMemoryBytes()
+	require.NoError(tr.Preload(keys))
+	brt := tr.(*branchRootTrie)
+	require.Equal(16, brt.nodeCache.Len())
+	size := tr.EstimatedMemoryBytes() - base
+	require.Equal(brt.cacheBytes, size)
+	// the evicted nodes are read from the KVStore
+	for _, k := range keys {
+		v, err := tr.Get(k)
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+	require.Equal(16, brt.nodeCache.Len())
+}
+
+func BenchmarkPreload(b *testing.B) {
+	ctx := context.Background()
+	kv := &countingKVStore{KVStore: newInMemKVStore()}
+	tr, err := NewTrie(KeyLengthOption(8), KVStoreOption(kv))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := tr.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	keys := preloadTestKeys(10000)
+	for _, k := range keys {
+		if err := tr.Upsert(k, k); err != nil {
+			b.Fatal(err)
+		}
+	}
+	root := tr.RootHash()
+	// the keys touched by a simulated block
+	touched := keys[:200]
+
+	for _, preload := range []bool{false, true} {
+		name := "nopreload"
+		if preload {
+			name = "preload"
+		}
+		b.Run(name, func(b *testing.B) {
+			var gets int64
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				tr, err := NewTrie(KeyLengthOption(8), KVStoreOption(kv), RootHashOption(root))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := tr.Start(ctx); err != nil {
+					b.Fatal(err)
+				}
+				if preload {
+					if err := tr.Preload(touched); err != nil {
+						b.Fatal(err)
+					}
+				}
+				atomic.StoreInt64(&kv.gets, 0)
+				b.StartTimer()
+				for _, k := range touched {
+					if _, err := tr.Get(k); err != nil {
+						b.Fatal(err)
+					}
+				}
+				gets += atomic.LoadInt64(&kv.gets)
+			}
+			b.ReportMetric(float64(gets)/float64(b.N), "gets/op")
+		})
+	}
+}
+
+func TestNodeHashCache(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	brt := tr.(*branchRootTrie)
+	keys := preloadTestKeys(300)
+	for i, k := range keys {
+		require.NoError(tr.Upsert(k, k))
+		if i%3 == 0 {
+			require.NoError(tr.Delete(keys[i/2]))
+			require.NoError(tr.Upsert(keys[i/2], k))
+		}
+		// the cached root hash matches the one computed from scratch
+		require.Equal(DefaultHashFunc(brt.root.serialize()), tr.RootHash())
+	}
+	for _, k := range keys[:100] {
+		require.NoError(tr.Delete(k))
+		require.Equal(DefaultHashFunc(brt.root.serialize()), tr.RootHash())
+	}
+
+	// a trie built from scratch in a different order has the same root
+	fresh, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(fresh.Start(ctx))
+	for i := len(keys) - 1; i >= 100; i-- {
+		v, err := tr.Get(keys[i])
+		require.NoError(err)
+		require.NoError(fresh.Upsert(keys[i], v))
+	}
+	require.Equal(fresh.RootHash(), tr.RootHash())
+	require.NoError(tr.Stop(ctx))
+	require.NoError(fresh.Stop(ctx))
+}
+
+func BenchmarkUpsertHashes(b *testing.B) {
+	ctx := context.Background()
+	var hashes int64
+	countingHash := func(data []byte) []byte {
+		atomic.AddInt64(&hashes, 1)
+		return DefaultHashFunc(data)
+	}
+	tr, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(8), HashFuncOption(countingHash))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := tr.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	keys := preloadTestKeys(10000)
+	for _, k := range keys {
+		if err := tr.Upsert(k, k); err != nil {
+			b.Fatal(err)
+		}
+	}
+	atomic.StoreInt64(&hashes, 0)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		k := keys[n%len(keys)]
+		if err := tr.Upsert(k, byteutil.Uint64ToBytes(uint64(n))); err != nil {
+			b.Fatal(err)
+		}
+		tr.RootHash()
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&hashes))/float64(b.N), "hashes/op")
+}
+
+func TestNodeMetrics(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	var hashes int64
+	countingHash := func(data []byte) []byte {
+		atomic.AddInt64(&hashes, 1)
+		return DefaultHashFunc(data)
+	}
+	kv := &countingKVStore{KVStore: newInMemKVStore()}
+	tr, err := NewTrie(KVStoreOption(kv), KeyLengthOption(8), HashFuncOption(countingHash))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	keys := preloadTestKeys(1111)
+	for _, k := range keys[:1000] {
+		require.NoError(tr.Upsert(k, k))
+	}
+
+	metric := func(typ string) int64 {
+		return int64(promtestutil.ToFloat64(trieNodeMtc.WithLabelValues(typ)))
+	}
+	var lastPuts int64
+	for _, batch := range [][][]byte{keys[1000:1001], keys[1001:1011], keys[1011:1111]} {
+		atomic.StoreInt64(&hashes, 0)
+		atomic.StoreInt64(&kv.puts, 0)
+		atomic.StoreInt64(&kv.putBytes, 0)
+		h, p, b := metric("hash"), metric("put"), metric("putBytes")
+		for _, k := range batch {
+			require.NoError(tr.Upsert(k, k))
+		}
+		// each dirty node is hashed and written, the metrics match the nodes actually hashed and written
+		require.Equal(atomic.LoadInt64(&hashes), metric("hash")-h)
+		require.Equal(atomic.LoadInt64(&kv.puts), metric("put")-p)
+		require.Equal(atomic.LoadInt64(&kv.putBytes), metric("putBytes")-b)
+		require.True(kv.puts > lastPuts)
+		lastPuts = kv.puts
+	}
+}
+
+func TestEstimatedMemoryBytes(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kv := newInMemKVStore()
+	tr, err := NewTrie(KeyLengthOption(8), KVStoreOption(kv))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	keys := preloadTestKeys(1000)
+	for _, k := range keys {
+		require.NoError(tr.Upsert(k, k))
+	}
+	tr, err = NewTrie(KeyLengthOption(8), KVStoreOption(kv), RootHashOption(tr.RootHash()))
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	gauge := func() uint64 {
+		return uint64(promtestutil.ToFloat64(trieMemoryMtc.WithLabelValues("")))
+	}
+
+	// only the root is in memory
+	rootOnly := tr.EstimatedMemoryBytes()
+	require.NotZero(rootOnly)
+	// the nodes read by Get are not kept
+	for _, k := range keys[:100] {
+		_, err := tr.Get(k)
+		require.NoError(err)
+	}
+	require.Equal(rootOnly, tr.EstimatedMemoryBytes())
+
+	// loading a subtree grows the estimate, and the gauge follows it
+	require.NoError(tr.Preload(keys[:100]))
+	subtree := tr.EstimatedMemoryBytes()
+	require.True(subtree > rootOnly)
+	require.Equal(subtree, gauge())
+	require.NoError(tr.Preload(keys[100:]))
+	require.True(tr.EstimatedMemoryBytes() > subtree)
+	for _, k := range keys {
+		v, err := tr.Get(k)
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+
+	// the cache is dropped on stop
+	require.NoError(tr.Stop(ctx))
+	require.Equal(rootOnly, tr.EstimatedMemoryBytes())
+	require.Equal(rootOnly, gauge())
+}
+
+func TestConcurrentGet(t *testing.T) {
+	mutate := func(tr Trie, key, value []byte) error {
+		if value == nil {
+			return tr.Delete(key)
+		}
+		return tr.Upsert(key, value)
+	}
+	// the mutations swap the store they write to while Get reads the trie
+	commit := func(tr Trie, key, value []byte) error {
+		tx, err := tr.Begin()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			err = tx.Delete(key)
+		} else {
+			err = tx.Upsert(key, value)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+	t.Run("mutation", func(t *testing.T) {
+		testConcurrentGet(t, mutate)
+	})
+	t.Run("walTx", func(t *testing.T) {
+		testConcurrentGet(t, commit, WALOption())
+	})
+}
+
+func testConcurrentGet(t *testing.T, mutate func(Trie, []byte, []byte) error, opts ...Option) {
+	require := require.New(t)
+	ctx := context.Background()
+	store, err := NewKVStore("test", db.NewMemKVStore())
+	require.NoError(err)
+	require.NoError(store.Start(ctx))
+	tr, err := NewTrie(append([]Option{KVStoreOption(store), KeyLengthOption(8)}, opts...)...)
+	require.NoError(err)
+	require.NoError(tr.Start(ctx))
+	keys := preloadTestKeys(400)
+	// the first half of the keys is not changed while reading
+	stable, changed := keys[:200], keys[200:]
+	for _, k := range stable {
+		require.NoError(tr.Upsert(k, k))
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+		errs = make(chan error, 4)
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, k := range stable {
+					v, err := tr.Get(k)
+					if err != nil {
+						errs <- err
+						return
+					}
+					if !bytes.Equal(k, v) {
+						errs <- errors.Errorf("unexpected value %x of key %x", v, k)
+						return
+					}
+				}
+				for _, k := range changed {
+					v, err := tr.Get(k)
+					if errors.Cause(err) == ErrNotExist {
+						continue
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					if !bytes.Equal(k, v) && !bytes.Equal(stable[0], v) {
+						errs <- errors.Errorf("unexpected value %x of key %x", v, k)
+						return
+					}
+				}
+			}
+		}()
+	}
+	// interleave upserts, updates and deletes with the reads
+	for i := 0; i < 3; i++ {
+		for _, k := range changed {
+			require.NoError(mutate(tr, k, k))
+		}
+		for _, k := range changed {
+			require.NoError(mutate(tr, k, stable[0]))
+		}
+		for _, k := range changed {
+			require.NoError(mutate(tr, k, nil))
+		}
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(err)
+	}
+	for _, k := range stable {
+		v, err := tr.Get(k)
+		require.NoError(err)
+		require.Equal(k, v)
+	}
+	require.NoError(store.Stop(ctx))
+}
+
+// readingKVStore reads the trie on every deletion, as a concurrent Get may between the deletion of a replaced node and
+// the publication of the new root
+type readingKVStore struct {
+	KVStore
+	read    func() error
+	deletes int
+	errs    []error
+}
+
+func (s *readingKVStore) Delete(key []byte) error {
+	if s.read != nil {
+		s.deletes++
+		if err := s.read(); err != nil {
+			s.errs = append(s.errs, err)
+		}
+	}
+	return s.KVStore.Delete(key)
+}
+
+func TestGetDuringMutation(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WALOption()}} {
+		require := require.New(t)
+		ctx := context.Background()
+		store, err := NewKVStore("test", db.NewMemKVStore())
+		require.NoError(err)
+		require.NoError(store.Start(ctx))
+		reading := &readingKVStore{KVStore: store}
+		tr, err := NewTrie(append([]Option{KVStoreOption(reading), KeyLengthOption(8)}, opts...)...)
+		require.NoError(err)
+		require.NoError(tr.Start(ctx))
+		keys := preloadTestKeys(100)
+		stable, changed := keys[:50], keys[50:]
+		for _, k := range stable {
+			require.NoError(tr.Upsert(k, k))
+		}
+
+		// the nodes replaced by a mutation are deleted only once the new root is published
+		reading.read = func() error {
+			for _, k := range stable {
+				v, err := tr.Get(k)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(k, v) {
+					return errors.Errorf("unexpected value %x of key %x", v, k)
+				}
+			}
+			return nil
+		}
+		for _, k := range changed {
+			require.NoError(tr.Upsert(k, k))
+		}
+		for _, k := range changed {
+			require.NoError(tr.Delete(k))
+		}
+		require.NotZero(reading.deletes)
+		require.Empty(reading.errs)
+		require.NoError(store.Stop(ctx))
+	}
+}
+
+func BenchmarkConcurrentGet(b *testing.B) {
+	ctx := context.Background()
+	store, err := NewKVStore("test", db.NewMemKVStore())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	tr, err := NewTrie(KVStoreOption(store), KeyLengthOption(8))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := tr.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	keys := preloadTestKeys(10000)
+	for _, k := range keys {
+		if err := tr.Upsert(k, k); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, writing := range []bool{false, true} {
+		name := "idle"
+		if writing {
+			name = "writing"
+		}
+		b.Run(name, func(b *testing.B) {
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			if writing {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for n := 0; ; n++ {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						if err := tr.Upsert(keys[n%len(keys)], byteutil.Uint64ToBytes(uint64(n))); err != nil {
+							panic(err)
+						}
+					}
+				}()
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for n := 0; pb.Next(); n++ {
+					if _, err := tr.Get(keys[n%len(keys)]); err != nil {
+						panic(err)
+					}
+				}
+			})
+			b.StopTimer()
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func hash256Func(data []byte) []byte {
+	h := hash.Hash256b(data)
+	return h[:]
+}
+
+func TestTrieAccessors(t *testing.T) {
+	require := require.New(t)
+
+	tr, err := NewTrie()
+	require.NoError(err)
+	require.Equal(20, tr.KeyLength())
+	require.Equal(DefaultHashFuncName, tr.HashFuncName())
+
+	tr, err = NewTrie(KeyLengthOption(8), HashFuncOption(hash256Func))
+	require.NoError(err)
+	require.Equal(8, tr.KeyLength())
+	require.Equal("github.com/iotexproject/iotex-core/db/trie.hash256Func", tr.HashFuncName())
+
+	// the hash func of the node version written into the trie is reported
+	tr, err = NewTrie(KeyLengthOption(32), NodeHashFuncOption(1, DefaultHashFunc), NodeVersionOption(1))
+	require.NoError(err)
+	require.Equal(32, tr.KeyLength())
+	require.Equal(DefaultHashFuncName, tr.HashFuncName())
+	tr, err = NewTrie(HashFuncOption(hash256Func), NodeHashFuncOption(1, func(data []byte) []byte {
+		return data
+	}), NodeVersionOption(1))
+	require.NoError(err)
+	require.True(strings.HasSuffix(tr.HashFuncName(), "TestTrieAccessors.func1"))
+}
+
+func TestFallbackStore(t *testing.T) {
+	require := require.New(t)
+
+	fallback := newInMemKVStore()
+	tr, err := NewTrie(KVStoreOption(fallback), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.Upsert(dog, testV[3]))
+	require.NoError(tr.Upsert(ant, testV[7]))
+	root := tr.RootHash()
+
+	// the nodes missing from the empty primary store are read from the fallback store
+	primary := newInMemKVStore()
+	tr, err = NewTrie(KVStoreOption(primary), KeyLengthOption(8), RootHashOption(root))
+	require.NoError(err)
+	require.Equal(ErrNotExist, errors.Cause(tr.Start(context.Background())))
+	tr, err = NewTrie(KVStoreOption(primary), FallbackStoreOption(fallback), KeyLengthOption(8), RootHashOption(root))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	v, err := tr.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+	v, err = tr.Get(dog)
+	require.NoError(err)
+	require.Equal(testV[3], v)
+	v, err = tr.Get(ant)
+	require.NoError(err)
+	require.Equal(testV[7], v)
+	_, err = tr.Get(fox)
+	require.Equal(ErrNotExist, errors.Cause(err))
+
+	// the primary store is backfilled, such that the trie can be read without the fallback store
+	tr, err = NewTrie(KVStoreOption(primary), KeyLengthOption(8), RootHashOption(root))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	v, err = tr.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+	v, err = tr.Get(ant)
+	require.NoError(err)
+	require.Equal(testV[7], v)
+
+	// a node missing from both stores
+	tr, err = NewTrie(KVStoreOption(newInMemKVStore()), FallbackStoreOption(newInMemKVStore()), KeyLengthOption(8),
+		RootHashOption(root))
+	require.NoError(err)
+	require.Equal(ErrNotExist, errors.Cause(tr.Start(context.Background())))
+}
+
+func TestSharedNodeCount(t *testing.T) {
+	require := require.New(t)
+
+	// keep the deleted nodes such that both roots can be traversed
+	tr, err := NewTrie(KVStoreOption(&archiveKVStore{newInMemKVStore()}), KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	for i, k := range [][]byte{ham, car, cat, rat, egg, dog, fox, cow} {
+		require.NoError(tr.Upsert(k, testV[i]))
+	}
+	rootA := tr.RootHash()
+	shared, uniqueA, uniqueB, err := tr.SharedNodeCount(rootA, rootA)
+	require.NoError(err)
+	require.Zero(uniqueA)
+	require.Zero(uniqueB)
+	total := shared
+
+	require.NoError(tr.Upsert(ant, testV[7]))
+	rootB := tr.RootHash()
+	shared, uniqueA, uniqueB, err = tr.SharedNodeCount(rootA, rootB)
+	require.NoError(err)
+	require.Equal(total, shared+uniqueA)
+	// only the root differs, and the new leaf is added
+	require.Equal(1, uniqueA)
+	require.Equal(2, uniqueB)
+	require.True(shared > uniqueA+uniqueB)
+
+	// a changed value replaces the nodes along its path only
+	require.NoError(tr.Upsert(cat, []byte("kitten")))
+	rootC := tr.RootHash()
+	shared, uniqueB, uniqueC, err := tr.SharedNodeCount(rootB, rootC)
+	require.NoError(err)
+	require.Equal(uniqueB, uniqueC)
+	require.True(shared > uniqueB+uniqueC)
+
+	_, _, _, err = tr.SharedNodeCount(rootA, []byte("not a root"))
+	require.Error(err)
+}
+
+func TestVerifyOnLoad(t *testing.T) {
+	require := require.New(t)
+
+	kvStore := newInMemKVStore()
+	tr, err := NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), VerifyOnLoadOption())
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	require.NoError(tr.Upsert(cat, testV[2]))
+	require.NoError(tr.Upsert(dog, testV[3]))
+	root := tr.RootHash()
+
+	// a trusted store passes the verification
+	tr, err = NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), RootHashOption(root), VerifyOnLoadOption())
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	v, err := tr.Get(cat)
+	require.NoError(err)
+	require.Equal(testV[2], v)
+
+	// tamper with the leaf of cat
+	leafHash := DefaultHashFunc((&leafNode{key: cat, value: testV[2]}).serialize())
+	_, err = kvStore.Get(leafHash)
+	require.NoError(err)
+	require.NoError(kvStore.Put(leafHash, (&leafNode{key: cat, value: []byte("kitten")}).serialize()))
+
+	// the tampered value is returned without verification
+	tr, err = NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), RootHashOption(root))
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	v, err = tr.Get(cat)
+	require.NoError(err)
+	require.Equal([]byte("kitten"), v)
+
+	tr, err = NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), RootHashOption(root), VerifyOnLoadOption())
+	require.NoError(err)
+	require.NoError(tr.Start(context.Background()))
+	_, err = tr.Get(cat)
+	require.Equal(ErrCorruptedTrie, errors.Cause(err))
+	v, err = tr.Get(dog)
+	require.NoError(err)
+	require.Equal(testV[3], v)
+
+	// a tampered root fails on start
+	rootData, err := kvStore.Get(root)
+	require.NoError(err)
+	require.NoError(kvStore.Put(root, append(rootData, 0)))
+	tr, err = NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), RootHashOption(root), VerifyOnLoadOption())
+	require.NoError(err)
+	require.Equal(ErrCorruptedTrie, errors.Cause(tr.Start(context.Background())))
+}
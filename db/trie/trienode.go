@@ -13,6 +13,14 @@ type (
 	NodeType int
 )
 
+const (
+	// LegacyNodeVersion is the version of a node serialized without version prefix
+	LegacyNodeVersion byte = 0
+	// MaxNodeVersion is the max version of a node. A legacy node always starts with a protobuf field tag
+	// which is larger than it, so that the version prefix can be told apart from a legacy node
+	MaxNodeVersion byte = 0x0f
+)
+
 const (
 	// BRANCH is an internal node type of length 1 and multiple child
 	BRANCH NodeType = iota + 1
@@ -33,11 +41,25 @@ type Node interface {
 	Value() []byte
 
 	children(Trie) ([]Node, error)
-	search(Trie, keyType, uint8) Node
+	// search returns the leaf of the key, or nil if the key does not exist
+	search(Trie, keyType, uint8) (Node, error)
 	delete(Trie, keyType, uint8) (Node, error)
-	upsert(Trie, keyType, uint8, []byte) (Node, error)
+	upsert(Trie, keyType, uint8, []byte, uint64) (Node, error)
 
 	serialize() []byte
+	version() byte
+	// cachedHash returns the hash of the node computed before, nil if there is none. A node is not modified once
+	// created, an update creates new nodes along the path of the mutation, so the hash of a node is computed once
+	cachedHash() []byte
+	setCachedHash([]byte)
+}
+
+// versionedSer prefixes the serialized node with its version, a legacy node is kept as is
+func versionedSer(ver byte, ser []byte) []byte {
+	if ver == LegacyNodeVersion {
+		return ser
+	}
+	return append([]byte{ver}, ser...)
 }
 
 // key1 should not be longer than key2
@@ -110,6 +110,7 @@ type LeafPb struct {
 	Ext                  uint32   `protobuf:"varint,1,opt,name=ext,proto3" json:"ext,omitempty"`
 	Path                 []byte   `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
 	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Expiry               uint64   `protobuf:"varint,4,opt,name=expiry,proto3" json:"expiry,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -161,6 +162,13 @@ func (m *LeafPb) GetValue() []byte {
 	return nil
 }
 
+func (m *LeafPb) GetExpiry() uint64 {
+	if m != nil {
+		return m.Expiry
+	}
+	return 0
+}
+
 type ExtendPb struct {
 	Path                 []byte   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
 	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
@@ -314,21 +322,22 @@ func init() {
 func init() { proto.RegisterFile("trie.proto", fileDescriptor_4a69962149106130) }
 
 var fileDescriptor_4a69962149106130 = []byte{
-	// 250 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0x31, 0x6b, 0xc3, 0x30,
-	0x10, 0x85, 0xa3, 0xd8, 0x15, 0xe1, 0x9c, 0x96, 0x20, 0x32, 0x68, 0x34, 0xa6, 0x83, 0xe9, 0x60,
-	0x8a, 0xdb, 0xa1, 0x43, 0xa7, 0xd2, 0x21, 0x53, 0x11, 0xfa, 0x07, 0xbe, 0xfa, 0x4a, 0x02, 0x41,
-	0x36, 0xae, 0x5a, 0xfc, 0x2b, 0xfa, 0x9b, 0x8b, 0x74, 0x71, 0x48, 0xc0, 0xdb, 0x3b, 0xfb, 0xbd,
-	0x77, 0xdf, 0x21, 0x00, 0x3f, 0x1c, 0xa8, 0xea, 0x87, 0xce, 0x77, 0x4a, 0x06, 0xdd, 0x63, 0xf1,
-	0x02, 0x6b, 0x1c, 0x1a, 0xf7, 0xb9, 0xff, 0xe8, 0x5a, 0x32, 0xa8, 0xb6, 0x70, 0x73, 0x70, 0x2d,
-	0x8d, 0x5a, 0xe4, 0xa2, 0xbc, 0xb5, 0x3c, 0x28, 0x05, 0x69, 0xdf, 0xf8, 0xbd, 0x5e, 0xe6, 0xa2,
-	0x5c, 0xdb, 0xa8, 0x8b, 0x57, 0x58, 0x71, 0xd2, 0xa0, 0x7a, 0x9c, 0x34, 0x7d, 0x6b, 0x91, 0x27,
-	0x65, 0x56, 0x6f, 0x2b, 0x5e, 0x50, 0x5d, 0xb6, 0xdb, 0xb3, 0xab, 0x78, 0x07, 0x79, 0xa4, 0xe6,
-	0xcb, 0xa0, 0xda, 0x40, 0x42, 0xa3, 0x3f, 0xed, 0x0b, 0x72, 0x6e, 0x5b, 0xe0, 0xfa, 0x6d, 0x8e,
-	0x3f, 0xa4, 0x93, 0xf8, 0x91, 0x87, 0xe2, 0x19, 0x56, 0x34, 0x7a, 0x72, 0xad, 0xc1, 0x73, 0x4a,
-	0xcc, 0xa5, 0x96, 0x97, 0xa9, 0x3f, 0x01, 0xd2, 0xf1, 0xb9, 0x0f, 0x20, 0x19, 0x29, 0x3a, 0xb2,
-	0x7a, 0x73, 0x8d, 0x6d, 0x70, 0xb7, 0xb0, 0x27, 0x87, 0xba, 0x87, 0x34, 0x20, 0x47, 0x82, 0xac,
-	0xbe, 0x9b, 0x9c, 0x7c, 0xc6, 0x6e, 0x61, 0xe3, 0xdf, 0xd0, 0xc8, 0x48, 0x3a, 0xbd, 0x6e, 0x9c,
-	0x40, 0x43, 0x23, 0xeb, 0x37, 0x09, 0x69, 0xe0, 0x40, 0x19, 0xdf, 0xe4, 0xe9, 0x3f, 0x00, 0x00,
-	0xff, 0xff, 0x75, 0x36, 0xfd, 0x25, 0xa1, 0x01, 0x00, 0x00,
+	// 259 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6d, 0x91, 0x41, 0x6b, 0x83, 0x40,
+	0x10, 0x85, 0x63, 0xdc, 0x2c, 0x32, 0xa6, 0x21, 0x2c, 0x21, 0x78, 0x2c, 0x4b, 0x0f, 0xa1, 0x07,
+	0x29, 0x36, 0x87, 0x1e, 0x7a, 0xca, 0x29, 0xa7, 0x12, 0xf6, 0x9c, 0x8b, 0x5b, 0xa7, 0x28, 0x04,
+	0x15, 0x6b, 0x82, 0xfd, 0x13, 0xf9, 0xcd, 0xd9, 0xdd, 0xd1, 0x60, 0x21, 0xb7, 0x37, 0xb3, 0x6f,
+	0xbe, 0x79, 0xa3, 0x00, 0x6d, 0x53, 0x60, 0x5c, 0x37, 0x55, 0x5b, 0x09, 0x6e, 0x75, 0xad, 0xe5,
+	0x07, 0xcc, 0x75, 0x93, 0x96, 0xdf, 0xf9, 0x57, 0x95, 0xe1, 0x41, 0x8b, 0x15, 0xcc, 0x8a, 0x32,
+	0xc3, 0x2e, 0xf2, 0x9e, 0xbd, 0xcd, 0x93, 0xa2, 0x42, 0x08, 0x60, 0x75, 0xda, 0xe6, 0xd1, 0xd4,
+	0x34, 0xe7, 0xca, 0x69, 0xf9, 0x09, 0x01, 0x4d, 0x9a, 0xa9, 0xb7, 0x41, 0xe3, 0xaf, 0x19, 0xf4,
+	0x37, 0x61, 0xb2, 0x8a, 0x69, 0x41, 0x3c, 0xa6, 0xab, 0xbb, 0x4b, 0x1e, 0x81, 0x9f, 0x30, 0xfd,
+	0x31, 0xb3, 0x4b, 0xf0, 0xb1, 0x6b, 0xfb, 0x7d, 0x56, 0x3e, 0xda, 0x66, 0x73, 0x5d, 0xd2, 0xd3,
+	0x19, 0x23, 0xdf, 0x35, 0xa9, 0x10, 0x6b, 0xe0, 0xd8, 0xd5, 0x45, 0xf3, 0x17, 0x31, 0xd3, 0x66,
+	0xaa, 0xaf, 0xe4, 0x16, 0x02, 0x03, 0xc2, 0x32, 0x33, 0xfc, 0x81, 0xe6, 0x3d, 0xa2, 0x4d, 0x47,
+	0x34, 0x79, 0xf5, 0x80, 0x97, 0xf4, 0x19, 0x5e, 0x81, 0x53, 0x54, 0xe7, 0x08, 0x93, 0xe5, 0xff,
+	0x73, 0x0e, 0x7a, 0x3f, 0x51, 0xbd, 0x43, 0xbc, 0x00, 0xb3, 0xa7, 0xb8, 0x64, 0x61, 0xb2, 0x18,
+	0x9c, 0x74, 0x9e, 0xf1, 0xb9, 0x57, 0x4b, 0xa4, 0x48, 0x2e, 0xea, 0x88, 0x38, 0x04, 0xb5, 0x44,
+	0xd2, 0x3b, 0x0e, 0xcc, 0xe6, 0xd0, 0xdc, 0xfd, 0xab, 0xf7, 0x1b, 0x36, 0xa5, 0x87, 0x6d, 0xb9,
+	0x01, 0x00, 0x00,
 }
@@ -0,0 +1,187 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrTxClosed indicates the transaction has been committed or rolled back
+var ErrTxClosed = errors.New("trie transaction is closed")
+
+type (
+	// TrieTx is a set of trie mutations which are applied all together on Commit, or discarded on Rollback
+	TrieTx interface {
+		// Upsert inserts a new entry on commit
+		Upsert([]byte, []byte) error
+		// Delete deletes an entry on commit
+		Delete([]byte) error
+		// Commit applies the mutations in order, if any of them fails, none is applied
+		Commit() error
+		// Rollback discards the mutations
+		Rollback() error
+	}
+
+	txOp struct {
+		key    keyType
+		value  []byte
+		expiry uint64
+		delete bool
+	}
+
+	trieTx struct {
+		tr     *branchRootTrie
+		ops    []txOp
+		closed bool
+	}
+
+	// journalKVStore records the original value of each key written through it, such that the writes can be undone
+	journalKVStore struct {
+		KVStore
+		keys   [][]byte
+		values map[string][]byte
+	}
+)
+
+func (tr *branchRootTrie) Begin() (TrieTx, error) {
+	if tr.root == nil {
+		return nil, errors.Wrap(ErrInvalidTrie, "trie is not started")
+	}
+	return &trieTx{tr: tr}, nil
+}
+
+func (tx *trieTx) Upsert(key []byte, value []byte) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	kt, err := tx.tr.checkKeyType(key)
+	if err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{key: kt, value: append([]byte(nil), value...)})
+	return nil
+}
+
+func (tx *trieTx) Delete(key []byte) error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	kt, err := tx.tr.checkKeyType(key)
+	if err != nil {
+		return err
+	}
+	tx.ops = append(tx.ops, txOp{key: kt, delete: true})
+	return nil
+}
+
+func (tx *trieTx) Commit() error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.closed = true
+	trieMtc.WithLabelValues("root", "Commit").Inc()
+	tr := tx.tr
+	rootHash := tr.rootHash
+	return tr.applyWithWAL(tx.ops, func() error {
+		journal := newJournalKVStore(tr.mutationStore)
+		tr.mutationStore = journal
+		defer func() { tr.mutationStore = journal.KVStore }()
+		for _, op := range tx.ops {
+			var (
+				newRoot *branchNode
+				err     error
+			)
+			if op.delete {
+				newRoot, err = tr.delete(op.key)
+			} else {
+				newRoot, err = tr.upsert(op.key, op.value, op.expiry)
+			}
+			if err != nil {
+				return tx.abort(journal, rootHash, err)
+			}
+			tr.root = newRoot
+		}
+		tr.resetRoot(tr.root)
+		return nil
+	})
+}
+
+func (tx *trieTx) Rollback() error {
+	if tx.closed {
+		return ErrTxClosed
+	}
+	tx.closed = true
+	tx.ops = nil
+	return nil
+}
+
+// abort undoes the node writes of a failed commit and restores the root, the root is reloaded from the restored store
+func (tx *trieTx) abort(journal *journalKVStore, rootHash []byte, cause error) error {
+	if err := journal.undo(); err != nil {
+		return errors.Wrapf(err, "failed to undo trie transaction aborted by %v", cause)
+	}
+	tx.tr.mutationStore = journal.KVStore
+	if err := tx.tr.SetRootHash(rootHash); err != nil {
+		return errors.Wrapf(err, "failed to restore root of trie transaction aborted by %v", cause)
+	}
+	return cause
+}
+
+func newJournalKVStore(kvStore KVStore) *journalKVStore {
+	return &journalKVStore{
+		KVStore: kvStore,
+		values:  make(map[string][]byte),
+	}
+}
+
+func (s *journalKVStore) Put(key []byte, value []byte) error {
+	if err := s.record(key); err != nil {
+		return err
+	}
+	return s.KVStore.Put(key, value)
+}
+
+func (s *journalKVStore) Delete(key []byte) error {
+	if err := s.record(key); err != nil {
+		return err
+	}
+	return s.KVStore.Delete(key)
+}
+
+func (s *journalKVStore) record(key []byte) error {
+	if _, ok := s.values[string(key)]; ok {
+		return nil
+	}
+	value, err := s.KVStore.Get(key)
+	switch errors.Cause(err) {
+	case nil:
+	case ErrNotExist:
+		value = nil
+	default:
+		return err
+	}
+	s.keys = append(s.keys, append([]byte(nil), key...))
+	s.values[string(key)] = value
+	return nil
+}
+
+func (s *journalKVStore) undo() error {
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		key := s.keys[i]
+		value := s.values[string(key)]
+		if value == nil {
+			if err := s.KVStore.Delete(key); err != nil && errors.Cause(err) != ErrNotExist {
+				return err
+			}
+			continue
+		}
+		if err := s.KVStore.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
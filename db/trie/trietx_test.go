@@ -0,0 +1,119 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieTx(t *testing.T) {
+	require := require.New(t)
+
+	newTrie := func() Trie {
+		tr, err := NewTrie(KVStoreOption(newInMemKVStore()), KeyLengthOption(8))
+		require.NoError(err)
+		require.NoError(tr.Start(context.Background()))
+		require.NoError(tr.Upsert(cat, testV[2]))
+		require.NoError(tr.Upsert(car, testV[1]))
+		require.NoError(tr.Upsert(egg, testV[4]))
+		return tr
+	}
+	ops := []struct {
+		key    []byte
+		value  []byte
+		delete bool
+	}{
+		{dog, testV[3], false},
+		{car, nil, true},
+		{ham, testV[0], false},
+		{cat, []byte("kitten"), false},
+		{egg, nil, true},
+		{fox, testV[5], false},
+	}
+
+	// apply the mutations one by one
+	seq := newTrie()
+	for _, op := range ops {
+		if op.delete {
+			require.NoError(seq.Delete(op.key))
+		} else {
+			require.NoError(seq.Upsert(op.key, op.value))
+		}
+	}
+
+	// apply the mutations in a transaction
+	tr := newTrie()
+	root := tr.RootHash()
+	tx, err := tr.Begin()
+	require.NoError(err)
+	for _, op := range ops {
+		if op.delete {
+			require.NoError(tx.Delete(op.key))
+		} else {
+			require.NoError(tx.Upsert(op.key, op.value))
+		}
+	}
+	// nothing is applied before commit
+	require.Equal(root, tr.RootHash())
+	_, err = tr.Get(dog)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	require.NoError(tx.Commit())
+	require.Equal(seq.RootHash(), tr.RootHash())
+	for _, op := range ops {
+		v, err := tr.Get(op.key)
+		if op.delete {
+			require.Equal(ErrNotExist, errors.Cause(err))
+			continue
+		}
+		require.NoError(err)
+		require.Equal(op.value, v)
+	}
+	require.Equal(ErrTxClosed, tx.Commit())
+	require.Equal(ErrTxClosed, tx.Upsert(cat, testV[2]))
+
+	// rollback discards the mutations
+	root = tr.RootHash()
+	tx, err = tr.Begin()
+	require.NoError(err)
+	require.NoError(tx.Upsert(ant, testV[7]))
+	require.NoError(tx.Delete(dog))
+	require.NoError(tx.Rollback())
+	require.Equal(ErrTxClosed, tx.Commit())
+	require.Equal(root, tr.RootHash())
+	_, err = tr.Get(ant)
+	require.Equal(ErrNotExist, errors.Cause(err))
+
+	// a failed mutation aborts the whole transaction
+	tx, err = tr.Begin()
+	require.NoError(err)
+	require.Error(tx.Upsert([]byte{1, 2, 3}, testV[0]))
+	require.NoError(tx.Upsert(ant, testV[7]))
+	require.NoError(tx.Delete(dog))
+	require.NoError(tx.Upsert(cat, testV[2]))
+	require.NoError(tx.Delete(car))
+	require.Equal(ErrNotExist, errors.Cause(tx.Commit()))
+	require.Equal(root, tr.RootHash())
+	_, err = tr.Get(ant)
+	require.Equal(ErrNotExist, errors.Cause(err))
+	for _, op := range ops {
+		v, err := tr.Get(op.key)
+		if op.delete {
+			require.Equal(ErrNotExist, errors.Cause(err))
+			continue
+		}
+		require.NoError(err)
+		require.Equal(op.value, v)
+	}
+	// the trie is still usable
+	require.NoError(tr.Upsert(ant, testV[7]))
+	require.NoError(seq.Upsert(ant, testV[7]))
+	require.Equal(seq.RootHash(), tr.RootHash())
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// _walKeyPrefix is the prefix of the key of the write-ahead log, followed by the root key of the trie
+const _walKeyPrefix = "wal."
+
+// walKey returns the key of the write-ahead log in kvStore
+func (tr *branchRootTrie) walKey() []byte {
+	return tr.nodeKey([]byte(_walKeyPrefix + tr.rootKey))
+}
+
+// deferredDeleteKVStore defers the deletions of nodes until the new root has been published, and saved with the
+// write-ahead log, such that the nodes of the root the mutations are applied to are kept for the concurrent Get and the
+// recovery. The last write of a key decides whether it is deleted, as a deleted node could be written again by a later
+// mutation
+type deferredDeleteKVStore struct {
+	KVStore
+	deleted map[string]bool
+}
+
+func (s *deferredDeleteKVStore) Put(key []byte, value []byte) error {
+	s.deleted[string(key)] = false
+	return s.KVStore.Put(key, value)
+}
+
+func (s *deferredDeleteKVStore) Delete(key []byte) error {
+	s.deleted[string(key)] = true
+	return nil
+}
+
+func (s *deferredDeleteKVStore) flush() error {
+	for key, deleted := range s.deleted {
+		if !deleted {
+			continue
+		}
+		if err := s.KVStore.Delete([]byte(key)); err != nil && errors.Cause(err) != ErrNotExist {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyWithWAL applies the mutations to the trie. With the write-ahead log enabled, the mutations and the root they
+// are applied to are logged before any node is written, and the log is removed once the new root is saved under the
+// root key, such that Start can recover from a crash in between
+func (tr *branchRootTrie) applyWithWAL(ops []txOp, apply func() error) error {
+	if !tr.wal {
+		return tr.applyDeferringDeletes(apply)
+	}
+	if err := tr.mutationStore.Put(tr.walKey(), encodeWAL(tr.rootHash, ops)); err != nil {
+		return errors.Wrap(err, "failed to write trie wal")
+	}
+	store := &deferredDeleteKVStore{KVStore: tr.mutationStore, deleted: map[string]bool{}}
+	tr.mutationStore = store
+	err := apply()
+	tr.mutationStore = store.KVStore
+	if err != nil {
+		// the root is not changed, the nodes written are unreachable
+		if delErr := tr.mutationStore.Delete(tr.walKey()); delErr != nil {
+			return errors.Wrapf(delErr, "failed to delete trie wal of mutations failed by %v", err)
+		}
+		return err
+	}
+	return tr.completeWAL(store)
+}
+
+// applyDeferringDeletes applies the mutations and deletes the replaced nodes once the new root is published, so that a
+// concurrent Get under the previous root either finds its nodes or sees the new root to search again under
+func (tr *branchRootTrie) applyDeferringDeletes(apply func() error) error {
+	store := &deferredDeleteKVStore{KVStore: tr.mutationStore, deleted: map[string]bool{}}
+	tr.mutationStore = store
+	err := apply()
+	tr.mutationStore = store.KVStore
+	if err != nil {
+		// the root is not changed, its nodes are kept
+		return err
+	}
+	return errors.Wrap(store.flush(), "failed to delete replaced trie nodes")
+}
+
+// completeWAL saves the root under the root key, deletes the nodes replaced by the mutations, and removes the
+// write-ahead log. The replaced nodes are leaked on a crash after the root is saved
+func (tr *branchRootTrie) completeWAL(store *deferredDeleteKVStore) error {
+	if tr.rootKey != "" {
+		if err := tr.mutationStore.Put([]byte(tr.rootKey), tr.rootHash); err != nil {
+			return errors.Wrap(err, "failed to save trie root")
+		}
+	}
+	if err := store.flush(); err != nil {
+		return errors.Wrap(err, "failed to delete replaced trie nodes")
+	}
+	return errors.Wrap(tr.mutationStore.Delete(tr.walKey()), "failed to delete trie wal")
+}
+
+// recoverWAL replays the write-ahead log left by a crash. The mutations are applied again if the current root is the
+// one they were applied to, otherwise the new root has been saved and the log is just removed. The mutations either
+// are applied all together or none of them is, as they were before the crash
+func (tr *branchRootTrie) recoverWAL() error {
+	data, err := tr.kvStore.Get(tr.walKey())
+	switch errors.Cause(err) {
+	case nil:
+	case ErrNotExist:
+		return nil
+	default:
+		return errors.Wrap(err, "failed to read trie wal")
+	}
+	rootHash, ops, err := decodeWAL(data)
+	if err != nil {
+		return err
+	}
+	store := &deferredDeleteKVStore{KVStore: tr.mutationStore, deleted: map[string]bool{}}
+	if bytes.Equal(rootHash, tr.rootHash) {
+		tr.mutationStore = store
+		for _, op := range ops {
+			var newRoot *branchNode
+			if op.delete {
+				newRoot, err = tr.delete(op.key)
+			} else {
+				newRoot, err = tr.upsert(op.key, op.value, op.expiry)
+			}
+			if err != nil {
+				break
+			}
+			tr.root = newRoot
+		}
+		tr.mutationStore = store.KVStore
+		if err != nil {
+			// the mutations failed before the crash as well, the nodes written are unreachable
+			if err := tr.SetRootHash(rootHash); err != nil {
+				return errors.Wrap(err, "failed to restore trie root")
+			}
+			return errors.Wrap(tr.mutationStore.Delete(tr.walKey()), "failed to delete trie wal")
+		}
+		tr.resetRoot(tr.root)
+	}
+	return tr.completeWAL(store)
+}
+
+// encodeWAL encodes the root followed by the mutations, each of them made of a flag byte which is 1 for deletion,
+// then the key, the value and the expiry. The root, keys and values are prefixed by their uvarint lengths
+func encodeWAL(rootHash []byte, ops []txOp) []byte {
+	var (
+		buf bytes.Buffer
+		n   = make([]byte, binary.MaxVarintLen64)
+	)
+	writeBytes := func(b []byte) {
+		buf.Write(n[:binary.PutUvarint(n, uint64(len(b)))])
+		buf.Write(b)
+	}
+	writeBytes(rootHash)
+	for _, op := range ops {
+		if op.delete {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		writeBytes(op.key)
+		writeBytes(op.value)
+		buf.Write(n[:binary.PutUvarint(n, op.expiry)])
+	}
+	return buf.Bytes()
+}
+
+func decodeWAL(data []byte) ([]byte, []txOp, error) {
+	r := bytes.NewReader(data)
+	readBytes := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := make([]byte, size)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	}
+	rootHash, err := readBytes()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid trie wal")
+	}
+	var ops []txOp
+	for r.Len() > 0 {
+		var op txOp
+		flag, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid trie wal")
+		}
+		op.delete = flag == 1
+		if op.key, err = readBytes(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid trie wal")
+		}
+		if op.value, err = readBytes(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid trie wal")
+		}
+		if op.expiry, err = binary.ReadUvarint(r); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid trie wal")
+		}
+		ops = append(ops, op)
+	}
+	return rootHash, ops, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2020 IoTeX Foundation
+// This is an alpha (internal) release and is not suitable for production. This source code is provided 'as is' and no
+// warranties are given as to title or non-infringement, merchantability or fitness for purpose and, to the extent
+// permitted by law, all liability for your use of the code is disclaimed. This source code is governed by Apache
+// License 2.0 that can be found in the LICENSE file.
+
+package trie
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// crashKVStore panics right after the write which crash returns true for, like a process crashing in the middle of
+// writing the nodes
+type crashKVStore struct {
+	KVStore
+	writes int
+	crash  func(writes int, key []byte) bool
+}
+
+func (s *crashKVStore) Put(key []byte, value []byte) error {
+	if err := s.KVStore.Put(key, value); err != nil {
+		return err
+	}
+	s.afterWrite(key)
+	return nil
+}
+
+func (s *crashKVStore) Delete(key []byte) error {
+	if err := s.KVStore.Delete(key); err != nil {
+		return err
+	}
+	s.afterWrite(key)
+	return nil
+}
+
+func (s *crashKVStore) afterWrite(key []byte) {
+	s.writes++
+	if s.crash(s.writes, key) {
+		panic("crash")
+	}
+}
+
+func TestWAL(t *testing.T) {
+	require := require.New(t)
+
+	newTrie := func(kvStore KVStore) Trie {
+		tr, err := NewTrie(KVStoreOption(kvStore), KeyLengthOption(8), RootKeyOption("root"), WALOption())
+		require.NoError(err)
+		require.NoError(tr.Start(context.Background()))
+		return tr
+	}
+	mutate := func(tr Trie) error {
+		tx, err := tr.Begin()
+		require.NoError(err)
+		require.NoError(tx.Upsert(fox, testV[5]))
+		require.NoError(tx.Delete(dog))
+		return tx.Commit()
+	}
+
+	// the expected root
+	expected, err := NewTrie(KeyLengthOption(8))
+	require.NoError(err)
+	require.NoError(expected.Start(context.Background()))
+	require.NoError(expected.Upsert(cat, testV[2]))
+	require.NoError(expected.Upsert(dog, testV[3]))
+	baseRoot := expected.RootHash()
+	require.NoError(mutate(expected))
+
+	for _, test := range []struct {
+		name  string
+		crash func(int, []byte) bool
+	}{
+		{"after writing the wal", func(writes int, _ []byte) bool { return writes == 1 }},
+		{"in the middle of writing the nodes", func(writes int, _ []byte) bool { return writes == 3 }},
+		{"after saving the root", func(_ int, key []byte) bool { return string(key) == "root" }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			kvStore := newInMemKVStore()
+			tr := newTrie(kvStore)
+			require.NoError(tr.Upsert(cat, testV[2]))
+			require.NoError(tr.Upsert(dog, testV[3]))
+			require.Equal(baseRoot, tr.RootHash())
+
+			// restart on the store which crashes
+			tr = newTrie(&crashKVStore{KVStore: kvStore, crash: test.crash})
+			require.Panics(func() { _ = mutate(tr) })
+			_, err := kvStore.Get([]byte(_walKeyPrefix + "root"))
+			require.NoError(err)
+
+			// the wal is replayed on restart
+			tr = newTrie(kvStore)
+			require.Equal(expected.RootHash(), tr.RootHash())
+			v, err := tr.Get(fox)
+			require.NoError(err)
+			require.Equal(testV[5], v)
+			_, err = tr.Get(dog)
+			require.Equal(ErrNotExist, errors.Cause(err))
+			_, err = kvStore.Get([]byte(_walKeyPrefix + "root"))
+			require.Equal(ErrNotExist, errors.Cause(err))
+			root, err := kvStore.Get([]byte("root"))
+			require.NoError(err)
+			require.Equal(expected.RootHash(), root)
+		})
+	}
+
+	// a failed mutation leaves no wal and does not change the root
+	kvStore := newInMemKVStore()
+	tr := newTrie(kvStore)
+	require.NoError(tr.Upsert(cat, testV[2]))
+	root := tr.RootHash()
+	require.Equal(ErrNotExist, errors.Cause(tr.Delete(dog)))
+	require.Equal(root, tr.RootHash())
+	_, err = kvStore.Get([]byte(_walKeyPrefix + "root"))
+	require.Equal(ErrNotExist, errors.Cause(err))
+	tr = newTrie(kvStore)
+	require.Equal(root, tr.RootHash())
+}
@@ -7,7 +7,9 @@
 package state
 
 import (
+	"bytes"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
@@ -82,6 +84,40 @@ func (l CandidateList) Less(i, j int) bool {
 	return strings.Compare(l[i].Address, l[j].Address) == 1
 }
 
+// SortByVotes sorts the candidates by votes in descending order. Candidates with exactly equal votes are ordered by
+// their address bytes in ascending order, so the relative order of any two candidates only depends on the two of them,
+// and adding or removing an equal-vote candidate never reshuffles the others. Candidates whose address cannot be
+// decoded are ordered after valid ones by their address string.
+func (l CandidateList) SortByVotes() {
+	keys := make(map[string][]byte, len(l))
+	for _, c := range l {
+		if _, ok := keys[c.Address]; ok {
+			continue
+		}
+		if addr, err := address.FromString(c.Address); err == nil {
+			keys[c.Address] = addr.Bytes()
+		} else {
+			keys[c.Address] = nil
+		}
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		if res := l[i].Votes.Cmp(l[j].Votes); res != 0 {
+			return res == 1
+		}
+		ki, kj := keys[l[i].Address], keys[l[j].Address]
+		switch {
+		case ki != nil && kj != nil:
+			return bytes.Compare(ki, kj) < 0
+		case ki != nil:
+			return true
+		case kj != nil:
+			return false
+		default:
+			return l[i].Address < l[j].Address
+		}
+	})
+}
+
 // Serialize serializes a list of Candidates to bytes
 func (l *CandidateList) Serialize() ([]byte, error) {
 	return proto.Marshal(l.Proto())
@@ -156,6 +192,18 @@ func MapToCandidates(candidateMap CandidateMap) (CandidateList, error) {
 	return candidates, nil
 }
 
+// CandidatesToSortedSlice converts a map of cachedCandidates to candidate list sorted by votes, and by address for
+// equal votes, so that the order does not depend on the iteration of the map. It must be used wherever the list is
+// serialized or hashed
+func CandidatesToSortedSlice(candidateMap CandidateMap) (CandidateList, error) {
+	candidates, err := MapToCandidates(candidateMap)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(candidates)
+	return candidates, nil
+}
+
 // CandidatesToMap converts a candidate list to map of cachedCandidates
 func CandidatesToMap(candidates CandidateList) (CandidateMap, error) {
 	candidateMap := make(CandidateMap)
@@ -7,7 +7,9 @@
 package state
 
 import (
+	"bytes"
 	"math/big"
+	"math/rand"
 	"sort"
 	"testing"
 
@@ -85,6 +87,109 @@ func TestCandidateListSerializeAndDeserialize(t *testing.T) {
 	}
 }
 
+func TestCandidateListSortByVotes(t *testing.T) {
+	r := require.New(t)
+
+	addresses := func(l CandidateList) []string {
+		res := make([]string, 0, len(l))
+		for _, c := range l {
+			res = append(res, c.Address)
+		}
+		return res
+	}
+	var list CandidateList
+	for i := 0; i < 8; i++ {
+		list = append(list, &Candidate{
+			Address: identityset.Address(i).String(),
+			Votes:   big.NewInt(10),
+		})
+	}
+	list = append(list, &Candidate{
+		Address: identityset.Address(20).String(),
+		Votes:   big.NewInt(20),
+	})
+
+	expected := make(CandidateList, len(list))
+	copy(expected, list)
+	expected.SortByVotes()
+	r.Equal(identityset.Address(20).String(), expected[0].Address)
+	for i := 2; i < len(expected); i++ {
+		prev, err := address.FromString(expected[i-1].Address)
+		r.NoError(err)
+		curr, err := address.FromString(expected[i].Address)
+		r.NoError(err)
+		r.True(bytes.Compare(prev.Bytes(), curr.Bytes()) < 0)
+	}
+
+	// repeated selections over shuffled input give the same order
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := make(CandidateList, len(list))
+		copy(shuffled, list)
+		rnd.Shuffle(len(shuffled), shuffled.Swap)
+		shuffled.SortByVotes()
+		r.Equal(addresses(expected), addresses(shuffled))
+	}
+
+	// removing an equal-vote candidate keeps the relative order of the others
+	removed := expected[3].Address
+	var perturbed CandidateList
+	for _, c := range list {
+		if c.Address != removed {
+			perturbed = append(perturbed, c)
+		}
+	}
+	perturbed.SortByVotes()
+	var remaining []string
+	for _, addr := range addresses(expected) {
+		if addr != removed {
+			remaining = append(remaining, addr)
+		}
+	}
+	r.Equal(remaining, addresses(perturbed))
+
+	// adding it back restores the original order
+	perturbed = append(perturbed, expected[3])
+	perturbed.SortByVotes()
+	r.Equal(addresses(expected), addresses(perturbed))
+}
+
+func TestCandidatesToSortedSlice(t *testing.T) {
+	r := require.New(t)
+
+	// candidates 2 and 3 have equal votes
+	list := CandidateList{}
+	for i, votes := range []int64{10, 20, 20, 30, 0} {
+		list = append(list, &Candidate{
+			Address:       identityset.Address(i).String(),
+			Votes:         big.NewInt(votes),
+			RewardAddress: identityset.Address(i + 10).String(),
+		})
+	}
+	var expected []byte
+	for i := 0; i < 10; i++ {
+		// build the map in a different order each time
+		shuffled := make(CandidateList, len(list))
+		copy(shuffled, list)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		m, err := CandidatesToMap(shuffled)
+		r.NoError(err)
+		sorted, err := CandidatesToSortedSlice(m)
+		r.NoError(err)
+		r.Equal(len(list), len(sorted))
+		ser, err := sorted.Serialize()
+		r.NoError(err)
+		if expected == nil {
+			expected = ser
+			continue
+		}
+		r.Equal(expected, ser)
+	}
+	sorted, err := CandidatesToSortedSlice(nil)
+	r.NoError(err)
+	r.Empty(sorted)
+}
+
 func TestCandidate(t *testing.T) {
 	require := require.New(t)
 
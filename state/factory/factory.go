@@ -263,6 +263,9 @@ func (sf *factory) newWorkingSet(ctx context.Context, height uint64) (*workingSe
 		getStateFunc: func(ns string, key []byte, s interface{}) error {
 			return readState(tlt, ns, key, s)
 		},
+		existsFunc: func(ns string, key []byte) (bool, error) {
+			return stateExists(tlt, ns, key)
+		},
 		putStateFunc: func(ns string, key []byte, s interface{}) error {
 			ss, err := state.Serialize(s)
 			if err != nil {
@@ -504,6 +507,25 @@ func (sf *factory) State(s interface{}, opts ...protocol.StateOption) (uint64, e
 	return sf.currentChainHeight, state.Deserialize(s, value)
 }
 
+// Exists returns whether a confirmed state exists in the state factory
+func (sf *factory) Exists(opts ...protocol.StateOption) (bool, error) {
+	sf.mutex.RLock()
+	defer sf.mutex.RUnlock()
+	cfg, err := processOptions(opts...)
+	if err != nil {
+		return false, err
+	}
+	if cfg.AtHeight {
+		if cfg.Height > sf.currentChainHeight {
+			return false, errors.Errorf("query height %d is higher than tip height %d", cfg.Height, sf.currentChainHeight)
+		}
+		if cfg.Height != sf.currentChainHeight {
+			return sf.existsAtHeight(cfg.Height, cfg.Namespace, cfg.Key)
+		}
+	}
+	return keyExists(sf.dao, cfg.Namespace, cfg.Key)
+}
+
 // State returns a set states in the state factory
 func (sf *factory) States(opts ...protocol.StateOption) (uint64, state.Iterator, error) {
 	sf.mutex.RLock()
@@ -565,6 +587,18 @@ func readState(tlt *trie.TwoLayerTrie, ns string, key []byte, s interface{}) err
 	return state.Deserialize(s, data)
 }
 
+func stateExists(tlt *trie.TwoLayerTrie, ns string, key []byte) (bool, error) {
+	_, err := tlt.Get(namespaceKey(ns), key)
+	switch errors.Cause(err) {
+	case nil:
+		return true, nil
+	case trie.ErrNotExist:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 func (sf *factory) stateAtHeight(height uint64, ns string, key []byte, s interface{}) error {
 	if !sf.saveHistory {
 		return ErrNoArchiveData
@@ -581,6 +615,22 @@ func (sf *factory) stateAtHeight(height uint64, ns string, key []byte, s interfa
 	return readState(tlt, ns, key, s)
 }
 
+func (sf *factory) existsAtHeight(height uint64, ns string, key []byte) (bool, error) {
+	if !sf.saveHistory {
+		return false, ErrNoArchiveData
+	}
+	tlt, err := newTwoLayerTrie(ArchiveTrieNamespace, sf.dao, fmt.Sprintf("%s-%d", ArchiveTrieRootKey, height), false)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to generate trie for %d", height)
+	}
+	if err := tlt.Start(context.Background()); err != nil {
+		return false, err
+	}
+	defer tlt.Stop(context.Background())
+
+	return stateExists(tlt, ns, key)
+}
+
 func (sf *factory) createGenesisStates(ctx context.Context) error {
 	ws, err := sf.newWorkingSet(ctx, 0)
 	if err != nil {
@@ -32,6 +32,7 @@ import (
 	"github.com/iotexproject/iotex-core/action/protocol/poll"
 	"github.com/iotexproject/iotex-core/action/protocol/rewarding"
 	"github.com/iotexproject/iotex-core/action/protocol/rolldpos"
+	"github.com/iotexproject/iotex-core/action/protocol/staking"
 	"github.com/iotexproject/iotex-core/action/protocol/vote/candidatesutil"
 	"github.com/iotexproject/iotex-core/blockchain/block"
 	"github.com/iotexproject/iotex-core/blockchain/genesis"
@@ -1102,6 +1103,249 @@ func TestDeleteAndPutSameKey(t *testing.T) {
 	})
 }
 
+func TestExists(t *testing.T) {
+	testExists := func(t *testing.T, ws *workingSet) {
+		key := hash.Hash160b([]byte("test"))
+		exists, err := ws.Exists(protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		require.False(t, exists)
+		_, err = ws.PutState(state.Account{Nonce: 1}, protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		exists, err = ws.Exists(protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		require.True(t, exists)
+		exists, err = ws.Exists(protocol.NamespaceOption("other"), protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		require.False(t, exists)
+		_, err = ws.DelState(protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		exists, err = ws.Exists(protocol.LegacyKeyOption(key))
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+	ctx := protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+		Genesis:  config.Default.Genesis,
+		Registry: protocol.NewRegistry(),
+	})
+	t.Run("workingSet", func(t *testing.T) {
+		sf, err := NewFactory(config.Default, InMemTrieOption())
+		require.NoError(t, err)
+		ws, err := sf.(workingSetCreator).newWorkingSet(ctx, 0)
+		require.NoError(t, err)
+		testExists(t, ws)
+		exists, err := sf.Exists(protocol.LegacyKeyOption(hash.Hash160b([]byte("test"))))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+	t.Run("stateTx", func(t *testing.T) {
+		sdb, err := NewStateDB(config.Default, InMemStateDBOption())
+		require.NoError(t, err)
+		ws, err := sdb.(workingSetCreator).newWorkingSet(ctx, 0)
+		require.NoError(t, err)
+		testExists(t, ws)
+		exists, err := sdb.Exists(protocol.LegacyKeyOption(hash.Hash160b([]byte("test"))))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
+
+// stakingGenesisConfig returns a config whose genesis registers a candidate of owner with a self-stake bucket 0, and
+// creates bucket 1 of voter staked to it
+func stakingGenesisConfig(owner, voter address.Address) config.Config {
+	cfg := config.Default
+	cfg.Genesis.Staking.BootstrapCandidates = []genesis.BootstrapCandidate{
+		{
+			OwnerAddress:      owner.String(),
+			OperatorAddress:   identityset.Address(3).String(),
+			RewardAddress:     identityset.Address(4).String(),
+			Name:              "cand1",
+			SelfStakingTokens: "1200000000000000000000000",
+		},
+	}
+	cfg.Genesis.Staking.GenesisBuckets = []genesis.GenesisBucket{
+		{
+			OwnerAddress:  voter.String(),
+			CandidateName: "cand1",
+			Amount:        "100000000000000000000",
+			Duration:      91,
+			AutoStake:     true,
+		},
+	}
+	return cfg
+}
+
+// startStakingFactory starts an in-memory factory with the staking protocol of the options registered, an epoch lasts
+// 2 blocks
+func startStakingFactory(t *testing.T, cfg config.Config, opts ...staking.Option) (Factory, *staking.Protocol, context.Context) {
+	require := require.New(t)
+	sf, err := NewFactory(cfg, InMemTrieOption())
+	require.NoError(err)
+
+	registry := protocol.NewRegistry()
+	acc := account.NewProtocol(rewarding.DepositGas)
+	require.NoError(acc.Register(registry))
+	rp := rolldpos.NewProtocol(2, 2, 1)
+	require.NoError(rp.Register(registry))
+	sp, err := staking.NewProtocol(rewarding.DepositGas, sf, cfg.Genesis.Staking, opts...)
+	require.NoError(err)
+	require.NoError(sp.Register(registry))
+	ctx := protocol.WithBlockCtx(
+		protocol.WithBlockchainCtx(context.Background(), protocol.BlockchainCtx{
+			Genesis:  cfg.Genesis,
+			Registry: registry,
+		}),
+		protocol.BlockCtx{},
+	)
+	require.NoError(sf.Start(ctx))
+	return sf, sp, ctx
+}
+
+// runStakingBlock runs an empty block of the height on a working set of the factory, after prepare changes the states
+// of the working set if it is not nil. The staking states are only read by key in the working set, which does not
+// support iterating a namespace
+func runStakingBlock(t *testing.T, sf Factory, ctx context.Context, height uint64, prepare func(*workingSet)) *workingSet {
+	require := require.New(t)
+	ws, err := sf.(workingSetCreator).newWorkingSet(ctx, height)
+	require.NoError(err)
+	if prepare != nil {
+		prepare(ws)
+	}
+	g := protocol.MustGetBlockchainCtx(ctx).Genesis
+	blkCtx := protocol.WithBlockCtx(ctx, protocol.BlockCtx{
+		BlockHeight:    height,
+		BlockTimeStamp: time.Unix(g.Timestamp, 0).Add(time.Duration(height) * g.BlockInterval),
+		Producer:       identityset.Address(27),
+		GasLimit:       g.BlockGasLimit,
+	})
+	_, ws, err = runActions(blkCtx, ws, nil)
+	require.NoError(err)
+	return ws
+}
+
+func TestStakingGenesisStatesAtGreenland(t *testing.T) {
+	require := require.New(t)
+
+	owner, voter := identityset.Address(1), identityset.Address(2)
+	sf, sp, ctx := startStakingFactory(t, stakingGenesisConfig(owner, voter), staking.GreenlandHeightOption(0))
+	defer func() {
+		require.NoError(sf.Stop(ctx))
+	}()
+
+	// the genesis buckets are indexed by voter and candidate when Greenland starts at the genesis block
+	indices, err := sp.BucketsByVoterAndCandidate(sf, voter, owner)
+	require.NoError(err)
+	require.Equal([]uint64{1}, indices)
+}
+
+func TestStakingCreatePreStates(t *testing.T) {
+	require := require.New(t)
+
+	owner, voter := identityset.Address(1), identityset.Address(2)
+	sf, sp, ctx := startStakingFactory(t, stakingGenesisConfig(owner, voter), staking.GreenlandHeightOption(2))
+	defer func() {
+		require.NoError(sf.Stop(ctx))
+	}()
+	candidateVotes := func(ws *workingSet) *big.Int {
+		c := &staking.Candidate{}
+		_, err := ws.State(c, protocol.NamespaceOption(staking.CandidateNameSpace), protocol.KeyOption(owner.Bytes()))
+		require.NoError(err)
+		return c.Votes
+	}
+
+	ws := runStakingBlock(t, sf, ctx, 1, nil)
+	before := candidateVotes(ws)
+	require.NoError(ws.Commit())
+
+	// the votes are recalculated from the buckets at Greenland
+	ws = runStakingBlock(t, sf, ctx, 2, nil)
+	after := candidateVotes(ws)
+	staked, _ := new(big.Int).SetString("1200100000000000000000000", 10)
+	require.True(before.Cmp(staked) > 0)
+	require.True(after.Cmp(staked) > 0)
+
+	// the buckets created before Greenland are indexed by voter and candidate at it
+	indices, err := sp.BucketsByVoterAndCandidate(ws, voter, owner)
+	require.NoError(err)
+	require.Equal([]uint64{1}, indices)
+	indices, err = sp.BucketsByVoterAndCandidate(ws, owner, owner)
+	require.NoError(err)
+	require.Equal([]uint64{0}, indices)
+}
+
+func TestStakingOrphanMigration(t *testing.T) {
+	require := require.New(t)
+
+	owner, voter, defaultOwner := identityset.Address(1), identityset.Address(2), identityset.Address(5)
+	cfg := stakingGenesisConfig(owner, voter)
+	cfg.Genesis.Staking.BootstrapCandidates = append(cfg.Genesis.Staking.BootstrapCandidates, genesis.BootstrapCandidate{
+		OwnerAddress:      defaultOwner.String(),
+		OperatorAddress:   identityset.Address(6).String(),
+		RewardAddress:     identityset.Address(7).String(),
+		Name:              "cand2",
+		SelfStakingTokens: "1200000000000000000000000",
+	})
+	sf, sp, ctx := startStakingFactory(t, cfg, staking.OrphanMigrationOption(defaultOwner))
+	defer func() {
+		require.NoError(sf.Stop(ctx))
+	}()
+
+	// the buckets of the removed candidate are migrated at the start of epoch 1
+	ws := runStakingBlock(t, sf, ctx, 1, func(ws *workingSet) {
+		_, err := ws.DelState(protocol.NamespaceOption(staking.CandidateNameSpace), protocol.KeyOption(owner.Bytes()))
+		require.NoError(err)
+		orphaned, err := sp.OrphanedBuckets(ws)
+		require.NoError(err)
+		require.Len(orphaned, 2)
+	})
+	orphaned, err := sp.OrphanedBuckets(ws)
+	require.NoError(err)
+	require.Empty(orphaned)
+}
+
+func TestStakingEpochSnapshot(t *testing.T) {
+	require := require.New(t)
+
+	owner, voter := identityset.Address(1), identityset.Address(2)
+	sf, sp, ctx := startStakingFactory(t, stakingGenesisConfig(owner, voter), staking.WeightSnapshotOption())
+	defer func() {
+		require.NoError(sf.Stop(ctx))
+	}()
+
+	// the snapshots are taken at the start of epoch 1
+	ws := runStakingBlock(t, sf, ctx, 1, nil)
+	c := &staking.Candidate{}
+	_, err := ws.State(c, protocol.NamespaceOption(staking.CandidateNameSpace), protocol.KeyOption(owner.Bytes()))
+	require.NoError(err)
+	weights, err := sp.WeightSnapshotByEpoch(ws, 1)
+	require.NoError(err)
+	require.Len(weights, 1)
+	require.Equal(c.Votes.String(), weights[owner.String()].String())
+	stats, err := sp.NetworkStats(ws, 1)
+	require.NoError(err)
+	require.Equal(uint64(2), stats.TotalBuckets)
+	require.Equal("1200100000000000000000000", stats.TotalStake.String())
+	require.Equal(uint64(1), stats.ActiveCandidates)
+}
+
+func TestStakingEpochAudit(t *testing.T) {
+	require := require.New(t)
+
+	var reports []*staking.AuditReport
+	sf, _, ctx := startStakingFactory(t, stakingGenesisConfig(identityset.Address(1), identityset.Address(2)),
+		staking.AuditOption(func(epoch uint64, report *staking.AuditReport) {
+			reports = append(reports, report)
+		}))
+	defer func() {
+		require.NoError(sf.Stop(ctx))
+	}()
+
+	// the audit runs at the start of epoch 1, the auditor only receives the report if the audit succeeds
+	runStakingBlock(t, sf, ctx, 1, nil)
+	require.Len(reports, 1)
+	require.True(reports[0].IsClean())
+	require.Equal("1200100000000000000000000", reports[0].TotalStake.String())
+}
+
 func BenchmarkInMemRunAction(b *testing.B) {
 	cfg := config.Default
 	sf, err := NewFactory(cfg, InMemTrieOption())
@@ -179,6 +179,9 @@ func (sdb *stateDB) newWorkingSet(ctx context.Context, height uint64) (*workingS
 			}
 			return state.Deserialize(s, data)
 		},
+		existsFunc: func(ns string, key []byte) (bool, error) {
+			return keyExists(flusher.KVStoreWithBuffer(), ns, key)
+		},
 		putStateFunc: func(ns string, key []byte, s interface{}) error {
 			ss, err := state.Serialize(s)
 			if err != nil {
@@ -338,6 +341,21 @@ func (sdb *stateDB) State(s interface{}, opts ...protocol.StateOption) (uint64,
 	return sdb.currentChainHeight, sdb.state(cfg.Namespace, cfg.Key, s)
 }
 
+// Exists returns whether a confirmed state exists in the state factory
+func (sdb *stateDB) Exists(opts ...protocol.StateOption) (bool, error) {
+	sdb.mutex.RLock()
+	defer sdb.mutex.RUnlock()
+	cfg, err := processOptions(opts...)
+	if err != nil {
+		return false, err
+	}
+	if cfg.AtHeight {
+		return false, ErrNotSupported
+	}
+
+	return keyExists(sdb.dao, cfg.Namespace, cfg.Key)
+}
+
 // State returns a set of states in the state factory
 func (sdb *stateDB) States(opts ...protocol.StateOption) (uint64, state.Iterator, error) {
 	sdb.mutex.RLock()
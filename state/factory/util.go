@@ -7,6 +7,7 @@
 package factory
 
 import (
+	"bytes"
 	"context"
 	"sort"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/iotexproject/iotex-core/action/protocol/execution/evm"
 	"github.com/iotexproject/iotex-core/actpool/actioniterator"
 	"github.com/iotexproject/iotex-core/blockchain/block"
+	"github.com/iotexproject/iotex-core/db"
 	"github.com/iotexproject/iotex-core/pkg/log"
 )
 
@@ -36,9 +38,31 @@ func processOptions(opts ...protocol.StateOption) (*protocol.StateConfig, error)
 	if len(cfg.Namespace) == 0 {
 		cfg.Namespace = AccountKVNamespace
 	}
+	if len(cfg.LastKey) > 0 {
+		// resume from just after the last key
+		cond, lastKey := cfg.Cond, cfg.LastKey
+		cfg.Cond = func(k, v []byte) bool {
+			return bytes.Compare(k, lastKey) == 1 && (cond == nil || cond(k, v))
+		}
+		if bytes.Compare(cfg.MinKey, lastKey) == -1 {
+			cfg.MinKey = lastKey
+		}
+	}
 	return cfg, nil
 }
 
+func keyExists(kv db.KVStore, ns string, key []byte) (bool, error) {
+	_, err := kv.Get(ns, key)
+	switch errors.Cause(err) {
+	case nil:
+		return true, nil
+	case db.ErrNotExist:
+		return false, nil
+	default:
+		return false, errors.Wrapf(err, "error when checking the existence of %x", key)
+	}
+}
+
 // createGenesisStates initialize the genesis states
 func createGenesisStates(ctx context.Context, ws *workingSet) error {
 	if bcCtx, ok := protocol.GetBlockchainCtx(ctx); ok {
@@ -42,11 +42,13 @@ type (
 		dbFunc       func() db.KVStore
 		delStateFunc func(string, []byte) error
 		digestFunc   func() hash.Hash256
+		existsFunc   func(string, []byte) (bool, error)
 		finalizeFunc func(uint64) error
 		getStateFunc func(string, []byte, interface{}) error
 		putStateFunc func(string, []byte, interface{}) error
 		revertFunc   func(int) error
 		snapshotFunc func() int
+		listeners    []protocol.SnapshotListener
 	}
 
 	workingSetCreator interface {
@@ -177,11 +179,33 @@ func (ws *workingSet) Finalize() error {
 }
 
 func (ws *workingSet) Snapshot() int {
-	return ws.snapshotFunc()
+	s := ws.snapshotFunc()
+	for _, l := range ws.listeners {
+		l.Snapshot(s)
+	}
+	return s
 }
 
 func (ws *workingSet) Revert(snapshot int) error {
-	return ws.revertFunc(snapshot)
+	if err := ws.revertFunc(snapshot); err != nil {
+		return err
+	}
+	for _, l := range ws.listeners {
+		if err := l.Revert(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe adds a listener to be notified on snapshot and revert
+func (ws *workingSet) Subscribe(l protocol.SnapshotListener) {
+	for _, listener := range ws.listeners {
+		if listener == l {
+			return
+		}
+	}
+	ws.listeners = append(ws.listeners, l)
 }
 
 // Commit persists all changes in RunActions() into the DB
@@ -219,6 +243,16 @@ func (ws *workingSet) States(opts ...protocol.StateOption) (uint64, state.Iterat
 	return 0, nil, ErrNotSupported
 }
 
+// Exists checks whether a state exists in DB
+func (ws *workingSet) Exists(opts ...protocol.StateOption) (bool, error) {
+	stateDBMtc.WithLabelValues("exists").Inc()
+	ns, key, err := ws.processNonArchiveOptions(opts...)
+	if err != nil {
+		return false, err
+	}
+	return ws.existsFunc(ns, key)
+}
+
 // PutState puts a state into DB
 func (ws *workingSet) PutState(s interface{}, opts ...protocol.StateOption) (uint64, error) {
 	stateDBMtc.WithLabelValues("put").Inc()
@@ -19,6 +19,9 @@ var (
 
 	// ErrStateNotExist is the error that the state does not exist
 	ErrStateNotExist = errors.New("state does not exist")
+
+	// ErrStateAlreadyExists is the error that the state already exists
+	ErrStateAlreadyExists = errors.New("state already exists")
 )
 
 // State is the interface, which defines the common methods for state struct to be handled by state factory
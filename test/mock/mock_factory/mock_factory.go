@@ -122,6 +122,25 @@ func (mr *MockFactoryMockRecorder) States(arg0 ...interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "States", reflect.TypeOf((*MockFactory)(nil).States), arg0...)
 }
 
+// Exists mocks base method
+func (m *MockFactory) Exists(arg0 ...protocol.StateOption) (bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range arg0 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Exists", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists
+func (mr *MockFactoryMockRecorder) Exists(arg0 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockFactory)(nil).Exists), arg0...)
+}
+
 // Validate mocks base method
 func (m *MockFactory) Validate(arg0 context.Context, arg1 *block.Block) error {
 	m.ctrl.T.Helper()
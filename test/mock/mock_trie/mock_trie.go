@@ -8,6 +8,7 @@ import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
 	trie "github.com/iotexproject/iotex-core/db/trie"
+	io "io"
 	reflect "reflect"
 )
 
@@ -76,6 +77,20 @@ func (mr *MockTrieMockRecorder) Upsert(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockTrie)(nil).Upsert), arg0, arg1)
 }
 
+// UpsertWithExpiry mocks base method
+func (m *MockTrie) UpsertWithExpiry(arg0, arg1 []byte, arg2 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertWithExpiry", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertWithExpiry indicates an expected call of UpsertWithExpiry
+func (mr *MockTrieMockRecorder) UpsertWithExpiry(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertWithExpiry", reflect.TypeOf((*MockTrie)(nil).UpsertWithExpiry), arg0, arg1, arg2)
+}
+
 // Get mocks base method
 func (m *MockTrie) Get(arg0 []byte) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -105,6 +120,65 @@ func (mr *MockTrieMockRecorder) Delete(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTrie)(nil).Delete), arg0)
 }
 
+// DeleteIfExists mocks base method
+func (m *MockTrie) DeleteIfExists(arg0 []byte) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIfExists", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteIfExists indicates an expected call of DeleteIfExists
+func (mr *MockTrieMockRecorder) DeleteIfExists(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIfExists", reflect.TypeOf((*MockTrie)(nil).DeleteIfExists), arg0)
+}
+
+// Preload mocks base method
+func (m *MockTrie) Preload(arg0 [][]byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Preload", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Preload indicates an expected call of Preload
+func (mr *MockTrieMockRecorder) Preload(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Preload", reflect.TypeOf((*MockTrie)(nil).Preload), arg0)
+}
+
+// SweepExpired mocks base method
+func (m *MockTrie) SweepExpired(arg0 uint64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SweepExpired", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SweepExpired indicates an expected call of SweepExpired
+func (mr *MockTrieMockRecorder) SweepExpired(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SweepExpired", reflect.TypeOf((*MockTrie)(nil).SweepExpired), arg0)
+}
+
+// Begin mocks base method
+func (m *MockTrie) Begin() (trie.TrieTx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin")
+	ret0, _ := ret[0].(trie.TrieTx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin
+func (mr *MockTrieMockRecorder) Begin() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockTrie)(nil).Begin))
+}
+
 // RootHash mocks base method
 func (m *MockTrie) RootHash() []byte {
 	m.ctrl.T.Helper()
@@ -133,6 +207,49 @@ func (mr *MockTrieMockRecorder) SetRootHash(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRootHash", reflect.TypeOf((*MockTrie)(nil).SetRootHash), arg0)
 }
 
+// Checkpoint mocks base method
+func (m *MockTrie) Checkpoint(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checkpoint", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Checkpoint indicates an expected call of Checkpoint
+func (mr *MockTrieMockRecorder) Checkpoint(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkpoint", reflect.TypeOf((*MockTrie)(nil).Checkpoint), arg0)
+}
+
+// LoadCheckpoint mocks base method
+func (m *MockTrie) LoadCheckpoint(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadCheckpoint", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LoadCheckpoint indicates an expected call of LoadCheckpoint
+func (mr *MockTrieMockRecorder) LoadCheckpoint(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadCheckpoint", reflect.TypeOf((*MockTrie)(nil).LoadCheckpoint), arg0)
+}
+
+// ListCheckpoints mocks base method
+func (m *MockTrie) ListCheckpoints() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCheckpoints")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCheckpoints indicates an expected call of ListCheckpoints
+func (mr *MockTrieMockRecorder) ListCheckpoints() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCheckpoints", reflect.TypeOf((*MockTrie)(nil).ListCheckpoints))
+}
+
 // IsEmpty mocks base method
 func (m *MockTrie) IsEmpty() bool {
 	m.ctrl.T.Helper()
@@ -161,6 +278,93 @@ func (mr *MockTrieMockRecorder) DB() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DB", reflect.TypeOf((*MockTrie)(nil).DB))
 }
 
+// KeyLength mocks base method
+func (m *MockTrie) KeyLength() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeyLength")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// KeyLength indicates an expected call of KeyLength
+func (mr *MockTrieMockRecorder) KeyLength() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeyLength", reflect.TypeOf((*MockTrie)(nil).KeyLength))
+}
+
+// HashFuncName mocks base method
+func (m *MockTrie) HashFuncName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashFuncName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// HashFuncName indicates an expected call of HashFuncName
+func (mr *MockTrieMockRecorder) HashFuncName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashFuncName", reflect.TypeOf((*MockTrie)(nil).HashFuncName))
+}
+
+// EstimatedMemoryBytes mocks base method
+func (m *MockTrie) EstimatedMemoryBytes() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimatedMemoryBytes")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// EstimatedMemoryBytes indicates an expected call of EstimatedMemoryBytes
+func (mr *MockTrieMockRecorder) EstimatedMemoryBytes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimatedMemoryBytes", reflect.TypeOf((*MockTrie)(nil).EstimatedMemoryBytes))
+}
+
+// SharedNodeCount mocks base method
+func (m *MockTrie) SharedNodeCount(rootA []byte, rootB []byte) (int, int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SharedNodeCount", rootA, rootB)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(int)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// SharedNodeCount indicates an expected call of SharedNodeCount
+func (mr *MockTrieMockRecorder) SharedNodeCount(rootA, rootB interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SharedNodeCount", reflect.TypeOf((*MockTrie)(nil).SharedNodeCount), rootA, rootB)
+}
+
+// Export mocks base method
+func (m *MockTrie) Export(arg0 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export
+func (mr *MockTrieMockRecorder) Export(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockTrie)(nil).Export), arg0)
+}
+
+// Import mocks base method
+func (m *MockTrie) Import(arg0 io.Reader) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Import indicates an expected call of Import
+func (mr *MockTrieMockRecorder) Import(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockTrie)(nil).Import), arg0)
+}
+
 // deleteNodeFromDB mocks base method
 func (m *MockTrie) deleteNodeFromDB(tn trie.Node) error {
 	m.ctrl.T.Helper()
@@ -232,6 +436,20 @@ func (mr *MockTrieMockRecorder) emptyRootHash() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "emptyRootHash", reflect.TypeOf((*MockTrie)(nil).emptyRootHash))
 }
 
+// nodeVersion mocks base method
+func (m *MockTrie) nodeVersion() byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "nodeVersion")
+	ret0, _ := ret[0].(byte)
+	return ret0
+}
+
+// nodeVersion indicates an expected call of nodeVersion
+func (mr *MockTrieMockRecorder) nodeVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "nodeVersion", reflect.TypeOf((*MockTrie)(nil).nodeVersion))
+}
+
 // nodeHash mocks base method
 func (m *MockTrie) nodeHash(tn trie.Node) []byte {
 	m.ctrl.T.Helper()